@@ -1,9 +1,12 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -12,13 +15,52 @@ import (
 	"github.com/jucaza1/hotel-reserv/types"
 )
 
+const (
+	// DefaultAccessTokenTTL is how long an access token is valid when
+	// ACCESS_TOKEN_TTL is not set.
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// DefaultRefreshTokenTTL is how long a refresh token is valid when
+	// REFRESH_TOKEN_TTL is not set.
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	// DefaultAccountLockout throttles the logins to an account.
+	DefaultAccountLockout = types.LockoutPolicy{
+		FreeFailures: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockAfter:    10,
+		LockDuration: 15 * time.Minute,
+		Window:       time.Hour,
+	}
+	// DefaultIPLockout throttles the logins from a client IP, to any
+	// account. It allows more failures, as many users may share an IP.
+	DefaultIPLockout = types.LockoutPolicy{
+		FreeFailures: 10,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockAfter:    50,
+		LockDuration: 15 * time.Minute,
+		Window:       time.Hour,
+	}
+)
+
 type AuthHandler struct {
-	userStore db.UserStore
+	loginLimiter
+	userStore  db.UserStore
+	tokenStore db.TokenStore
+	accessTTL  time.Duration
+	refreshTTL time.Duration
 }
 
-func NewAuthHandler(userStore db.UserStore) *AuthHandler {
+func NewAuthHandler(userStore db.UserStore, tokenStore db.TokenStore, lockoutStore db.LockoutStore, accessTTL, refreshTTL time.Duration) *AuthHandler {
 	return &AuthHandler{
-		userStore: userStore,
+		loginLimiter: newLoginLimiter(lockoutStore),
+		userStore:    userStore,
+		tokenStore:   tokenStore,
+		accessTTL:    accessTTL,
+		refreshTTL:   refreshTTL,
 	}
 }
 
@@ -27,31 +69,256 @@ type AuthParams struct {
 	Pasword string `json:"password"`
 }
 
+// HandleAuthenticate logs a user in. Failed logins are counted by email and
+// by client IP, and too many of either are refused without checking the
+// password until the backoff or the lockout of the policy passes.
 func (h *AuthHandler) HandleAuthenticate(c *fiber.Ctx) error {
 	var params AuthParams
 	if err := c.BodyParser(&params); err != nil {
 		return types.ErrInvalidParams(err)
 	}
-	user, err := h.userStore.GetUserByEmail(c.Context(), params.Email)
+	throttles, err := h.reserve(c, params.Email)
 	if err != nil {
-		return types.ErrUnauthorized(fmt.Errorf("invalid credentials"))
+		return err
 	}
-	if !types.AuthUser(user.EncyptedPassword, params.Pasword) {
+	user, err := h.userStore.GetUserByEmail(c.Context(), params.Email)
+	if err != nil || !types.AuthUser(user.EncyptedPassword, params.Pasword) {
+		if err := h.failed(c, throttles); err != nil {
+			return err
+		}
 		return types.ErrUnauthorized(fmt.Errorf("invalid credentials"))
 	}
-	token := createTokenFromUser(user)
-	if len(token) == 0 {
+	if err := h.passed(c, throttles); err != nil {
+		return err
+	}
+	return h.issueTokens(c, user, "")
+}
+
+// loginLimiter throttles password checks by email and by client IP. Every
+// check is counted as a failed login before the password is compared, so
+// concurrent checks can not get past the limits together, and given back
+// when the check is refused or the password is right.
+type loginLimiter struct {
+	lockoutStore   db.LockoutStore
+	accountLockout types.LockoutPolicy
+	ipLockout      types.LockoutPolicy
+}
+
+func newLoginLimiter(lockoutStore db.LockoutStore) loginLimiter {
+	return loginLimiter{
+		lockoutStore:   lockoutStore,
+		accountLockout: DefaultAccountLockout,
+		ipLockout:      DefaultIPLockout,
+	}
+}
+
+// loginThrottle is what the failed logins of a request are counted by, an
+// email or a client IP, and the policy throttling them.
+type loginThrottle struct {
+	kind    types.LoginKind
+	subject string
+	policy  types.LockoutPolicy
+	// attempts are the failed logins counted, this password check included.
+	attempts *types.LoginAttempts
+}
+
+// reserve counts a password check for email from the client IP as a failed
+// login on every throttle. The check is refused, and given back, when it
+// comes before the retry time of any throttle as counted before it, telling
+// the client when to retry in Retry-After.
+func (l *loginLimiter) reserve(c *fiber.Ctx, email string) ([]*loginThrottle, error) {
+	throttles := []*loginThrottle{
+		{kind: types.LoginByEmail, subject: email, policy: l.accountLockout},
+		{kind: types.LoginByIP, subject: c.IP(), policy: l.ipLockout},
+	}
+	now := time.Now()
+	for i, throttle := range throttles {
+		attempts, err := l.lockoutStore.RecordLoginFailure(c.Context(), throttle.kind, throttle.subject, now, throttle.policy.Window)
+		if err != nil {
+			return nil, undoFailed(err, l.release(c, throttles[:i]))
+		}
+		throttle.attempts = attempts
+	}
+	for _, throttle := range throttles {
+		if retryAt := throttle.policy.RetryAt(throttle.attempts.Before()); now.Before(retryAt) {
+			wait := int(math.Ceil(retryAt.Sub(now).Seconds()))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(wait))
+			err := types.ErrTooManyLogins(fmt.Errorf("logins by %s %s refused for %ds", throttle.kind, throttle.attempts.Subject, wait))
+			return nil, undoFailed(err, l.release(c, throttles))
+		}
+	}
+	return throttles, nil
+}
+
+// release gives back the failed logins counted by reserve.
+func (l *loginLimiter) release(c *fiber.Ctx, throttles []*loginThrottle) error {
+	for _, throttle := range throttles {
+		if err := l.lockoutStore.ForgetLoginFailure(c.Context(), throttle.attempts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// passed forgets the failed logins of the email of a right password.
+func (l *loginLimiter) passed(c *fiber.Ctx, throttles []*loginThrottle) error {
+	for _, throttle := range throttles {
+		//the IP keeps its failures, or an attacker could reset them logging in
+		//to an account of their own
+		if throttle.kind == types.LoginByIP {
+			if err := l.lockoutStore.ForgetLoginFailure(c.Context(), throttle.attempts); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := l.lockoutStore.ClearLoginAttempts(c.Context(), throttle.attempts.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failed keeps the failed login counted for a wrong password, locking out
+// and auditing the throttles reaching the lockout of their policy. The
+// backoff starts when the password check ended, so it does not pass during
+// it.
+func (l *loginLimiter) failed(c *fiber.Ctx, throttles []*loginThrottle) error {
+	now := time.Now()
+	for _, throttle := range throttles {
+		attempts := throttle.attempts
+		if err := l.lockoutStore.TouchLoginFailure(c.Context(), attempts.ID, now); err != nil {
+			return err
+		}
+		if !throttle.policy.Locks(attempts) {
+			continue
+		}
+		until := now.Add(throttle.policy.LockDuration)
+		if err := l.lockoutStore.LockLogin(c.Context(), attempts.ID, until); err != nil {
+			return err
+		}
+		if _, err := l.lockoutStore.InsertLockoutEvent(c.Context(), &types.LockoutEvent{
+			Kind:        attempts.Kind,
+			Subject:     attempts.Subject,
+			Action:      types.LockoutLocked,
+			Failures:    attempts.Failures,
+			IP:          c.IP(),
+			LockedUntil: until,
+			At:          now,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleRefresh exchanges a refresh token for a new access token and a new
+// refresh token. Presenting a refresh token that was already exchanged
+// revokes every token descending from the same login.
+func (h *AuthHandler) HandleRefresh(c *fiber.Ctx) error {
+	var params types.RefreshParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := params.Validate(); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	token, err := h.tokenStore.GetRefreshToken(c.Context(), types.HashToken(params.RefreshToken))
+	if err != nil {
+		var e types.ErrorSt
+		if errors.As(err, &e) && e.Status == http.StatusNotFound {
+			return types.ErrUnauthorized(fmt.Errorf("invalid refresh token"))
+		}
+		return err
+	}
+	now := time.Now()
+	if err := token.Usable(now); err != nil {
+		if !token.UsedAt.IsZero() {
+			return h.revokeReused(c, token, now)
+		}
+		return types.ErrUnauthorized(err)
+	}
+	if err := h.tokenStore.UseRefreshToken(c.Context(), token.ID, now); err != nil {
+		//a concurrent refresh used it first
+		return h.revokeReused(c, token, now)
+	}
+	user, err := h.userStore.GetUserByID(c.Context(), token.UserID)
+	if err != nil {
+		return types.ErrUnauthorized(fmt.Errorf("token user not in database"))
+	}
+	return h.issueTokens(c, user, token.Family)
+}
+
+func (h *AuthHandler) revokeReused(c *fiber.Ctx, token *types.RefreshToken, now time.Time) error {
+	if err := h.tokenStore.RevokeRefreshTokens(c.Context(), token.Family, now); err != nil {
+		return err
+	}
+	return types.ErrUnauthorized(fmt.Errorf("refresh token reused, session revoked"))
+}
+
+// HandleLogout revokes the access token of the request and, when its
+// refresh token is given, every refresh token of the same login.
+func (h *AuthHandler) HandleLogout(c *fiber.Ctx) error {
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("unauthorized"))
+	}
+	access, ok := c.Context().UserValue("token").(types.AccessToken)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("unauthorized"))
+	}
+	var params types.RefreshParams
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&params); err != nil {
+			return types.ErrInvalidParams(err)
+		}
+	}
+	if err := h.tokenStore.RevokeAccessToken(c.Context(), &types.RevokedToken{
+		ID:        access.ID,
+		ExpiresAt: access.ExpiresAt,
+	}); err != nil {
+		return err
+	}
+	if params.Validate() == nil {
+		token, err := h.tokenStore.GetRefreshToken(c.Context(), types.HashToken(params.RefreshToken))
+		if err == nil && token.UserID == user.ID {
+			if err := h.tokenStore.RevokeRefreshTokens(c.Context(), token.Family, time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// issueTokens sends a new access token of user in X-Authorization and a new
+// refresh token of family, a new one when empty, in X-Refresh-Token.
+func (h *AuthHandler) issueTokens(c *fiber.Ctx, user *types.User, family string) error {
+	access, err := createTokenFromUser(user, h.accessTTL)
+	if err != nil {
 		return types.ErrInternal(fmt.Errorf("error creating token"))
 	}
-	c.Response().Header.Add("X-Authorization", token)
+	token, refresh, err := types.NewRefreshToken(user.ID, family, h.refreshTTL)
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	if _, err := h.tokenStore.InsertRefreshToken(c.Context(), token); err != nil {
+		return err
+	}
+	c.Response().Header.Add("X-Authorization", access)
+	c.Response().Header.Add("X-Refresh-Token", refresh)
 	return c.SendStatus(http.StatusNoContent)
 }
 
-func createTokenFromUser(user *types.User) string {
-	exp := time.Now().Add(time.Hour * 4)
+func createTokenFromUser(user *types.User, ttl time.Duration) (string, error) {
+	jti, err := types.NewTokenID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"id":      user.ID,
-		"expires": exp.Unix(),
+		"jti":     jti,
+		"issued":  now.UnixMilli(),
+		"expires": now.Add(ttl).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	secret := os.Getenv("JWT_SECRET")
@@ -61,6 +328,7 @@ func createTokenFromUser(user *types.User) string {
 	tokenStr, err := token.SignedString([]byte(secret))
 	if err != nil {
 		fmt.Println("failed to sign token with secret")
+		return "", err
 	}
-	return tokenStr
+	return tokenStr, nil
 }
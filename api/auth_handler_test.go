@@ -6,8 +6,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/gofiber/fiber/v2"
+	middleware "github.com/jucaza1/hotel-reserv/api/middelware"
 	"github.com/jucaza1/hotel-reserv/types"
 )
 
@@ -15,7 +19,7 @@ func TestHandleAuthenticateSuccess(t *testing.T) {
 	tdb := userSetup(t)
 	defer tdb.userTeardown(t)
 	app := NewFiberAppCentralErr()
-	authHandler := NewAuthHandler(tdb.UserStore)
+	authHandler := NewAuthHandler(tdb.UserStore, tdb.TokenStore, tdb.LockoutStore, DefaultAccessTokenTTL, DefaultRefreshTokenTTL)
 	app.Post("/auth", authHandler.HandleAuthenticate)
 
 	userParams := types.CreateUserParams{
@@ -56,7 +60,7 @@ func TestHandleAuthenticateWrongPassword(t *testing.T) {
 	tdb := userSetup(t)
 	defer tdb.userTeardown(t)
 	app := NewFiberAppCentralErr()
-	authHandler := NewAuthHandler(tdb.UserStore)
+	authHandler := NewAuthHandler(tdb.UserStore, tdb.TokenStore, tdb.LockoutStore, DefaultAccessTokenTTL, DefaultRefreshTokenTTL)
 	app.Post("/auth", authHandler.HandleAuthenticate)
 
 	userParams := types.CreateUserParams{
@@ -97,7 +101,7 @@ func TestHandleAuthenticateWrongEmail(t *testing.T) {
 	tdb := userSetup(t)
 	defer tdb.userTeardown(t)
 	app := NewFiberAppCentralErr()
-	authHandler := NewAuthHandler(tdb.UserStore)
+	authHandler := NewAuthHandler(tdb.UserStore, tdb.TokenStore, tdb.LockoutStore, DefaultAccessTokenTTL, DefaultRefreshTokenTTL)
 	app.Post("/auth", authHandler.HandleAuthenticate)
 
 	userParams := types.CreateUserParams{
@@ -133,3 +137,270 @@ func TestHandleAuthenticateWrongEmail(t *testing.T) {
 		t.Errorf("expected token not to be found in headers")
 	}
 }
+
+func TestHandleRefreshAndLogout(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	tdb := userSetup(t)
+	defer tdb.userTeardown(t)
+	app := NewFiberAppCentralErr()
+	authHandler := NewAuthHandler(tdb.UserStore, tdb.TokenStore, tdb.LockoutStore, DefaultAccessTokenTTL, DefaultRefreshTokenTTL)
+	jwtAuth := middleware.JWTAuthentication(tdb.UserStore, tdb.TokenStore)
+	app.Post("/auth", authHandler.HandleAuthenticate)
+	app.Post("/auth/refresh", authHandler.HandleRefresh)
+	app.Post("/auth/logout", jwtAuth, authHandler.HandleLogout)
+	app.Get("/me", jwtAuth, func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusOK)
+	})
+
+	userParams := types.CreateUserParams{
+		Firstname: "testName",
+		Lastname:  "testLast",
+		Email:     "test@foo.com",
+		Password:  "secretpasstest",
+	}
+	insertedUser, err := types.NewUserFromParams(userParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tdb.UserStore.InsertUser(context.Background(), insertedUser); err != nil {
+		t.Fatal(err)
+	}
+	request := func(path, access string, params any) *http.Response {
+		t.Helper()
+		b, _ := json.Marshal(params)
+		method := "POST"
+		if params == nil {
+			method = "GET"
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		if access != "" {
+			req.Header.Add("X-Authorization", access)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	expectStatus := func(resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Fatalf("expected the response status code to be %d but got %d", status, resp.StatusCode)
+		}
+	}
+	tokens := func(resp *http.Response) (string, string) {
+		t.Helper()
+		expectStatus(resp, http.StatusNoContent)
+		access, refresh := resp.Header.Get("X-Authorization"), resp.Header.Get("X-Refresh-Token")
+		if access == "" || refresh == "" {
+			t.Fatalf("expected an access and a refresh token in headers")
+		}
+		return access, refresh
+	}
+
+	access, refresh := tokens(request("/auth", "", AuthParams{Email: userParams.Email, Pasword: userParams.Password}))
+	expectStatus(request("/me", access, nil), http.StatusOK)
+
+	// refreshing rotates the refresh token
+	newAccess, newRefresh := tokens(request("/auth/refresh", "", types.RefreshParams{RefreshToken: refresh}))
+	if newRefresh == refresh || newAccess == access {
+		t.Fatalf("expected new tokens after refreshing")
+	}
+	expectStatus(request("/me", newAccess, nil), http.StatusOK)
+	expectStatus(request("/auth/refresh", "", types.RefreshParams{RefreshToken: "unknown"}), http.StatusUnauthorized)
+
+	// reusing a refresh token revokes the whole family
+	expectStatus(request("/auth/refresh", "", types.RefreshParams{RefreshToken: refresh}), http.StatusUnauthorized)
+	expectStatus(request("/auth/refresh", "", types.RefreshParams{RefreshToken: newRefresh}), http.StatusUnauthorized)
+
+	// logging out revokes the access token and the refresh tokens of the login
+	access, refresh = tokens(request("/auth", "", AuthParams{Email: userParams.Email, Pasword: userParams.Password}))
+	expectStatus(request("/auth/logout", access, types.RefreshParams{RefreshToken: refresh}), http.StatusNoContent)
+	expectStatus(request("/me", access, nil), http.StatusUnauthorized)
+	expectStatus(request("/auth/refresh", "", types.RefreshParams{RefreshToken: refresh}), http.StatusUnauthorized)
+	expectStatus(request("/auth/logout", access, types.RefreshParams{}), http.StatusUnauthorized)
+}
+
+func TestHandleAuthenticateLockout(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	tdb := userSetup(t)
+	defer tdb.userTeardown(t)
+	authHandler := NewAuthHandler(tdb.UserStore, tdb.TokenStore, tdb.LockoutStore, DefaultAccessTokenTTL, DefaultRefreshTokenTTL)
+	authHandler.accountLockout = types.LockoutPolicy{
+		FreeFailures: 2,
+		BaseDelay:    200 * time.Millisecond,
+		MaxDelay:     400 * time.Millisecond,
+		LockAfter:    4,
+		LockDuration: time.Hour,
+		Window:       time.Hour,
+	}
+	authHandler.ipLockout = types.LockoutPolicy{
+		FreeFailures: 100,
+		LockAfter:    7,
+		LockDuration: time.Hour,
+		Window:       time.Hour,
+	}
+	lockoutHandler := NewLockoutHandler(tdb.LockoutStore)
+	admin := types.User{ID: "0001", Email: "admin@mail.com", IsAdmin: true}
+	app := NewFiberAppCentralErr()
+	app.Post("/auth", authHandler.HandleAuthenticate)
+	app.Get("/lockouts", provideContextUser(admin), lockoutHandler.HandleGetLockoutEvents)
+	app.Post("/lockouts/unlock", provideContextUser(admin), lockoutHandler.HandleUnlock)
+
+	userParams := types.CreateUserParams{
+		Firstname: "testName",
+		Lastname:  "testLast",
+		Email:     "test@foo.com",
+		Password:  "secretpasstest",
+	}
+	insertedUser, err := types.NewUserFromParams(userParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tdb.UserStore.InsertUser(context.Background(), insertedUser); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus := func(resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Fatalf("expected the response status code to be %d but got %d", status, resp.StatusCode)
+		}
+	}
+	login := func(email, password string, status int) *http.Response {
+		t.Helper()
+		resp := holdTestRequest(t, app, "POST", "/auth", AuthParams{Email: email, Pasword: password})
+		expectStatus(resp, status)
+		return resp
+	}
+	events := func(query string, count int) []types.LockoutEvent {
+		t.Helper()
+		resp := holdTestRequest(t, app, "GET", "/lockouts?"+query, nil)
+		expectStatus(resp, http.StatusOK)
+		var events []types.LockoutEvent
+		if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != count {
+			t.Fatalf("expected %d lockout events but got %d", count, len(events))
+		}
+		return events
+	}
+
+	// after the free failures each attempt waits longer
+	login(userParams.Email, "wrongpassword", http.StatusUnauthorized)
+	login(userParams.Email, "wrongpassword", http.StatusUnauthorized)
+	resp := login("TEST@foo.com", userParams.Password, http.StatusTooManyRequests)
+	if resp.Header.Get("Retry-After") != "1" {
+		t.Fatalf("expected to retry after 1 second but got %q", resp.Header.Get("Retry-After"))
+	}
+	time.Sleep(250 * time.Millisecond)
+	login(userParams.Email, "wrongpassword", http.StatusUnauthorized)
+	time.Sleep(250 * time.Millisecond)
+	login(userParams.Email, "wrongpassword", http.StatusTooManyRequests)
+	time.Sleep(200 * time.Millisecond)
+
+	// the lockout refuses even the right password, until an admin unlocks
+	login(userParams.Email, "wrongpassword", http.StatusUnauthorized)
+	login(userParams.Email, userParams.Password, http.StatusTooManyRequests)
+	locked := events("kind=email&subject=TEST@foo.com", 1)[0]
+	if locked.Action != types.LockoutLocked || locked.Subject != userParams.Email || locked.Failures != 4 || locked.IP == "" {
+		t.Fatalf("expected a lockout of %s after 4 failures but got %+v", userParams.Email, locked)
+	}
+	expectStatus(holdTestRequest(t, app, "POST", "/lockouts/unlock", types.UnlockParams{}), http.StatusBadRequest)
+	expectStatus(holdTestRequest(t, app, "POST", "/lockouts/unlock", types.UnlockParams{Email: "nobody@foo.com"}), http.StatusNotFound)
+	expectStatus(holdTestRequest(t, app, "POST", "/lockouts/unlock", types.UnlockParams{Email: userParams.Email}), http.StatusOK)
+	login(userParams.Email, userParams.Password, http.StatusNoContent)
+	if unlocked := events("", 2)[0]; unlocked.Action != types.LockoutUnlocked || unlocked.UnlockedBy != admin.ID {
+		t.Fatalf("expected an unlock by %s but got %+v", admin.ID, unlocked)
+	}
+
+	// failures to any account add up on the client IP
+	login("a@foo.com", "wrongpassword", http.StatusUnauthorized)
+	login("b@foo.com", "wrongpassword", http.StatusUnauthorized)
+	login("c@foo.com", "wrongpassword", http.StatusUnauthorized)
+	login(userParams.Email, userParams.Password, http.StatusTooManyRequests)
+	ip := events("kind=ip", 1)[0].Subject
+	expectStatus(holdTestRequest(t, app, "POST", "/lockouts/unlock", types.UnlockParams{IP: ip}), http.StatusOK)
+	login(userParams.Email, userParams.Password, http.StatusNoContent)
+}
+
+func TestHandleAuthenticateConcurrentFailures(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	tdb := userSetup(t)
+	defer tdb.userTeardown(t)
+	authHandler := NewAuthHandler(tdb.UserStore, tdb.TokenStore, tdb.LockoutStore, DefaultAccessTokenTTL, DefaultRefreshTokenTTL)
+	authHandler.accountLockout = types.LockoutPolicy{
+		FreeFailures: 3,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Minute,
+		LockAfter:    100,
+		LockDuration: time.Hour,
+		Window:       time.Hour,
+	}
+	authHandler.ipLockout.FreeFailures = 100
+	app := NewFiberAppCentralErr()
+	app.Post("/auth", authHandler.HandleAuthenticate)
+
+	params := types.CreateUserParams{
+		Firstname: "testName",
+		Lastname:  "testLast",
+		Email:     "test@foo.com",
+		Password:  "secretpasstest",
+	}
+	user, err := types.NewUserFromParams(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tdb.UserStore.InsertUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	// every password check is counted before the password is compared, so
+	// parallel guesses get no more checks than the free failures
+	const requests = 10
+	var (
+		wg       sync.WaitGroup
+		statuses = make(chan int, requests)
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b, _ := json.Marshal(AuthParams{Email: params.Email, Pasword: "wrongpassword"})
+			req := httptest.NewRequest("POST", "/auth", bytes.NewReader(b))
+			req.Header.Add("Content-Type", "application/json")
+			//the password checks run in parallel, give them time
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	var checked, refused int
+	for status := range statuses {
+		switch status {
+		case http.StatusUnauthorized:
+			checked++
+		case http.StatusTooManyRequests:
+			refused++
+		default:
+			t.Errorf("unexpected status code %d", status)
+		}
+	}
+	if checked != authHandler.accountLockout.FreeFailures || refused != requests-checked {
+		t.Fatalf("expected %d password checks but got %d", authHandler.accountLockout.FreeFailures, checked)
+	}
+	attempts, err := tdb.LockoutStore.GetLoginAttempts(context.Background(), types.LoginKey(types.LoginByEmail, params.Email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts.Failures != checked {
+		t.Fatalf("expected the refused logins given back, %d failures counted but got %d", checked, attempts.Failures)
+	}
+}
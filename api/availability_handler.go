@@ -0,0 +1,33 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type AvailabilityHandler struct {
+	availabilityStore db.AvailabilityStore
+}
+
+func NewAvailabilityHandler(as db.AvailabilityStore) *AvailabilityHandler {
+	return &AvailabilityHandler{
+		availabilityStore: as,
+	}
+}
+
+func (h *AvailabilityHandler) HandleGetAvailability(c *fiber.Ctx) error {
+	var query types.AvailabilityQuery
+	if err := c.QueryParser(&query); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	params, err := types.NewAvailabilityParams(query)
+	if err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	availability, err := h.availabilityStore.GetAvailableRooms(c.Context(), params)
+	if err != nil {
+		return err
+	}
+	return c.JSON(availability)
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type availabilityTestDB struct {
+	db.HotelStore
+	db.RoomStore
+	db.BookingStore
+	db.AvailabilityStore
+}
+
+func (tdb *availabilityTestDB) availabilityTeardown(t *testing.T) {
+	if err := tdb.RoomStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tdb.BookingStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tdb.HotelStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func availabilitySetup(t *testing.T) *availabilityTestDB {
+	store := newTestStore(t)
+	return &availabilityTestDB{
+		HotelStore:        store.Hotel,
+		RoomStore:         store.Room,
+		BookingStore:      store.Booking,
+		AvailabilityStore: store.Availability,
+	}
+}
+
+func seedAvailabilityRoom(t *testing.T, rs db.RoomStore, hotelID string, size types.RoomSize, price float64) *types.Room {
+	room := types.NewRoomFromParams(types.CreateRoomParams{Size: size, Price: eur(price)})
+	room.HotelID = hotelID
+	room, err := rs.InsertRoom(context.Background(), room)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return room
+}
+
+func TestHandleGetAvailability(t *testing.T) {
+	tdb := availabilitySetup(t)
+	defer tdb.availabilityTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	availabilityHandler := NewAvailabilityHandler(tdb.AvailabilityStore)
+	app.Get("/availability", availabilityHandler.HandleGetAvailability)
+
+	madrid, err := tdb.HotelStore.InsertHotel(context.Background(), &types.Hotel{Name: "Sol", Location: "Madrid, Spain", Rooms: []string{}, TimeZone: "Europe/Madrid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paris, err := tdb.HotelStore.InsertHotel(context.Background(), &types.Hotel{Name: "Rose", Location: "Paris, France", Rooms: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	booked := seedAvailabilityRoom(t, tdb.RoomStore, madrid.ID, types.Normal, 100)
+	free := seedAvailabilityRoom(t, tdb.RoomStore, madrid.ID, types.Normal, 120)
+	seedAvailabilityRoom(t, tdb.RoomStore, madrid.ID, types.Normal, 300)
+	seedAvailabilityRoom(t, tdb.RoomStore, paris.ID, types.Small, 90)
+	parisNormal := seedAvailabilityRoom(t, tdb.RoomStore, paris.ID, types.Normal, 110)
+
+	from := time.Now().AddDate(0, 0, 10)
+	to := from.AddDate(0, 0, 3)
+	booking, err := types.NewBookingFromParams(types.CreateBookingParams{
+		FromDate: from.AddDate(0, 0, 1),
+		ToDate:   from.AddDate(0, 0, 5),
+	}, "0000", madrid, booked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tdb.BookingStore.InsertBooking(context.Background(), booking); err != nil {
+		t.Fatal(err)
+	}
+
+	reqUri := fmt.Sprintf("/availability?from=%s&to=%s&size=Normal&maxPrice=200&currency=EUR",
+		from.Format(time.DateOnly), to.Format(time.DateOnly))
+	req := httptest.NewRequest("GET", reqUri, nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Error(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var have []types.HotelAvailability
+	if err := json.NewDecoder(resp.Body).Decode(&have); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]*types.Room{
+		madrid.ID: {free},
+		paris.ID:  {parisNormal},
+	}
+	if len(have) != len(expected) {
+		t.Fatalf("expected %d hotels but got %d", len(expected), len(have))
+	}
+	for _, hotel := range have {
+		rooms, ok := expected[hotel.Hotel.ID]
+		if !ok {
+			t.Errorf("unexpected hotel %s", hotel.Hotel.ID)
+			continue
+		}
+		if len(hotel.Rooms) != len(rooms) {
+			t.Errorf("expected hotel %s to have %d rooms but got %d", hotel.Hotel.ID, len(rooms), len(hotel.Rooms))
+			continue
+		}
+		for i, room := range rooms {
+			compareRoomWithID(t, room, hotel.Rooms[i])
+		}
+	}
+}
+
+func TestHandleGetAvailabilityByLocation(t *testing.T) {
+	tdb := availabilitySetup(t)
+	defer tdb.availabilityTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	availabilityHandler := NewAvailabilityHandler(tdb.AvailabilityStore)
+	app.Get("/availability", availabilityHandler.HandleGetAvailability)
+
+	madrid, err := tdb.HotelStore.InsertHotel(context.Background(), &types.Hotel{Name: "Sol", Location: "Madrid, Spain", Rooms: []string{}, TimeZone: "Europe/Madrid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paris, err := tdb.HotelStore.InsertHotel(context.Background(), &types.Hotel{Name: "Rose", Location: "Paris, France", Rooms: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedAvailabilityRoom(t, tdb.RoomStore, madrid.ID, types.Normal, 100)
+	parisRoom := seedAvailabilityRoom(t, tdb.RoomStore, paris.ID, types.Small, 90)
+
+	from := time.Now().AddDate(0, 0, 10)
+	reqUri := fmt.Sprintf("/availability?from=%s&to=%s&location=paris",
+		from.Format(time.DateOnly), from.AddDate(0, 0, 2).Format(time.DateOnly))
+	req := httptest.NewRequest("GET", reqUri, nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Error(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var have []types.HotelAvailability
+	if err := json.NewDecoder(resp.Body).Decode(&have); err != nil {
+		t.Fatal(err)
+	}
+	if len(have) != 1 || have[0].Hotel.ID != paris.ID {
+		t.Fatalf("expected only hotel %s to be available", paris.ID)
+	}
+	if len(have[0].Rooms) != 1 {
+		t.Fatalf("expected 1 room but got %d", len(have[0].Rooms))
+	}
+	compareRoomWithID(t, parisRoom, have[0].Rooms[0])
+}
+
+func TestHandleGetAvailabilityInvalidDates(t *testing.T) {
+	tdb := availabilitySetup(t)
+	defer tdb.availabilityTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	availabilityHandler := NewAvailabilityHandler(tdb.AvailabilityStore)
+	app.Get("/availability", availabilityHandler.HandleGetAvailability)
+
+	from := time.Now().AddDate(0, 0, 10)
+	reqUri := fmt.Sprintf("/availability?from=%s&to=%s",
+		from.Format(time.DateOnly), from.AddDate(0, 0, -2).Format(time.DateOnly))
+	req := httptest.NewRequest("GET", reqUri, nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Error(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status code expected %d but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	// stays longer than a year are refused before their nights are listed
+	reqUri = fmt.Sprintf("/availability?from=%s&to=%s",
+		from.Format(time.DateOnly), from.AddDate(50, 0, 0).Format(time.DateOnly))
+	resp, err = app.Test(httptest.NewRequest("GET", reqUri, nil))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status code expected %d but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestHandleGetAvailabilityMaxPriceWithoutCurrency(t *testing.T) {
+	tdb := availabilitySetup(t)
+	defer tdb.availabilityTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	availabilityHandler := NewAvailabilityHandler(tdb.AvailabilityStore)
+	app.Get("/availability", availabilityHandler.HandleGetAvailability)
+
+	from := time.Now().AddDate(0, 0, 10)
+	reqUri := fmt.Sprintf("/availability?from=%s&to=%s&maxPrice=200",
+		from.Format(time.DateOnly), from.AddDate(0, 0, 2).Format(time.DateOnly))
+	req := httptest.NewRequest("GET", reqUri, nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Error(err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status code expected %d but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
@@ -1,22 +1,41 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/invoicing"
+	"github.com/jucaza1/hotel-reserv/payments"
+	"github.com/jucaza1/hotel-reserv/pricing"
+	"github.com/jucaza1/hotel-reserv/promos"
 	"github.com/jucaza1/hotel-reserv/types"
 )
 
 type BookingHandler struct {
-	bookStore db.BookingStore
-	roomStore db.RoomStore
+	bookStore  db.BookingStore
+	roomStore  db.RoomStore
+	hotelStore db.HotelStore
+	pricer     *pricing.Engine
+	payments   *payments.Service
+	folioStore db.FolioStore
+	invoices   *invoicing.Service
+	promos     *promos.Service
 }
 
-func NewBookingHandler(bs db.BookingStore, rs db.RoomStore) *BookingHandler {
+func NewBookingHandler(bs db.BookingStore, rs db.RoomStore, hs db.HotelStore, pricer *pricing.Engine, payments *payments.Service, fs db.FolioStore, invoices *invoicing.Service, promos *promos.Service) *BookingHandler {
 	return &BookingHandler{
-		bookStore: bs,
-		roomStore: rs,
+		bookStore:  bs,
+		roomStore:  rs,
+		hotelStore: hs,
+		pricer:     pricer,
+		payments:   payments,
+		folioStore: fs,
+		invoices:   invoices,
+		promos:     promos,
 	}
 }
 
@@ -29,7 +48,11 @@ func (h *BookingHandler) HandleGetBookingsByRoom(c *fiber.Ctx) error {
 	if !ok {
 		return types.ErrUnauthorized(fmt.Errorf("user not found"))
 	}
-	if user.IsAdmin {
+	room, err := h.roomStore.GetRoom(c.Context(), roomID)
+	if err != nil {
+		return err
+	}
+	if user.Can(types.PermViewBookings, room.HotelID) {
 		bookings, err := h.bookStore.GetBookingsByRoom(c.Context(), roomID)
 		if err != nil {
 			return err
@@ -44,29 +67,150 @@ func (h *BookingHandler) HandleGetBookingsByRoom(c *fiber.Ctx) error {
 	}
 }
 
+// HandlePostBooking books the room as pending, confirming the booking once
+// its promo code is redeemed and its total is authorized on the payment
+// method. Otherwise the booking is cancelled, freeing the room.
 func (h *BookingHandler) HandlePostBooking(c *fiber.Ctx) error {
+	booking, room, hotel, params, err := h.bookingFromRequest(c)
+	if err != nil {
+		return err
+	}
+	if err := (types.PaymentParams{PaymentMethod: params.PaymentMethod}).Validate(); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := h.priceBooking(c, booking, room, hotel, params); err != nil {
+		return err
+	}
+	booking.AwaitPayment()
+	InsertedBooking, err := h.bookStore.InsertBooking(c.Context(), booking)
+	if err != nil {
+		return err
+	}
+	if err := h.promos.Redeem(c.Context(), InsertedBooking); err != nil {
+		_, cancelErr := h.bookStore.TransitionBooking(c.Context(), InsertedBooking.ID, types.StatusCancelled)
+		return undoFailed(err, cancelErr)
+	}
+	payment, err := h.payments.Authorize(c.Context(), InsertedBooking, params.PaymentMethod)
+	if err != nil {
+		//free the room even if the promo code can not be given back
+		_, cancelErr := h.bookStore.TransitionBooking(c.Context(), InsertedBooking.ID, types.StatusCancelled)
+		return undoFailed(err, errors.Join(cancelErr, h.promos.Release(c.Context(), InsertedBooking.ID)))
+	}
+	confirmed, err := h.bookStore.TransitionBooking(c.Context(), InsertedBooking.ID, types.StatusConfirmed)
+	if err != nil {
+		_, voidErr := h.payments.Void(c.Context(), payment)
+		return undoFailed(err, voidErr)
+	}
+	return c.JSON(confirmed)
+}
+
+// undoFailed returns err, the reason a request failed, along with undoErr,
+// the reason undoing what the request had done failed too, if it did.
+func undoFailed(err, undoErr error) error {
+	if undoErr == nil {
+		return err
+	}
+	return types.ErrInternal(errors.Join(err, undoErr))
+}
+
+// priceBooking sets the price of booking, the quoted one when params carry
+// a quote token for the same stay, discounted by the promo code of params.
+func (h *BookingHandler) priceBooking(c *fiber.Ctx, booking *types.Booking, room *types.Room, hotel *types.Hotel, params types.CreateBookingParams) error {
+	if len(params.QuoteToken) > 0 {
+		quote, err := parseQuoteToken(params.QuoteToken)
+		if err != nil {
+			return types.ErrInvalidParams(err)
+		}
+		if !quote.matches(booking.UserID, booking) {
+			return types.ErrInvalidParams(fmt.Errorf("quote token does not match the booking"))
+		}
+		booking.Price = quote.Price
+		return nil
+	}
+	price, err := h.pricer.Price(c.Context(), hotel, room, booking.Nights, booking.Guests)
+	if err != nil {
+		return err
+	}
+	if len(params.PromoCode) > 0 {
+		price, err = h.promos.Apply(c.Context(), params.PromoCode, booking.UserID, hotel, booking.Guests, price)
+		if err != nil {
+			return err
+		}
+	}
+	booking.Price = price
+	return nil
+}
+
+// HandlePostQuote prices a stay like HandlePostBooking would, checking the
+// room is free but without booking it.
+func (h *BookingHandler) HandlePostQuote(c *fiber.Ctx) error {
+	booking, room, hotel, params, err := h.bookingFromRequest(c)
+	if err != nil {
+		return err
+	}
+	available, err := h.bookStore.IsRoomAvailable(c.Context(), room.ID, booking.Nights)
+	if err != nil {
+		return err
+	}
+	if !available {
+		return types.ErrUnavailableDate(fmt.Errorf("unavailable date"))
+	}
+	params.QuoteToken = ""
+	if err := h.priceBooking(c, booking, room, hotel, params); err != nil {
+		return err
+	}
+	expires := time.Now().Add(quoteTTL)
+	token, err := createQuoteToken(booking.UserID, booking, expires)
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	return c.JSON(types.Quote{
+		RoomID:    booking.RoomID,
+		HotelID:   booking.HotelID,
+		FromDate:  booking.FromDate,
+		ToDate:    booking.ToDate,
+		Nights:    booking.Nights,
+		Guests:    booking.Guests,
+		Price:     booking.Price,
+		Token:     token,
+		ExpiresAt: expires,
+	})
+}
+
+// bookingFromRequest builds the unpriced booking of the context user for the
+// room in the path and the stay in the body.
+func (h *BookingHandler) bookingFromRequest(c *fiber.Ctx) (*types.Booking, *types.Room, *types.Hotel, types.CreateBookingParams, error) {
+	var params types.CreateBookingParams
 	roomID := c.Params("id")
 	if len(roomID) == 0 {
-		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+		return nil, nil, nil, params, types.ErrInvalidID(fmt.Errorf("missing params in path"))
 	}
 	room, err := h.roomStore.GetRoom(c.Context(), roomID)
 	if err != nil {
-		return err
+		return nil, nil, nil, params, err
+	}
+	hotel, err := h.hotelStore.GetHotelByID(c.Context(), room.HotelID)
+	if err != nil {
+		return nil, nil, nil, params, err
 	}
-	var params types.CreateBookingParams
 	if err := c.BodyParser(&params); err != nil {
-		return types.ErrInvalidParams(err)
+		return nil, nil, nil, params, types.ErrInvalidParams(err)
 	}
 	if err := params.Validate(); err != nil {
-		return types.ErrInvalidParams(err)
+		return nil, nil, nil, params, types.ErrInvalidParams(err)
 	}
-	userID := c.Context().UserValue("user").(types.User).ID
-	booking, _ := types.NewBookingFromParams(params, userID, room.HotelID, roomID)
-	InsertedBooking, err := h.bookStore.InsertBooking(c.Context(), booking)
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return nil, nil, nil, params, types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	booking, err := types.NewBookingFromParams(params, user.ID, hotel, roomID)
 	if err != nil {
-		return err
+		return nil, nil, nil, params, types.ErrInvalidParams(err)
+	}
+	if err := room.Admits(booking.Guests); err != nil {
+		return nil, nil, nil, params, err
 	}
-	return c.JSON(InsertedBooking)
+	return booking, room, hotel, params, nil
 }
 
 func (h *BookingHandler) HandleGetBookingsByHotel(c *fiber.Ctx) error {
@@ -78,7 +222,7 @@ func (h *BookingHandler) HandleGetBookingsByHotel(c *fiber.Ctx) error {
 	if !ok {
 		return types.ErrUnauthorized(fmt.Errorf("user not found"))
 	}
-	if user.IsAdmin {
+	if user.Can(types.PermViewBookings, hotelID) {
 		bookings, err := h.bookStore.GetBookingsByHotel(c.Context(), hotelID)
 		if err != nil {
 			return err
@@ -115,6 +259,9 @@ func (h *BookingHandler) HandleGetBookings(c *fiber.Ctx) error {
 	}
 }
 
+// HandleCancelBooking cancels a booking charging the fee of its hotel's
+// cancellation policy, which the staff of the hotel may override giving a
+// reason.
 func (h *BookingHandler) HandleCancelBooking(c *fiber.Ctx) error {
 	bookingID := c.Params("id")
 	if len(bookingID) == 0 {
@@ -124,24 +271,330 @@ func (h *BookingHandler) HandleCancelBooking(c *fiber.Ctx) error {
 	if !ok {
 		return types.ErrUnauthorized(fmt.Errorf("user not found"))
 	}
-	if user.IsAdmin {
-		if err := h.bookStore.CancelBooking(c.Context(), bookingID); err != nil {
+	var params types.CancelBookingParams
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&params); err != nil {
+			return types.ErrInvalidParams(err)
+		}
+	}
+	if err := params.Validate(); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	booking, err := h.bookStore.GetBookingByID(c.Context(), bookingID)
+	if err != nil {
+		return err
+	}
+	if !user.CanAccess(booking, types.PermManageBookings) {
+		return types.ErrUnauthorized(fmt.Errorf("unauthorized cancel on different user"))
+	}
+	if params.Fee != nil && !user.Can(types.PermManageBookings, booking.HotelID) {
+		return types.ErrUnauthorized(fmt.Errorf("only hotel staff can override cancellation fees"))
+	}
+	cancelled, err := h.cancellation(c.Context(), booking, user, params)
+	if err != nil {
+		return err
+	}
+	cancellation, err := h.cancel(c.Context(), booking, cancelled, user)
+	if err != nil {
+		return err
+	}
+	return c.JSON(types.MsgCancelled{Cancelled: bookingID, Cancellation: cancellation})
+}
+
+// cancellation returns booking cancelled by user, with the fee of its
+// hotel's policy or the one of params.
+func (h *BookingHandler) cancellation(ctx context.Context, booking *types.Booking, user types.User, params types.CancelBookingParams) (*types.Booking, error) {
+	if err := h.notInvoiced(ctx, booking); err != nil {
+		return nil, err
+	}
+	hotel, err := h.hotelStore.GetHotelByID(ctx, booking.HotelID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	cancellation := types.NewCancellation(booking, hotel, user.ID, now)
+	if params.Fee != nil {
+		if err := cancellation.Override(*params.Fee, params.Reason); err != nil {
+			return nil, types.ErrInvalidParams(err)
+		}
+	}
+	cancelled := *booking
+	if err := cancelled.Cancel(cancellation, now); err != nil {
+		return nil, err
+	}
+	return &cancelled, nil
+}
+
+// notInvoiced fails for a booking that has an invoice, which no longer
+// changes.
+func (h *BookingHandler) notInvoiced(ctx context.Context, booking *types.Booking) error {
+	issued, err := h.invoices.Issued(ctx, booking.ID)
+	if err != nil {
+		return err
+	}
+	if issued {
+		return types.ErrBookingNotModifiable(fmt.Errorf("booking %s is invoiced", booking.ID))
+	}
+	return nil
+}
+
+// cancel stores booking as cancelled and settles the cancellation.
+func (h *BookingHandler) cancel(ctx context.Context, booking, cancelled *types.Booking, user types.User) (*types.Cancellation, error) {
+	if _, err := h.bookStore.CancelBooking(ctx, booking, cancelled); err != nil {
+		return nil, err
+	}
+	return h.settleCancellation(ctx, booking, cancelled, user)
+}
+
+// settleCancellation gives back the promo code redemption of a stored
+// cancelled booking, charges the cancellation fee to its folio and settles
+// its payments.
+func (h *BookingHandler) settleCancellation(ctx context.Context, booking, cancelled *types.Booking, user types.User) (*types.Cancellation, error) {
+	if err := h.promos.Release(ctx, booking.ID); err != nil {
+		return nil, err
+	}
+	cancellation := cancelled.Cancellation
+	if !cancellation.Fee.IsZero() {
+		fee := types.NewFolioItem(booking.ID, types.FolioFee, "Cancellation fee", cancellation.Fee, user.ID)
+		if _, err := h.folioStore.InsertFolioItems(ctx, fee); err != nil {
+			return nil, err
+		}
+	}
+	if err := h.payments.SettleCancellation(ctx, booking.ID, *cancellation); err != nil {
+		return nil, err
+	}
+	return cancellation, nil
+}
+
+// HandleModifyBooking moves a booking not yet started to other dates and,
+// optionally, another room, pricing the new stay again. A new total is
+// authorized on the payment method in place of the previous one.
+func (h *BookingHandler) HandleModifyBooking(c *fiber.Ctx) error {
+	bookingID := c.Params("id")
+	if len(bookingID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	booking, err := h.bookStore.GetBookingByID(c.Context(), bookingID)
+	if err != nil {
+		return err
+	}
+	if !user.CanAccess(booking, types.PermManageBookings) {
+		return types.ErrUnauthorized(fmt.Errorf("unauthorized modification on different user"))
+	}
+	var params types.ModifyBookingParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := params.Validate(); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	modified, err := h.modification(c.Context(), booking, user, params)
+	if err != nil {
+		return err
+	}
+	payment, replaced, err := h.payments.Reauthorize(c.Context(), booking, modified, params.PaymentMethod)
+	if err != nil {
+		return err
+	}
+	updated, err := h.bookStore.ModifyBooking(c.Context(), booking, modified)
+	if err != nil {
+		return undoFailed(err, h.voidPayments(c.Context(), payment))
+	}
+	if err := h.voidPayments(c.Context(), replaced...); err != nil {
+		return err
+	}
+	return c.JSON(updated)
+}
+
+// voidPayments voids the authorizations of payments, skipping nil ones.
+func (h *BookingHandler) voidPayments(ctx context.Context, payments ...*types.Payment) error {
+	for _, payment := range payments {
+		if payment == nil {
+			continue
+		}
+		if _, err := h.payments.Void(ctx, payment); err != nil {
 			return err
 		}
-	} else {
-		booking, err := h.bookStore.GetBookingByID(c.Context(), bookingID)
+	}
+	return nil
+}
+
+// modification returns booking moved by user to the stay of params, priced
+// again.
+func (h *BookingHandler) modification(ctx context.Context, booking *types.Booking, user types.User, params types.ModifyBookingParams) (*types.Booking, error) {
+	if err := h.notInvoiced(ctx, booking); err != nil {
+		return nil, err
+	}
+	roomID := params.RoomID
+	if len(roomID) == 0 {
+		roomID = booking.RoomID
+	}
+	room, err := h.roomStore.GetRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	//staff may only move bookings into the hotels they manage
+	if booking.UserID != user.ID && !user.Can(types.PermManageBookings, room.HotelID) {
+		return nil, types.ErrUnauthorized(fmt.Errorf("unauthorized move to hotel %s", room.HotelID))
+	}
+	hotel, err := h.hotelStore.GetHotelByID(ctx, room.HotelID)
+	if err != nil {
+		return nil, err
+	}
+	stay, err := types.NewBookingFromParams(types.CreateBookingParams{
+		FromDate: params.FromDate,
+		ToDate:   params.ToDate,
+		Guests:   booking.Guests,
+	}, booking.UserID, hotel, roomID)
+	if err != nil {
+		return nil, types.ErrInvalidParams(err)
+	}
+	if err := room.Admits(stay.Guests); err != nil {
+		return nil, err
+	}
+	stay.Price, err = h.pricer.Price(ctx, hotel, room, stay.Nights, stay.Guests)
+	if err != nil {
+		return nil, err
+	}
+	//the promo code was redeemed when booking, the new stay keeps it
+	if code := booking.Price.PromoCode; len(code) > 0 {
+		stay.Price, err = h.promos.Reapply(ctx, code, hotel, stay.Guests, stay.Price)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if booking.UserID == user.ID {
-			if err := h.bookStore.CancelBooking(c.Context(), bookingID); err != nil {
-				return err
-			}
-		} else {
-			return types.ErrUnauthorized(fmt.Errorf("unauthorized cancel on different user"))
+	}
+	modified := *booking
+	if err := modified.Modify(stay, user.ID, time.Now()); err != nil {
+		return nil, err
+	}
+	return &modified, nil
+}
+
+// HandleCheckIn charges the nights and taxes of the stay to the folio and
+// checks the guest in. The charges are voided if the guest can not be
+// checked in after all.
+func (h *BookingHandler) HandleCheckIn(c *fiber.Ctx) error {
+	bookingID := c.Params("id")
+	if len(bookingID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	booking, err := h.bookStore.GetBookingByID(c.Context(), bookingID)
+	if err != nil {
+		return err
+	}
+	//refuse early bookings that can not be checked in, without charging them
+	check := *booking
+	check.StatusHistory = nil
+	if err := check.Transition(types.StatusCheckedIn, time.Now()); err != nil {
+		return err
+	}
+	hotel, err := h.hotelStore.GetHotelByID(c.Context(), booking.HotelID)
+	if err != nil {
+		return err
+	}
+	charges, err := h.folioStore.InsertFolioItems(c.Context(), types.NewStayFolioItems(booking, hotel)...)
+	if err != nil {
+		return err
+	}
+	checkedIn, err := h.bookStore.TransitionBooking(c.Context(), bookingID, types.StatusCheckedIn)
+	if err != nil {
+		return undoFailed(err, h.voidFolioItems(c.Context(), charges, user, "check-in failed"))
+	}
+	return c.JSON(checkedIn)
+}
+
+// voidFolioItems voids items posted by a request that failed.
+func (h *BookingHandler) voidFolioItems(ctx context.Context, items []*types.FolioItem, user types.User, reason string) error {
+	for _, item := range items {
+		if _, err := h.folioStore.VoidFolioItem(ctx, item.ID, types.FolioVoid{At: time.Now(), UserID: user.ID, Reason: reason}); err != nil {
+			return err
 		}
 	}
-	return c.JSON(types.MsgCancelled{Cancelled: bookingID})
+	return nil
+}
+
+// HandleCheckOut checks the guest out once the folio is settled, and issues
+// the invoice of the stay. The folio is checked again once checked out, as
+// items may be posted until then, and the guest is checked back in if it
+// is owed after all.
+func (h *BookingHandler) HandleCheckOut(c *fiber.Ctx) error {
+	bookingID := c.Params("id")
+	if len(bookingID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	booking, err := h.bookStore.GetBookingByID(c.Context(), bookingID)
+	if err != nil {
+		return err
+	}
+	folio, err := h.folio(c.Context(), booking)
+	if err != nil {
+		return err
+	}
+	if !folio.Settled() {
+		return types.ErrFolioNotSettled(fmt.Errorf("balance of booking %s is %s", bookingID, folio.Balance))
+	}
+	checkedOut, err := h.transition(c, types.StatusCheckedOut)
+	if err != nil {
+		return err
+	}
+	folio, err = h.folio(c.Context(), checkedOut)
+	if err == nil && !folio.Settled() {
+		err = types.ErrFolioNotSettled(fmt.Errorf("balance of booking %s is %s", bookingID, folio.Balance))
+	}
+	if err != nil {
+		_, revertErr := h.bookStore.RevertTransition(c.Context(), bookingID, types.StatusCheckedOut)
+		return undoFailed(err, revertErr)
+	}
+	if _, err := h.invoices.Issue(c.Context(), checkedOut); err != nil {
+		return err
+	}
+	return c.JSON(checkedOut)
+}
+
+func (h *BookingHandler) HandleNoShow(c *fiber.Ctx) error {
+	return h.transitionBooking(c, types.StatusNoShow)
+}
+
+func (h *BookingHandler) transitionBooking(c *fiber.Ctx, status types.BookingStatus) error {
+	booking, err := h.transition(c, status)
+	if err != nil {
+		return err
+	}
+	return c.JSON(booking)
+}
+
+// transition moves the booking of the path to status.
+func (h *BookingHandler) transition(c *fiber.Ctx, status types.BookingStatus) (*types.Booking, error) {
+	bookingID := c.Params("id")
+	if len(bookingID) == 0 {
+		return nil, types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	return h.bookStore.TransitionBooking(c.Context(), bookingID, status)
+}
+
+// folio totals the folio of booking in the currency it was priced in.
+func (h *BookingHandler) folio(ctx context.Context, booking *types.Booking) (*types.Folio, error) {
+	hotel, err := h.hotelStore.GetHotelByID(ctx, booking.HotelID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := h.folioStore.GetFolioItems(ctx, booking.ID)
+	if err != nil {
+		return nil, err
+	}
+	folio, err := types.NewFolio(booking.ID, booking.PriceCurrency(hotel), items)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return folio, nil
 }
 
 func (h *BookingHandler) HandleDeleteBooking(c *fiber.Ctx) error {
@@ -154,3 +607,202 @@ func (h *BookingHandler) HandleDeleteBooking(c *fiber.Ctx) error {
 	}
 	return c.JSON(types.MsgDeleted{Deleted: bookingID})
 }
+
+// HandlePostGroupBooking books every room of the request for the same stay
+// as one group, either all of them or none. Each booking is authorized on
+// the payment method on its own; if any is declined the authorizations
+// taken are voided and every booking of the group is cancelled.
+func (h *BookingHandler) HandlePostGroupBooking(c *fiber.Ctx) error {
+	var params types.CreateGroupBookingParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := params.Validate(); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := (types.PaymentParams{PaymentMethod: params.PaymentMethod}).Validate(); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	bookings := make([]*types.Booking, len(params.RoomIDs))
+	for i, roomID := range params.RoomIDs {
+		room, err := h.roomStore.GetRoom(c.Context(), roomID)
+		if err != nil {
+			return err
+		}
+		hotel, err := h.hotelStore.GetHotelByID(c.Context(), room.HotelID)
+		if err != nil {
+			return err
+		}
+		booking, err := types.NewBookingFromParams(params.BookingParams(), user.ID, hotel, roomID)
+		if err != nil {
+			return types.ErrInvalidParams(err)
+		}
+		if err := room.Admits(booking.Guests); err != nil {
+			return err
+		}
+		if err := h.priceBooking(c, booking, room, hotel, params.BookingParams()); err != nil {
+			return err
+		}
+		booking.AwaitPayment()
+		bookings[i] = booking
+	}
+	inserted, err := h.bookStore.InsertBookingGroup(c.Context(), bookings)
+	if err != nil {
+		return err
+	}
+	authorized := []*types.Payment{}
+	for _, booking := range inserted {
+		payment, err := h.payments.Authorize(c.Context(), booking, params.PaymentMethod)
+		if err != nil {
+			return undoFailed(err, h.abortGroup(c.Context(), inserted, authorized))
+		}
+		authorized = append(authorized, payment)
+	}
+	confirmed := make([]*types.Booking, len(inserted))
+	for i, booking := range inserted {
+		confirmed[i], err = h.bookStore.TransitionBooking(c.Context(), booking.ID, types.StatusConfirmed)
+		if err != nil {
+			return undoFailed(err, h.abortGroup(c.Context(), inserted, authorized))
+		}
+	}
+	return c.JSON(types.NewBookingGroup(confirmed))
+}
+
+// abortGroup voids the authorized payments of a group booking that could
+// not be paid or confirmed and cancels its bookings, whether pending or
+// confirmed already. It goes on after a failure to free as much as it can.
+func (h *BookingHandler) abortGroup(ctx context.Context, bookings []*types.Booking, authorized []*types.Payment) error {
+	err := h.voidPayments(ctx, authorized...)
+	for _, booking := range bookings {
+		if _, cancelErr := h.bookStore.TransitionBooking(ctx, booking.ID, types.StatusCancelled); cancelErr != nil {
+			err = errors.Join(err, cancelErr)
+		}
+	}
+	return err
+}
+
+func (h *BookingHandler) HandleGetGroupBooking(c *fiber.Ctx) error {
+	group, _, err := h.ownGroup(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(group)
+}
+
+// HandleCancelGroupBooking cancels every booking of a group not cancelled
+// yet, each with the fee of its hotel's cancellation policy. Every booking
+// is checked and then cancelled at once, so nothing is cancelled when any
+// of them can not be.
+func (h *BookingHandler) HandleCancelGroupBooking(c *fiber.Ctx) error {
+	group, user, err := h.ownGroup(c)
+	if err != nil {
+		return err
+	}
+	active := group.Active()
+	if len(active) == 0 {
+		return types.ErrInvalidTransition(fmt.Errorf("group %s is already cancelled", group.ID))
+	}
+	cancelled := make([]*types.Booking, len(active))
+	for i, booking := range active {
+		cancelled[i], err = h.cancellation(c.Context(), booking, user, types.CancelBookingParams{})
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := h.bookStore.CancelBookingGroup(c.Context(), active, cancelled); err != nil {
+		return err
+	}
+	cancellations := make([]*types.Cancellation, len(active))
+	for i, booking := range active {
+		cancellations[i], err = h.settleCancellation(c.Context(), booking, cancelled[i], user)
+		if err != nil {
+			return err
+		}
+	}
+	return c.JSON(types.MsgGroupCancelled{Cancelled: group.ID, Cancellations: cancellations})
+}
+
+// HandleModifyGroupBooking moves every booking of a group not cancelled to
+// new dates, each in its own room and priced again, authorizing new totals
+// as HandleModifyBooking does. Either every booking is moved or none is.
+func (h *BookingHandler) HandleModifyGroupBooking(c *fiber.Ctx) error {
+	group, user, err := h.ownGroup(c)
+	if err != nil {
+		return err
+	}
+	var params types.ModifyGroupBookingParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := params.Validate(); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	active := group.Active()
+	if len(active) == 0 {
+		return types.ErrBookingNotModifiable(fmt.Errorf("group %s is cancelled", group.ID))
+	}
+	modified := make([]*types.Booking, len(active))
+	for i, booking := range active {
+		modified[i], err = h.modification(c.Context(), booking, user, types.ModifyBookingParams{
+			FromDate: params.FromDate,
+			ToDate:   params.ToDate,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	authorized := []*types.Payment{}
+	replaced := []*types.Payment{}
+	for i, booking := range active {
+		payment, previous, err := h.payments.Reauthorize(c.Context(), booking, modified[i], params.PaymentMethod)
+		if err != nil {
+			return undoFailed(err, h.voidPayments(c.Context(), authorized...))
+		}
+		if payment != nil {
+			authorized = append(authorized, payment)
+		}
+		replaced = append(replaced, previous...)
+	}
+	if _, err := h.bookStore.ModifyBookingGroup(c.Context(), active, modified); err != nil {
+		return undoFailed(err, h.voidPayments(c.Context(), authorized...))
+	}
+	if err := h.voidPayments(c.Context(), replaced...); err != nil {
+		return err
+	}
+	bookings, err := h.bookStore.GetBookingsByGroup(c.Context(), group.ID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(types.NewBookingGroup(bookings))
+}
+
+// ownGroup returns the group booking of the path if the context user may
+// access every booking of it, see types.User.CanAccess.
+func (h *BookingHandler) ownGroup(c *fiber.Ctx) (*types.BookingGroup, types.User, error) {
+	groupID := c.Params("id")
+	if len(groupID) == 0 {
+		return nil, types.User{}, types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return nil, types.User{}, types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	bookings, err := h.bookStore.GetBookingsByGroup(c.Context(), groupID)
+	if err != nil {
+		return nil, user, err
+	}
+	if len(bookings) == 0 {
+		return nil, user, types.ErrNotFound(fmt.Errorf("group %s not found", groupID))
+	}
+	group := types.NewBookingGroup(bookings)
+	for _, booking := range bookings {
+		if !user.CanAccess(booking, types.PermManageBookings) {
+			return nil, user, types.ErrUnauthorized(fmt.Errorf("unauthorized access to group of different user"))
+		}
+	}
+	return group, user, nil
+}
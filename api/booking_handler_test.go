@@ -7,20 +7,29 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/invoicing"
+	"github.com/jucaza1/hotel-reserv/payments"
+	"github.com/jucaza1/hotel-reserv/pricing"
+	"github.com/jucaza1/hotel-reserv/promos"
 	"github.com/jucaza1/hotel-reserv/types"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type bookingTestDB struct {
 	db.HotelStore
 	db.RoomStore
 	db.BookingStore
+	db.RatePlanStore
+	db.PaymentStore
+	db.FolioStore
+	db.UserStore
+	db.InvoiceStore
+	db.PromoStore
 }
 
 func (tdb *bookingTestDB) bookingTeardown(t *testing.T) {
@@ -33,26 +42,80 @@ func (tdb *bookingTestDB) bookingTeardown(t *testing.T) {
 	if err := tdb.HotelStore.Drop(context.TODO()); err != nil {
 		t.Fatal(err)
 	}
+	if err := tdb.RatePlanStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tdb.PaymentStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tdb.FolioStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tdb.UserStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tdb.InvoiceStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tdb.PromoStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func bookingSetup(t *testing.T) *bookingTestDB {
-	injectENV(t)
-	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(db.DBURI))
+	store := newTestStore(t)
+	return &bookingTestDB{
+		HotelStore:    store.Hotel,
+		RoomStore:     store.Room,
+		BookingStore:  store.Booking,
+		RatePlanStore: store.RatePlan,
+		PaymentStore:  store.Payment,
+		FolioStore:    store.Folio,
+		UserStore:     store.User,
+		InvoiceStore:  store.Invoice,
+		PromoStore:    store.Promo,
+	}
+}
+
+// testPaymentMethod is accepted by the fake payment provider.
+const testPaymentMethod = "fake-card"
+
+func newTestPayments(t *testing.T, ps db.PaymentStore, fs db.FolioStore) *payments.Service {
+	provider, err := payments.NewFakeProvider("")
 	if err != nil {
 		t.Fatal(err)
 	}
-	return &bookingTestDB{
-		HotelStore:   db.NewMongoHotelStore(client, db.TestDBNAME),
-		RoomStore:    db.NewMongoRoomStore(client, db.TestDBNAME, db.NewMongoHotelStore(client, db.TestDBNAME)),
-		BookingStore: db.NewMongoBookingStore(client, db.TestDBNAME),
+	return payments.NewService(provider, ps, fs)
+}
+
+func (tdb *bookingTestDB) newTestInvoices() *invoicing.Service {
+	return invoicing.NewService(tdb.InvoiceStore, tdb.HotelStore, tdb.RoomStore, tdb.UserStore, tdb.FolioStore)
+}
+
+func (tdb *bookingTestDB) newTestPromos() *promos.Service {
+	return promos.NewService(tdb.PromoStore)
+}
+
+func seedTestUser(t *testing.T, us db.UserStore, email string) *types.User {
+	user, err := us.InsertUser(context.Background(), &types.User{
+		Firstname: "testname",
+		Lastname:  "testlast",
+		Email:     email,
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
+	return user
 }
 
 func seedTestRoom(t *testing.T, tdb db.RoomStore, hotelID string) (roomID string) {
-	params := types.CreateRoomParams{
-		Price: 100,
+	return seedTestRoomWith(t, tdb, hotelID, types.CreateRoomParams{
+		Price: eur(100),
 		Size:  types.Normal,
-	}
+	})
+}
+
+func seedTestRoomWith(t *testing.T, tdb db.RoomStore, hotelID string, params types.CreateRoomParams) (roomID string) {
 	room := types.NewRoomFromParams(params)
 	room.HotelID = hotelID
 	insertedRoom, err := tdb.InsertRoom(context.Background(), room)
@@ -67,7 +130,7 @@ func TestHandleGetBookingsByRoomAsAdmin(t *testing.T) {
 	defer tdb.bookingTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
 	userAdmin := types.User{
 		ID:               "0000",
 		Firstname:        "testname",
@@ -88,12 +151,13 @@ func TestHandleGetBookingsByRoomAsAdmin(t *testing.T) {
 
 	hotelID := seedTestHotel(t, tdb.HotelStore)
 	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
 	params := [2]types.CreateBookingParams{
 		{
 			FromDate: time.Now().Add(time.Hour * 24),
 			ToDate:   time.Now().Add(time.Hour * 72),
 		}, {
-			FromDate: time.Now().Add(time.Hour * 84),
+			FromDate: time.Now().Add(time.Hour * 96),
 			ToDate:   time.Now().Add(time.Hour * 108),
 		},
 	}
@@ -110,7 +174,7 @@ func TestHandleGetBookingsByRoomAsAdmin(t *testing.T) {
 	)
 
 	for i, param := range params {
-		newBookingNoAdmin[i], err = types.NewBookingFromParams(param, userNoAdmin.ID, hotelID, roomID)
+		newBookingNoAdmin[i], err = types.NewBookingFromParams(param, userNoAdmin.ID, hotel, roomID)
 		if err != nil {
 			t.Error(err)
 		}
@@ -119,7 +183,7 @@ func TestHandleGetBookingsByRoomAsAdmin(t *testing.T) {
 			t.Error(err)
 		}
 	}
-	newBookingAdmin, err = types.NewBookingFromParams(paramsAdmin, userAdmin.ID, hotelID, roomID)
+	newBookingAdmin, err = types.NewBookingFromParams(paramsAdmin, userAdmin.ID, hotel, roomID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -150,7 +214,7 @@ func TestHandleGetBookingsByRoomNoAdmin(t *testing.T) {
 	defer tdb.bookingTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
 	userAdmin := types.User{
 		ID:               "0000",
 		Firstname:        "testname",
@@ -171,12 +235,13 @@ func TestHandleGetBookingsByRoomNoAdmin(t *testing.T) {
 
 	hotelID := seedTestHotel(t, tdb.HotelStore)
 	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
 	params := [2]types.CreateBookingParams{
 		{
 			FromDate: time.Now().Add(time.Hour * 24),
 			ToDate:   time.Now().Add(time.Hour * 72),
 		}, {
-			FromDate: time.Now().Add(time.Hour * 84),
+			FromDate: time.Now().Add(time.Hour * 96),
 			ToDate:   time.Now().Add(time.Hour * 108),
 		},
 	}
@@ -192,7 +257,7 @@ func TestHandleGetBookingsByRoomNoAdmin(t *testing.T) {
 	)
 
 	for i, param := range params {
-		newBookingNoAdmin[i], err = types.NewBookingFromParams(param, userNoAdmin.ID, hotelID, roomID)
+		newBookingNoAdmin[i], err = types.NewBookingFromParams(param, userNoAdmin.ID, hotel, roomID)
 		if err != nil {
 			t.Error(err)
 		}
@@ -201,7 +266,7 @@ func TestHandleGetBookingsByRoomNoAdmin(t *testing.T) {
 			t.Error(err)
 		}
 	}
-	newBookingAdmin, err = types.NewBookingFromParams(paramsAdmin, userAdmin.ID, hotelID, roomID)
+	newBookingAdmin, err = types.NewBookingFromParams(paramsAdmin, userAdmin.ID, hotel, roomID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -235,7 +300,7 @@ func TestHandleGetBookingsByHotelAsAdmin(t *testing.T) {
 	defer tdb.bookingTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
 	userAdmin := types.User{
 		ID:               "0000",
 		Firstname:        "testname",
@@ -256,12 +321,13 @@ func TestHandleGetBookingsByHotelAsAdmin(t *testing.T) {
 
 	hotelID := seedTestHotel(t, tdb.HotelStore)
 	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
 	params := [2]types.CreateBookingParams{
 		{
 			FromDate: time.Now().Add(time.Hour * 24),
 			ToDate:   time.Now().Add(time.Hour * 72),
 		}, {
-			FromDate: time.Now().Add(time.Hour * 84),
+			FromDate: time.Now().Add(time.Hour * 96),
 			ToDate:   time.Now().Add(time.Hour * 108),
 		},
 	}
@@ -278,7 +344,7 @@ func TestHandleGetBookingsByHotelAsAdmin(t *testing.T) {
 	)
 
 	for i, param := range params {
-		newBookingNoAdmin[i], err = types.NewBookingFromParams(param, userNoAdmin.ID, hotelID, roomID)
+		newBookingNoAdmin[i], err = types.NewBookingFromParams(param, userNoAdmin.ID, hotel, roomID)
 		if err != nil {
 			t.Error(err)
 		}
@@ -287,7 +353,7 @@ func TestHandleGetBookingsByHotelAsAdmin(t *testing.T) {
 			t.Error(err)
 		}
 	}
-	newBookingAdmin, err = types.NewBookingFromParams(paramsAdmin, userAdmin.ID, hotelID, roomID)
+	newBookingAdmin, err = types.NewBookingFromParams(paramsAdmin, userAdmin.ID, hotel, roomID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -318,7 +384,7 @@ func TestHandleGetBookingsByHotelNoAdmin(t *testing.T) {
 	defer tdb.bookingTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
 	userAdmin := types.User{
 		ID:               "0000",
 		Firstname:        "testname",
@@ -339,12 +405,13 @@ func TestHandleGetBookingsByHotelNoAdmin(t *testing.T) {
 
 	hotelID := seedTestHotel(t, tdb.HotelStore)
 	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
 	params := [2]types.CreateBookingParams{
 		{
 			FromDate: time.Now().Add(time.Hour * 24),
 			ToDate:   time.Now().Add(time.Hour * 72),
 		}, {
-			FromDate: time.Now().Add(time.Hour * 84),
+			FromDate: time.Now().Add(time.Hour * 96),
 			ToDate:   time.Now().Add(time.Hour * 108),
 		},
 	}
@@ -360,7 +427,7 @@ func TestHandleGetBookingsByHotelNoAdmin(t *testing.T) {
 	)
 
 	for i, param := range params {
-		newBookingNoAdmin[i], err = types.NewBookingFromParams(param, userNoAdmin.ID, hotelID, roomID)
+		newBookingNoAdmin[i], err = types.NewBookingFromParams(param, userNoAdmin.ID, hotel, roomID)
 		if err != nil {
 			t.Error(err)
 		}
@@ -369,7 +436,7 @@ func TestHandleGetBookingsByHotelNoAdmin(t *testing.T) {
 			t.Error(err)
 		}
 	}
-	newBookingAdmin, err = types.NewBookingFromParams(paramsAdmin, userAdmin.ID, hotelID, roomID)
+	newBookingAdmin, err = types.NewBookingFromParams(paramsAdmin, userAdmin.ID, hotel, roomID)
 	if err != nil {
 		t.Error(err)
 	}
@@ -403,7 +470,7 @@ func TestHandleCancelBooking(t *testing.T) {
 	defer tdb.bookingTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
 	user := types.User{
 		ID:               "0000",
 		Firstname:        "testname",
@@ -416,12 +483,13 @@ func TestHandleCancelBooking(t *testing.T) {
 
 	hotelID := seedTestHotel(t, tdb.HotelStore)
 	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
 	params := [2]types.CreateBookingParams{
 		{
 			FromDate: time.Now().Add(time.Hour * 24),
 			ToDate:   time.Now().Add(time.Hour * 72),
 		}, {
-			FromDate: time.Now().Add(time.Hour * 84),
+			FromDate: time.Now().Add(time.Hour * 96),
 			ToDate:   time.Now().Add(time.Hour * 108),
 		},
 	}
@@ -432,7 +500,7 @@ func TestHandleCancelBooking(t *testing.T) {
 	)
 
 	for i, param := range params {
-		newBooking[i], err = types.NewBookingFromParams(param, user.ID, hotelID, roomID)
+		newBooking[i], err = types.NewBookingFromParams(param, user.ID, hotel, roomID)
 		if err != nil {
 			t.Error(err)
 		}
@@ -475,6 +543,459 @@ func TestHandleCancelBooking(t *testing.T) {
 	if have[1].Cancelled != true {
 		t.Errorf("expected second booking to be cancelled")
 	}
+	if have[0].Status != types.StatusConfirmed || have[1].Status != types.StatusCancelled {
+		t.Errorf("expected statuses confirmed and cancelled but got %s and %s", have[0].Status, have[1].Status)
+	}
+}
+
+func TestHandleCancelBookingPolicy(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	guest := types.User{ID: "0000", Email: "test@mail.com"}
+	admin := types.User{ID: "0001", Email: "admin@mail.com", IsAdmin: true}
+	pricer := pricing.NewEngine(tdb.RatePlanStore)
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricer, newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	app.Post("/rooms/:id/bookings", provideContextUser(guest), bookingHandler.HandlePostBooking)
+	app.Patch("/bookings/:id", provideContextUser(guest), bookingHandler.HandleCancelBooking)
+	adminApp := NewFiberAppCentralErr()
+	adminApp.Patch("/bookings/:id", provideContextUser(admin), bookingHandler.HandleCancelBooking)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	if err := tdb.UpdateHotel(context.Background(), hotelID, map[string]any{
+		"taxRate": 10.0,
+		"cancellationPolicy": types.CancellationPolicy{
+			Rules: []types.CancellationRule{{DaysBefore: 7, FeePercent: 50}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	day := func(days int) time.Time {
+		return time.Now().AddDate(0, 0, days)
+	}
+	// the first night of the stay from day 4 is sold at a non refundable rate
+	if _, err := tdb.InsertRatePlan(context.Background(), types.NewRatePlanFromParams(types.CreateRatePlanParams{
+		Name:          "Saver",
+		FromDate:      day(4).Format(time.DateOnly),
+		ToDate:        day(4).Format(time.DateOnly),
+		NonRefundable: true,
+	}, hotelID)); err != nil {
+		t.Fatal(err)
+	}
+	book := func(from, to time.Time) string {
+		b, err := json.Marshal(types.CreateBookingParams{FromDate: from, ToDate: to, PaymentMethod: testPaymentMethod})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", fmt.Sprintf("/rooms/%s/bookings", roomID), bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var booking types.Booking
+		if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+			t.Fatal(err)
+		}
+		return booking.ID
+	}
+	cancel := func(app *fiber.App, bookingID string, params *types.CancelBookingParams, status int) *types.Cancellation {
+		var body bytes.Buffer
+		if params != nil {
+			if err := json.NewEncoder(&body).Encode(params); err != nil {
+				t.Fatal(err)
+			}
+		}
+		req := httptest.NewRequest("PATCH", fmt.Sprintf("/bookings/%s", bookingID), &body)
+		req.Header.Add("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("status code expected %d but got %d", status, resp.StatusCode)
+		}
+		var msg types.MsgCancelled
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg.Cancellation
+	}
+
+	tests := []struct {
+		name      string
+		bookingID string
+		fee       types.Money
+		refund    types.Money
+	}{
+		{"free before the policy applies", book(day(20), day(22)), eur(0), eur(220)},
+		{"fee within the policy days", book(day(2), day(4)), eur(110), eur(110)},
+		{"non refundable night in full", book(day(4), day(6)), eur(165), eur(55)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			have := cancel(app, tt.bookingID, nil, http.StatusOK)
+			if have.Fee != tt.fee || have.Refund != tt.refund || have.Overridden {
+				t.Errorf("expected fee %s and refund %s but got %+v", tt.fee, tt.refund, have)
+			}
+			stored, err := tdb.GetBookingByID(context.Background(), tt.bookingID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != types.StatusCancelled || stored.Cancellation == nil || stored.Cancellation.Fee != tt.fee {
+				t.Errorf("expected the cancellation to be stored but got %s %+v", stored.Status, stored.Cancellation)
+			}
+		})
+	}
+
+	bookingID := book(day(6), day(7))
+	goodwill := &types.CancelBookingParams{Fee: &types.Money{Amount: 0, Currency: "EUR"}, Reason: "goodwill"}
+	cancel(app, bookingID, goodwill, http.StatusUnauthorized)
+	cancel(adminApp, bookingID, &types.CancelBookingParams{Fee: goodwill.Fee}, http.StatusBadRequest)
+	cancel(adminApp, bookingID, &types.CancelBookingParams{Fee: &types.Money{Amount: 20000, Currency: "EUR"}, Reason: "x"}, http.StatusBadRequest)
+	have := cancel(adminApp, bookingID, goodwill, http.StatusOK)
+	if have.Fee != eur(0) || have.Refund != eur(110) || have.PolicyFee != eur(55) || !have.Overridden || have.Reason != "goodwill" {
+		t.Errorf("expected the admin fee to replace the policy fee but got %+v", have)
+	}
+}
+
+func TestHandleBookingLifecycle(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	staff := types.User{ID: "0001", Email: "admin@mail.com", IsAdmin: true}
+	app.Post("/bookings/:id/check-in", provideContextUser(staff), bookingHandler.HandleCheckIn)
+	app.Post("/bookings/:id/check-out", provideContextUser(staff), bookingHandler.HandleCheckOut)
+	app.Post("/bookings/:id/no-show", provideContextUser(staff), bookingHandler.HandleNoShow)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	guest := seedTestUser(t, tdb.UserStore, "test@mail.com")
+	insert := func(fromDate time.Time, nights []string) string {
+		booking, err := tdb.InsertBooking(context.Background(), &types.Booking{
+			UserID:   guest.ID,
+			HotelID:  hotelID,
+			RoomID:   roomID,
+			FromDate: fromDate,
+			ToDate:   fromDate.AddDate(0, 0, len(nights)),
+			Nights:   nights,
+			Status:   types.StatusConfirmed,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return booking.ID
+	}
+	post := func(path string, expectedStatus int) *types.Booking {
+		resp, err := app.Test(httptest.NewRequest("POST", path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("%s: status code expected %d but got %d", path, expectedStatus, resp.StatusCode)
+		}
+		var booking types.Booking
+		json.NewDecoder(resp.Body).Decode(&booking)
+		return &booking
+	}
+
+	arriving := insert(time.Now().Add(time.Hour), []string{"2100-01-01", "2100-01-02"})
+	late := insert(time.Now().Add(-time.Hour), []string{"2100-02-01"})
+	future := insert(time.Now().AddDate(0, 0, 10), []string{"2100-03-01"})
+
+	post(fmt.Sprintf("/bookings/%s/check-out", arriving), http.StatusConflict)
+	post(fmt.Sprintf("/bookings/%s/check-in", future), http.StatusConflict)
+	post(fmt.Sprintf("/bookings/%s/no-show", future), http.StatusConflict)
+	if have := post(fmt.Sprintf("/bookings/%s/check-in", arriving), http.StatusOK); have.Status != types.StatusCheckedIn {
+		t.Errorf("expected status %s but got %s", types.StatusCheckedIn, have.Status)
+	}
+	if _, err := tdb.TransitionBooking(context.Background(), arriving, types.StatusCancelled); err == nil {
+		t.Errorf("expected a checked-in booking not to be cancellable")
+	}
+	have := post(fmt.Sprintf("/bookings/%s/check-out", arriving), http.StatusOK)
+	if have.Status != types.StatusCheckedOut || len(have.StatusHistory) != 2 {
+		t.Errorf("expected status %s after 2 changes but got %s after %d",
+			types.StatusCheckedOut, have.Status, len(have.StatusHistory))
+	}
+
+	if have := post(fmt.Sprintf("/bookings/%s/no-show", late), http.StatusOK); have.Status != types.StatusNoShow {
+		t.Errorf("expected status %s but got %s", types.StatusNoShow, have.Status)
+	}
+	available, err := tdb.IsRoomAvailable(context.Background(), roomID, []string{"2100-02-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !available {
+		t.Errorf("expected a no-show to release the room nights")
+	}
+}
+
+func TestHandleModifyBooking(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	user := types.User{
+		ID:               "0000",
+		Firstname:        "testname",
+		Lastname:         "testlast",
+		Email:            "test@mail.com",
+		EncyptedPassword: "0",
+		IsAdmin:          false,
+	}
+	otherUser := types.User{
+		ID:               "0002",
+		Firstname:        "testname2",
+		Lastname:         "testlast2",
+		Email:            "test2@mail.com",
+		EncyptedPassword: "0",
+		IsAdmin:          false,
+	}
+	app.Put("/bookings/:id", provideContextUser(user), bookingHandler.HandleModifyBooking)
+	app.Put("/other/bookings/:id", provideContextUser(otherUser), bookingHandler.HandleModifyBooking)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	otherRoomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	day := func(d int) time.Time {
+		return time.Date(2100, time.March, d, 0, 0, 0, 0, time.UTC)
+	}
+	insert := func(userID string, from, to int) *types.Booking {
+		booking, err := types.NewBookingFromParams(types.CreateBookingParams{FromDate: day(from), ToDate: day(to)}, userID, hotel, roomID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if booking, err = tdb.InsertBooking(context.Background(), booking); err != nil {
+			t.Fatal(err)
+		}
+		return booking
+	}
+	mine := insert(user.ID, 10, 12)
+	insert(otherUser.ID, 14, 16)
+	put := func(path string, params types.ModifyBookingParams, expectedStatus int) *types.Booking {
+		b, err := json.Marshal(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("PUT", path, bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != expectedStatus {
+			t.Errorf("%+v: status code expected %d but got %d", params, expectedStatus, resp.StatusCode)
+		}
+		var booking types.Booking
+		json.NewDecoder(resp.Body).Decode(&booking)
+		return &booking
+	}
+	free := func(roomID string, nights ...string) bool {
+		available, err := tdb.IsRoomAvailable(context.Background(), roomID, nights)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return available
+	}
+	path := fmt.Sprintf("/bookings/%s", mine.ID)
+
+	put(fmt.Sprintf("/other/bookings/%s", mine.ID), types.ModifyBookingParams{FromDate: day(20), ToDate: day(22)}, http.StatusUnauthorized)
+
+	// overlapping its own nights is fine
+	have := put(path, types.ModifyBookingParams{FromDate: day(11), ToDate: day(14)}, http.StatusOK)
+	if len(have.Nights) != 3 || have.Nights[0] != "2100-03-11" || have.Price.Nights != 3 {
+		t.Errorf("expected 3 priced nights from 2100-03-11 but got %v", have.Nights)
+	}
+	if len(have.Modifications) != 1 || have.Modifications[0].Nights[0] != "2100-03-10" {
+		t.Errorf("expected the previous stay to be kept but got %+v", have.Modifications)
+	}
+	if !free(roomID, "2100-03-10") || free(roomID, "2100-03-13") {
+		t.Errorf("expected the dropped night to be released and the added one taken")
+	}
+
+	// overlapping another booking is not, and leaves the booking untouched
+	put(path, types.ModifyBookingParams{FromDate: day(13), ToDate: day(15)}, http.StatusUnprocessableEntity)
+	stored, err := tdb.GetBookingByID(context.Background(), mine.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Nights) != 3 || stored.Nights[0] != "2100-03-11" || free(roomID, "2100-03-11", "2100-03-12", "2100-03-13") {
+		t.Errorf("expected the failed modification to keep the booked nights but got %v", stored.Nights)
+	}
+
+	have = put(path, types.ModifyBookingParams{RoomID: otherRoomID, FromDate: day(14), ToDate: day(16)}, http.StatusOK)
+	if have.RoomID != otherRoomID || len(have.Modifications) != 2 {
+		t.Errorf("expected the booking to move to room %s but got %s", otherRoomID, have.RoomID)
+	}
+	if !free(roomID, "2100-03-11", "2100-03-12", "2100-03-13") || free(otherRoomID, "2100-03-14", "2100-03-15") {
+		t.Errorf("expected the nights to move to the new room")
+	}
+
+	// staff move bookings only within the hotels they manage
+	staff := types.User{ID: "0003", Email: "staff@mail.com"}
+	if err := staff.Grant(types.RoleParams{Role: types.RoleFrontDesk, HotelIDs: []string{hotelID}}); err != nil {
+		t.Fatal(err)
+	}
+	app.Put("/staff/bookings/:id", provideContextUser(staff), bookingHandler.HandleModifyBooking)
+	foreignRoomID := seedTestRoom(t, tdb.RoomStore, seedTestHotel(t, tdb.HotelStore))
+	staffPath := fmt.Sprintf("/staff/bookings/%s", mine.ID)
+	put(staffPath, types.ModifyBookingParams{RoomID: foreignRoomID, FromDate: day(20), ToDate: day(22)}, http.StatusUnauthorized)
+	if !free(foreignRoomID, "2100-03-20", "2100-03-21") {
+		t.Errorf("expected the refused move to leave room %s free", foreignRoomID)
+	}
+	if have := put(staffPath, types.ModifyBookingParams{RoomID: roomID, FromDate: day(20), ToDate: day(22)}, http.StatusOK); have.RoomID != roomID {
+		t.Errorf("expected the booking to move to room %s but got %s", roomID, have.RoomID)
+	}
+}
+
+func TestHandleGroupBooking(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	user := types.User{ID: "0000", Email: "test@mail.com"}
+	otherUser := types.User{ID: "0002", Email: "test2@mail.com"}
+	app.Post("/bookings/group", provideContextUser(user), bookingHandler.HandlePostGroupBooking)
+	app.Get("/bookings/group/:id", provideContextUser(user), bookingHandler.HandleGetGroupBooking)
+	app.Patch("/bookings/group/:id", provideContextUser(user), bookingHandler.HandleCancelGroupBooking)
+	app.Put("/bookings/group/:id", provideContextUser(user), bookingHandler.HandleModifyGroupBooking)
+	app.Get("/other/bookings/group/:id", provideContextUser(otherUser), bookingHandler.HandleGetGroupBooking)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	staff := types.User{ID: "0003", Email: "staff@mail.com"}
+	if err := staff.Grant(types.RoleParams{Role: types.RoleFrontDesk, HotelIDs: []string{hotelID}}); err != nil {
+		t.Fatal(err)
+	}
+	app.Get("/staff/bookings/group/:id", provideContextUser(staff), bookingHandler.HandleGetGroupBooking)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
+	roomIDs := []string{
+		seedTestRoom(t, tdb.RoomStore, hotelID),
+		seedTestRoom(t, tdb.RoomStore, hotelID),
+		seedTestRoom(t, tdb.RoomStore, hotelID),
+	}
+	day := func(d int) time.Time {
+		return time.Date(2100, time.March, d, 0, 0, 0, 0, time.UTC)
+	}
+	taken, err := types.NewBookingFromParams(types.CreateBookingParams{FromDate: day(11), ToDate: day(12)}, otherUser.ID, hotel, roomIDs[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tdb.InsertBooking(context.Background(), taken); err != nil {
+		t.Fatal(err)
+	}
+	free := func(roomID string, nights ...string) bool {
+		available, err := tdb.IsRoomAvailable(context.Background(), roomID, nights)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return available
+	}
+	request := func(method, path string, params any, expectedStatus int) *http.Response {
+		t.Helper()
+		resp := holdTestRequest(t, app, method, path, params)
+		if resp.StatusCode != expectedStatus {
+			t.Fatalf("%s %s: status code expected %d but got %d", method, path, expectedStatus, resp.StatusCode)
+		}
+		return resp
+	}
+	params := types.CreateGroupBookingParams{
+		RoomIDs:       []string{roomIDs[0], roomIDs[0]},
+		FromDate:      day(10),
+		ToDate:        day(12),
+		PaymentMethod: testPaymentMethod,
+	}
+	request("POST", "/bookings/group", params, http.StatusBadRequest)
+
+	// a busy room fails the whole group
+	params.RoomIDs = roomIDs
+	request("POST", "/bookings/group", params, http.StatusUnprocessableEntity)
+	if !free(roomIDs[0], "2100-03-10", "2100-03-11") || !free(roomIDs[1], "2100-03-10", "2100-03-11") {
+		t.Fatalf("expected the failed group to leave every room free")
+	}
+
+	// a declined payment cancels every booking of the group
+	params.RoomIDs = roomIDs[:2]
+	params.PaymentMethod = payments.FakeDeclinedMethod
+	request("POST", "/bookings/group", params, http.StatusPaymentRequired)
+	if !free(roomIDs[0], "2100-03-10", "2100-03-11") || !free(roomIDs[1], "2100-03-10", "2100-03-11") {
+		t.Fatalf("expected the declined group to leave every room free")
+	}
+
+	params.PaymentMethod = testPaymentMethod
+	var group types.BookingGroup
+	if err := json.NewDecoder(request("POST", "/bookings/group", params, http.StatusOK).Body).Decode(&group); err != nil {
+		t.Fatal(err)
+	}
+	if len(group.ID) == 0 || len(group.Bookings) != 2 {
+		t.Fatalf("expected a group of 2 bookings but got %+v", group)
+	}
+	for i, booking := range group.Bookings {
+		if booking.GroupID != group.ID || booking.RoomID != roomIDs[i] || booking.Status != types.StatusConfirmed {
+			t.Fatalf("expected a confirmed booking of room %s in group %s but got %+v", roomIDs[i], group.ID, booking)
+		}
+		authorized, err := tdb.GetPaymentsByBooking(context.Background(), booking.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(authorized) != 1 || authorized[0].Status != types.PaymentAuthorized || authorized[0].Amount != booking.Price.Total {
+			t.Fatalf("expected the total of booking %s authorized but got %+v", booking.ID, authorized)
+		}
+	}
+	path := fmt.Sprintf("/bookings/group/%s", group.ID)
+	request("GET", path, nil, http.StatusOK)
+	request("GET", "/other"+path, nil, http.StatusUnauthorized)
+	request("GET", "/staff"+path, nil, http.StatusOK)
+	request("GET", fmt.Sprintf("/bookings/group/%s", roomIDs[0]), nil, http.StatusNotFound)
+
+	// moving onto a busy night moves no booking
+	request("PUT", path, types.ModifyGroupBookingParams{FromDate: day(11), ToDate: day(13)}, http.StatusOK)
+	taken, err = types.NewBookingFromParams(types.CreateBookingParams{FromDate: day(14), ToDate: day(15)}, otherUser.ID, hotel, roomIDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tdb.InsertBooking(context.Background(), taken); err != nil {
+		t.Fatal(err)
+	}
+	request("PUT", path, types.ModifyGroupBookingParams{FromDate: day(13), ToDate: day(15)}, http.StatusUnprocessableEntity)
+	for _, roomID := range roomIDs[:2] {
+		if free(roomID, "2100-03-11") || free(roomID, "2100-03-12") || !free(roomID, "2100-03-10", "2100-03-13") {
+			t.Fatalf("expected room %s to keep the nights of the group", roomID)
+		}
+	}
+
+	var cancelled types.MsgGroupCancelled
+	if err := json.NewDecoder(request("PATCH", path, nil, http.StatusOK).Body).Decode(&cancelled); err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Cancelled != group.ID || len(cancelled.Cancellations) != 2 {
+		t.Fatalf("expected both bookings cancelled but got %+v", cancelled)
+	}
+	if !free(roomIDs[0], "2100-03-11", "2100-03-12") || !free(roomIDs[1], "2100-03-11", "2100-03-12") {
+		t.Fatalf("expected the cancelled group to free its rooms")
+	}
+	request("PATCH", path, nil, http.StatusConflict)
+	request("PUT", path, types.ModifyGroupBookingParams{FromDate: day(20), ToDate: day(22)}, http.StatusConflict)
+
+	// a booking that can not be cancelled keeps every booking of the group
+	params.FromDate, params.ToDate = day(20), day(22)
+	if err := json.NewDecoder(request("POST", "/bookings/group", params, http.StatusOK).Body).Decode(&group); err != nil {
+		t.Fatal(err)
+	}
+	invoice := &types.Invoice{HotelID: hotelID, Year: 2100, BookingID: group.Bookings[1].ID}
+	if _, err := tdb.InsertInvoice(context.Background(), invoice); err != nil {
+		t.Fatal(err)
+	}
+	request("PATCH", fmt.Sprintf("/bookings/group/%s", group.ID), nil, http.StatusConflict)
+	if free(roomIDs[0], "2100-03-20", "2100-03-21") {
+		t.Fatalf("expected the group to keep the nights of room %s", roomIDs[0])
+	}
 }
 
 func TestHandleDeleteBooking(t *testing.T) {
@@ -482,7 +1003,7 @@ func TestHandleDeleteBooking(t *testing.T) {
 	defer tdb.bookingTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
 	user := types.User{
 		ID:               "0000",
 		Firstname:        "testname",
@@ -495,12 +1016,13 @@ func TestHandleDeleteBooking(t *testing.T) {
 
 	hotelID := seedTestHotel(t, tdb.HotelStore)
 	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
 	params := [2]types.CreateBookingParams{
 		{
 			FromDate: time.Now().Add(time.Hour * 24),
 			ToDate:   time.Now().Add(time.Hour * 72),
 		}, {
-			FromDate: time.Now().Add(time.Hour * 84),
+			FromDate: time.Now().Add(time.Hour * 96),
 			ToDate:   time.Now().Add(time.Hour * 108),
 		},
 	}
@@ -511,7 +1033,7 @@ func TestHandleDeleteBooking(t *testing.T) {
 	)
 
 	for i, param := range params {
-		newBooking[i], err = types.NewBookingFromParams(param, user.ID, hotelID, roomID)
+		newBooking[i], err = types.NewBookingFromParams(param, user.ID, hotel, roomID)
 		if err != nil {
 			t.Error(err)
 		}
@@ -552,7 +1074,7 @@ func TestHandlePostBookingsSuccess(t *testing.T) {
 	defer tdb.bookingTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
 	user := types.User{
 		ID:               "0000",
 		Firstname:        "testname",
@@ -565,6 +1087,7 @@ func TestHandlePostBookingsSuccess(t *testing.T) {
 
 	hotelID := seedTestHotel(t, tdb.HotelStore)
 	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
 	params := [2]types.CreateBookingParams{
 		{
 			FromDate: time.Now().Add(time.Hour * 24 * 20),
@@ -580,7 +1103,7 @@ func TestHandlePostBookingsSuccess(t *testing.T) {
 		insertedBooking [2]*types.Booking
 	)
 	for i, param := range params {
-		newBooking[i], err = types.NewBookingFromParams(param, user.ID, hotelID, roomID)
+		newBooking[i], err = types.NewBookingFromParams(param, user.ID, hotel, roomID)
 		if err != nil {
 			t.Error(err)
 		}
@@ -590,8 +1113,9 @@ func TestHandlePostBookingsSuccess(t *testing.T) {
 		}
 	}
 	postBookingParams := types.CreateBookingParams{
-		FromDate: time.Now().Add(time.Hour * 24 * 15),
-		ToDate:   time.Now().Add(time.Hour * 24 * 18),
+		PaymentMethod: testPaymentMethod,
+		FromDate:      time.Now().Add(time.Hour * 24 * 15),
+		ToDate:        time.Now().Add(time.Hour * 24 * 18),
 	}
 
 	b, err := json.Marshal(postBookingParams)
@@ -612,19 +1136,47 @@ func TestHandlePostBookingsSuccess(t *testing.T) {
 	if err := json.NewDecoder(resp.Body).Decode(&have); err != nil {
 		t.Error(err)
 	}
-	expected, err := types.NewBookingFromParams(postBookingParams, user.ID, hotelID, roomID)
+	expected, err := types.NewBookingFromParams(postBookingParams, user.ID, hotel, roomID)
 	if err != nil {
 		t.Error(err)
 	}
 	compareBooking(t, expected, &have)
 }
 
+func TestHandlePostBookingsFailureTooLong(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	user := types.User{ID: "0000", Email: "test@mail.com"}
+	app.Post("/rooms/:id/bookings", provideContextUser(user), bookingHandler.HandlePostBooking)
+	roomID := seedTestRoom(t, tdb.RoomStore, seedTestHotel(t, tdb.HotelStore))
+
+	from := time.Now().AddDate(0, 0, 10)
+	resp := holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/bookings", roomID), types.CreateBookingParams{
+		FromDate:      from,
+		ToDate:        from.AddDate(50, 0, 0),
+		PaymentMethod: testPaymentMethod,
+	})
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status code expected %d but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	bookings, err := tdb.GetBookingsByUser(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookings) != 0 {
+		t.Fatalf("expected no booking but got %d", len(bookings))
+	}
+}
+
 func TestHandlePostBookingsFailureDate(t *testing.T) {
 	tdb := bookingSetup(t)
 	defer tdb.bookingTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
 	user := types.User{
 		ID:               "0000",
 		Firstname:        "testname",
@@ -637,6 +1189,7 @@ func TestHandlePostBookingsFailureDate(t *testing.T) {
 
 	hotelID := seedTestHotel(t, tdb.HotelStore)
 	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
 	params := [2]types.CreateBookingParams{
 		{
 			FromDate: time.Now().Add(time.Hour * 24 * 20),
@@ -652,7 +1205,7 @@ func TestHandlePostBookingsFailureDate(t *testing.T) {
 		insertedBooking [2]*types.Booking
 	)
 	for i, param := range params {
-		newBooking[i], err = types.NewBookingFromParams(param, user.ID, hotelID, roomID)
+		newBooking[i], err = types.NewBookingFromParams(param, user.ID, hotel, roomID)
 		if err != nil {
 			t.Error(err)
 		}
@@ -662,8 +1215,9 @@ func TestHandlePostBookingsFailureDate(t *testing.T) {
 		}
 	}
 	postBookingParams := types.CreateBookingParams{
-		FromDate: time.Now().Add(time.Hour * 24 * 24),
-		ToDate:   time.Now().Add(time.Hour * 24 * 28),
+		PaymentMethod: testPaymentMethod,
+		FromDate:      time.Now().Add(time.Hour * 24 * 24),
+		ToDate:        time.Now().Add(time.Hour * 24 * 28),
 	}
 
 	b, err := json.Marshal(postBookingParams)
@@ -690,10 +1244,653 @@ func TestHandlePostBookingsFailureDate(t *testing.T) {
 
 }
 
-func provideContextUser(u types.User) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		c.Context().SetUserValue("user", u)
-		return c.Next()
+func TestHandlePostBookingBackToBack(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	user := types.User{
+		ID:               "0000",
+		Firstname:        "testname",
+		Lastname:         "testlast",
+		Email:            "test@mail.com",
+		EncyptedPassword: "0",
+		IsAdmin:          false,
+	}
+	app.Post("/rooms/:id/bookings", provideContextUser(user), bookingHandler.HandlePostBooking)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
+	checkOut := time.Now().AddDate(0, 0, 25)
+	booking, err := types.NewBookingFromParams(types.CreateBookingParams{
+		FromDate: time.Now().AddDate(0, 0, 20),
+		ToDate:   checkOut,
+	}, user.ID, hotel, roomID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tdb.BookingStore.InsertBooking(context.Background(), booking); err != nil {
+		t.Fatal(err)
+	}
+	postBookingParams := types.CreateBookingParams{
+		PaymentMethod: testPaymentMethod,
+		FromDate:      checkOut,
+		ToDate:        checkOut.AddDate(0, 0, 2),
+	}
+	b, err := json.Marshal(postBookingParams)
+	if err != nil {
+		t.Error(err)
+	}
+	reqUri := fmt.Sprintf("/rooms/%s/bookings", roomID)
+	req := httptest.NewRequest("POST", reqUri, bytes.NewReader(b))
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Error(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var have types.Booking
+	if err := json.NewDecoder(resp.Body).Decode(&have); err != nil {
+		t.Error(err)
+	}
+	expectedNights := []string{
+		checkOut.Format(time.DateOnly),
+		checkOut.AddDate(0, 0, 1).Format(time.DateOnly),
+	}
+	if fmt.Sprint(have.Nights) != fmt.Sprint(expectedNights) {
+		t.Errorf("expected booking nights %v but got %v", expectedNights, have.Nights)
+	}
+}
+
+func TestHandlePostBookingHotelSchedule(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	user := types.User{
+		ID:               "0000",
+		Firstname:        "testname",
+		Lastname:         "testlast",
+		Email:            "test@mail.com",
+		EncyptedPassword: "0",
+		IsAdmin:          false,
+	}
+	app.Post("/rooms/:id/bookings", provideContextUser(user), bookingHandler.HandlePostBooking)
+
+	hotel, err := types.NewHotelFromParams(types.CreateHotelParams{
+		Name:         "hoteltest",
+		Location:     "landtest",
+		Rating:       4,
+		TimeZone:     "America/New_York",
+		CheckInTime:  "14:00",
+		CheckOutTime: "10:30",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hotel, err = tdb.HotelStore.InsertHotel(context.Background(), hotel); err != nil {
+		t.Fatal(err)
+	}
+	roomID := seedTestRoom(t, tdb.RoomStore, hotel.ID)
+	// written as midnight in UTC+14, the day must still be read as the 10th
+	checkIn := time.Date(2100, time.March, 10, 0, 0, 0, 0, time.FixedZone("UTC+14", 14*3600))
+	postBookingParams := types.CreateBookingParams{
+		PaymentMethod: testPaymentMethod,
+		FromDate:      checkIn,
+		ToDate:        checkIn.AddDate(0, 0, 3),
+	}
+	b, err := json.Marshal(postBookingParams)
+	if err != nil {
+		t.Error(err)
+	}
+	reqUri := fmt.Sprintf("/rooms/%s/bookings", roomID)
+	req := httptest.NewRequest("POST", reqUri, bytes.NewReader(b))
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Error(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var have types.Booking
+	if err := json.NewDecoder(resp.Body).Decode(&have); err != nil {
+		t.Error(err)
+	}
+	newYork, _ := time.LoadLocation("America/New_York")
+	expectedFrom := time.Date(2100, time.March, 10, 14, 0, 0, 0, newYork)
+	expectedTo := time.Date(2100, time.March, 13, 10, 30, 0, 0, newYork)
+	if !have.FromDate.Equal(expectedFrom) {
+		t.Errorf("expected booking fromDate %s but got %s", expectedFrom, have.FromDate)
+	}
+	if !have.ToDate.Equal(expectedTo) {
+		t.Errorf("expected booking toDate %s but got %s", expectedTo, have.ToDate)
+	}
+	if len(have.Nights) != 3 || have.Nights[0] != "2100-03-10" {
+		t.Errorf("expected 3 nights from 2100-03-10 but got %v", have.Nights)
+	}
+}
+
+func TestHandlePostBookingPrice(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	user := types.User{
+		ID:               "0000",
+		Firstname:        "testname",
+		Lastname:         "testlast",
+		Email:            "test@mail.com",
+		EncyptedPassword: "0",
+		IsAdmin:          false,
+	}
+	app.Post("/rooms/:id/bookings", provideContextUser(user), bookingHandler.HandlePostBooking)
+
+	hotel, err := types.NewHotelFromParams(types.CreateHotelParams{
+		Name:     "hoteltest",
+		Location: "landtest",
+		Rating:   4,
+		Currency: "USD",
+		TaxRate:  10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hotel, err = tdb.HotelStore.InsertHotel(context.Background(), hotel); err != nil {
+		t.Fatal(err)
+	}
+	room, err := tdb.RoomStore.InsertRoom(context.Background(), &types.Room{
+		HotelID: hotel.ID,
+		Size:    types.Normal,
+		Price:   types.MoneyFromFloat(100, "USD"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roomID := room.ID
+	postBookingParams := types.CreateBookingParams{
+		PaymentMethod: testPaymentMethod,
+		FromDate:      time.Now().AddDate(0, 0, 5),
+		ToDate:        time.Now().AddDate(0, 0, 8),
+	}
+	b, err := json.Marshal(postBookingParams)
+	if err != nil {
+		t.Error(err)
+	}
+	reqUri := fmt.Sprintf("/rooms/%s/bookings", roomID)
+	req := httptest.NewRequest("POST", reqUri, bytes.NewReader(b))
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Error(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var have types.Booking
+	if err := json.NewDecoder(resp.Body).Decode(&have); err != nil {
+		t.Error(err)
+	}
+	expected := types.PriceBreakdown{
+		NightlyRate: types.MoneyFromFloat(100, "USD"),
+		Nights:      3,
+		Subtotal:    types.MoneyFromFloat(300, "USD"),
+		Taxes:       types.MoneyFromFloat(30, "USD"),
+		Total:       types.MoneyFromFloat(330, "USD"),
+		Currency:    "USD",
+	}
+	comparePrice(t, expected, have.Price)
+	if len(have.Price.NightPrices) != 3 {
+		t.Errorf("expected 3 night prices but got %d", len(have.Price.NightPrices))
+	}
+	stored, err := tdb.BookingStore.GetBookingByID(context.Background(), have.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comparePrice(t, expected, stored.Price)
+}
+
+func TestHandlePostBookingOccupancy(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	user := types.User{ID: "0000", Email: "test@mail.com"}
+	app.Post("/rooms/:id/bookings", provideContextUser(user), bookingHandler.HandlePostBooking)
+	app.Post("/rooms/:id/quote", provideContextUser(user), bookingHandler.HandlePostQuote)
+	app.Put("/bookings/:id", provideContextUser(user), bookingHandler.HandleModifyBooking)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	smallRoomID := seedTestRoomWith(t, tdb.RoomStore, hotelID, types.CreateRoomParams{Price: eur(100), Size: types.Small})
+	familyRoomID := seedTestRoomWith(t, tdb.RoomStore, hotelID, types.CreateRoomParams{
+		Price:          eur(100),
+		Size:           types.Normal,
+		Occupancy:      types.Occupancy{MaxAdults: 3, MaxTotal: 4},
+		Beds:           []types.Bed{{Type: types.BedDouble, Count: 1}, {Type: types.BedSingle, Count: 2}},
+		IncludedGuests: 2,
+		ExtraAdult:     eur(30),
+		ExtraChild:     eur(10),
+	})
+	stay := func(guests types.Guests, from int) types.CreateBookingParams {
+		return types.CreateBookingParams{
+			FromDate:      time.Now().AddDate(0, 0, from),
+			ToDate:        time.Now().AddDate(0, 0, from+2),
+			Guests:        guests,
+			PaymentMethod: testPaymentMethod,
+		}
+	}
+	tests := []struct {
+		roomID   string
+		path     string
+		guests   types.Guests
+		status   int
+		subtotal types.Money
+	}{
+		// the small room takes its limits from its size
+		{smallRoomID, "quote", types.Guests{Adults: 6}, http.StatusUnprocessableEntity, types.Money{}},
+		{smallRoomID, "bookings", types.Guests{Adults: 2, ChildAges: []int{4}}, http.StatusUnprocessableEntity, types.Money{}},
+		{smallRoomID, "quote", types.Guests{Adults: 2}, http.StatusOK, eur(200)},
+		{familyRoomID, "quote", types.Guests{Adults: 4}, http.StatusUnprocessableEntity, types.Money{}},
+		{familyRoomID, "quote", types.Guests{Adults: 1, ChildAges: []int{3, 5, 7}}, http.StatusUnprocessableEntity, types.Money{}},
+		{familyRoomID, "quote", types.Guests{Adults: 3, ChildAges: []int{3, 5}}, http.StatusUnprocessableEntity, types.Money{}},
+		// guests beyond the two included pay their extra price every night
+		{familyRoomID, "quote", types.Guests{Adults: 2}, http.StatusOK, eur(200)},
+		{familyRoomID, "quote", types.Guests{Adults: 1, ChildAges: []int{3, 5}}, http.StatusOK, eur(220)},
+		{familyRoomID, "quote", types.Guests{Adults: 3, ChildAges: []int{5}}, http.StatusOK, eur(280)},
+	}
+	for _, tt := range tests {
+		resp := holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/%s", tt.roomID, tt.path), stay(tt.guests, 10))
+		if resp.StatusCode != tt.status {
+			t.Errorf("%+v: status code expected %d but got %d", tt.guests, tt.status, resp.StatusCode)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var quote types.Quote
+		if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
+			t.Fatal(err)
+		}
+		if quote.Price.Subtotal != tt.subtotal {
+			t.Errorf("%+v: expected a subtotal of %s but got %s", tt.guests, tt.subtotal, quote.Price.Subtotal)
+		}
+	}
+
+	// moving a booking to a room its guests do not fit in is refused
+	resp := holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/bookings", familyRoomID), stay(types.Guests{Adults: 3}, 20))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var booking types.Booking
+	if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+		t.Fatal(err)
+	}
+	if booking.Price.Subtotal != eur(260) {
+		t.Fatalf("expected a subtotal of %s but got %s", eur(260), booking.Price.Subtotal)
+	}
+	resp = holdTestRequest(t, app, "PUT", fmt.Sprintf("/bookings/%s", booking.ID), types.ModifyBookingParams{
+		RoomID:   smallRoomID,
+		FromDate: time.Now().AddDate(0, 0, 30),
+		ToDate:   time.Now().AddDate(0, 0, 32),
+	})
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("status code expected 422 but got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlePostBookingTaxes(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	user := types.User{ID: "0000", Email: "test@mail.com"}
+	app.Post("/rooms/:id/bookings", provideContextUser(user), bookingHandler.HandlePostBooking)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoomWith(t, tdb.RoomStore, hotelID, types.CreateRoomParams{Price: eur(100), Size: types.Large})
+	if err := tdb.UpdateHotel(context.Background(), hotelID, map[string]any{
+		"taxRate": 10.0,
+		"touristTax": types.TouristTax{
+			Amount:         eur(2.5),
+			MaxNights:      2,
+			ExemptUnderAge: 12,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	from := 5
+	book := func(guests types.Guests, status int) types.Booking {
+		t.Helper()
+		from += 3
+		resp := holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/bookings", roomID), types.CreateBookingParams{
+			FromDate:      time.Now().AddDate(0, 0, from),
+			ToDate:        time.Now().AddDate(0, 0, from+3),
+			Guests:        guests,
+			PaymentMethod: testPaymentMethod,
+		})
+		if resp.StatusCode != status {
+			t.Fatalf("status code expected %d but got %d", status, resp.StatusCode)
+		}
+		var booking types.Booking
+		if status == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return booking
+	}
+
+	// 2 adults and a child of 14 pay the tourist tax for 2 of the 3 nights,
+	// the child of 5 is exempt
+	have := book(types.Guests{Adults: 2, ChildAges: []int{5, 14}}, http.StatusOK)
+	comparePrice(t, types.PriceBreakdown{
+		NightlyRate: eur(100),
+		Nights:      3,
+		Subtotal:    eur(300),
+		Taxes:       eur(45),
+		Total:       eur(345),
+		Currency:    "EUR",
+	}, have.Price)
+	if len(have.Price.TaxLines) != 2 {
+		t.Fatalf("expected VAT and tourist tax but got %+v", have.Price.TaxLines)
+	}
+	vat, tourist := have.Price.TaxLines[0], have.Price.TaxLines[1]
+	if vat.Kind != types.TaxVAT || vat.Rate != 10 || vat.Amount != eur(30) {
+		t.Errorf("expected VAT of %s but got %+v", eur(30), vat)
+	}
+	if tourist.Kind != types.TaxTourist || tourist.Guests != 3 || tourist.Nights != 2 || tourist.Amount != eur(15) {
+		t.Errorf("expected tourist tax of %s for 3 guests and 2 nights but got %+v", eur(15), tourist)
+	}
+	if have.Guests.Adults != 2 || len(have.Guests.ChildAges) != 2 {
+		t.Errorf("expected the guests stored but got %+v", have.Guests)
+	}
+
+	// without guests a single adult stays
+	have = book(types.Guests{}, http.StatusOK)
+	if have.Guests.Adults != 1 || have.Price.Taxes != eur(35) {
+		t.Errorf("expected a single adult taxed %s but got %+v taxed %s", eur(35), have.Guests, have.Price.Taxes)
+	}
+
+	book(types.Guests{ChildAges: []int{8}}, http.StatusBadRequest)
+	book(types.Guests{Adults: 1, ChildAges: []int{18}}, http.StatusBadRequest)
+}
+
+func TestHandlePostBookingRatePlans(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	user := types.User{
+		ID:               "0000",
+		Firstname:        "testname",
+		Lastname:         "testlast",
+		Email:            "test@mail.com",
+		EncyptedPassword: "0",
+		IsAdmin:          false,
+	}
+	app.Post("/rooms/:id/bookings", provideContextUser(user), bookingHandler.HandlePostBooking)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	plans := []types.CreateRatePlanParams{
+		{
+			Name:               "Weekend",
+			WeekdayMultipliers: map[string]float64{"friday": 1.5, "saturday": 1.5},
+		}, {
+			RoomID:        roomID,
+			Name:          "Spring",
+			FromDate:      "2100-03-11",
+			ToDate:        "2100-03-12",
+			BaseRate:      eur(120),
+			StayDiscounts: []types.StayDiscount{{MinNights: 4, Percent: 10}},
+			Priority:      1,
+		},
+	}
+	for _, params := range plans {
+		if _, err := tdb.RatePlanStore.InsertRatePlan(context.Background(), types.NewRatePlanFromParams(params, hotelID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// wednesday to sunday: the spring plan prices thursday and friday, the
+	// weekend plan the rest
+	checkIn := time.Date(2100, time.March, 10, 0, 0, 0, 0, time.UTC)
+	postBookingParams := types.CreateBookingParams{
+		PaymentMethod: testPaymentMethod,
+		FromDate:      checkIn,
+		ToDate:        checkIn.AddDate(0, 0, 4),
+	}
+	b, err := json.Marshal(postBookingParams)
+	if err != nil {
+		t.Error(err)
+	}
+	reqUri := fmt.Sprintf("/rooms/%s/bookings", roomID)
+	req := httptest.NewRequest("POST", reqUri, bytes.NewReader(b))
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Error(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var have types.Booking
+	if err := json.NewDecoder(resp.Body).Decode(&have); err != nil {
+		t.Error(err)
+	}
+	comparePrice(t, types.PriceBreakdown{
+		NightlyRate: eur(116.5),
+		Nights:      4,
+		Subtotal:    eur(466),
+		Taxes:       eur(0),
+		Total:       eur(466),
+		Currency:    "EUR",
+	}, have.Price)
+	expectedAmounts := []float64{100, 108, 108, 150}
+	if len(have.Price.NightPrices) != len(expectedAmounts) {
+		t.Fatalf("expected %d night prices but got %d", len(expectedAmounts), len(have.Price.NightPrices))
+	}
+	for i, amount := range expectedAmounts {
+		if have.Price.NightPrices[i].Amount != eur(amount) {
+			t.Errorf("expected night %s to cost %.2f but got %v",
+				have.Price.NightPrices[i].Night, amount, have.Price.NightPrices[i].Amount)
+		}
+	}
+	if len(have.Price.Discounts) != 1 || have.Price.Discounts[0].Amount != eur(24) {
+		t.Errorf("expected a single discount of 24 but got %+v", have.Price.Discounts)
+	}
+}
+
+func TestHandlePostQuote(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+	t.Setenv("JWT_SECRET", "quotesecret")
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	user := types.User{
+		ID:               "0000",
+		Firstname:        "testname",
+		Lastname:         "testlast",
+		Email:            "test@mail.com",
+		EncyptedPassword: "0",
+		IsAdmin:          false,
+	}
+	app.Post("/rooms/:id/quote", provideContextUser(user), bookingHandler.HandlePostQuote)
+	app.Post("/rooms/:id/bookings", provideContextUser(user), bookingHandler.HandlePostBooking)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	checkIn := time.Now().AddDate(0, 0, 10)
+	post := func(path string, params types.CreateBookingParams) *http.Response {
+		b, err := json.Marshal(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", fmt.Sprintf("/rooms/%s/%s", roomID, path), bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := post("quote", types.CreateBookingParams{FromDate: checkIn, ToDate: checkIn.AddDate(0, 0, 2)})
+	if resp.StatusCode != 200 {
+		t.Fatalf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var quote types.Quote
+	if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
+		t.Fatal(err)
+	}
+	if quote.Price.Total != eur(200) || len(quote.Price.NightPrices) != 2 || len(quote.Token) == 0 {
+		t.Errorf("expected a 2 night quote of 200 with a token but got %+v", quote)
+	}
+	bookings, err := tdb.GetBookingsByRoom(context.Background(), roomID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookings) != 0 {
+		t.Errorf("expected quoting not to book but got %d bookings", len(bookings))
+	}
+
+	// the quoted price holds even though the rate goes up before booking
+	if _, err := tdb.InsertRatePlan(context.Background(), types.NewRatePlanFromParams(types.CreateRatePlanParams{
+		Name:     "Peak",
+		BaseRate: eur(500),
+	}, hotelID)); err != nil {
+		t.Fatal(err)
+	}
+	resp = post("bookings", types.CreateBookingParams{
+		PaymentMethod: testPaymentMethod,
+		FromDate:      checkIn,
+		ToDate:        checkIn.AddDate(0, 0, 3),
+		QuoteToken:    quote.Token,
+	})
+	if resp.StatusCode != 400 {
+		t.Errorf("status code expected 400 for a quote of other dates but got %d", resp.StatusCode)
+	}
+	resp = post("bookings", types.CreateBookingParams{
+		PaymentMethod: testPaymentMethod,
+		FromDate:      checkIn,
+		ToDate:        checkIn.AddDate(0, 0, 2),
+		QuoteToken:    quote.Token,
+	})
+	if resp.StatusCode != 200 {
+		t.Fatalf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var booking types.Booking
+	if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+		t.Fatal(err)
+	}
+	comparePrice(t, quote.Price, booking.Price)
+
+	resp = post("quote", types.CreateBookingParams{FromDate: checkIn, ToDate: checkIn.AddDate(0, 0, 2)})
+	if resp.StatusCode != 422 {
+		t.Errorf("status code expected 422 for a booked room but got %d", resp.StatusCode)
+	}
+}
+
+func TestHandlePostBookingConcurrent(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	user := types.User{
+		ID:               "0000",
+		Firstname:        "testname",
+		Lastname:         "testlast",
+		Email:            "test@mail.com",
+		EncyptedPassword: "0",
+		IsAdmin:          false,
+	}
+	app.Post("/rooms/:id/bookings", provideContextUser(user), bookingHandler.HandlePostBooking)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	postBookingParams := types.CreateBookingParams{
+		PaymentMethod: testPaymentMethod,
+		FromDate:      time.Now().Add(time.Hour * 24 * 10),
+		ToDate:        time.Now().Add(time.Hour * 24 * 12),
+	}
+	b, err := json.Marshal(postBookingParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const requests = 20
+	var (
+		wg       sync.WaitGroup
+		statuses = make(chan int, requests)
+		reqUri   = fmt.Sprintf("/rooms/%s/bookings", roomID)
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", reqUri, bytes.NewReader(b))
+			req.Header.Add("Content-Type", "application/json")
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	var created, rejected int
+	for status := range statuses {
+		switch status {
+		case http.StatusOK:
+			created++
+		case http.StatusUnprocessableEntity:
+			rejected++
+		default:
+			t.Errorf("unexpected status code %d", status)
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected exactly 1 booking to be created but got %d", created)
+	}
+	if rejected != requests-1 {
+		t.Errorf("expected %d bookings to be rejected but got %d", requests-1, rejected)
+	}
+	have, err := tdb.GetBookingsByRoom(context.Background(), roomID)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(have) != 1 {
+		t.Errorf("expected the room to have 1 booking but got %d", len(have))
+	}
+}
+
+func provideContextUser(u types.User) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Context().SetUserValue("user", u)
+		return c.Next()
+	}
+}
+func eur(amount float64) types.Money {
+	return types.MoneyFromFloat(amount, "EUR")
+}
+func comparePrice(t *testing.T, expected types.PriceBreakdown, have types.PriceBreakdown) {
+	if have.NightlyRate != expected.NightlyRate || have.Nights != expected.Nights ||
+		have.Subtotal != expected.Subtotal || have.Taxes != expected.Taxes ||
+		have.Total != expected.Total || have.Currency != expected.Currency {
+		t.Errorf("expected booking price %+v but got %+v", expected, have)
 	}
 }
 func compareBookingWithID(t *testing.T, expected *types.Booking, have *types.Booking) {
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type FolioHandler struct {
+	folioStore db.FolioStore
+	bookings   *BookingHandler
+}
+
+func NewFolioHandler(fs db.FolioStore, bookings *BookingHandler) *FolioHandler {
+	return &FolioHandler{
+		folioStore: fs,
+		bookings:   bookings,
+	}
+}
+
+// HandleGetFolio returns the folio of a booking to its user, or to staff
+// allowed to view the bookings of its hotel, admins included.
+func (h *FolioHandler) HandleGetFolio(c *fiber.Ctx) error {
+	bookingID := c.Params("id")
+	if len(bookingID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	booking, err := h.bookings.bookStore.GetBookingByID(c.Context(), bookingID)
+	if err != nil {
+		return err
+	}
+	if !user.CanAccess(booking, types.PermViewBookings) {
+		return types.ErrUnauthorized(fmt.Errorf("unauthorized access to folio of different user"))
+	}
+	folio, err := h.bookings.folio(c.Context(), booking)
+	if err != nil {
+		return err
+	}
+	return c.JSON(folio)
+}
+
+// HandlePostFolioItem posts an extra or a front desk payment to the folio of
+// the booking of the path, in the currency of the booking.
+func (h *FolioHandler) HandlePostFolioItem(c *fiber.Ctx) error {
+	bookingID := c.Params("id")
+	if len(bookingID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	var params types.PostFolioItemParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	booking, err := h.openBooking(c, bookingID)
+	if err != nil {
+		return err
+	}
+	hotel, err := h.bookings.hotelStore.GetHotelByID(c.Context(), booking.HotelID)
+	if err != nil {
+		return err
+	}
+	currency := booking.PriceCurrency(hotel)
+	amount := params.Amount.OrCurrency(currency)
+	if amount.Currency != currency {
+		return types.ErrInvalidParams(fmt.Errorf("amount should be in %s", currency))
+	}
+	item := types.NewFolioItem(bookingID, params.Type, params.Description, amount, user.ID)
+	if _, err := h.folioStore.InsertFolioItems(c.Context(), item); err != nil {
+		return err
+	}
+	return c.JSON(item)
+}
+
+// HandleVoidFolioItem voids an item of an open folio. Payments and refunds
+// of the payment provider are not voided, but refunded through it.
+func (h *FolioHandler) HandleVoidFolioItem(c *fiber.Ctx) error {
+	itemID := c.Params("id")
+	if len(itemID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	var params types.VoidFolioItemParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := params.Validate(); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	item, err := h.folioStore.GetFolioItem(c.Context(), itemID)
+	if err != nil {
+		return err
+	}
+	if item.PaymentID != "" {
+		return types.ErrInvalidPaymentOperation(fmt.Errorf("folio item %s belongs to payment %s", itemID, item.PaymentID))
+	}
+	if _, err := h.openBooking(c, item.BookingID); err != nil {
+		return err
+	}
+	voided, err := h.folioStore.VoidFolioItem(c.Context(), itemID, types.FolioVoid{
+		At:     time.Now(),
+		UserID: user.ID,
+		Reason: params.Reason,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(voided)
+}
+
+// openBooking returns the booking of bookingID if its folio is open.
+func (h *FolioHandler) openBooking(c *fiber.Ctx, bookingID string) (*types.Booking, error) {
+	booking, err := h.bookings.bookStore.GetBookingByID(c.Context(), bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if !booking.FolioOpen() {
+		return nil, types.ErrBookingNotModifiable(fmt.Errorf("folio of %s booking %s is closed", booking.Status, bookingID))
+	}
+	return booking, nil
+}
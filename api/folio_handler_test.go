@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/pricing"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+func TestHandleFolio(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	guest := *seedTestUser(t, tdb.UserStore, "test@mail.com")
+	admin := types.User{ID: "0001", Email: "admin@mail.com", IsAdmin: true}
+	service := newTestPayments(t, tdb.PaymentStore, tdb.FolioStore)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), service, tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	folioHandler := NewFolioHandler(tdb.FolioStore, bookingHandler)
+	app := NewFiberAppCentralErr()
+	app.Get("/bookings/:id/folio", provideContextUser(guest), folioHandler.HandleGetFolio)
+	adminApp := NewFiberAppCentralErr()
+	adminApp.Post("/bookings/:id/check-in", provideContextUser(admin), bookingHandler.HandleCheckIn)
+	adminApp.Post("/bookings/:id/check-out", provideContextUser(admin), bookingHandler.HandleCheckOut)
+	adminApp.Post("/bookings/:id/folio", provideContextUser(admin), folioHandler.HandlePostFolioItem)
+	adminApp.Post("/folio/:id/void", provideContextUser(admin), folioHandler.HandleVoidFolioItem)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	if err := tdb.UpdateHotel(context.Background(), hotelID, map[string]any{"taxRate": 10.0}); err != nil {
+		t.Fatal(err)
+	}
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
+	nights := []string{"2100-01-01", "2100-01-02"}
+	price, err := types.NewPriceBreakdown([]types.NightPrice{
+		types.NewNightPrice(nights[0], eur(100), 0, ""),
+		types.NewNightPrice(nights[1], eur(100), 0, ""),
+	}, nil, hotel, types.Guests{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	booking, err := tdb.InsertBooking(context.Background(), &types.Booking{
+		UserID:   guest.ID,
+		HotelID:  hotelID,
+		RoomID:   roomID,
+		FromDate: time.Now().Add(time.Hour),
+		ToDate:   time.Now().AddDate(0, 0, 2),
+		Nights:   nights,
+		Price:    price,
+		Status:   types.StatusConfirmed,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := service.Authorize(context.Background(), booking, testPaymentMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getFolio := func() *types.Folio {
+		resp := holdTestRequest(t, app, "GET", fmt.Sprintf("/bookings/%s/folio", booking.ID), nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status code expected %d but got %d", http.StatusOK, resp.StatusCode)
+		}
+		var folio types.Folio
+		if err := json.NewDecoder(resp.Body).Decode(&folio); err != nil {
+			t.Fatal(err)
+		}
+		return &folio
+	}
+	expectStatus := func(resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Fatalf("status code expected %d but got %d", status, resp.StatusCode)
+		}
+	}
+	checkOut := fmt.Sprintf("/bookings/%s/check-out", booking.ID)
+	postItem := fmt.Sprintf("/bookings/%s/folio", booking.ID)
+
+	// checking in charges the nights and taxes
+	expectStatus(holdTestRequest(t, adminApp, "POST", fmt.Sprintf("/bookings/%s/check-in", booking.ID), nil), http.StatusOK)
+	folio := getFolio()
+	if len(folio.Items) != 3 || folio.Charges != eur(220) || folio.Balance != eur(220) {
+		t.Fatalf("expected 2 nights and taxes charging %s but got %+v", eur(220), folio)
+	}
+
+	// extras
+	expectStatus(holdTestRequest(t, adminApp, "POST", postItem, types.PostFolioItemParams{
+		Type: types.FolioRoom, Description: "Room upgrade", Amount: eur(50),
+	}), http.StatusBadRequest)
+	resp := holdTestRequest(t, adminApp, "POST", postItem, types.PostFolioItemParams{
+		Type: types.FolioExtra, Description: "Minibar", Amount: eur(15),
+	})
+	expectStatus(resp, http.StatusOK)
+	var minibar types.FolioItem
+	if err := json.NewDecoder(resp.Body).Decode(&minibar); err != nil {
+		t.Fatal(err)
+	}
+	if minibar.PostedBy != admin.ID {
+		t.Fatalf("expected the item posted by %s but got %s", admin.ID, minibar.PostedBy)
+	}
+	if have := getFolio().Balance; have != eur(235) {
+		t.Fatalf("expected balance %s but got %s", eur(235), have)
+	}
+	expectStatus(holdTestRequest(t, adminApp, "POST", checkOut, nil), http.StatusConflict)
+
+	// the captured payment is posted to the folio and can not be voided there
+	if _, err := service.Capture(context.Background(), payment, eur(220)); err != nil {
+		t.Fatal(err)
+	}
+	folio = getFolio()
+	if folio.Payments != eur(220) || folio.Balance != eur(15) {
+		t.Fatalf("expected %s paid and %s due but got %+v", eur(220), eur(15), folio)
+	}
+	paymentItem := folio.Items[len(folio.Items)-1]
+	expectStatus(holdTestRequest(t, adminApp, "POST", fmt.Sprintf("/folio/%s/void", paymentItem.ID), types.VoidFolioItemParams{Reason: "mistake"}), http.StatusConflict)
+
+	// voiding the extra settles the folio
+	voidMinibar := fmt.Sprintf("/folio/%s/void", minibar.ID)
+	expectStatus(holdTestRequest(t, adminApp, "POST", voidMinibar, types.VoidFolioItemParams{}), http.StatusBadRequest)
+	expectStatus(holdTestRequest(t, adminApp, "POST", voidMinibar, types.VoidFolioItemParams{Reason: "not consumed"}), http.StatusOK)
+	expectStatus(holdTestRequest(t, adminApp, "POST", voidMinibar, types.VoidFolioItemParams{Reason: "not consumed"}), http.StatusConflict)
+	if folio := getFolio(); !folio.Settled() {
+		t.Fatalf("expected a settled folio but got balance %s", folio.Balance)
+	}
+
+	expectStatus(holdTestRequest(t, adminApp, "POST", checkOut, nil), http.StatusOK)
+	// the folio is closed after check-out
+	expectStatus(holdTestRequest(t, adminApp, "POST", postItem, types.PostFolioItemParams{
+		Type: types.FolioExtra, Description: "Parking", Amount: eur(10),
+	}), http.StatusConflict)
+}
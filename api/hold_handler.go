@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+// DefaultHoldTTL is how long a hold keeps a room when HOLD_TTL is not set.
+const DefaultHoldTTL = 15 * time.Minute
+
+type HoldHandler struct {
+	holdStore db.HoldStore
+	bookings  *BookingHandler
+	ttl       time.Duration
+}
+
+func NewHoldHandler(hs db.HoldStore, bookings *BookingHandler, ttl time.Duration) *HoldHandler {
+	return &HoldHandler{
+		holdStore: hs,
+		bookings:  bookings,
+		ttl:       ttl,
+	}
+}
+
+// HandlePostHold keeps the room of the path for the stay of the body, priced
+// like HandlePostBooking would, until the hold expires.
+func (h *HoldHandler) HandlePostHold(c *fiber.Ctx) error {
+	booking, room, hotel, params, err := h.bookings.bookingFromRequest(c)
+	if err != nil {
+		return err
+	}
+	if err := h.bookings.priceBooking(c, booking, room, hotel, params); err != nil {
+		return err
+	}
+	hold, err := h.holdStore.InsertHold(c.Context(), types.NewHold(booking, h.ttl))
+	if err != nil {
+		return err
+	}
+	return c.JSON(hold)
+}
+
+func (h *HoldHandler) HandleGetHold(c *fiber.Ctx) error {
+	hold, err := h.ownHold(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(hold)
+}
+
+// HandlePostHoldBooking converts a hold into a booking at the held price,
+// once its promo code is redeemed and the price is authorized on the
+// payment method.
+func (h *HoldHandler) HandlePostHoldBooking(c *fiber.Ctx) error {
+	hold, err := h.ownHold(c)
+	if err != nil {
+		return err
+	}
+	var params types.PaymentParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := params.Validate(); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if hold.Expired(time.Now()) {
+		return types.ErrHoldExpired(fmt.Errorf("hold %s expired", hold.ID))
+	}
+	if err := h.bookings.promos.Redeem(c.Context(), hold.Booking()); err != nil {
+		return err
+	}
+	payment, err := h.bookings.payments.Authorize(c.Context(), hold.Booking(), params.PaymentMethod)
+	if err != nil {
+		return undoFailed(err, h.bookings.promos.Release(c.Context(), hold.ID))
+	}
+	booking, err := h.holdStore.ConvertHold(c.Context(), hold.ID)
+	if err != nil {
+		_, voidErr := h.bookings.payments.Void(c.Context(), payment)
+		return undoFailed(err, errors.Join(voidErr, h.bookings.promos.Release(c.Context(), hold.ID)))
+	}
+	return c.JSON(booking)
+}
+
+func (h *HoldHandler) HandleDeleteHold(c *fiber.Ctx) error {
+	hold, err := h.ownHold(c)
+	if err != nil {
+		return err
+	}
+	if err := h.holdStore.ReleaseHold(c.Context(), hold.ID); err != nil {
+		return err
+	}
+	return c.JSON(types.MsgDeleted{Deleted: hold.ID})
+}
+
+// ownHold returns the hold of the path if it belongs to the context user or
+// the user is an admin.
+func (h *HoldHandler) ownHold(c *fiber.Ctx) (*types.Hold, error) {
+	holdID := c.Params("id")
+	if len(holdID) == 0 {
+		return nil, types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return nil, types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	hold, err := h.holdStore.GetHold(c.Context(), holdID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsAdmin && hold.UserID != user.ID {
+		return nil, types.ErrUnauthorized(fmt.Errorf("unauthorized access to hold of different user"))
+	}
+	return hold, nil
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/pricing"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type holdTestDB struct {
+	*bookingTestDB
+	db.HoldStore
+}
+
+func (tdb *holdTestDB) holdTeardown(t *testing.T) {
+	if err := tdb.HoldStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	tdb.bookingTeardown(t)
+}
+
+func holdSetup(t *testing.T) *holdTestDB {
+	store := newTestStore(t)
+	return &holdTestDB{
+		bookingTestDB: &bookingTestDB{
+			HotelStore:    store.Hotel,
+			RoomStore:     store.Room,
+			BookingStore:  store.Booking,
+			RatePlanStore: store.RatePlan,
+			PaymentStore:  store.Payment,
+			FolioStore:    store.Folio,
+			UserStore:     store.User,
+			InvoiceStore:  store.Invoice,
+			PromoStore:    store.Promo,
+		},
+		HoldStore: store.Hold,
+	}
+}
+
+// holdTestApp serves the hold and booking routes for user, holding rooms
+// for ttl.
+func holdTestApp(t *testing.T, tdb *holdTestDB, user types.User, ttl time.Duration) *fiber.App {
+	app := NewFiberAppCentralErr()
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	holdHandler := NewHoldHandler(tdb.HoldStore, bookingHandler, ttl)
+	app.Post("/rooms/:id/holds", provideContextUser(user), holdHandler.HandlePostHold)
+	app.Post("/rooms/:id/bookings", provideContextUser(user), bookingHandler.HandlePostBooking)
+	app.Get("/holds/:id", provideContextUser(user), holdHandler.HandleGetHold)
+	app.Post("/holds/:id/booking", provideContextUser(user), holdHandler.HandlePostHoldBooking)
+	app.Delete("/holds/:id", provideContextUser(user), holdHandler.HandleDeleteHold)
+	return app
+}
+
+func holdTestRequest(t *testing.T, app *fiber.App, method, path string, params any) *http.Response {
+	var body bytes.Buffer
+	if params != nil {
+		if err := json.NewEncoder(&body).Encode(params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestHandlePostHold(t *testing.T) {
+	tdb := holdSetup(t)
+	defer tdb.holdTeardown(t)
+
+	user := types.User{ID: "0000", Email: "test@mail.com"}
+	other := types.User{ID: "0002", Email: "test2@mail.com"}
+	app := holdTestApp(t, tdb, user, time.Hour)
+	otherApp := holdTestApp(t, tdb, other, time.Hour)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	checkIn := time.Now().AddDate(0, 0, 10)
+	params := types.CreateBookingParams{FromDate: checkIn, ToDate: checkIn.AddDate(0, 0, 2), PaymentMethod: testPaymentMethod}
+
+	resp := holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/holds", roomID), params)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var hold types.Hold
+	if err := json.NewDecoder(resp.Body).Decode(&hold); err != nil {
+		t.Fatal(err)
+	}
+	if hold.Price.Total != eur(200) || len(hold.Nights) != 2 {
+		t.Errorf("expected a 2 night hold of 200 but got %+v", hold)
+	}
+	if d := time.Until(hold.ExpiresAt); d <= 0 || d > time.Hour {
+		t.Errorf("expected the hold to expire within an hour but got %s", hold.ExpiresAt)
+	}
+
+	// the held room can be neither booked nor held again
+	resp = holdTestRequest(t, otherApp, "POST", fmt.Sprintf("/rooms/%s/bookings", roomID), params)
+	if resp.StatusCode != 422 {
+		t.Errorf("status code expected 422 for a held room but got %d", resp.StatusCode)
+	}
+	resp = holdTestRequest(t, otherApp, "POST", fmt.Sprintf("/rooms/%s/holds", roomID), params)
+	if resp.StatusCode != 422 {
+		t.Errorf("status code expected 422 for a held room but got %d", resp.StatusCode)
+	}
+
+	resp = holdTestRequest(t, otherApp, "POST", fmt.Sprintf("/holds/%s/booking", hold.ID), types.PaymentParams{PaymentMethod: testPaymentMethod})
+	if resp.StatusCode != 401 {
+		t.Errorf("status code expected 401 converting the hold of another user but got %d", resp.StatusCode)
+	}
+	resp = holdTestRequest(t, app, "POST", fmt.Sprintf("/holds/%s/booking", hold.ID), types.PaymentParams{PaymentMethod: testPaymentMethod})
+	if resp.StatusCode != 200 {
+		t.Fatalf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var booking types.Booking
+	if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+		t.Fatal(err)
+	}
+	if booking.ID != hold.ID || booking.Status != types.StatusConfirmed {
+		t.Errorf("expected confirmed booking %s but got %s %s", hold.ID, booking.ID, booking.Status)
+	}
+	comparePrice(t, hold.Price, booking.Price)
+
+	stored, err := tdb.GetBookingByID(context.Background(), hold.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareBookingWithID(t, &booking, stored)
+	resp = holdTestRequest(t, app, "GET", fmt.Sprintf("/holds/%s", hold.ID), nil)
+	if resp.StatusCode != 404 {
+		t.Errorf("status code expected 404 for a converted hold but got %d", resp.StatusCode)
+	}
+	resp = holdTestRequest(t, otherApp, "POST", fmt.Sprintf("/rooms/%s/bookings", roomID), params)
+	if resp.StatusCode != 422 {
+		t.Errorf("status code expected 422 for a booked room but got %d", resp.StatusCode)
+	}
+}
+
+func TestHandleHoldExpiry(t *testing.T) {
+	tdb := holdSetup(t)
+	defer tdb.holdTeardown(t)
+
+	user := types.User{ID: "0000", Email: "test@mail.com"}
+	app := holdTestApp(t, tdb, user, 50*time.Millisecond)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	checkIn := time.Now().AddDate(0, 0, 10)
+	params := types.CreateBookingParams{FromDate: checkIn, ToDate: checkIn.AddDate(0, 0, 2), PaymentMethod: testPaymentMethod}
+
+	resp := holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/holds", roomID), params)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var hold types.Hold
+	if err := json.NewDecoder(resp.Body).Decode(&hold); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	resp = holdTestRequest(t, app, "POST", fmt.Sprintf("/holds/%s/booking", hold.ID), types.PaymentParams{PaymentMethod: testPaymentMethod})
+	if resp.StatusCode != 410 {
+		t.Errorf("status code expected 410 converting an expired hold but got %d", resp.StatusCode)
+	}
+	// an expired hold stops blocking the room even before it is cleaned up
+	available, err := tdb.IsRoomAvailable(context.Background(), roomID, hold.Nights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !available {
+		t.Error("expected an expired hold not to block the room")
+	}
+
+	resp = holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/holds", roomID), params)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	time.Sleep(100 * time.Millisecond)
+	expired, err := tdb.ExpireHolds(context.Background(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 2 {
+		t.Errorf("expected 2 expired holds but got %d", len(expired))
+	}
+	resp = holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/bookings", roomID), params)
+	if resp.StatusCode != 200 {
+		t.Errorf("status code expected 200 booking the room of expired holds but got %d", resp.StatusCode)
+	}
+}
+
+func TestHandleDeleteHold(t *testing.T) {
+	tdb := holdSetup(t)
+	defer tdb.holdTeardown(t)
+
+	user := types.User{ID: "0000", Email: "test@mail.com"}
+	app := holdTestApp(t, tdb, user, time.Hour)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	checkIn := time.Now().AddDate(0, 0, 10)
+	params := types.CreateBookingParams{FromDate: checkIn, ToDate: checkIn.AddDate(0, 0, 2), PaymentMethod: testPaymentMethod}
+
+	resp := holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/holds", roomID), params)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	var hold types.Hold
+	if err := json.NewDecoder(resp.Body).Decode(&hold); err != nil {
+		t.Fatal(err)
+	}
+	resp = holdTestRequest(t, app, "DELETE", fmt.Sprintf("/holds/%s", hold.ID), nil)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	resp = holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/bookings", roomID), params)
+	if resp.StatusCode != 200 {
+		t.Errorf("status code expected 200 booking the room of a released hold but got %d", resp.StatusCode)
+	}
+}
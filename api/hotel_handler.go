@@ -1,8 +1,10 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"slices"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jucaza1/hotel-reserv/db"
@@ -10,12 +12,18 @@ import (
 )
 
 type HotelHandler struct {
-	hotelStore db.HotelStore
+	hotelStore    db.HotelStore
+	roomStore     db.RoomStore
+	ratePlanStore db.RatePlanStore
+	promoStore    db.PromoStore
 }
 
-func NewHotelHandler(hs db.HotelStore) *HotelHandler {
+func NewHotelHandler(hs db.HotelStore, rs db.RoomStore, rps db.RatePlanStore, ps db.PromoStore) *HotelHandler {
 	return &HotelHandler{
-		hotelStore: hs,
+		hotelStore:    hs,
+		roomStore:     rs,
+		ratePlanStore: rps,
+		promoStore:    ps,
 	}
 }
 
@@ -80,10 +88,56 @@ func (h *HotelHandler) HandlePatchHotel(c *fiber.Ctx) error {
 	if err := c.BodyParser(&updateMap); err != nil {
 		return types.ErrInvalidParams(err)
 	}
-	validUpdate, err := types.ValidateHotelUpdate(updateMap)
+	hotel, err := h.hotelStore.GetHotelByID(c.Context(), hotelID)
+	if err != nil {
+		return err
+	}
+	validUpdate, err := types.ValidateHotelUpdate(hotel, updateMap)
 	if err != nil {
 		return types.ErrInvalidParams(err)
 	}
+	if updateMap.Currency != "" && updateMap.Currency != hotel.PriceCurrency() {
+		if err := h.checkPricedIn(c.Context(), hotel.ID, updateMap.Currency); err != nil {
+			return err
+		}
+	}
 	h.hotelStore.UpdateHotel(c.Context(), hotelID, *validUpdate)
 	return c.JSON(types.MsgUpdated{Updated: hotelID})
 }
+
+// checkPricedIn fails with an invalid params error when a room, rate plan or
+// fixed promo of the hotel is priced in another currency than currency, as
+// the hotel could not be priced once changed to it.
+func (h *HotelHandler) checkPricedIn(ctx context.Context, hotelID, currency string) error {
+	other := func(m types.Money) bool {
+		return m.Currency != "" && m.Currency != currency
+	}
+	rooms, err := h.roomStore.GetRooms(ctx, hotelID)
+	if err != nil {
+		return err
+	}
+	for _, room := range rooms {
+		if other(room.Price) || other(room.ExtraAdult) || other(room.ExtraChild) {
+			return types.ErrInvalidParams(fmt.Errorf("room %s of hotel %s is priced in %s", room.ID, hotelID, room.Price.Currency))
+		}
+	}
+	plans, err := h.ratePlanStore.GetRatePlansByHotel(ctx, hotelID)
+	if err != nil {
+		return err
+	}
+	for _, plan := range plans {
+		if plan.BaseRate.Amount > 0 && other(plan.BaseRate) {
+			return types.ErrInvalidParams(fmt.Errorf("rate plan %s of hotel %s is priced in %s", plan.ID, hotelID, plan.BaseRate.Currency))
+		}
+	}
+	promos, err := h.promoStore.GetPromos(ctx)
+	if err != nil {
+		return err
+	}
+	for _, promo := range promos {
+		if promo.Kind == types.PromoFixed && slices.Contains(promo.HotelIDs, hotelID) && other(promo.Amount) {
+			return types.ErrInvalidParams(fmt.Errorf("promo code %s of hotel %s is in %s", promo.Code, hotelID, promo.Amount.Currency))
+		}
+	}
+	return nil
+}
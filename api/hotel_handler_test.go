@@ -11,28 +11,30 @@ import (
 
 	"github.com/jucaza1/hotel-reserv/db"
 	"github.com/jucaza1/hotel-reserv/types"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type hotelTestDB struct {
 	db.HotelStore
+	RoomStore     db.RoomStore
+	RatePlanStore db.RatePlanStore
+	PromoStore    db.PromoStore
 }
 
 func (tdb *hotelTestDB) hotelTeardown(t *testing.T) {
-	if err := tdb.HotelStore.Drop(context.TODO()); err != nil {
-		t.Fatal(err)
+	for _, store := range []db.Dropper{tdb.HotelStore, tdb.RoomStore, tdb.RatePlanStore, tdb.PromoStore} {
+		if err := store.Drop(context.TODO()); err != nil {
+			t.Fatal(err)
+		}
 	}
 }
 
 func hotelSetup(t *testing.T) *hotelTestDB {
-	injectENV(t)
-	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(db.DBURI))
-	if err != nil {
-		t.Fatal(err)
-	}
+	store := newTestStore(t)
 	return &hotelTestDB{
-		HotelStore: db.NewMongoHotelStore(client, db.TestDBNAME),
+		HotelStore:    store.Hotel,
+		RoomStore:     store.Room,
+		RatePlanStore: store.RatePlan,
+		PromoStore:    store.Promo,
 	}
 }
 func TestPostHotel(t *testing.T) {
@@ -40,7 +42,7 @@ func TestPostHotel(t *testing.T) {
 	defer tdb.hotelTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	hotelHandler := NewHotelHandler(tdb.HotelStore)
+	hotelHandler := NewHotelHandler(tdb.HotelStore, tdb.RoomStore, tdb.RatePlanStore, tdb.PromoStore)
 	app.Post("/", hotelHandler.HandlePostHotel)
 
 	params := types.CreateHotelParams{
@@ -68,7 +70,7 @@ func TestGetHotel(t *testing.T) {
 	defer tdb.hotelTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	hotelHandler := NewHotelHandler(tdb.HotelStore)
+	hotelHandler := NewHotelHandler(tdb.HotelStore, tdb.RoomStore, tdb.RatePlanStore, tdb.PromoStore)
 	app.Get("/:id", hotelHandler.HandleGetHotel)
 
 	params := types.CreateHotelParams{
@@ -105,7 +107,7 @@ func TestGetHotels(t *testing.T) {
 	defer tdb.hotelTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	hotelHandler := NewHotelHandler(tdb.HotelStore)
+	hotelHandler := NewHotelHandler(tdb.HotelStore, tdb.RoomStore, tdb.RatePlanStore, tdb.PromoStore)
 	app.Get("/", hotelHandler.HandleGetHotels)
 
 	params := [2]types.CreateHotelParams{
@@ -153,7 +155,7 @@ func TestDeleteHotel(t *testing.T) {
 	defer tdb.roomTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	hotelHandler := NewHotelHandler(tdb.HotelStore)
+	hotelHandler := NewHotelHandler(tdb.HotelStore, tdb.RoomStore, nil, nil)
 	roomHandler := NewRoomHandler(tdb.RoomStore, tdb.HotelStore)
 	app.Delete("/:id", hotelHandler.HandleDeleteHotel, roomHandler.HandleDeleteRoomsByHotel)
 
@@ -174,10 +176,10 @@ func TestDeleteHotel(t *testing.T) {
 	roomParams := [2]types.CreateRoomParams{
 		{
 			Size:  types.Normal,
-			Price: 100,
+			Price: eur(100),
 		}, {
 			Size:  types.Large,
-			Price: 110,
+			Price: eur(110),
 		},
 	}
 	insertedRooms := [2]*types.Room{}
@@ -238,7 +240,7 @@ func TestPatchHotel(t *testing.T) {
 
 	app := NewFiberAppCentralErr()
 
-	hotelHandler := NewHotelHandler(tdb.HotelStore)
+	hotelHandler := NewHotelHandler(tdb.HotelStore, tdb.RoomStore, tdb.RatePlanStore, tdb.PromoStore)
 	app.Patch("/:id", hotelHandler.HandlePatchHotel)
 	params := types.CreateHotelParams{
 		Name:     "test1",
@@ -289,6 +291,131 @@ func TestPatchHotel(t *testing.T) {
 	compareHotelWithID(t, &hotel2, hotel)
 }
 
+func TestPatchHotelTouristTaxCurrency(t *testing.T) {
+	tdb := hotelSetup(t)
+	defer tdb.hotelTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	hotelHandler := NewHotelHandler(tdb.HotelStore, tdb.RoomStore, tdb.RatePlanStore, tdb.PromoStore)
+	app.Patch("/:id", hotelHandler.HandlePatchHotel)
+	hotel, err := types.NewHotelFromParams(types.CreateHotelParams{
+		Name:       "test1",
+		Location:   "testLand1",
+		Rating:     3,
+		Currency:   "EUR",
+		TouristTax: types.TouristTax{Amount: types.NewMoney(250, "EUR")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hotel, err = tdb.InsertHotel(context.Background(), hotel); err != nil {
+		t.Fatal(err)
+	}
+	patch := func(update types.UpdateHotel, expectedStatus int) {
+		t.Helper()
+		resp := holdTestRequest(t, app, "PATCH", fmt.Sprintf("/%s", hotel.ID), update)
+		if resp.StatusCode != expectedStatus {
+			t.Fatalf("%+v: status code expected %d but got %d", update, expectedStatus, resp.StatusCode)
+		}
+	}
+	usdTax := types.TouristTax{Amount: types.NewMoney(300, "USD")}
+
+	// neither the currency nor the tourist tax may leave them apart
+	patch(types.UpdateHotel{Currency: "USD"}, http.StatusBadRequest)
+	patch(types.UpdateHotel{TouristTax: &usdTax}, http.StatusBadRequest)
+	have, err := tdb.GetHotelByID(context.Background(), hotel.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have.Currency != "EUR" || have.TouristTax.Amount != types.NewMoney(250, "EUR") {
+		t.Fatalf("expected the hotel unchanged but got %s with a tourist tax of %s", have.Currency, have.TouristTax.Amount)
+	}
+
+	// changed together they are fine
+	patch(types.UpdateHotel{Currency: "USD", TouristTax: &usdTax}, http.StatusOK)
+	if have, err = tdb.GetHotelByID(context.Background(), hotel.ID); err != nil {
+		t.Fatal(err)
+	}
+	if have.Currency != "USD" || have.TouristTax.Amount != usdTax.Amount {
+		t.Fatalf("expected a tourist tax of %s but got %s", usdTax.Amount, have.TouristTax.Amount)
+	}
+}
+
+func TestPatchHotelCurrencyInUse(t *testing.T) {
+	tdb := hotelSetup(t)
+	defer tdb.hotelTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	hotelHandler := NewHotelHandler(tdb.HotelStore, tdb.RoomStore, tdb.RatePlanStore, tdb.PromoStore)
+	app.Patch("/:id", hotelHandler.HandlePatchHotel)
+	insertHotel := func() string {
+		t.Helper()
+		hotel, err := types.NewHotelFromParams(types.CreateHotelParams{Name: "test1", Location: "testLand1", Rating: 3, Currency: "EUR"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hotel, err = tdb.InsertHotel(context.Background(), hotel); err != nil {
+			t.Fatal(err)
+		}
+		return hotel.ID
+	}
+	patch := func(hotelID, currency string, expectedStatus int) {
+		t.Helper()
+		resp := holdTestRequest(t, app, "PATCH", fmt.Sprintf("/%s", hotelID), types.UpdateHotel{Currency: currency})
+		if resp.StatusCode != expectedStatus {
+			t.Fatalf("%s: status code expected %d but got %d", currency, expectedStatus, resp.StatusCode)
+		}
+		hotel, err := tdb.GetHotelByID(context.Background(), hotelID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expectedStatus == http.StatusOK && hotel.Currency != currency {
+			t.Fatalf("expected the hotel in %s but got %s", currency, hotel.Currency)
+		}
+	}
+
+	// nothing priced yet, the currency may change
+	hotelID := insertHotel()
+	patch(hotelID, "USD", http.StatusOK)
+	patch(hotelID, "EUR", http.StatusOK)
+
+	// once anything of the hotel is priced in it, it may not
+	seeds := map[string]func(hotelID string) error{
+		"room": func(hotelID string) error {
+			seedTestRoom(t, tdb.RoomStore, hotelID)
+			return nil
+		},
+		"rate plan": func(hotelID string) error {
+			_, err := tdb.RatePlanStore.InsertRatePlan(context.Background(), &types.RatePlan{HotelID: hotelID, Name: "summer", BaseRate: eur(120)})
+			return err
+		},
+		"fixed promo": func(hotelID string) error {
+			_, err := tdb.PromoStore.InsertPromo(context.Background(), types.NewPromoFromParams(types.CreatePromoParams{
+				Code:     "FIXED10",
+				Kind:     types.PromoFixed,
+				Amount:   eur(10),
+				HotelIDs: []string{hotelID},
+			}))
+			return err
+		},
+	}
+	for name, seed := range seeds {
+		hotelID := insertHotel()
+		if err := seed(hotelID); err != nil {
+			t.Fatal(err)
+		}
+		patch(hotelID, "EUR", http.StatusOK)
+		patch(hotelID, "USD", http.StatusBadRequest)
+		hotel, err := tdb.GetHotelByID(context.Background(), hotelID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hotel.Currency != "EUR" {
+			t.Fatalf("%s: expected the hotel to stay in EUR but got %s", name, hotel.Currency)
+		}
+	}
+}
+
 func compareHotel(t *testing.T, expected *types.CreateHotelParams, have *types.Hotel) {
 	if len(have.ID) == 0 {
 		t.Errorf("expected a hotel id to be set")
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/invoicing"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type InvoiceHandler struct {
+	invoices  *invoicing.Service
+	bookStore db.BookingStore
+}
+
+func NewInvoiceHandler(invoices *invoicing.Service, bs db.BookingStore) *InvoiceHandler {
+	return &InvoiceHandler{
+		invoices:  invoices,
+		bookStore: bs,
+	}
+}
+
+// HandleGetInvoice renders the invoice of a booking to its user, or to staff
+// allowed to view the bookings of its hotel, admins included, issuing it if
+// the booking has none yet. It is HTML unless format=pdf is in the query or
+// PDF is preferred by Accept.
+func (h *InvoiceHandler) HandleGetInvoice(c *fiber.Ctx) error {
+	bookingID := c.Params("id")
+	if len(bookingID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	format := c.Query("format")
+	if format == "" && c.Accepts(fiber.MIMETextHTML, "application/pdf") == "application/pdf" {
+		format = "pdf"
+	}
+	if format != "" && format != "html" && format != "pdf" {
+		return types.ErrInvalidParams(fmt.Errorf("format should be html or pdf"))
+	}
+	booking, err := h.bookStore.GetBookingByID(c.Context(), bookingID)
+	if err != nil {
+		return err
+	}
+	if !user.CanAccess(booking, types.PermViewBookings) {
+		return types.ErrUnauthorized(fmt.Errorf("unauthorized access to invoice of different user"))
+	}
+	invoice, err := h.invoices.Issue(c.Context(), booking)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	if format == "pdf" {
+		if err := invoicing.RenderPDF(&body, invoice); err != nil {
+			return types.ErrInternal(err)
+		}
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("inline; filename=\"invoice-%s.pdf\"", invoice.Number))
+		return c.Send(body.Bytes())
+	}
+	if err := invoicing.RenderHTML(&body, invoice); err != nil {
+		return types.ErrInternal(err)
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Send(body.Bytes())
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/pricing"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+func TestHandleGetInvoice(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	guest := *seedTestUser(t, tdb.UserStore, "test@mail.com")
+	other := types.User{ID: "0001", Email: "other@mail.com"}
+	admin := types.User{ID: "0002", Email: "admin@mail.com", IsAdmin: true}
+	invoices := tdb.newTestInvoices()
+	service := newTestPayments(t, tdb.PaymentStore, tdb.FolioStore)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), service, tdb.FolioStore, invoices, tdb.newTestPromos())
+	invoiceHandler := NewInvoiceHandler(invoices, tdb.BookingStore)
+	app := NewFiberAppCentralErr()
+	app.Get("/bookings/:id/invoice", provideContextUser(guest), invoiceHandler.HandleGetInvoice)
+	app.Patch("/bookings/:id", provideContextUser(guest), bookingHandler.HandleCancelBooking)
+	otherApp := NewFiberAppCentralErr()
+	otherApp.Get("/bookings/:id/invoice", provideContextUser(other), invoiceHandler.HandleGetInvoice)
+	adminApp := NewFiberAppCentralErr()
+	adminApp.Post("/bookings/:id/check-in", provideContextUser(admin), bookingHandler.HandleCheckIn)
+	adminApp.Post("/bookings/:id/check-out", provideContextUser(admin), bookingHandler.HandleCheckOut)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	otherHotelID := seedTestHotel(t, tdb.HotelStore)
+	insert := func(hotelID string, status types.BookingStatus) *types.Booking {
+		hotel := getTestHotel(t, tdb.HotelStore, hotelID)
+		nights := []string{"2100-01-01", "2100-01-02"}
+		price, err := types.NewPriceBreakdown([]types.NightPrice{
+			types.NewNightPrice(nights[0], eur(100), 0, ""),
+			types.NewNightPrice(nights[1], eur(100), 0, ""),
+		}, nil, hotel, types.Guests{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		booking, err := tdb.InsertBooking(context.Background(), &types.Booking{
+			UserID:   guest.ID,
+			HotelID:  hotelID,
+			RoomID:   seedTestRoom(t, tdb.RoomStore, hotelID),
+			FromDate: time.Now().Add(time.Hour),
+			ToDate:   time.Now().AddDate(0, 0, 2),
+			Nights:   nights,
+			Price:    price,
+			Status:   status,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return booking
+	}
+	getInvoice := func(booking *types.Booking, query string, status int) (*http.Response, string) {
+		t.Helper()
+		resp := holdTestRequest(t, app, "GET", fmt.Sprintf("/bookings/%s/invoice%s", booking.ID, query), nil)
+		if resp.StatusCode != status {
+			t.Fatalf("status code expected %d but got %d", status, resp.StatusCode)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(body)
+	}
+	year := time.Now().Year()
+
+	// bookings are not invoiced before checking out
+	confirmed := insert(hotelID, types.StatusConfirmed)
+	getInvoice(confirmed, "", http.StatusNotFound)
+	if _, err := tdb.GetInvoiceByBooking(context.Background(), confirmed.ID); err == nil {
+		t.Fatalf("expected no invoice issued for a confirmed booking")
+	}
+
+	first := insert(hotelID, types.StatusCheckedOut)
+	resp, body := getInvoice(first, "", http.StatusOK)
+	number := fmt.Sprintf("%d-000001", year)
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || !strings.Contains(body, "Invoice "+number) {
+		t.Fatalf("expected HTML invoice %s but got %s", number, body)
+	}
+	if !strings.Contains(body, guest.Email) || !strings.Contains(body, "200.00 EUR") {
+		t.Fatalf("expected the invoice of %s totalling 200.00 EUR but got %s", guest.Email, body)
+	}
+	resp, body = getInvoice(first, "?format=pdf", http.StatusOK)
+	if resp.Header.Get("Content-Type") != "application/pdf" || !strings.HasPrefix(body, "%PDF") {
+		t.Fatalf("expected a PDF but got %s", resp.Header.Get("Content-Type"))
+	}
+	if !bytes.Contains([]byte(body), []byte("Invoice "+number)) {
+		t.Fatalf("expected PDF of invoice %s", number)
+	}
+	getInvoice(first, "?format=xml", http.StatusBadRequest)
+
+	// invoices are numbered per hotel
+	_, body = getInvoice(insert(hotelID, types.StatusCheckedOut), "", http.StatusOK)
+	if number := fmt.Sprintf("%d-000002", year); !strings.Contains(body, "Invoice "+number) {
+		t.Fatalf("expected invoice %s but got %s", number, body)
+	}
+	_, body = getInvoice(insert(otherHotelID, types.StatusCheckedOut), "", http.StatusOK)
+	if !strings.Contains(body, "Invoice "+number) {
+		t.Fatalf("expected invoice %s but got %s", number, body)
+	}
+
+	// an issued invoice does not change
+	if err := tdb.UpdateHotel(context.Background(), hotelID, map[string]any{"name": "renamed"}); err != nil {
+		t.Fatal(err)
+	}
+	_, body = getInvoice(first, "", http.StatusOK)
+	if !strings.Contains(body, "Invoice "+number) || strings.Contains(body, "renamed") {
+		t.Fatalf("expected invoice %s unchanged but got %s", number, body)
+	}
+
+	getInvoice(insert(hotelID, types.StatusCancelled), "", http.StatusNotFound)
+	resp = holdTestRequest(t, otherApp, "GET", fmt.Sprintf("/bookings/%s/invoice", first.ID), nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status code expected %d but got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	// an invoiced booking can not be cancelled
+	if _, err := tdb.InsertInvoice(context.Background(), &types.Invoice{HotelID: hotelID, Year: year, BookingID: confirmed.ID}); err != nil {
+		t.Fatal(err)
+	}
+	resp = holdTestRequest(t, app, "PATCH", fmt.Sprintf("/bookings/%s", confirmed.ID), nil)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("status code expected %d but got %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	// checking out issues the invoice
+	stay := insert(otherHotelID, types.StatusConfirmed)
+	payment, err := service.Authorize(context.Background(), stay, testPaymentMethod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIn := holdTestRequest(t, adminApp, "POST", fmt.Sprintf("/bookings/%s/check-in", stay.ID), nil)
+	if checkIn.StatusCode != http.StatusOK {
+		t.Fatalf("status code expected %d but got %d", http.StatusOK, checkIn.StatusCode)
+	}
+	if _, err := service.Capture(context.Background(), payment, eur(200)); err != nil {
+		t.Fatal(err)
+	}
+	checkOut := holdTestRequest(t, adminApp, "POST", fmt.Sprintf("/bookings/%s/check-out", stay.ID), nil)
+	if checkOut.StatusCode != http.StatusOK {
+		t.Fatalf("status code expected %d but got %d", http.StatusOK, checkOut.StatusCode)
+	}
+	invoice, err := tdb.GetInvoiceByBooking(context.Background(), stay.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice.Number != fmt.Sprintf("%d-000002", year) {
+		t.Fatalf("expected invoice %d-000002 but got %s", year, invoice.Number)
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type LockoutHandler struct {
+	lockoutStore db.LockoutStore
+}
+
+func NewLockoutHandler(ls db.LockoutStore) *LockoutHandler {
+	return &LockoutHandler{
+		lockoutStore: ls,
+	}
+}
+
+// HandleGetLockoutEvents returns the audit records of lockouts and unlocks,
+// of the email or IP of the subject query when given, newest first.
+func (h *LockoutHandler) HandleGetLockoutEvents(c *fiber.Ctx) error {
+	kind := types.LoginKind(c.Query("kind"))
+	switch kind {
+	case "", types.LoginByEmail, types.LoginByIP:
+	default:
+		return types.ErrInvalidParams(fmt.Errorf("kind %s is invalid", kind))
+	}
+	subject := c.Query("subject")
+	if kind != "" {
+		subject = types.LoginSubject(kind, subject)
+	}
+	events, err := h.lockoutStore.GetLockoutEvents(c.Context(), kind, subject)
+	if err != nil {
+		return err
+	}
+	return c.JSON(events)
+}
+
+// HandleUnlock lifts the lockout and forgets the failed logins of the email
+// and the IP of the body, recording who unlocked them.
+func (h *LockoutHandler) HandleUnlock(c *fiber.Ctx) error {
+	admin, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	var params types.UnlockParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	subjects := map[types.LoginKind]string{types.LoginByEmail: params.Email, types.LoginByIP: params.IP}
+	events := []*types.LockoutEvent{}
+	for _, kind := range []types.LoginKind{types.LoginByEmail, types.LoginByIP} {
+		if subjects[kind] == "" {
+			continue
+		}
+		key := types.LoginKey(kind, subjects[kind])
+		attempts, err := h.lockoutStore.GetLoginAttempts(c.Context(), key)
+		if err != nil {
+			var e types.ErrorSt
+			if errors.As(err, &e) && e.Status == http.StatusNotFound {
+				continue
+			}
+			return err
+		}
+		if err := h.lockoutStore.ClearLoginAttempts(c.Context(), key); err != nil {
+			return err
+		}
+		event, err := h.lockoutStore.InsertLockoutEvent(c.Context(), &types.LockoutEvent{
+			Kind:        kind,
+			Subject:     attempts.Subject,
+			Action:      types.LockoutUnlocked,
+			Failures:    attempts.Failures,
+			LockedUntil: attempts.LockedUntil,
+			UnlockedBy:  admin.ID,
+			At:          time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return types.ErrNotFound(fmt.Errorf("no failed logins to unlock"))
+	}
+	return c.JSON(events)
+}
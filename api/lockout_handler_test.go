@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+func TestHandleLockouts(t *testing.T) {
+	tdb := userSetup(t)
+	defer tdb.userTeardown(t)
+	lockoutHandler := NewLockoutHandler(tdb.LockoutStore)
+	admin := types.User{ID: "0001", Email: "admin@mail.com", IsAdmin: true}
+	app := NewFiberAppCentralErr()
+	app.Get("/lockouts", provideContextUser(admin), lockoutHandler.HandleGetLockoutEvents)
+	app.Post("/lockouts/unlock", provideContextUser(admin), lockoutHandler.HandleUnlock)
+	app.Post("/anonymous/lockouts/unlock", lockoutHandler.HandleUnlock)
+
+	expectStatus := func(resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Fatalf("expected the response status code to be %d but got %d", status, resp.StatusCode)
+		}
+	}
+	decode := func(resp *http.Response) []types.LockoutEvent {
+		t.Helper()
+		var events []types.LockoutEvent
+		if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		return events
+	}
+	events := func(query string, count int) []types.LockoutEvent {
+		t.Helper()
+		resp := holdTestRequest(t, app, "GET", "/lockouts?"+query, nil)
+		expectStatus(resp, http.StatusOK)
+		events := decode(resp)
+		if len(events) != count {
+			t.Fatalf("%s: expected %d lockout events but got %d", query, count, len(events))
+		}
+		return events
+	}
+	// lock locks out the logins of subject as the auth handler does
+	lock := func(kind types.LoginKind, subject string, failures int, at time.Time) {
+		t.Helper()
+		var attempts *types.LoginAttempts
+		for i := 0; i < failures; i++ {
+			var err error
+			if attempts, err = tdb.LockoutStore.RecordLoginFailure(context.Background(), kind, subject, at, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+		}
+		until := at.Add(time.Hour)
+		if err := tdb.LockoutStore.LockLogin(context.Background(), attempts.ID, until); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tdb.LockoutStore.InsertLockoutEvent(context.Background(), &types.LockoutEvent{
+			Kind:        kind,
+			Subject:     attempts.Subject,
+			Action:      types.LockoutLocked,
+			Failures:    failures,
+			IP:          "10.0.0.1",
+			LockedUntil: until,
+			At:          at,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	locked := func(kind types.LoginKind, subject string) bool {
+		t.Helper()
+		_, err := tdb.LockoutStore.GetLoginAttempts(context.Background(), types.LoginKey(kind, subject))
+		return err == nil
+	}
+
+	now := time.Now()
+	lock(types.LoginByEmail, "Test@foo.com", 4, now.Add(-time.Minute))
+	lock(types.LoginByIP, "10.0.0.1", 7, now)
+
+	// newest first, filtered by kind and subject, emails case insensitively
+	if all := events("", 2); all[0].Kind != types.LoginByIP || all[1].Subject != "test@foo.com" {
+		t.Fatalf("expected the IP lockout before the email one but got %+v", all)
+	}
+	events("kind=email&subject=TEST@foo.com", 1)
+	events("kind=ip&subject=10.0.0.2", 0)
+	expectStatus(holdTestRequest(t, app, "GET", "/lockouts?kind=phone", nil), http.StatusBadRequest)
+
+	expectStatus(holdTestRequest(t, app, "POST", "/anonymous/lockouts/unlock", types.UnlockParams{Email: "test@foo.com"}), http.StatusUnauthorized)
+	expectStatus(holdTestRequest(t, app, "POST", "/lockouts/unlock", types.UnlockParams{}), http.StatusBadRequest)
+	expectStatus(holdTestRequest(t, app, "POST", "/lockouts/unlock", types.UnlockParams{IP: "not an ip"}), http.StatusBadRequest)
+	expectStatus(holdTestRequest(t, app, "POST", "/lockouts/unlock", types.UnlockParams{Email: "nobody@foo.com"}), http.StatusNotFound)
+
+	// unlocking both at once records who unlocked each
+	resp := holdTestRequest(t, app, "POST", "/lockouts/unlock", types.UnlockParams{Email: "TEST@foo.com", IP: "10.0.0.1"})
+	expectStatus(resp, http.StatusOK)
+	unlocked := decode(resp)
+	if len(unlocked) != 2 {
+		t.Fatalf("expected 2 unlock events but got %d", len(unlocked))
+	}
+	for _, event := range unlocked {
+		if event.Action != types.LockoutUnlocked || event.UnlockedBy != admin.ID || event.LockedUntil.IsZero() {
+			t.Fatalf("expected an unlock by %s but got %+v", admin.ID, event)
+		}
+	}
+	if locked(types.LoginByEmail, "test@foo.com") || locked(types.LoginByIP, "10.0.0.1") {
+		t.Fatalf("expected the unlocked logins to be forgotten")
+	}
+	events("", 4)
+	events("kind=email&subject=test@foo.com", 2)
+	expectStatus(holdTestRequest(t, app, "POST", "/lockouts/unlock", types.UnlockParams{Email: "test@foo.com"}), http.StatusNotFound)
+}
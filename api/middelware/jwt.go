@@ -11,7 +11,10 @@ import (
 	"github.com/jucaza1/hotel-reserv/types"
 )
 
-func JWTAuthentication(us db.UserStore) fiber.Handler {
+// JWTAuthentication accepts requests carrying a valid, unexpired and not
+// revoked access token in X-Authorization, and saves its user as "user"
+// and the token as "token".
+func JWTAuthentication(us db.UserStore, ts db.TokenStore) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		token := c.Get("X-Authorization")
 		if len(token) == 0 {
@@ -30,13 +33,35 @@ func JWTAuthentication(us db.UserStore) fiber.Handler {
 		if remaining <= 0 {
 			return types.ErrUnauthorized(fmt.Errorf("token expired"))
 		}
+		//check revocation
+		jti, _ := claims["jti"].(string)
+		if len(jti) == 0 {
+			return types.ErrUnauthorized(fmt.Errorf("token id not present"))
+		}
+		revoked, err := ts.IsAccessTokenRevoked(c.Context(), jti)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return types.ErrUnauthorized(fmt.Errorf("token revoked"))
+		}
 		//check and save user
 		userID, _ := claims["id"].(string)
 		user, err := us.GetUserByID(c.Context(), userID)
 		if err != nil || userID != user.ID {
 			return types.ErrUnauthorized(fmt.Errorf("token user not in database"))
 		}
+		//check the token was issued after the password last changed
+		issued, _ := claims["issued"].(float64)
+		if int64(issued) < user.PasswordChangedAt.UnixMilli() {
+			return types.ErrUnauthorized(fmt.Errorf("token issued before the password changed"))
+		}
 		c.Context().SetUserValue("user", *user)
+		c.Context().SetUserValue("token", types.AccessToken{
+			ID:        jti,
+			UserID:    user.ID,
+			ExpiresAt: time.Unix(int64(tm), 0),
+		})
 		return c.Next()
 	}
 }
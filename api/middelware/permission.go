@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+// HotelResolver returns the ID of the hotel a request accesses.
+type HotelResolver func(c *fiber.Ctx) (string, error)
+
+// RequirePermission lets the request through when the user has perm on the
+// hotel resolved by hotelOf, admins have every permission.
+func RequirePermission(perm types.Permission, hotelOf HotelResolver) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, ok := c.Context().UserValue("user").(types.User)
+		if !ok {
+			return types.ErrUnauthorized(fmt.Errorf("user not found"))
+		}
+		if user.IsAdmin {
+			return c.Next()
+		}
+		hotelID, err := hotelOf(c)
+		if err != nil {
+			return err
+		}
+		if !user.Can(perm, hotelID) {
+			return types.ErrUnauthorized(fmt.Errorf("user lacks %s on hotel %s", perm, hotelID))
+		}
+		return c.Next()
+	}
+}
+
+// HotelParam resolves the hotel of the path parameter name.
+func HotelParam(name string) HotelResolver {
+	return func(c *fiber.Ctx) (string, error) {
+		return param(c, name)
+	}
+}
+
+// RoomHotel resolves the hotel of the room of the path parameter id.
+func RoomHotel(rs db.RoomStore) HotelResolver {
+	return func(c *fiber.Ctx) (string, error) {
+		id, err := param(c, "id")
+		if err != nil {
+			return "", err
+		}
+		room, err := rs.GetRoom(c.Context(), id)
+		if err != nil {
+			return "", err
+		}
+		return room.HotelID, nil
+	}
+}
+
+// BookingHotel resolves the hotel of the booking of the path parameter id.
+func BookingHotel(bs db.BookingStore) HotelResolver {
+	return func(c *fiber.Ctx) (string, error) {
+		id, err := param(c, "id")
+		if err != nil {
+			return "", err
+		}
+		return bookingHotel(c, bs, id)
+	}
+}
+
+// PaymentHotel resolves the hotel of the booking paid by the payment of the
+// path parameter id.
+func PaymentHotel(ps db.PaymentStore, bs db.BookingStore) HotelResolver {
+	return func(c *fiber.Ctx) (string, error) {
+		id, err := param(c, "id")
+		if err != nil {
+			return "", err
+		}
+		payment, err := ps.GetPayment(c.Context(), id)
+		if err != nil {
+			return "", err
+		}
+		return bookingHotel(c, bs, payment.BookingID)
+	}
+}
+
+// FolioItemHotel resolves the hotel of the booking charged by the folio
+// item of the path parameter id.
+func FolioItemHotel(fs db.FolioStore, bs db.BookingStore) HotelResolver {
+	return func(c *fiber.Ctx) (string, error) {
+		id, err := param(c, "id")
+		if err != nil {
+			return "", err
+		}
+		item, err := fs.GetFolioItem(c.Context(), id)
+		if err != nil {
+			return "", err
+		}
+		return bookingHotel(c, bs, item.BookingID)
+	}
+}
+
+func param(c *fiber.Ctx, name string) (string, error) {
+	id := c.Params(name)
+	if len(id) == 0 {
+		return "", types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	return id, nil
+}
+
+func bookingHotel(c *fiber.Ctx, bs db.BookingStore, bookingID string) (string, error) {
+	booking, err := bs.GetBookingByID(c.Context(), bookingID)
+	if err != nil {
+		return "", err
+	}
+	return booking.HotelID, nil
+}
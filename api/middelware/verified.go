@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+// VerifiedEmailMiddleware lets through users who verified their email.
+func VerifiedEmailMiddleware(c *fiber.Ctx) error {
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	if !user.EmailVerified {
+		return types.ErrEmailNotVerified(fmt.Errorf("user %s has not verified %s", user.ID, user.Email))
+	}
+	return c.Next()
+}
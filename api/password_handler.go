@@ -0,0 +1,162 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/mail"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+// DefaultPasswordResetTTL is how long a password reset token is valid when
+// PASSWORD_RESET_TTL is not set.
+const DefaultPasswordResetTTL = time.Hour
+
+type PasswordHandler struct {
+	loginLimiter
+	userStore  db.UserStore
+	tokenStore db.TokenStore
+	sender     mail.Sender
+	resetTTL   time.Duration
+}
+
+func NewPasswordHandler(us db.UserStore, ts db.TokenStore, ls db.LockoutStore, sender mail.Sender, resetTTL time.Duration) *PasswordHandler {
+	return &PasswordHandler{
+		loginLimiter: newLoginLimiter(ls),
+		userStore:    us,
+		tokenStore:   ts,
+		sender:       sender,
+		resetTTL:     resetTTL,
+	}
+}
+
+// HandleChangePassword sets a new password for the user, who must give the
+// current one. Wrong current passwords count as failed logins, throttled as
+// in HandleAuthenticate. Every session of the user ends, this one included.
+func (h *PasswordHandler) HandleChangePassword(c *fiber.Ctx) error {
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	var params types.ChangePasswordParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	throttles, err := h.reserve(c, user.Email)
+	if err != nil {
+		return err
+	}
+	if !types.AuthUser(user.EncyptedPassword, params.CurrentPassword) {
+		if err := h.failed(c, throttles); err != nil {
+			return err
+		}
+		return types.ErrUnauthorized(fmt.Errorf("invalid credentials"))
+	}
+	if err := h.passed(c, throttles); err != nil {
+		return err
+	}
+	if err := h.setPassword(c, user.ID, params.NewPassword); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// HandleForgotPassword mails a password reset token to the user of the
+// email. It answers the same whether the user exists or not, and whether
+// the mail could be sent or not, so it can not be used to find out who has
+// an account. Failures are logged instead.
+func (h *PasswordHandler) HandleForgotPassword(c *fiber.Ctx) error {
+	var params types.ForgotPasswordParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := h.sendPasswordReset(c, params.Email); err != nil {
+		fmt.Println("failed to send password reset:", err)
+	}
+	return c.JSON(types.MsgResetRequested{
+		Message: "if the email has an account a password reset token was mailed to it",
+	})
+}
+
+// sendPasswordReset mails a new password reset token to the user of email,
+// if there is one.
+func (h *PasswordHandler) sendPasswordReset(c *fiber.Ctx, email string) error {
+	user, err := h.userStore.GetUserByEmail(c.Context(), email)
+	if err != nil {
+		var e types.ErrorSt
+		if errors.As(err, &e) && e.Status == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	reset, token, err := types.NewPasswordReset(user.ID, h.resetTTL)
+	if err != nil {
+		return err
+	}
+	if _, err := h.tokenStore.InsertPasswordReset(c.Context(), reset); err != nil {
+		return err
+	}
+	return h.sender.Send(c.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nUse this token to set a new password until %s:\n\n%s\n\n"+
+			"If you did not ask to reset your password you can ignore this email.\n",
+			user.Firstname, reset.ExpiresAt.UTC().Format(time.RFC1123), token),
+	})
+}
+
+// HandleResetPassword sets a new password with a reset token, which works
+// once. Every session of the user ends.
+func (h *PasswordHandler) HandleResetPassword(c *fiber.Ctx) error {
+	var params types.ResetPasswordParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	reset, err := h.tokenStore.GetPasswordReset(c.Context(), types.HashToken(params.Token))
+	if err != nil {
+		var e types.ErrorSt
+		if errors.As(err, &e) && e.Status == http.StatusNotFound {
+			return types.ErrUnauthorized(fmt.Errorf("invalid reset token"))
+		}
+		return err
+	}
+	user, err := h.userStore.GetUserByID(c.Context(), reset.UserID)
+	if err != nil {
+		return types.ErrUnauthorized(fmt.Errorf("reset token user not in database"))
+	}
+	now := time.Now()
+	if err := reset.Usable(user, now); err != nil {
+		return types.ErrUnauthorized(err)
+	}
+	if err := h.tokenStore.UsePasswordReset(c.Context(), reset.ID, now); err != nil {
+		return err
+	}
+	if err := h.setPassword(c, user.ID, params.NewPassword); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// setPassword stores password for the user of userID and ends its sessions:
+// access tokens issued before now are refused and refresh tokens revoked.
+func (h *PasswordHandler) setPassword(c *fiber.Ctx, userID, password string) error {
+	encpw, err := types.EncryptPassword(password)
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	now := time.Now()
+	if err := h.userStore.SetPassword(c.Context(), userID, encpw, now); err != nil {
+		return err
+	}
+	return h.tokenStore.RevokeUserRefreshTokens(c.Context(), userID, now)
+}
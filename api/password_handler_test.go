@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	middleware "github.com/jucaza1/hotel-reserv/api/middelware"
+	"github.com/jucaza1/hotel-reserv/mail"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+func TestHandlePasswordChangeAndReset(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	tdb := userSetup(t)
+	defer tdb.userTeardown(t)
+	outbox := mail.NewOutbox("")
+	app := NewFiberAppCentralErr()
+	authHandler := NewAuthHandler(tdb.UserStore, tdb.TokenStore, tdb.LockoutStore, DefaultAccessTokenTTL, DefaultRefreshTokenTTL)
+	passwordHandler := NewPasswordHandler(tdb.UserStore, tdb.TokenStore, tdb.LockoutStore, outbox, DefaultPasswordResetTTL)
+	jwtAuth := middleware.JWTAuthentication(tdb.UserStore, tdb.TokenStore)
+	app.Post("/auth", authHandler.HandleAuthenticate)
+	app.Post("/auth/refresh", authHandler.HandleRefresh)
+	app.Post("/auth/password/forgot", passwordHandler.HandleForgotPassword)
+	app.Post("/auth/password/reset", passwordHandler.HandleResetPassword)
+	app.Post("/users/password", jwtAuth, passwordHandler.HandleChangePassword)
+	app.Post("/me", jwtAuth, func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusOK)
+	})
+
+	userParams := types.CreateUserParams{
+		Firstname: "testName",
+		Lastname:  "testLast",
+		Email:     "test@foo.com",
+		Password:  "secretpasstest",
+	}
+	insertedUser, err := types.NewUserFromParams(userParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tdb.UserStore.InsertUser(context.Background(), insertedUser); err != nil {
+		t.Fatal(err)
+	}
+	request := func(path, access string, params any) *http.Response {
+		t.Helper()
+		b, _ := json.Marshal(params)
+		req := httptest.NewRequest("POST", path, bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		if access != "" {
+			req.Header.Add("X-Authorization", access)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	expectStatus := func(resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Fatalf("expected the response status code to be %d but got %d", status, resp.StatusCode)
+		}
+	}
+	login := func(password string, status int) (string, string) {
+		t.Helper()
+		resp := request("/auth", "", AuthParams{Email: userParams.Email, Pasword: password})
+		expectStatus(resp, status)
+		return resp.Header.Get("X-Authorization"), resp.Header.Get("X-Refresh-Token")
+	}
+
+	access, refresh := login(userParams.Password, http.StatusNoContent)
+	expectStatus(request("/users/password", access, types.ChangePasswordParams{
+		CurrentPassword: "wrongpassword", NewPassword: "newsecretpass",
+	}), http.StatusUnauthorized)
+	expectStatus(request("/users/password", access, types.ChangePasswordParams{
+		CurrentPassword: userParams.Password, NewPassword: "short",
+	}), http.StatusBadRequest)
+	expectStatus(request("/users/password", access, types.ChangePasswordParams{
+		CurrentPassword: userParams.Password, NewPassword: "newsecretpass",
+	}), http.StatusNoContent)
+
+	// changing the password ends every session
+	expectStatus(request("/me", access, nil), http.StatusUnauthorized)
+	expectStatus(request("/auth/refresh", "", types.RefreshParams{RefreshToken: refresh}), http.StatusUnauthorized)
+	login(userParams.Password, http.StatusUnauthorized)
+	access, _ = login("newsecretpass", http.StatusNoContent)
+	expectStatus(request("/me", access, nil), http.StatusOK)
+
+	// unknown emails are answered alike but get no mail
+	expectStatus(request("/auth/password/forgot", "", types.ForgotPasswordParams{Email: "nobody@foo.com"}), http.StatusOK)
+	if messages := outbox.Messages("nobody@foo.com"); len(messages) != 0 {
+		t.Fatalf("expected no mail to unknown users but got %d", len(messages))
+	}
+	expectStatus(request("/auth/password/forgot", "", types.ForgotPasswordParams{Email: userParams.Email}), http.StatusOK)
+	messages := outbox.Messages(userParams.Email)
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 reset mail but got %d", len(messages))
+	}
+	token := strings.Split(messages[0].Body, "\n\n")[2]
+
+	// a mail that can not be sent is answered alike too
+	broken := NewPasswordHandler(tdb.UserStore, tdb.TokenStore, tdb.LockoutStore, mail.NewOutbox(t.TempDir()), DefaultPasswordResetTTL)
+	app.Post("/broken/password/forgot", broken.HandleForgotPassword)
+	expectStatus(request("/broken/password/forgot", "", types.ForgotPasswordParams{Email: userParams.Email}), http.StatusOK)
+
+	expectStatus(request("/auth/password/reset", "", types.ResetPasswordParams{
+		Token: "wrongtoken", NewPassword: "resetsecretpass",
+	}), http.StatusUnauthorized)
+	expectStatus(request("/auth/password/reset", "", types.ResetPasswordParams{
+		Token: token, NewPassword: "resetsecretpass",
+	}), http.StatusNoContent)
+	expectStatus(request("/me", access, nil), http.StatusUnauthorized)
+	expectStatus(request("/auth/password/reset", "", types.ResetPasswordParams{
+		Token: token, NewPassword: "othersecretpass",
+	}), http.StatusUnauthorized)
+	login("newsecretpass", http.StatusUnauthorized)
+	access, _ = login("resetsecretpass", http.StatusNoContent)
+
+	// wrong current passwords are throttled like failed logins
+	passwordHandler.accountLockout.FreeFailures = 1
+	passwordHandler.accountLockout.BaseDelay = time.Minute
+	expectStatus(request("/users/password", access, types.ChangePasswordParams{
+		CurrentPassword: "wrongpassword", NewPassword: "othersecretpass",
+	}), http.StatusUnauthorized)
+	expectStatus(request("/users/password", access, types.ChangePasswordParams{
+		CurrentPassword: "resetsecretpass", NewPassword: "othersecretpass",
+	}), http.StatusTooManyRequests)
+	login("resetsecretpass", http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/payments"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type PaymentHandler struct {
+	payments     *payments.Service
+	paymentStore db.PaymentStore
+	bookStore    db.BookingStore
+}
+
+func NewPaymentHandler(payments *payments.Service, ps db.PaymentStore, bs db.BookingStore) *PaymentHandler {
+	return &PaymentHandler{
+		payments:     payments,
+		paymentStore: ps,
+		bookStore:    bs,
+	}
+}
+
+// HandleGetBookingPayments lists the payments of a booking to its user, or
+// to staff allowed to view the bookings of its hotel, admins included.
+func (h *PaymentHandler) HandleGetBookingPayments(c *fiber.Ctx) error {
+	bookingID := c.Params("id")
+	if len(bookingID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	booking, err := h.bookStore.GetBookingByID(c.Context(), bookingID)
+	if err != nil {
+		return err
+	}
+	if !user.CanAccess(booking, types.PermViewBookings) {
+		return types.ErrUnauthorized(fmt.Errorf("unauthorized access to payments of different user"))
+	}
+	payments, err := h.paymentStore.GetPaymentsByBooking(c.Context(), bookingID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(payments)
+}
+
+// HandleCapturePayment captures the amount of the body, or all that was
+// authorized.
+func (h *PaymentHandler) HandleCapturePayment(c *fiber.Ctx) error {
+	payment, amount, err := h.paymentFromRequest(c)
+	if err != nil {
+		return err
+	}
+	if amount == nil {
+		capturable := payment.Capturable()
+		amount = &capturable
+	}
+	updated, err := h.payments.Capture(c.Context(), payment, amount.OrCurrency(payment.Amount.Currency))
+	if err != nil {
+		return err
+	}
+	return c.JSON(updated)
+}
+
+// HandleRefundPayment refunds the amount of the body, or all that is left
+// of the capture.
+func (h *PaymentHandler) HandleRefundPayment(c *fiber.Ctx) error {
+	payment, amount, err := h.paymentFromRequest(c)
+	if err != nil {
+		return err
+	}
+	if amount == nil {
+		refundable := payment.Refundable()
+		amount = &refundable
+	}
+	updated, err := h.payments.Refund(c.Context(), payment, amount.OrCurrency(payment.Amount.Currency))
+	if err != nil {
+		return err
+	}
+	return c.JSON(updated)
+}
+
+func (h *PaymentHandler) HandleVoidPayment(c *fiber.Ctx) error {
+	payment, _, err := h.paymentFromRequest(c)
+	if err != nil {
+		return err
+	}
+	updated, err := h.payments.Void(c.Context(), payment)
+	if err != nil {
+		return err
+	}
+	return c.JSON(updated)
+}
+
+// paymentFromRequest returns the payment of the path and the amount of the
+// body, nil when not given.
+func (h *PaymentHandler) paymentFromRequest(c *fiber.Ctx) (*types.Payment, *types.Money, error) {
+	paymentID := c.Params("id")
+	if len(paymentID) == 0 {
+		return nil, nil, types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	var params types.PaymentAmountParams
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&params); err != nil {
+			return nil, nil, types.ErrInvalidParams(err)
+		}
+	}
+	payment, err := h.paymentStore.GetPayment(c.Context(), paymentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return payment, params.Amount, nil
+}
@@ -0,0 +1,389 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/payments"
+	"github.com/jucaza1/hotel-reserv/pricing"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+// paymentTestApp serves the booking routes for guest and the payment routes
+// for admin.
+func paymentTestApp(t *testing.T, tdb *bookingTestDB, guest, admin types.User) *fiber.App {
+	service := newTestPayments(t, tdb.PaymentStore, tdb.FolioStore)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), service, tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	paymentHandler := NewPaymentHandler(service, tdb.PaymentStore, tdb.BookingStore)
+	app := NewFiberAppCentralErr()
+	app.Post("/rooms/:id/bookings", provideContextUser(guest), bookingHandler.HandlePostBooking)
+	app.Patch("/bookings/:id", provideContextUser(guest), bookingHandler.HandleCancelBooking)
+	app.Put("/bookings/:id", provideContextUser(guest), bookingHandler.HandleModifyBooking)
+	app.Get("/bookings/:id/payments", provideContextUser(guest), paymentHandler.HandleGetBookingPayments)
+	app.Post("/payments/:id/capture", provideContextUser(admin), paymentHandler.HandleCapturePayment)
+	app.Post("/payments/:id/refund", provideContextUser(admin), paymentHandler.HandleRefundPayment)
+	app.Post("/payments/:id/void", provideContextUser(admin), paymentHandler.HandleVoidPayment)
+	return app
+}
+
+func decodePayment(t *testing.T, resp *http.Response, status int) *types.Payment {
+	if resp.StatusCode != status {
+		t.Fatalf("status code expected %d but got %d", status, resp.StatusCode)
+	}
+	var payment types.Payment
+	if err := json.NewDecoder(resp.Body).Decode(&payment); err != nil {
+		t.Fatal(err)
+	}
+	return &payment
+}
+
+func TestHandlePostBookingPayment(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	guest := types.User{ID: "0000", Email: "test@mail.com"}
+	admin := types.User{ID: "0001", Email: "admin@mail.com", IsAdmin: true}
+	app := paymentTestApp(t, tdb, guest, admin)
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	checkIn := time.Now().AddDate(0, 0, 10)
+	params := types.CreateBookingParams{
+		FromDate:      checkIn,
+		ToDate:        checkIn.AddDate(0, 0, 2),
+		PaymentMethod: payments.FakeDeclinedMethod,
+	}
+	path := fmt.Sprintf("/rooms/%s/bookings", roomID)
+
+	// a declined payment cancels the booking and frees the room
+	resp := holdTestRequest(t, app, "POST", path, params)
+	if resp.StatusCode != http.StatusPaymentRequired {
+		t.Fatalf("status code expected %d but got %d", http.StatusPaymentRequired, resp.StatusCode)
+	}
+	bookings, err := tdb.GetBookingsByRoom(context.Background(), roomID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookings) != 1 || bookings[0].Status != types.StatusCancelled {
+		t.Fatalf("expected the declined booking cancelled but got %+v", bookings)
+	}
+	declined, err := tdb.GetPaymentsByBooking(context.Background(), bookings[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(declined) != 1 || declined[0].Status != types.PaymentFailed {
+		t.Fatalf("expected a failed payment but got %+v", declined)
+	}
+
+	// no payment method
+	params.PaymentMethod = ""
+	resp = holdTestRequest(t, app, "POST", path, params)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status code expected %d but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	// an authorized payment confirms the booking
+	params.PaymentMethod = testPaymentMethod
+	resp = holdTestRequest(t, app, "POST", path, params)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status code expected %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+	var booking types.Booking
+	if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+		t.Fatal(err)
+	}
+	if booking.Status != types.StatusConfirmed {
+		t.Fatalf("expected status %s but got %s", types.StatusConfirmed, booking.Status)
+	}
+	resp = holdTestRequest(t, app, "GET", fmt.Sprintf("/bookings/%s/payments", booking.ID), nil)
+	var have []types.Payment
+	if err := json.NewDecoder(resp.Body).Decode(&have); err != nil {
+		t.Fatal(err)
+	}
+	if len(have) != 1 || have[0].Status != types.PaymentAuthorized || have[0].Amount != eur(200) {
+		t.Fatalf("expected one authorization of %s but got %+v", eur(200), have)
+	}
+}
+
+func TestHandlePaymentOperations(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	guest := types.User{ID: "0000", Email: "test@mail.com"}
+	admin := types.User{ID: "0001", Email: "admin@mail.com", IsAdmin: true}
+	app := paymentTestApp(t, tdb, guest, admin)
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	book := func(days int) *types.Payment {
+		checkIn := time.Now().AddDate(0, 0, days)
+		resp := holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/bookings", roomID), types.CreateBookingParams{
+			FromDate:      checkIn,
+			ToDate:        checkIn.AddDate(0, 0, 2),
+			PaymentMethod: testPaymentMethod,
+		})
+		var booking types.Booking
+		if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+			t.Fatal(err)
+		}
+		payments, err := tdb.GetPaymentsByBooking(context.Background(), booking.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(payments) != 1 {
+			t.Fatalf("expected 1 payment but got %d", len(payments))
+		}
+		return payments[0]
+	}
+
+	// capture part of the authorization, then refund all of the capture
+	payment := book(10)
+	half := eur(100)
+	captured := decodePayment(t, holdTestRequest(t, app, "POST", fmt.Sprintf("/payments/%s/capture", payment.ID), types.PaymentAmountParams{Amount: &half}), http.StatusOK)
+	if captured.Status != types.PaymentCaptured || captured.Captured != half {
+		t.Fatalf("expected %s captured but got %+v", half, captured)
+	}
+	resp := holdTestRequest(t, app, "POST", fmt.Sprintf("/payments/%s/capture", payment.ID), nil)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("status code expected %d but got %d", http.StatusConflict, resp.StatusCode)
+	}
+	refunded := decodePayment(t, holdTestRequest(t, app, "POST", fmt.Sprintf("/payments/%s/refund", payment.ID), nil), http.StatusOK)
+	if refunded.Status != types.PaymentRefunded || refunded.Refunded != half {
+		t.Fatalf("expected %s refunded but got %+v", half, refunded)
+	}
+
+	// void an authorization, which can then not be captured
+	payment = book(20)
+	voided := decodePayment(t, holdTestRequest(t, app, "POST", fmt.Sprintf("/payments/%s/void", payment.ID), nil), http.StatusOK)
+	if voided.Status != types.PaymentVoided {
+		t.Fatalf("expected status %s but got %s", types.PaymentVoided, voided.Status)
+	}
+	resp = holdTestRequest(t, app, "POST", fmt.Sprintf("/payments/%s/capture", payment.ID), nil)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("status code expected %d but got %d", http.StatusConflict, resp.StatusCode)
+	}
+
+	// a free cancellation voids the authorization
+	payment = book(30)
+	resp = holdTestRequest(t, app, "PATCH", fmt.Sprintf("/bookings/%s", payment.BookingID), nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status code expected %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+	settled, err := tdb.GetPayment(context.Background(), payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settled.Status != types.PaymentVoided {
+		t.Fatalf("expected status %s but got %s", types.PaymentVoided, settled.Status)
+	}
+}
+
+// flakyProvider is a fake provider losing the answer of the next lost
+// captures it carries out, as when the connection drops.
+type flakyProvider struct {
+	*payments.FakeProvider
+	lost int
+}
+
+func (p *flakyProvider) Capture(ctx context.Context, reference string, amount types.Money, key string) error {
+	if err := p.FakeProvider.Capture(ctx, reference, amount, key); err != nil {
+		return err
+	}
+	if p.lost > 0 {
+		p.lost--
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func TestPaymentOperationsOnce(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	fake, err := payments.NewFakeProvider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &flakyProvider{FakeProvider: fake}
+	service := payments.NewService(provider, tdb.PaymentStore, tdb.FolioStore)
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	ctx := context.Background()
+	authorize := func(days int) *types.Payment {
+		checkIn := time.Now().AddDate(0, 0, days)
+		booking, err := types.NewBookingFromParams(types.CreateBookingParams{FromDate: checkIn, ToDate: checkIn.AddDate(0, 0, 2)}, "0000", hotel, roomID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		booking.Price.Total = eur(200)
+		if booking, err = tdb.InsertBooking(ctx, booking); err != nil {
+			t.Fatal(err)
+		}
+		payment, err := service.Authorize(ctx, booking, testPaymentMethod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payment
+	}
+
+	// of two captures of the same payment read, only the first one runs
+	payment := authorize(10)
+	if _, err := service.Capture(ctx, payment, eur(50)); err != nil {
+		t.Fatal(err)
+	}
+	var e types.ErrorSt
+	if _, err := service.Capture(ctx, payment, eur(200)); !errors.As(err, &e) || e.Status != http.StatusConflict {
+		t.Fatalf("expected a conflict but got %v", err)
+	}
+	stored, err := tdb.GetPayment(ctx, payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Captured != eur(50) || stored.Pending != nil {
+		t.Fatalf("expected %s captured but got %+v", eur(50), stored)
+	}
+
+	// a capture the provider took but which was not recorded completes
+	// without being taken twice
+	payment = authorize(20)
+	pending := *payment
+	pending.Pending = &types.PaymentOperation{Type: types.PaymentCapture, Amount: eur(50), Key: "interrupted"}
+	if _, err := tdb.UpdatePayment(ctx, payment, &pending); err != nil {
+		t.Fatal(err)
+	}
+	if err := provider.Capture(ctx, payment.Reference, eur(50), "interrupted"); err != nil {
+		t.Fatal(err)
+	}
+	captured, err := service.Capture(ctx, &pending, eur(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if captured.Status != types.PaymentCaptured || captured.Captured != eur(50) || captured.Pending != nil {
+		t.Fatalf("expected %s captured but got %+v", eur(50), captured)
+	}
+	items, err := tdb.GetFolioItems(ctx, payment.BookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Amount != eur(50) {
+		t.Fatalf("expected one payment of %s on the folio but got %+v", eur(50), items)
+	}
+
+	// a capture whose answer is lost stays pending and is sent again with
+	// the same key by the retry
+	payment = authorize(30)
+	provider.lost = 1
+	if _, err := service.Capture(ctx, payment, eur(80)); !errors.As(err, &e) || e.Status != http.StatusInternalServerError {
+		t.Fatalf("expected an internal error but got %v", err)
+	}
+	stored, err = tdb.GetPayment(ctx, payment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Pending == nil || stored.Pending.Type != types.PaymentCapture || stored.Captured.Amount != 0 {
+		t.Fatalf("expected the capture pending but got %+v", stored)
+	}
+	captured, err = service.Capture(ctx, stored, eur(80))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if captured.Captured != eur(80) || captured.Pending != nil {
+		t.Fatalf("expected %s captured but got %+v", eur(80), captured)
+	}
+	if items, err = tdb.GetFolioItems(ctx, payment.BookingID); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Amount != eur(80) {
+		t.Fatalf("expected one payment of %s on the folio but got %+v", eur(80), items)
+	}
+
+	// a capture the provider rejects is dropped
+	booking, err := tdb.GetBookingByID(ctx, payment.BookingID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, err := tdb.InsertPayment(ctx, types.NewPayment(booking, payments.FakeProviderName, "fake_unknown", eur(200)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Capture(ctx, unknown, eur(10)); err == nil {
+		t.Fatalf("expected the capture of an unknown charge to fail")
+	}
+	if stored, err = tdb.GetPayment(ctx, unknown.ID); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Pending != nil {
+		t.Fatalf("expected the rejected capture dropped but got %+v", stored.Pending)
+	}
+}
+
+func TestHandleModifyBookingPayment(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	guest := types.User{ID: "0000", Email: "test@mail.com"}
+	admin := types.User{ID: "0001", Email: "admin@mail.com", IsAdmin: true}
+	app := paymentTestApp(t, tdb, guest, admin)
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	checkIn := time.Now().AddDate(0, 0, 10)
+	resp := holdTestRequest(t, app, "POST", fmt.Sprintf("/rooms/%s/bookings", roomID), types.CreateBookingParams{
+		FromDate:      checkIn,
+		ToDate:        checkIn.AddDate(0, 0, 2),
+		PaymentMethod: testPaymentMethod,
+	})
+	var booking types.Booking
+	if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/bookings/%s", booking.ID)
+	params := types.ModifyBookingParams{FromDate: checkIn, ToDate: checkIn.AddDate(0, 0, 3)}
+
+	// a longer stay needs a payment method to authorize its new total
+	resp = holdTestRequest(t, app, "PUT", path, params)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status code expected %d but got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	params.PaymentMethod = payments.FakeDeclinedMethod
+	resp = holdTestRequest(t, app, "PUT", path, params)
+	if resp.StatusCode != http.StatusPaymentRequired {
+		t.Fatalf("status code expected %d but got %d", http.StatusPaymentRequired, resp.StatusCode)
+	}
+	stored, err := tdb.GetBookingByID(context.Background(), booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Nights) != 2 {
+		t.Fatalf("expected a declined modification to keep 2 nights but got %v", stored.Nights)
+	}
+
+	// the new total is authorized in place of the previous one
+	params.PaymentMethod = testPaymentMethod
+	resp = holdTestRequest(t, app, "PUT", path, params)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status code expected %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+	have, err := tdb.GetPaymentsByBooking(context.Background(), booking.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(have) != 3 || have[0].Status != types.PaymentVoided || have[2].Status != types.PaymentAuthorized || have[2].Amount != eur(300) {
+		t.Fatalf("expected the authorization of %s voided for one of %s but got %+v", eur(200), eur(300), have)
+	}
+
+	// the total of a charged booking can not change
+	resp = holdTestRequest(t, app, "POST", fmt.Sprintf("/payments/%s/capture", have[2].ID), nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status code expected %d but got %d", http.StatusOK, resp.StatusCode)
+	}
+	params.ToDate = checkIn.AddDate(0, 0, 4)
+	resp = holdTestRequest(t, app, "PUT", path, params)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("status code expected %d but got %d", http.StatusConflict, resp.StatusCode)
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type PromoHandler struct {
+	promoStore db.PromoStore
+	hotelStore db.HotelStore
+}
+
+func NewPromoHandler(ps db.PromoStore, hs db.HotelStore) *PromoHandler {
+	return &PromoHandler{
+		promoStore: ps,
+		hotelStore: hs,
+	}
+}
+
+func (h *PromoHandler) HandleGetPromos(c *fiber.Ctx) error {
+	promos, err := h.promoStore.GetPromos(c.Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(promos)
+}
+
+func (h *PromoHandler) HandleGetPromo(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if len(id) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	promo, err := h.promoStore.GetPromo(c.Context(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(promo)
+}
+
+func (h *PromoHandler) HandlePostPromo(c *fiber.Ctx) error {
+	var params types.CreatePromoParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	for _, hotelID := range params.HotelIDs {
+		if _, err := h.hotelStore.GetHotelByID(c.Context(), hotelID); err != nil {
+			return err
+		}
+	}
+	promo, err := h.promoStore.InsertPromo(c.Context(), types.NewPromoFromParams(params))
+	if err != nil {
+		return err
+	}
+	return c.JSON(promo)
+}
+
+// HandleDeletePromo deletes a promo code, bookings already discounted with
+// it keep their price.
+func (h *PromoHandler) HandleDeletePromo(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if len(id) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	if _, err := h.promoStore.GetPromo(c.Context(), id); err != nil {
+		return err
+	}
+	if err := h.promoStore.DeletePromo(c.Context(), id); err != nil {
+		return err
+	}
+	return c.JSON(types.MsgDeleted{Deleted: id})
+}
+
+func (h *PromoHandler) HandleGetPromoRedemptions(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if len(id) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	if _, err := h.promoStore.GetPromo(c.Context(), id); err != nil {
+		return err
+	}
+	redemptions, err := h.promoStore.GetPromoRedemptions(c.Context(), id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(redemptions)
+}
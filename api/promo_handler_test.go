@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/pricing"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+func TestHandlePromos(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	admin := types.User{ID: "0001", Email: "admin@mail.com", IsAdmin: true}
+	adminApp := NewFiberAppCentralErr()
+	promoHandler := NewPromoHandler(tdb.PromoStore, tdb.HotelStore)
+	adminApp.Get("/promos", provideContextUser(admin), promoHandler.HandleGetPromos)
+	adminApp.Post("/promos", provideContextUser(admin), promoHandler.HandlePostPromo)
+	adminApp.Get("/promos/:id/redemptions", provideContextUser(admin), promoHandler.HandleGetPromoRedemptions)
+	adminApp.Delete("/promos/:id", provideContextUser(admin), promoHandler.HandleDeletePromo)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	guestApp := func(user types.User) func(method, path string, params any) *http.Response {
+		app := NewFiberAppCentralErr()
+		app.Post("/rooms/:id/bookings", provideContextUser(user), bookingHandler.HandlePostBooking)
+		app.Post("/rooms/:id/quote", provideContextUser(user), bookingHandler.HandlePostQuote)
+		app.Patch("/bookings/:id", provideContextUser(user), bookingHandler.HandleCancelBooking)
+		return func(method, path string, params any) *http.Response {
+			return holdTestRequest(t, app, method, path, params)
+		}
+	}
+	guest := guestApp(types.User{ID: "0000", Email: "test@mail.com"})
+	otherGuest := guestApp(types.User{ID: "0002", Email: "other@mail.com"})
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	otherHotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	otherRoomID := seedTestRoom(t, tdb.RoomStore, otherHotelID)
+	expectStatus := func(resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Fatalf("status code expected %d but got %d", status, resp.StatusCode)
+		}
+	}
+	createPromo := func(params types.CreatePromoParams) *types.Promo {
+		t.Helper()
+		resp := holdTestRequest(t, adminApp, "POST", "/promos", params)
+		expectStatus(resp, http.StatusOK)
+		var promo types.Promo
+		if err := json.NewDecoder(resp.Body).Decode(&promo); err != nil {
+			t.Fatal(err)
+		}
+		return &promo
+	}
+	from := 10
+	stay := func(code string) types.CreateBookingParams {
+		from += 3
+		return types.CreateBookingParams{
+			FromDate:      time.Now().AddDate(0, 0, from),
+			ToDate:        time.Now().AddDate(0, 0, from+3),
+			PromoCode:     code,
+			PaymentMethod: testPaymentMethod,
+		}
+	}
+	book := func(post func(string, string, any) *http.Response, roomID, code string, status int) *types.Booking {
+		t.Helper()
+		resp := post("POST", fmt.Sprintf("/rooms/%s/bookings", roomID), stay(code))
+		expectStatus(resp, status)
+		var booking types.Booking
+		if status == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return &booking
+	}
+
+	expectStatus(holdTestRequest(t, adminApp, "POST", "/promos", types.CreatePromoParams{
+		Code: "x", Kind: types.PromoPercent, Percent: 150,
+	}), http.StatusBadRequest)
+	summer := createPromo(types.CreatePromoParams{
+		Code:       "summer10",
+		Kind:       types.PromoPercent,
+		Percent:    10,
+		MaxPerUser: 1,
+		HotelIDs:   []string{hotelID},
+	})
+	if summer.Code != "SUMMER10" {
+		t.Fatalf("expected code SUMMER10 but got %s", summer.Code)
+	}
+	expectStatus(holdTestRequest(t, adminApp, "POST", "/promos", types.CreatePromoParams{
+		Code: "SUMMER10", Kind: types.PromoPercent, Percent: 5,
+	}), http.StatusBadRequest)
+
+	// quoting shows the discount without redeeming the code
+	resp := guest("POST", fmt.Sprintf("/rooms/%s/quote", roomID), stay("summer10"))
+	expectStatus(resp, http.StatusOK)
+	var quote types.Quote
+	if err := json.NewDecoder(resp.Body).Decode(&quote); err != nil {
+		t.Fatal(err)
+	}
+	if quote.Price.Subtotal != eur(270) || quote.Price.PromoCode != "SUMMER10" {
+		t.Fatalf("expected a subtotal of %s with SUMMER10 but got %+v", eur(270), quote.Price)
+	}
+
+	booking := book(guest, roomID, "Summer10", http.StatusOK)
+	if booking.Price.Subtotal != eur(270) || booking.Price.PromoDiscount() != eur(30) {
+		t.Fatalf("expected %s off a subtotal of %s but got %+v", eur(30), eur(270), booking.Price)
+	}
+	// once per user, and only at the hotels of the code
+	book(guest, roomID, "SUMMER10", http.StatusUnprocessableEntity)
+	book(otherGuest, otherRoomID, "SUMMER10", http.StatusUnprocessableEntity)
+	book(otherGuest, roomID, "SUMMER10", http.StatusOK)
+	book(guest, roomID, "WINTER", http.StatusUnprocessableEntity)
+
+	resp = holdTestRequest(t, adminApp, "GET", fmt.Sprintf("/promos/%s/redemptions", summer.ID), nil)
+	expectStatus(resp, http.StatusOK)
+	var redemptions []types.PromoRedemption
+	if err := json.NewDecoder(resp.Body).Decode(&redemptions); err != nil {
+		t.Fatal(err)
+	}
+	if len(redemptions) != 2 || redemptions[0].BookingID != booking.ID || redemptions[0].Discount != eur(30) {
+		t.Fatalf("expected 2 redemptions, the first by %s, but got %+v", booking.ID, redemptions)
+	}
+
+	// cancelling gives the code back to the guest
+	expectStatus(guest("PATCH", fmt.Sprintf("/bookings/%s", booking.ID), nil), http.StatusOK)
+	book(guest, roomID, "SUMMER10", http.StatusOK)
+
+	// a fixed amount is taken off the whole stay
+	fixed := createPromo(types.CreatePromoParams{Code: "WELCOME", Kind: types.PromoFixed, Amount: eur(50)})
+	if booking := book(guest, otherRoomID, "welcome", http.StatusOK); booking.Price.Subtotal != eur(250) {
+		t.Fatalf("expected a subtotal of %s but got %s", eur(250), booking.Price.Subtotal)
+	}
+
+	// expired codes are refused
+	createPromo(types.CreatePromoParams{
+		Code: "SPRING", Kind: types.PromoPercent, Percent: 10,
+		ValidFrom: time.Now().AddDate(0, -2, 0), ValidTo: time.Now().AddDate(0, -1, 0),
+	})
+	book(guest, roomID, "SPRING", http.StatusUnprocessableEntity)
+
+	expectStatus(holdTestRequest(t, adminApp, "DELETE", fmt.Sprintf("/promos/%s", fixed.ID), nil), http.StatusOK)
+	book(guest, roomID, "WELCOME", http.StatusUnprocessableEntity)
+}
+
+func TestHandlePromoConcurrentRedemptions(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	promo, err := tdb.InsertPromo(context.Background(), types.NewPromoFromParams(types.CreatePromoParams{
+		Code:           "FLASH",
+		Kind:           types.PromoPercent,
+		Percent:        50,
+		MaxRedemptions: 3,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const requests = 10
+	var (
+		wg       sync.WaitGroup
+		statuses = make(chan int, requests)
+	)
+	for i := 0; i < requests; i++ {
+		app := NewFiberAppCentralErr()
+		app.Post("/rooms/:id/bookings", provideContextUser(types.User{ID: fmt.Sprintf("%04d", i)}), bookingHandler.HandlePostBooking)
+		reqUri := fmt.Sprintf("/rooms/%s/bookings", seedTestRoom(t, tdb.RoomStore, hotelID))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- holdTestRequest(t, app, "POST", reqUri, types.CreateBookingParams{
+				FromDate:      time.Now().AddDate(0, 0, 10),
+				ToDate:        time.Now().AddDate(0, 0, 12),
+				PromoCode:     "FLASH",
+				PaymentMethod: testPaymentMethod,
+			}).StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	var created, rejected int
+	for status := range statuses {
+		switch status {
+		case http.StatusOK:
+			created++
+		case http.StatusUnprocessableEntity:
+			rejected++
+		default:
+			t.Errorf("unexpected status code %d", status)
+		}
+	}
+	if created != promo.MaxRedemptions || rejected != requests-promo.MaxRedemptions {
+		t.Fatalf("expected %d bookings with the code but got %d", promo.MaxRedemptions, created)
+	}
+	stored, err := tdb.GetPromo(context.Background(), promo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Redemptions != promo.MaxRedemptions {
+		t.Fatalf("expected %d redemptions but got %d", promo.MaxRedemptions, stored.Redemptions)
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+const quoteTTL = time.Minute * 15
+
+// quoteClaims binds a quoted price to the user, room, nights and guests it
+// was quoted for.
+type quoteClaims struct {
+	UserID string               `json:"userID"`
+	RoomID string               `json:"roomID"`
+	Nights []string             `json:"nights"`
+	Guests types.Guests         `json:"guests"`
+	Price  types.PriceBreakdown `json:"price"`
+	jwt.RegisteredClaims
+}
+
+func createQuoteToken(userID string, booking *types.Booking, expires time.Time) (string, error) {
+	claims := quoteClaims{
+		UserID: userID,
+		RoomID: booking.RoomID,
+		Nights: booking.Nights,
+		Guests: booking.Guests,
+		Price:  booking.Price,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+func parseQuoteToken(tokenStr string) (*quoteClaims, error) {
+	var claims quoteClaims
+	_, err := jwt.ParseWithClaims(tokenStr, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %s", token.Header["alg"])
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("invalid quote token: %w", err)
+	}
+	return &claims, nil
+}
+
+// matches reports whether the quote was issued to userID for the stay of
+// booking.
+func (q *quoteClaims) matches(userID string, booking *types.Booking) bool {
+	return q.UserID == userID && q.RoomID == booking.RoomID && slices.Equal(q.Nights, booking.Nights) &&
+		q.Guests.Equal(booking.Guests)
+}
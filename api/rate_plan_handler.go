@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type RatePlanHandler struct {
+	ratePlanStore db.RatePlanStore
+	hotelStore    db.HotelStore
+	roomStore     db.RoomStore
+}
+
+func NewRatePlanHandler(rps db.RatePlanStore, hs db.HotelStore, rs db.RoomStore) *RatePlanHandler {
+	return &RatePlanHandler{
+		ratePlanStore: rps,
+		hotelStore:    hs,
+		roomStore:     rs,
+	}
+}
+
+func (h *RatePlanHandler) HandleGetRatePlans(c *fiber.Ctx) error {
+	hotelID := c.Params("hid")
+	if len(hotelID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	if _, err := h.hotelStore.GetHotelByID(c.Context(), hotelID); err != nil {
+		return err
+	}
+	plans, err := h.ratePlanStore.GetRatePlansByHotel(c.Context(), hotelID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(plans)
+}
+
+func (h *RatePlanHandler) HandlePostRatePlan(c *fiber.Ctx) error {
+	hotelID := c.Params("hid")
+	if len(hotelID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	hotel, err := h.hotelStore.GetHotelByID(c.Context(), hotelID)
+	if err != nil {
+		return err
+	}
+	var params types.CreateRatePlanParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	params.BaseRate = params.BaseRate.OrCurrency(hotel.PriceCurrency())
+	if params.BaseRate.Currency != hotel.PriceCurrency() {
+		return types.ErrInvalidParams(fmt.Errorf("base rate should be in %s", hotel.PriceCurrency()))
+	}
+	if params.RoomID != "" {
+		room, err := h.roomStore.GetRoom(c.Context(), params.RoomID)
+		if err != nil {
+			return err
+		}
+		if room.HotelID != hotelID {
+			return types.ErrInvalidParams(fmt.Errorf("room %s does not belong to hotel %s", params.RoomID, hotelID))
+		}
+	}
+	plan, err := h.ratePlanStore.InsertRatePlan(c.Context(), types.NewRatePlanFromParams(params, hotelID))
+	if err != nil {
+		return err
+	}
+	return c.JSON(plan)
+}
+
+func (h *RatePlanHandler) HandleDeleteRatePlan(c *fiber.Ctx) error {
+	hotelID := c.Params("hid")
+	id := c.Params("id")
+	if len(hotelID) == 0 || len(id) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	plan, err := h.ratePlanStore.GetRatePlan(c.Context(), id)
+	if err != nil {
+		return err
+	}
+	if plan.HotelID != hotelID {
+		return types.ErrNotFound(fmt.Errorf("rate plan %s not found in hotel %s", id, hotelID))
+	}
+	if err := h.ratePlanStore.DeleteRatePlan(c.Context(), id); err != nil {
+		return err
+	}
+	return c.JSON(types.MsgDeleted{Deleted: id})
+}
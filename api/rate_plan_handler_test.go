@@ -0,0 +1,165 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type ratePlanTestDB struct {
+	db.RatePlanStore
+	db.HotelStore
+	db.RoomStore
+}
+
+func (tdb *ratePlanTestDB) ratePlanTeardown(t *testing.T) {
+	if err := tdb.RatePlanStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tdb.RoomStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tdb.HotelStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ratePlanSetup(t *testing.T) *ratePlanTestDB {
+	store := newTestStore(t)
+	return &ratePlanTestDB{
+		RatePlanStore: store.RatePlan,
+		HotelStore:    store.Hotel,
+		RoomStore:     store.Room,
+	}
+}
+
+func TestHandlePostRatePlan(t *testing.T) {
+	tdb := ratePlanSetup(t)
+	defer tdb.ratePlanTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	rateHandler := NewRatePlanHandler(tdb.RatePlanStore, tdb.HotelStore, tdb.RoomStore)
+	app.Post("/hotels/:hid/rates", rateHandler.HandlePostRatePlan)
+	app.Get("/hotels/:hid/rates", rateHandler.HandleGetRatePlans)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	otherHotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	reqUri := fmt.Sprintf("/hotels/%s/rates", hotelID)
+
+	tests := []struct {
+		name   string
+		params types.CreateRatePlanParams
+		status int
+	}{
+		{
+			name: "room plan",
+			params: types.CreateRatePlanParams{
+				RoomID:             roomID,
+				Name:               "Summer",
+				FromDate:           "2100-06-01",
+				ToDate:             "2100-08-31",
+				BaseRate:           eur(150),
+				WeekdayMultipliers: map[string]float64{"saturday": 1.2},
+				StayDiscounts:      []types.StayDiscount{{MinNights: 7, Percent: 15}},
+				Priority:           2,
+			},
+			status: 200,
+		}, {
+			name: "invalid weekday and dates",
+			params: types.CreateRatePlanParams{
+				Name:               "Broken",
+				FromDate:           "2100-08-31",
+				ToDate:             "2100-06-01",
+				WeekdayMultipliers: map[string]float64{"someday": 1.2},
+			},
+			status: 400,
+		}, {
+			name: "room of another hotel",
+			params: types.CreateRatePlanParams{
+				RoomID: seedTestRoom(t, tdb.RoomStore, otherHotelID),
+				Name:   "Foreign",
+			},
+			status: 400,
+		},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(tt.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", reqUri, bytes.NewReader(b))
+		req.Header.Add("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status code expected %d but got %d", tt.name, tt.status, resp.StatusCode)
+		}
+	}
+
+	req := httptest.NewRequest("GET", reqUri, nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plans []types.RatePlan
+	if err := json.NewDecoder(resp.Body).Decode(&plans); err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("expected 1 rate plan but got %d", len(plans))
+	}
+	if plans[0].RoomID != roomID || plans[0].BaseRate != eur(150) || plans[0].WeekdayMultipliers["saturday"] != 1.2 {
+		t.Errorf("unexpected rate plan %+v", plans[0])
+	}
+}
+
+func TestHandleDeleteRatePlan(t *testing.T) {
+	tdb := ratePlanSetup(t)
+	defer tdb.ratePlanTeardown(t)
+
+	app := NewFiberAppCentralErr()
+	rateHandler := NewRatePlanHandler(tdb.RatePlanStore, tdb.HotelStore, tdb.RoomStore)
+	app.Delete("/hotels/:hid/rates/:id", rateHandler.HandleDeleteRatePlan)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	otherHotelID := seedTestHotel(t, tdb.HotelStore)
+	plan, err := tdb.InsertRatePlan(context.Background(),
+		types.NewRatePlanFromParams(types.CreateRatePlanParams{Name: "Weekend"}, hotelID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("DELETE", fmt.Sprintf("/hotels/%s/rates/%s", otherHotelID, plan.ID), nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Errorf("status code expected 404 but got %d", resp.StatusCode)
+	}
+
+	req = httptest.NewRequest("DELETE", fmt.Sprintf("/hotels/%s/rates/%s", hotelID, plan.ID), nil)
+	resp, err = app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("status code expected 200 but got %d", resp.StatusCode)
+	}
+	plans, err := tdb.GetRatePlansByHotel(context.Background(), hotelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 0 {
+		t.Errorf("expected no rate plans but got %d", len(plans))
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type RoleHandler struct {
+	userStore  db.UserStore
+	hotelStore db.HotelStore
+}
+
+func NewRoleHandler(us db.UserStore, hs db.HotelStore) *RoleHandler {
+	return &RoleHandler{
+		userStore:  us,
+		hotelStore: hs,
+	}
+}
+
+// HandleGrantRole grants the role of the body to the user of the path, on
+// the hotels of the body for the staff roles.
+func (h *RoleHandler) HandleGrantRole(c *fiber.Ctx) error {
+	userID := c.Params("id")
+	if len(userID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	var params types.RoleParams
+	if err := c.BodyParser(&params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	for _, hotelID := range params.HotelIDs {
+		if _, err := h.hotelStore.GetHotelByID(c.Context(), hotelID); err != nil {
+			return err
+		}
+	}
+	user, err := h.userStore.GetUserByID(c.Context(), userID)
+	if err != nil {
+		return err
+	}
+	if err := user.Grant(params); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := h.userStore.SetUserRoles(c.Context(), user.ID, user.IsAdmin, user.Roles); err != nil {
+		return err
+	}
+	return c.JSON(user)
+}
+
+// HandleRevokeRole revokes the role of the path from the user of the path,
+// only on the hotels of the comma separated hotelIDs query when given.
+func (h *RoleHandler) HandleRevokeRole(c *fiber.Ctx) error {
+	userID := c.Params("id")
+	if len(userID) == 0 {
+		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
+	}
+	params := types.RoleParams{Role: types.Role(c.Params("role"))}
+	if hotelIDs := c.Query("hotelIDs"); hotelIDs != "" {
+		params.HotelIDs = strings.Split(hotelIDs, ",")
+	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	user, err := h.userStore.GetUserByID(c.Context(), userID)
+	if err != nil {
+		return err
+	}
+	if params.Role == types.RoleAdmin {
+		if current, ok := c.Context().UserValue("user").(types.User); ok && current.ID == user.ID {
+			return types.ErrInvalidParams(fmt.Errorf("admins can not revoke their own admin role"))
+		}
+	}
+	user.Revoke(params)
+	if err := h.userStore.SetUserRoles(c.Context(), user.ID, user.IsAdmin, user.Roles); err != nil {
+		return err
+	}
+	return c.JSON(user)
+}
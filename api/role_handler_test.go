@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	middleware "github.com/jucaza1/hotel-reserv/api/middelware"
+	"github.com/jucaza1/hotel-reserv/pricing"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHandleRoles(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+
+	admin := types.User{ID: "0001", Email: "admin@mail.com", IsAdmin: true}
+	adminApp := NewFiberAppCentralErr()
+	roleHandler := NewRoleHandler(tdb.UserStore, tdb.HotelStore)
+	adminApp.Post("/users/:id/roles", provideContextUser(admin), roleHandler.HandleGrantRole)
+	adminApp.Delete("/users/:id/roles/:role", provideContextUser(admin), roleHandler.HandleRevokeRole)
+	bookingHandler := NewBookingHandler(tdb.BookingStore, tdb.RoomStore, tdb.HotelStore, pricing.NewEngine(tdb.RatePlanStore), newTestPayments(t, tdb.PaymentStore, tdb.FolioStore), tdb.FolioStore, tdb.newTestInvoices(), tdb.newTestPromos())
+	ok := func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusOK)
+	}
+	// staff requests as the user as stored, with the roles granted so far
+	staff := func(userID, method, path string) *http.Response {
+		t.Helper()
+		user, err := tdb.UserStore.GetUserByID(context.Background(), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		app := NewFiberAppCentralErr()
+		app.Use(provideContextUser(*user))
+		app.Post("/bookings/:id/check-in", middleware.RequirePermission(types.PermManageBookings, middleware.BookingHotel(tdb.BookingStore)), ok)
+		app.Post("/hotels/:hid/rates", middleware.RequirePermission(types.PermManageRates, middleware.HotelParam("hid")), ok)
+		app.Get("/hotels/:hid/bookings", bookingHandler.HandleGetBookingsByHotel)
+		return holdTestRequest(t, app, method, path, nil)
+	}
+	expectStatus := func(resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Fatalf("status code expected %d but got %d", status, resp.StatusCode)
+		}
+	}
+	grant := func(userID string, params types.RoleParams, status int) *types.User {
+		t.Helper()
+		resp := holdTestRequest(t, adminApp, "POST", fmt.Sprintf("/users/%s/roles", userID), params)
+		expectStatus(resp, status)
+		var user types.User
+		json.NewDecoder(resp.Body).Decode(&user)
+		return &user
+	}
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	otherHotelID := seedTestHotel(t, tdb.HotelStore)
+	guest := seedTestUser(t, tdb.UserStore, "guest@mail.com")
+	clerk := seedTestUser(t, tdb.UserStore, "clerk@mail.com")
+	insert := func(hotelID string, night string) string {
+		booking, err := tdb.InsertBooking(context.Background(), &types.Booking{
+			UserID:   guest.ID,
+			HotelID:  hotelID,
+			RoomID:   seedTestRoom(t, tdb.RoomStore, hotelID),
+			FromDate: time.Now().Add(time.Hour),
+			ToDate:   time.Now().AddDate(0, 0, 1),
+			Nights:   []string{night},
+			Status:   types.StatusConfirmed,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return booking.ID
+	}
+	bookingID := insert(hotelID, "2100-01-01")
+	otherBookingID := insert(otherHotelID, "2100-01-01")
+
+	// guests have no staff permission
+	expectStatus(staff(clerk.ID, "POST", fmt.Sprintf("/bookings/%s/check-in", bookingID)), http.StatusUnauthorized)
+
+	grant(clerk.ID, types.RoleParams{Role: types.RoleGuest}, http.StatusBadRequest)
+	grant(clerk.ID, types.RoleParams{Role: "owner", HotelIDs: []string{hotelID}}, http.StatusBadRequest)
+	grant(clerk.ID, types.RoleParams{Role: types.RoleFrontDesk}, http.StatusBadRequest)
+	grant(clerk.ID, types.RoleParams{Role: types.RoleFrontDesk, HotelIDs: []string{primitive.NewObjectID().Hex()}}, http.StatusNotFound)
+	user := grant(clerk.ID, types.RoleParams{Role: types.RoleFrontDesk, HotelIDs: []string{hotelID}}, http.StatusOK)
+	if len(user.Roles) != 1 || user.Roles[0].Role != types.RoleFrontDesk || user.Roles[0].HotelIDs[0] != hotelID {
+		t.Fatalf("expected front desk of hotel %s but got %+v", hotelID, user.Roles)
+	}
+
+	// front desk manages the bookings of its hotel only, and no rates
+	expectStatus(staff(clerk.ID, "POST", fmt.Sprintf("/bookings/%s/check-in", bookingID)), http.StatusOK)
+	expectStatus(staff(clerk.ID, "POST", fmt.Sprintf("/bookings/%s/check-in", otherBookingID)), http.StatusUnauthorized)
+	expectStatus(staff(clerk.ID, "POST", fmt.Sprintf("/hotels/%s/rates", hotelID)), http.StatusUnauthorized)
+	resp := staff(clerk.ID, "GET", fmt.Sprintf("/hotels/%s/bookings", hotelID))
+	expectStatus(resp, http.StatusOK)
+	var bookings []*types.Booking
+	if err := json.NewDecoder(resp.Body).Decode(&bookings); err != nil {
+		t.Fatal(err)
+	}
+	if len(bookings) != 1 || bookings[0].ID != bookingID {
+		t.Fatalf("expected the booking of the guest at hotel %s but got %d bookings", hotelID, len(bookings))
+	}
+
+	// managers also manage rates
+	grant(clerk.ID, types.RoleParams{Role: types.RoleManager, HotelIDs: []string{otherHotelID}}, http.StatusOK)
+	expectStatus(staff(clerk.ID, "POST", fmt.Sprintf("/hotels/%s/rates", otherHotelID)), http.StatusOK)
+	expectStatus(staff(clerk.ID, "POST", fmt.Sprintf("/bookings/%s/check-in", otherBookingID)), http.StatusOK)
+	expectStatus(staff(clerk.ID, "POST", fmt.Sprintf("/hotels/%s/rates", hotelID)), http.StatusUnauthorized)
+
+	expectStatus(holdTestRequest(t, adminApp, "DELETE", fmt.Sprintf("/users/%s/roles/%s?hotelIDs=%s", clerk.ID, types.RoleFrontDesk, hotelID), nil), http.StatusOK)
+	expectStatus(staff(clerk.ID, "POST", fmt.Sprintf("/bookings/%s/check-in", bookingID)), http.StatusUnauthorized)
+	expectStatus(holdTestRequest(t, adminApp, "DELETE", fmt.Sprintf("/users/%s/roles/%s", clerk.ID, types.RoleManager), nil), http.StatusOK)
+	expectStatus(staff(clerk.ID, "POST", fmt.Sprintf("/hotels/%s/rates", otherHotelID)), http.StatusUnauthorized)
+
+	// platform admins have every permission on every hotel
+	if user := grant(clerk.ID, types.RoleParams{Role: types.RoleAdmin}, http.StatusOK); !user.IsAdmin || len(user.Roles) != 0 {
+		t.Fatalf("expected an admin without staff roles but got %+v", user)
+	}
+	expectStatus(staff(clerk.ID, "POST", fmt.Sprintf("/hotels/%s/rates", hotelID)), http.StatusOK)
+}
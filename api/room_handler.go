@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jucaza1/hotel-reserv/db"
@@ -73,13 +74,26 @@ func (h *RoomHandler) HandlePostRoom(c *fiber.Ctx) error {
 	if len(hotelID) == 0 {
 		return types.ErrInvalidID(fmt.Errorf("missing params in path"))
 	}
-	if _, err := h.hotelStore.GetHotelByID(c.Context(), hotelID); err != nil {
+	hotel, err := h.hotelStore.GetHotelByID(c.Context(), hotelID)
+	if err != nil {
 		return err
 	}
 	var params types.CreateRoomParams
 	if err := c.BodyParser(&params); err != nil {
 		return types.ErrInvalidParams(err)
 	}
+	if errors := params.Validate(); len(errors) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(errors)
+	}
+	currency := hotel.PriceCurrency()
+	params.Price = params.Price.OrCurrency(currency)
+	params.ExtraAdult = params.ExtraAdult.OrCurrency(currency)
+	params.ExtraChild = params.ExtraChild.OrCurrency(currency)
+	for _, price := range []types.Money{params.Price, params.ExtraAdult, params.ExtraChild} {
+		if price.Currency != currency {
+			return types.ErrInvalidParams(fmt.Errorf("room prices should be in %s", currency))
+		}
+	}
 	room := types.NewRoomFromParams(params)
 	room.HotelID = hotelID
 	insertedRoom, err := h.roomStore.InsertRoom(c.Context(), room)
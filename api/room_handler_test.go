@@ -11,8 +11,6 @@ import (
 
 	"github.com/jucaza1/hotel-reserv/db"
 	"github.com/jucaza1/hotel-reserv/types"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type roomTestDB struct {
@@ -29,14 +27,10 @@ func (tdb *roomTestDB) roomTeardown(t *testing.T) {
 	}
 }
 func roomSetup(t *testing.T) *roomTestDB {
-	injectENV(t)
-	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(db.DBURI))
-	if err != nil {
-		t.Fatal(err)
-	}
+	store := newTestStore(t)
 	return &roomTestDB{
-		HotelStore: db.NewMongoHotelStore(client, db.TestDBNAME),
-		RoomStore:  db.NewMongoRoomStore(client, db.TestDBNAME, db.NewMongoHotelStore(client, db.TestDBNAME)),
+		HotelStore: store.Hotel,
+		RoomStore:  store.Room,
 	}
 }
 func seedTestHotel(t *testing.T, tdb db.HotelStore) (hotelID string) {
@@ -56,6 +50,14 @@ func seedTestHotel(t *testing.T, tdb db.HotelStore) (hotelID string) {
 	return insertedHotel.ID
 }
 
+func getTestHotel(t *testing.T, tdb db.HotelStore, hotelID string) *types.Hotel {
+	hotel, err := tdb.GetHotelByID(context.Background(), hotelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hotel
+}
+
 func TestHandleGetRooms(t *testing.T) {
 	tdb := roomSetup(t)
 	defer tdb.roomTeardown(t)
@@ -67,10 +69,10 @@ func TestHandleGetRooms(t *testing.T) {
 	params := [2]types.CreateRoomParams{
 		{
 			Size:  types.Normal,
-			Price: 100,
+			Price: eur(100),
 		}, {
 			Size:  types.Large,
-			Price: 110,
+			Price: eur(110),
 		},
 	}
 	insertedRooms := [2]*types.Room{}
@@ -111,10 +113,10 @@ func TestHandleGetRoomByID(t *testing.T) {
 	params := [2]types.CreateRoomParams{
 		{
 			Size:  types.Normal,
-			Price: 100,
+			Price: eur(100),
 		}, {
 			Size:  types.Large,
-			Price: 110,
+			Price: eur(110),
 		},
 	}
 	insertedRooms := [2]*types.Room{}
@@ -153,10 +155,10 @@ func TestHandleDeleteRoom(t *testing.T) {
 	params := [2]types.CreateRoomParams{
 		{
 			Size:  types.Normal,
-			Price: 100,
+			Price: eur(100),
 		}, {
 			Size:  types.Large,
-			Price: 110,
+			Price: eur(110),
 		},
 	}
 	insertedRooms := [2]*types.Room{}
@@ -208,7 +210,7 @@ func TestHandlePostRoom(t *testing.T) {
 
 	params := types.CreateRoomParams{
 		Size:  types.Normal,
-		Price: 100,
+		Price: eur(100),
 	}
 	b, _ := json.Marshal(params)
 	reqUri := fmt.Sprintf("/hotels/%s/rooms", hotelID)
@@ -233,6 +235,95 @@ func TestHandlePostRoom(t *testing.T) {
 
 }
 
+func TestHandlePostRoomPrice(t *testing.T) {
+	tdb := roomSetup(t)
+	defer tdb.roomTeardown(t)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	app := NewFiberAppCentralErr()
+	roomHandler := NewRoomHandler(tdb.RoomStore, tdb.HotelStore)
+	app.Post("/hotels/:hid/rooms", roomHandler.HandlePostRoom)
+
+	tests := []struct {
+		body     string
+		status   int
+		expected types.Money
+	}{
+		{`{"size":"Normal","price":150.5}`, 200, types.NewMoney(15050, "EUR")},
+		{`{"size":"Normal","price":{"amount":9999,"currency":"EUR"}}`, 200, types.NewMoney(9999, "EUR")},
+		{`{"size":"Normal","price":{"amount":9999,"currency":"USD"}}`, 400, types.Money{}},
+	}
+	reqUri := fmt.Sprintf("/hotels/%s/rooms", hotelID)
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", reqUri, bytes.NewReader([]byte(tt.body)))
+		req.Header.Add("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status code expected %d but got %d", tt.body, tt.status, resp.StatusCode)
+			continue
+		}
+		if tt.status != 200 {
+			continue
+		}
+		var room types.Room
+		if err := json.NewDecoder(resp.Body).Decode(&room); err != nil {
+			t.Fatal(err)
+		}
+		if room.Price != tt.expected {
+			t.Errorf("%s: expected room price %v but got %v", tt.body, tt.expected, room.Price)
+		}
+	}
+}
+
+func TestHandlePostRoomOccupancy(t *testing.T) {
+	tdb := roomSetup(t)
+	defer tdb.roomTeardown(t)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	app := NewFiberAppCentralErr()
+	roomHandler := NewRoomHandler(tdb.RoomStore, tdb.HotelStore)
+	app.Post("/hotels/:hid/rooms", roomHandler.HandlePostRoom)
+
+	tests := []struct {
+		body   string
+		status int
+	}{
+		{`{"size":"Large","price":100,"occupancy":{"maxAdults":2,"maxChildren":2,"maxTotal":4},"beds":[{"type":"king","count":1},{"type":"single","count":2}],"includedGuests":2,"extraAdult":30,"extraChild":15}`, 200},
+		{`{"size":"Normal","price":100,"occupancy":{"maxAdults":-1}}`, 400},
+		{`{"size":"Normal","price":100,"occupancy":{"maxAdults":4,"maxTotal":3}}`, 400},
+		{`{"size":"Normal","price":100,"beds":[{"type":"hammock","count":1}]}`, 400},
+		{`{"size":"Normal","price":100,"beds":[{"type":"double","count":0}]}`, 400},
+		{`{"size":"Normal","price":100,"extraAdult":30}`, 400},
+		{`{"size":"Normal","price":100,"includedGuests":2,"extraAdult":{"amount":3000,"currency":"USD"}}`, 400},
+	}
+	reqUri := fmt.Sprintf("/hotels/%s/rooms", hotelID)
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", reqUri, bytes.NewReader([]byte(tt.body)))
+		req.Header.Add("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status code expected %d but got %d", tt.body, tt.status, resp.StatusCode)
+			continue
+		}
+		if tt.status != 200 {
+			continue
+		}
+		var room types.Room
+		if err := json.NewDecoder(resp.Body).Decode(&room); err != nil {
+			t.Fatal(err)
+		}
+		if room.Occupancy.MaxTotal != 4 || len(room.Beds) != 2 || room.IncludedGuests != 2 || room.ExtraAdult != eur(30) || room.ExtraChild != eur(15) {
+			t.Errorf("expected the occupancy, beds and extra guest prices to be stored but got %+v", room)
+		}
+	}
+}
+
 func compareRoomWithID(t *testing.T, expected *types.Room, have *types.Room) {
 	if len(have.ID) == 0 || have.ID != expected.ID {
 		t.Errorf("expected room id %s but got %s", expected.ID, have.ID)
@@ -244,7 +335,7 @@ func compareRoomWithID(t *testing.T, expected *types.Room, have *types.Room) {
 		t.Errorf("expected room size %d but got %d", expected.Size, have.Size)
 	}
 	if have.Price != expected.Price {
-		t.Errorf("expected room price %f but got %f", expected.Price, have.Price)
+		t.Errorf("expected room price %v but got %v", expected.Price, have.Price)
 	}
 }
 func compareRoom(t *testing.T, expected *types.Room, have *types.Room) {
@@ -258,6 +349,6 @@ func compareRoom(t *testing.T, expected *types.Room, have *types.Room) {
 		t.Errorf("expected room size %d but got %d", expected.Size, have.Size)
 	}
 	if have.Price != expected.Price {
-		t.Errorf("expected room price %f but got %f", expected.Price, have.Price)
+		t.Errorf("expected room price %v but got %v", expected.Price, have.Price)
 	}
 }
@@ -10,12 +10,14 @@ import (
 )
 
 type UserHandler struct {
-	userStore db.UserStore
+	userStore     db.UserStore
+	verifications *VerificationHandler
 }
 
-func NewUserHandler(userStore db.UserStore) *UserHandler {
+func NewUserHandler(userStore db.UserStore, verifications *VerificationHandler) *UserHandler {
 	return &UserHandler{
-		userStore: userStore,
+		userStore:     userStore,
+		verifications: verifications,
 	}
 }
 
@@ -35,13 +37,35 @@ func (h *UserHandler) HandlePatchUser(c *fiber.Ctx) error {
 	if len(errors) > 0 {
 		return c.Status(http.StatusBadRequest).JSON(errors)
 	}
-	if email := updateValid["email"]; email != "" {
+	return h.updateUser(c, userID, updateValid)
+}
+
+// updateUser applies the validated update to the user of userID. A new
+// email must not be in use and is flagged not verified; keeping the same
+// email changes nothing about it.
+func (h *UserHandler) updateUser(c *fiber.Ctx, userID string, updateValid map[string]string) error {
+	user, err := h.userStore.GetUserByID(c.Context(), userID)
+	if err != nil {
+		return err
+	}
+	email := updateValid["email"]
+	if email == user.Email {
+		delete(updateValid, "email")
+		email = ""
+	}
+	if email != "" {
 		if userAlready, _ := h.userStore.GetUserByEmail(c.Context(), email); userAlready != nil {
 			return c.Status(http.StatusUnprocessableEntity).JSON(map[string]string{"email": "email in use"})
-
 		}
 	}
-	h.userStore.UpdateUser(c.Context(), userID, updateValid)
+	if err := h.userStore.UpdateUser(c.Context(), userID, updateValid); err != nil {
+		return err
+	}
+	if email != "" {
+		if err := h.verifications.emailChanged(c.Context(), userID); err != nil {
+			return err
+		}
+	}
 	return c.JSON(types.MsgUpdated{Updated: userID})
 }
 
@@ -76,6 +100,7 @@ func (h *UserHandler) HandlePostUser(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
+	h.verifications.trySendVerification(c.Context(), insertedUser)
 	return c.Status(http.StatusCreated).JSON(insertedUser)
 }
 func (h *UserHandler) HandlePostAdminUser(c *fiber.Ctx) error {
@@ -95,6 +120,7 @@ func (h *UserHandler) HandlePostAdminUser(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
+	h.verifications.trySendVerification(c.Context(), insertedUser)
 	return c.Status(http.StatusCreated).JSON(insertedUser)
 }
 
@@ -139,12 +165,5 @@ func (h *UserHandler) HandlePatchMyUser(c *fiber.Ctx) error {
 	if len(errors) > 0 {
 		return c.Status(http.StatusBadRequest).JSON(errors)
 	}
-	if email := updateValid["email"]; email != "" {
-		if userAlready, _ := h.userStore.GetUserByEmail(c.Context(), email); userAlready != nil {
-			return c.Status(http.StatusUnprocessableEntity).JSON(map[string]string{"email": "email in use"})
-
-		}
-	}
-	h.userStore.UpdateUser(c.Context(), userID, updateValid)
-	return c.JSON(types.MsgUpdated{Updated: userID})
+	return h.updateUser(c, userID, updateValid)
 }
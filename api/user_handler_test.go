@@ -9,30 +9,34 @@ import (
 	"testing"
 
 	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/mail"
 	"github.com/jucaza1/hotel-reserv/types"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type userTestDB struct {
 	db.UserStore
+	db.TokenStore
+	db.LockoutStore
 }
 
 func (tdb *userTestDB) userTeardown(t *testing.T) {
 	if err := tdb.UserStore.Drop(context.TODO()); err != nil {
 		t.Fatal(err)
 	}
+	if err := tdb.TokenStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tdb.LockoutStore.Drop(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func userSetup(t *testing.T) *userTestDB {
-	injectENV(t)
-	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(db.DBURI))
-	if err != nil {
-		t.Errorf("dbURI %s", db.DBURI)
-		t.Fatal(err)
-	}
+	store := newTestStore(t)
 	return &userTestDB{
-		UserStore: db.NewMongoUserStore(client, db.TestDBNAME),
+		UserStore:    store.User,
+		TokenStore:   store.Token,
+		LockoutStore: store.Lockout,
 	}
 }
 
@@ -41,7 +45,7 @@ func TestPostUser(t *testing.T) {
 	defer tdb.userTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	userHandler := NewUserHandler(tdb.UserStore)
+	userHandler := NewUserHandler(tdb.UserStore, NewVerificationHandler(tdb.UserStore, mail.NewOutbox(""), ""))
 	app.Post("/", userHandler.HandlePostUser)
 
 	params := types.CreateUserParams{
@@ -57,8 +61,8 @@ func TestPostUser(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	if resp.StatusCode != 200 {
-		t.Errorf("status code expected 200 but got %d", resp.StatusCode)
+	if resp.StatusCode != 201 {
+		t.Errorf("status code expected 201 but got %d", resp.StatusCode)
 	}
 	var user types.User
 	json.NewDecoder(resp.Body).Decode(&user)
@@ -70,7 +74,7 @@ func TestGetUser(t *testing.T) {
 	defer tdb.userTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	userHandler := NewUserHandler(tdb.UserStore)
+	userHandler := NewUserHandler(tdb.UserStore, NewVerificationHandler(tdb.UserStore, mail.NewOutbox(""), ""))
 	app.Get("/:id", userHandler.HandleGetUser)
 
 	params := types.CreateUserParams{
@@ -108,7 +112,7 @@ func TestGetUsers(t *testing.T) {
 	defer tdb.userTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	userHandler := NewUserHandler(tdb.UserStore)
+	userHandler := NewUserHandler(tdb.UserStore, NewVerificationHandler(tdb.UserStore, mail.NewOutbox(""), ""))
 	app.Get("/", userHandler.HandleGetUsers)
 
 	params := [2]types.CreateUserParams{
@@ -160,7 +164,7 @@ func TestDeleteUser(t *testing.T) {
 	defer tdb.userTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	userHandler := NewUserHandler(tdb.UserStore)
+	userHandler := NewUserHandler(tdb.UserStore, NewVerificationHandler(tdb.UserStore, mail.NewOutbox(""), ""))
 	app.Delete("/:id", userHandler.HandleDeleteUser)
 
 	params := types.CreateUserParams{
@@ -211,7 +215,7 @@ func TestPatchUser(t *testing.T) {
 	defer tdb.userTeardown(t)
 
 	app := NewFiberAppCentralErr()
-	userHandler := NewUserHandler(tdb.UserStore)
+	userHandler := NewUserHandler(tdb.UserStore, NewVerificationHandler(tdb.UserStore, mail.NewOutbox(""), ""))
 	app.Patch("/:id", userHandler.HandlePatchUser)
 
 	params := types.CreateUserParams{
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
@@ -8,7 +9,10 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/joho/godotenv"
 	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/db/memory"
 	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func NewFiberAppCentralErr() *fiber.App {
@@ -44,3 +48,25 @@ func injectENV(t *testing.T) {
 		t.Fatal("error: MONGO_DB_URI not found in .env")
 	}
 }
+
+// newTestStore returns the stores selected by TEST_DB_DRIVER: "mongo" uses
+// the test database, anything else runs in memory.
+func newTestStore(t *testing.T) *db.Store {
+	if err := godotenv.Load("../.env"); err != nil {
+		godotenv.Load("../default.env")
+	}
+	if os.Getenv("TEST_DB_DRIVER") != "mongo" {
+		return memory.NewStore()
+	}
+	injectENV(t)
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(db.DBURI))
+	if err != nil {
+		t.Errorf("dbURI %s", db.DBURI)
+		t.Fatal(err)
+	}
+	store, err := db.NewMongoStore(client, db.TestDBNAME)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
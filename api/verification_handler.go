@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/mail"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type VerificationHandler struct {
+	userStore db.UserStore
+	sender    mail.Sender
+	// publicURL is where users reach the API, verification links point to
+	// its /api/verify.
+	publicURL string
+}
+
+func NewVerificationHandler(us db.UserStore, sender mail.Sender, publicURL string) *VerificationHandler {
+	return &VerificationHandler{
+		userStore: us,
+		sender:    sender,
+		publicURL: publicURL,
+	}
+}
+
+// HandleVerifyEmail verifies the email of the user of the token of a
+// verification link.
+func (h *VerificationHandler) HandleVerifyEmail(c *fiber.Ctx) error {
+	claims, err := parseVerifyToken(c.Query("token"))
+	if err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	user, err := h.userStore.GetUserByID(c.Context(), claims.UserID)
+	if err != nil {
+		return err
+	}
+	if user.Email != claims.Email {
+		return types.ErrInvalidParams(fmt.Errorf("verification link of a previous email"))
+	}
+	if err := h.userStore.SetEmailVerified(c.Context(), user.ID, claims.Email, true); err != nil {
+		return err
+	}
+	return c.JSON(types.MsgVerified{Verified: user.ID})
+}
+
+// HandleResendVerification mails the user a new verification link.
+func (h *VerificationHandler) HandleResendVerification(c *fiber.Ctx) error {
+	user, ok := c.Context().UserValue("user").(types.User)
+	if !ok {
+		return types.ErrUnauthorized(fmt.Errorf("user not found"))
+	}
+	if user.EmailVerified {
+		return types.ErrInvalidParams(fmt.Errorf("email already verified"))
+	}
+	if err := h.sendVerification(c.Context(), &user); err != nil {
+		return err
+	}
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// sendVerification mails user a link verifying its email.
+func (h *VerificationHandler) sendVerification(ctx context.Context, user *types.User) error {
+	expires := time.Now().Add(verifyTTL)
+	token, err := createVerifyToken(user, expires)
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	link := fmt.Sprintf("%s/api/verify?token=%s", h.publicURL, url.QueryEscape(token))
+	if err := h.sender.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hello %s,\n\nOpen this link to verify your email before %s:\n\n%s\n\n"+
+			"If you did not create an account you can ignore this email.\n",
+			user.Firstname, expires.UTC().Format(time.RFC1123), link),
+	}); err != nil {
+		return types.ErrInternal(fmt.Errorf("sending email verification: %w", err))
+	}
+	return nil
+}
+
+// trySendVerification is sendVerification for a user already stored, which
+// is kept when the mail can not be sent. The failure is logged and the user
+// can ask for a new link.
+func (h *VerificationHandler) trySendVerification(ctx context.Context, user *types.User) {
+	if err := h.sendVerification(ctx, user); err != nil {
+		fmt.Println("failed to send email verification:", err)
+	}
+}
+
+// emailChanged flags the email of the user of userID not verified and mails
+// a link verifying it.
+func (h *VerificationHandler) emailChanged(ctx context.Context, userID string) error {
+	if err := h.userStore.SetEmailVerified(ctx, userID, "", false); err != nil {
+		return err
+	}
+	user, err := h.userStore.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	h.trySendVerification(ctx, user)
+	return nil
+}
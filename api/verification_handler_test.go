@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	middleware "github.com/jucaza1/hotel-reserv/api/middelware"
+	"github.com/jucaza1/hotel-reserv/mail"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+func TestHandleVerifyEmail(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	tdb := userSetup(t)
+	defer tdb.userTeardown(t)
+	outbox := mail.NewOutbox("")
+	verifyHandler := NewVerificationHandler(tdb.UserStore, outbox, "http://localhost:4000")
+	userHandler := NewUserHandler(tdb.UserStore, verifyHandler)
+	var userID string
+	// asUser provides the user as stored, like JWTAuthentication
+	asUser := func(c *fiber.Ctx) error {
+		user, err := tdb.UserStore.GetUserByID(c.Context(), userID)
+		if err != nil {
+			return err
+		}
+		c.Context().SetUserValue("user", *user)
+		return c.Next()
+	}
+	app := NewFiberAppCentralErr()
+	app.Post("/register", userHandler.HandlePostUser)
+	app.Get("/verify", verifyHandler.HandleVerifyEmail)
+	app.Post("/users/verify", asUser, verifyHandler.HandleResendVerification)
+	app.Patch("/users", asUser, userHandler.HandlePatchMyUser)
+	app.Post("/rooms/:id/bookings", asUser, middleware.VerifiedEmailMiddleware, func(c *fiber.Ctx) error {
+		return c.SendStatus(http.StatusCreated)
+	})
+	expectStatus := func(resp *http.Response, status int) {
+		t.Helper()
+		if resp.StatusCode != status {
+			t.Fatalf("expected the response status code to be %d but got %d", status, resp.StatusCode)
+		}
+	}
+	// lastLink returns the path of the last verification link mailed to email
+	lastLink := func(email string, count int) string {
+		t.Helper()
+		messages := outbox.Messages(email)
+		if len(messages) != count {
+			t.Fatalf("expected %d messages to %s but got %d", count, email, len(messages))
+		}
+		body := messages[count-1].Body
+		start := strings.Index(body, "http://localhost:4000/api/verify?token=")
+		if start < 0 {
+			t.Fatalf("expected a verification link in %q", body)
+		}
+		link, err := url.Parse(strings.Fields(body[start:])[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "/verify?" + link.RawQuery
+	}
+
+	resp := holdTestRequest(t, app, "POST", "/register", types.CreateUserParams{
+		Firstname: "testName",
+		Lastname:  "testLast",
+		Email:     "test@foo.com",
+		Password:  "secretpasstest",
+	})
+	expectStatus(resp, http.StatusCreated)
+	var user types.User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerified {
+		t.Fatalf("expected a new user with an unverified email")
+	}
+	userID = user.ID
+	link := lastLink(user.Email, 1)
+
+	// unverified users can not book
+	resp = holdTestRequest(t, app, "POST", "/rooms/0001/bookings", nil)
+	expectStatus(resp, http.StatusForbidden)
+	expectStatus(holdTestRequest(t, app, "GET", "/verify?token=forged", nil), http.StatusBadRequest)
+	expectStatus(holdTestRequest(t, app, "POST", "/users/verify", nil), http.StatusNoContent)
+	lastLink(user.Email, 2)
+
+	resp = holdTestRequest(t, app, "GET", link, nil)
+	expectStatus(resp, http.StatusOK)
+	var msg types.MsgVerified
+	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Verified != user.ID {
+		t.Fatalf("expected user %s verified but got %s", user.ID, msg.Verified)
+	}
+	expectStatus(holdTestRequest(t, app, "POST", "/rooms/0001/bookings", nil), http.StatusCreated)
+	expectStatus(holdTestRequest(t, app, "POST", "/users/verify", nil), http.StatusBadRequest)
+
+	// keeping the same email keeps it verified
+	expectStatus(holdTestRequest(t, app, "PATCH", "/users", types.UpdateUser{Email: user.Email, Firstname: "sameMail"}), http.StatusOK)
+	expectStatus(holdTestRequest(t, app, "POST", "/rooms/0001/bookings", nil), http.StatusCreated)
+	lastLink(user.Email, 2)
+
+	// a new email is unverified, and links mailed to the previous one void
+	expectStatus(holdTestRequest(t, app, "PATCH", "/users", types.UpdateUser{Email: "new@foo.com"}), http.StatusOK)
+	expectStatus(holdTestRequest(t, app, "POST", "/rooms/0001/bookings", nil), http.StatusForbidden)
+	expectStatus(holdTestRequest(t, app, "GET", link, nil), http.StatusBadRequest)
+	expectStatus(holdTestRequest(t, app, "GET", lastLink("new@foo.com", 1), nil), http.StatusOK)
+	verified, err := tdb.UserStore.GetUserByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verified.EmailVerified || verified.Email != "new@foo.com" {
+		t.Fatalf("expected new@foo.com verified but got %s verified %t", verified.Email, verified.EmailVerified)
+	}
+
+	// a user is created even when the link can not be mailed
+	broken := NewUserHandler(tdb.UserStore, NewVerificationHandler(tdb.UserStore, mail.NewOutbox(t.TempDir()), "http://localhost:4000"))
+	app.Post("/broken/register", broken.HandlePostUser)
+	expectStatus(holdTestRequest(t, app, "POST", "/broken/register", types.CreateUserParams{
+		Firstname: "otherName",
+		Lastname:  "otherLast",
+		Email:     "other@foo.com",
+		Password:  "secretpasstest",
+	}), http.StatusCreated)
+	if _, err := tdb.UserStore.GetUserByEmail(context.Background(), "other@foo.com"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+const verifyTTL = time.Hour * 48
+
+// verifyClaims binds an email verification link to the user and the email
+// address it was mailed to, so it can not verify an address changed since.
+type verifyClaims struct {
+	UserID string `json:"userID"`
+	Email  string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+func createVerifyToken(user *types.User, expires time.Time) (string, error) {
+	claims := verifyClaims{
+		UserID: user.ID,
+		Email:  user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "verify",
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+func parseVerifyToken(tokenStr string) (*verifyClaims, error) {
+	var claims verifyClaims
+	_, err := jwt.ParseWithClaims(tokenStr, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %s", token.Header["alg"])
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	}, jwt.WithExpirationRequired(), jwt.WithSubject("verify"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid verification token: %w", err)
+	}
+	return &claims, nil
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/payments"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type WebhookHandler struct {
+	eventStore   db.PaymentEventStore
+	bookStore    db.BookingStore
+	paymentStore db.PaymentStore
+	secret       []byte
+}
+
+func NewWebhookHandler(es db.PaymentEventStore, bs db.BookingStore, ps db.PaymentStore, secret []byte) *WebhookHandler {
+	return &WebhookHandler{
+		eventStore:   es,
+		bookStore:    bs,
+		paymentStore: ps,
+		secret:       secret,
+	}
+}
+
+// HandlePaymentWebhook records on a booking the payment status reported by
+// a payment event, signed by the processor with the shared secret, for a
+// payment of the booking. Events already processed are acknowledged without
+// processing them again.
+func (h *WebhookHandler) HandlePaymentWebhook(c *fiber.Ctx) error {
+	if !payments.VerifySignature(h.secret, c.Body(), c.Get(payments.SignatureHeader)) {
+		return types.ErrUnauthorized(fmt.Errorf("invalid webhook signature"))
+	}
+	var event types.PaymentEvent
+	if err := json.Unmarshal(c.Body(), &event); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := event.Validate(); err != nil {
+		return types.ErrInvalidParams(err)
+	}
+	if err := h.checkReference(c.Context(), &event); err != nil {
+		return err
+	}
+	status, _ := event.BookingPaymentStatus()
+	event.ReceivedAt = time.Now()
+	inserted, err := h.eventStore.InsertPaymentEvent(c.Context(), &event)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		return c.JSON(types.MsgReceived{Received: event.ID, Duplicate: true})
+	}
+	if _, err := h.bookStore.SetPaymentStatus(c.Context(), event.BookingID, status, event.CreatedAt); err != nil {
+		//let the processor redeliver the event
+		if delErr := h.eventStore.DeletePaymentEvent(c.Context(), event.ID); delErr != nil {
+			return delErr
+		}
+		return err
+	}
+	return c.JSON(types.MsgReceived{Received: event.ID})
+}
+
+// checkReference checks the payment the event settles is a payment of its
+// booking.
+func (h *WebhookHandler) checkReference(ctx context.Context, event *types.PaymentEvent) error {
+	if _, err := h.bookStore.GetBookingByID(ctx, event.BookingID); err != nil {
+		return err
+	}
+	payments, err := h.paymentStore.GetPaymentsByBooking(ctx, event.BookingID)
+	if err != nil {
+		return err
+	}
+	for _, payment := range payments {
+		if payment.Reference == event.Reference {
+			return nil
+		}
+	}
+	return types.ErrInvalidParams(fmt.Errorf("reference %s is not a payment of booking %s", event.Reference, event.BookingID))
+}
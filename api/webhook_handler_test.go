@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/payments"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestHandlePaymentWebhook(t *testing.T) {
+	tdb := bookingSetup(t)
+	defer tdb.bookingTeardown(t)
+	eventStore := newTestStore(t).PaymentEvent
+	defer eventStore.Drop(context.Background())
+
+	secret := []byte("test-webhook-secret")
+	app := NewFiberAppCentralErr()
+	webhookHandler := NewWebhookHandler(eventStore, tdb.BookingStore, tdb.PaymentStore, secret)
+	app.Post("/api/webhooks/payments", webhookHandler.HandlePaymentWebhook)
+
+	hotelID := seedTestHotel(t, tdb.HotelStore)
+	roomID := seedTestRoom(t, tdb.RoomStore, hotelID)
+	hotel := getTestHotel(t, tdb.HotelStore, hotelID)
+	booking, err := types.NewBookingFromParams(types.CreateBookingParams{
+		FromDate: time.Now().AddDate(0, 0, 1),
+		ToDate:   time.Now().AddDate(0, 0, 3),
+	}, "0000", hotel, roomID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booking, err = tdb.InsertBooking(context.Background(), booking); err != nil {
+		t.Fatal(err)
+	}
+	payment, err := tdb.InsertPayment(context.Background(), types.NewPayment(booking, payments.FakeProviderName, "fake_paid", eur(200)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(sender *payments.WebhookSender, event types.PaymentEvent, status int) types.MsgReceived {
+		req, err := sender.Request(context.Background(), &event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != status {
+			t.Fatalf("status code expected %d but got %d", status, resp.StatusCode)
+		}
+		var msg types.MsgReceived
+		if status == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return msg
+	}
+	paymentStatus := func() types.BookingPaymentStatus {
+		have, err := tdb.GetBookingByID(context.Background(), booking.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return have.PaymentStatus
+	}
+	sender := payments.NewWebhookSender("/api/webhooks/payments", secret)
+	paidAt := time.Now().UTC().Truncate(time.Millisecond)
+	paid := types.PaymentEvent{
+		ID:        "evt_paid",
+		Type:      types.PaymentEventSucceeded,
+		BookingID: booking.ID,
+		Reference: payment.Reference,
+		CreatedAt: paidAt,
+	}
+
+	// signed with another secret
+	send(payments.NewWebhookSender("/api/webhooks/payments", []byte("other")), paid, http.StatusUnauthorized)
+	if have := paymentStatus(); have != "" {
+		t.Fatalf("expected no payment status but got %s", have)
+	}
+
+	// a payment of another booking
+	other := paid
+	other.Reference = "fake_other"
+	send(sender, other, http.StatusBadRequest)
+	if have := paymentStatus(); have != "" {
+		t.Fatalf("expected no payment status but got %s", have)
+	}
+
+	// unknown event type
+	send(sender, types.PaymentEvent{ID: "evt_unknown", Type: "payment.unknown", BookingID: booking.ID}, http.StatusBadRequest)
+
+	msg := send(sender, paid, http.StatusOK)
+	if msg.Received != paid.ID || msg.Duplicate {
+		t.Fatalf("expected %s received once but got %+v", paid.ID, msg)
+	}
+	if have := paymentStatus(); have != types.BookingPaid {
+		t.Fatalf("expected payment status %s but got %s", types.BookingPaid, have)
+	}
+
+	// a redelivered event is not processed again
+	redelivered := paid
+	redelivered.Type = types.PaymentEventFailed
+	redelivered.CreatedAt = paidAt.Add(time.Minute)
+	if msg := send(sender, redelivered, http.StatusOK); !msg.Duplicate {
+		t.Fatalf("expected %s as duplicate but got %+v", paid.ID, msg)
+	}
+	// nor are events older than the last one processed
+	send(sender, types.PaymentEvent{
+		ID:        "evt_late",
+		Type:      types.PaymentEventFailed,
+		BookingID: booking.ID,
+		Reference: payment.Reference,
+		CreatedAt: paidAt.Add(-time.Minute),
+	}, http.StatusOK)
+	if have := paymentStatus(); have != types.BookingPaid {
+		t.Fatalf("expected payment status %s but got %s", types.BookingPaid, have)
+	}
+
+	// events for unknown bookings fail until the booking exists
+	missing := types.PaymentEvent{
+		ID:        "evt_missing",
+		Type:      types.PaymentEventFailed,
+		BookingID: primitive.NewObjectID().Hex(),
+		Reference: payment.Reference,
+	}
+	send(sender, missing, http.StatusNotFound)
+	send(sender, missing, http.StatusNotFound)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -11,43 +12,62 @@ import (
 	"github.com/jucaza1/hotel-reserv/api"
 	middleware "github.com/jucaza1/hotel-reserv/api/middelware"
 	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/db/memory"
+	"github.com/jucaza1/hotel-reserv/invoicing"
+	"github.com/jucaza1/hotel-reserv/mail"
+	"github.com/jucaza1/hotel-reserv/payments"
+	"github.com/jucaza1/hotel-reserv/pricing"
+	"github.com/jucaza1/hotel-reserv/promos"
+	"github.com/jucaza1/hotel-reserv/types"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// placeholderWebhookSecret is the secret earlier versions of default.env
+// shipped with, anyone can sign webhooks with it.
+const placeholderWebhookSecret = "change-me-webhook-secret"
+
 func main() {
 	if err := godotenv.Load(".env"); err != nil {
 		if err := godotenv.Load("default.env"); err != nil {
 			log.Fatal(err)
 		}
 	}
-	db.DBURI = os.Getenv("MONGO_DB_URI")
-	db.DBNAME = os.Getenv("MONGO_DB_NAME")
 	listenAddr := os.Getenv("HTTP_LISTEN_ADDRESS")
 	if listenAddr == "" {
 		log.Fatal("error: HTTP_LISTEN_ADDRESS not found in .env")
 	}
-	if db.DBNAME == "" {
-		log.Fatal("error: MONGO_DB_NAME not found in .env")
-	}
-	if db.DBURI == "" {
-		log.Fatal("error: MONGO_DB_URI not found in .env")
+	store := initStore(os.Getenv("DB_DRIVER"))
+	holdTTL := parseTTL("HOLD_TTL", api.DefaultHoldTTL)
+	accessTTL := parseTTL("ACCESS_TOKEN_TTL", api.DefaultAccessTokenTTL)
+	refreshTTL := parseTTL("REFRESH_TOKEN_TTL", api.DefaultRefreshTokenTTL)
+	resetTTL := parseTTL("PASSWORD_RESET_TTL", api.DefaultPasswordResetTTL)
+	sender := initMailSender(os.Getenv("MAIL_SENDER"))
+	publicURL := os.Getenv("PUBLIC_URL")
+	if publicURL == "" {
+		publicURL = "http://localhost" + listenAddr
+		log.Println("PUBLIC_URL not set, verification links point to", publicURL)
 	}
-	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(db.DBURI))
-	if err != nil {
-		log.Fatal(err)
+	provider := initPaymentProvider(os.Getenv("PAYMENT_PROVIDER"))
+	webhookSecret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	switch webhookSecret {
+	case "":
+		log.Println("PAYMENT_WEBHOOK_SECRET not set, payment webhooks are rejected")
+	case placeholderWebhookSecret:
+		log.Fatal("error: PAYMENT_WEBHOOK_SECRET is the public placeholder, set a secret of your own")
 	}
+	go expireHolds(store.Hold, promos.NewService(store.Promo), time.Minute)
 
-    app:= api.NewFiberAppCentralErr()
+	app := api.NewFiberAppCentralErr()
 
 	//CORS
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
 		// AllowOrigins:     "http://localhost:5173,",              // Specific origins
-		AllowMethods:     "GET,POST,PUT,DELETE",            // HTTP methods
-		AllowHeaders:     "Content-Type,X-Authorization",   // Custom headers
-		ExposeHeaders:    "Content-Length,X-Authorization", // Headers exposed to the client
-		AllowCredentials: false,                            // Allow cookies
+		AllowMethods:     "GET,POST,PUT,DELETE",                            // HTTP methods
+		AllowHeaders:     "Content-Type,X-Authorization",                   // Custom headers
+		ExposeHeaders:    "Content-Length,X-Authorization,X-Refresh-Token", // Headers exposed to the client
+		AllowCredentials: false,                                            // Allow cookies
 	}))
 	app.Options("/*", func(c *fiber.Ctx) error {
 		// c.Set("Access-Control-Allow-Origin", "*")
@@ -67,27 +87,63 @@ func main() {
 
 	//var initialization
 	var (
-		uStore         = db.NewMongoUserStore(client, db.DBNAME)
-		hStore         = db.NewMongoHotelStore(client, db.DBNAME)
-		rStore         = db.NewMongoRoomStore(client, db.DBNAME, hStore)
-		bStore         = db.NewMongoBookingStore(client, db.DBNAME)
-		userHandler    = api.NewUserHandler(uStore)
-		hotelHandler   = api.NewHotelHandler(hStore)
-		roomHandler    = api.NewRoomHandler(rStore, hStore)
-		bookingHandler = api.NewBookingHandler(bStore, rStore)
-		authHandler    = api.NewAuthHandler(uStore)
-		auth           = app.Group("/api")
-		apiv1          = app.Group("/api/v1", middleware.JWTAuthentication(uStore))
-		admin          = apiv1.Group("/admin", middleware.AdminMiddleware)
+		uStore          = store.User
+		hStore          = store.Hotel
+		rStore          = store.Room
+		bStore          = store.Booking
+		aStore          = store.Availability
+		rpStore         = store.RatePlan
+		pricer          = pricing.NewEngine(rpStore)
+		paymentService  = payments.NewService(provider, store.Payment, store.Folio)
+		invoices        = invoicing.NewService(store.Invoice, hStore, rStore, uStore, store.Folio)
+		promoService    = promos.NewService(store.Promo)
+		verifyHandler   = api.NewVerificationHandler(uStore, sender, publicURL)
+		userHandler     = api.NewUserHandler(uStore, verifyHandler)
+		hotelHandler    = api.NewHotelHandler(hStore, rStore, rpStore, store.Promo)
+		roomHandler     = api.NewRoomHandler(rStore, hStore)
+		bookingHandler  = api.NewBookingHandler(bStore, rStore, hStore, pricer, paymentService, store.Folio, invoices, promoService)
+		authHandler     = api.NewAuthHandler(uStore, store.Token, store.Lockout, accessTTL, refreshTTL)
+		passwordHandler = api.NewPasswordHandler(uStore, store.Token, store.Lockout, sender, resetTTL)
+		availHandler    = api.NewAvailabilityHandler(aStore)
+		rateHandler     = api.NewRatePlanHandler(rpStore, hStore, rStore)
+		holdHandler     = api.NewHoldHandler(store.Hold, bookingHandler, holdTTL)
+		paymentHandler  = api.NewPaymentHandler(paymentService, store.Payment, bStore)
+		folioHandler    = api.NewFolioHandler(store.Folio, bookingHandler)
+		invoiceHandler  = api.NewInvoiceHandler(invoices, bStore)
+		promoHandler    = api.NewPromoHandler(store.Promo, hStore)
+		roleHandler     = api.NewRoleHandler(uStore, hStore)
+		lockoutHandler  = api.NewLockoutHandler(store.Lockout)
+		webhookHandler  = api.NewWebhookHandler(store.PaymentEvent, bStore, store.Payment, []byte(webhookSecret))
+		auth            = app.Group("/api")
+		jwtAuth         = middleware.JWTAuthentication(uStore, store.Token)
+		apiv1           = app.Group("/api/v1", jwtAuth)
+		//routes under /admin check the role of the user on each route, as
+		//hotel staff may use some of them
+		admin        = apiv1.Group("/admin")
+		isAdmin      = middleware.AdminMiddleware
+		isVerified   = middleware.VerifiedEmailMiddleware
+		can          = middleware.RequirePermission
+		bookingHotel = middleware.BookingHotel(bStore)
+		paymentHotel = middleware.PaymentHotel(store.Payment, bStore)
 	)
 
 	//auth
 	auth.Post("/auth", authHandler.HandleAuthenticate)
 	auth.Post("/register", userHandler.HandlePostUser)
+	auth.Get("/verify", verifyHandler.HandleVerifyEmail)
+	auth.Post("/auth/refresh", authHandler.HandleRefresh)
+	auth.Post("/auth/logout", jwtAuth, authHandler.HandleLogout)
+	auth.Post("/auth/password/forgot", passwordHandler.HandleForgotPassword)
+	auth.Post("/auth/password/reset", passwordHandler.HandleResetPassword)
+
+	//payment processor callbacks, authenticated by their signature
+	app.Post("/api/webhooks/payments", webhookHandler.HandlePaymentWebhook)
 
 	//version api
 	apiv1.Get("/users", userHandler.HandleGetMyUser)
 	apiv1.Patch("/users", userHandler.HandlePatchMyUser)
+	apiv1.Post("/users/password", passwordHandler.HandleChangePassword)
+	apiv1.Post("/users/verify", verifyHandler.HandleResendVerification)
 
 	//hotel handler
 	apiv1.Get("/hotels", hotelHandler.HandleGetHotels)
@@ -97,36 +153,186 @@ func main() {
 	apiv1.Get("/hotels/:hid/rooms", roomHandler.HandleGetRoomsByHotelID)
 	apiv1.Get("rooms/:id", roomHandler.HandleGetRoomByID)
 
+	//availability handler
+	apiv1.Get("/availability", availHandler.HandleGetAvailability)
+
 	//booking handler
 	apiv1.Get("/rooms/:id/bookings", bookingHandler.HandleGetBookingsByRoom)
-	apiv1.Post("/rooms/:id/bookings", bookingHandler.HandlePostBooking)
+	apiv1.Post("/rooms/:id/bookings", isVerified, bookingHandler.HandlePostBooking)
+	apiv1.Post("/rooms/:id/quote", bookingHandler.HandlePostQuote)
 	apiv1.Get("/hotels/:hid/bookings", bookingHandler.HandleGetBookingsByHotel)
 	apiv1.Get("/bookings", bookingHandler.HandleGetBookings)
+	apiv1.Post("/bookings/group", isVerified, bookingHandler.HandlePostGroupBooking)
+	apiv1.Get("/bookings/group/:id", bookingHandler.HandleGetGroupBooking)
+	apiv1.Patch("/bookings/group/:id", bookingHandler.HandleCancelGroupBooking)
+	apiv1.Put("/bookings/group/:id", bookingHandler.HandleModifyGroupBooking)
 	apiv1.Patch("/bookings/:id", bookingHandler.HandleCancelBooking)
+	apiv1.Put("/bookings/:id", bookingHandler.HandleModifyBooking)
+
+	//payment handler
+	apiv1.Get("/bookings/:id/payments", paymentHandler.HandleGetBookingPayments)
+
+	//folio handler
+	apiv1.Get("/bookings/:id/folio", folioHandler.HandleGetFolio)
+
+	//invoice handler
+	apiv1.Get("/bookings/:id/invoice", invoiceHandler.HandleGetInvoice)
+
+	//hold handler
+	apiv1.Post("/rooms/:id/holds", isVerified, holdHandler.HandlePostHold)
+	apiv1.Get("/holds/:id", holdHandler.HandleGetHold)
+	apiv1.Post("/holds/:id/booking", isVerified, holdHandler.HandlePostHoldBooking)
+	apiv1.Delete("/holds/:id", holdHandler.HandleDeleteHold)
 
 	//admin only user handlers
-	admin.Patch("/users/:id", userHandler.HandlePatchUser)
-	admin.Delete("/users/:id", userHandler.HandleDeleteUser)
-	admin.Post("/users", userHandler.HandlePostUser)
-	admin.Post("/users/admin", userHandler.HandlePostAdminUser)
-	admin.Get("/users/me", userHandler.HandleGetMyUser)
-	admin.Get("/users", userHandler.HandleGetUsers)
-	admin.Get("/users/:id", userHandler.HandleGetUser)
-
-	//admin only room handlers
-	admin.Delete("/rooms/id", roomHandler.HandleDeleteRoom)
-	admin.Post("/hotels/:hid/rooms/", roomHandler.HandlePostRoom)
+	admin.Patch("/users/:id", isAdmin, userHandler.HandlePatchUser)
+	admin.Delete("/users/:id", isAdmin, userHandler.HandleDeleteUser)
+	admin.Post("/users", isAdmin, userHandler.HandlePostUser)
+	admin.Post("/users/admin", isAdmin, userHandler.HandlePostAdminUser)
+	admin.Get("/users/me", isAdmin, userHandler.HandleGetMyUser)
+	admin.Get("/users", isAdmin, userHandler.HandleGetUsers)
+	admin.Get("/users/:id", isAdmin, userHandler.HandleGetUser)
+
+	//admin only role handlers
+	admin.Post("/users/:id/roles", isAdmin, roleHandler.HandleGrantRole)
+	admin.Delete("/users/:id/roles/:role", isAdmin, roleHandler.HandleRevokeRole)
+
+	//admin only login lockout handlers
+	admin.Get("/lockouts", isAdmin, lockoutHandler.HandleGetLockoutEvents)
+	admin.Post("/lockouts/unlock", isAdmin, lockoutHandler.HandleUnlock)
+
+	//hotel staff room handlers
+	admin.Delete("/rooms/:id", can(types.PermManageRooms, middleware.RoomHotel(rStore)), roomHandler.HandleDeleteRoom)
+	admin.Post("/hotels/:hid/rooms/", can(types.PermManageRooms, middleware.HotelParam("hid")), roomHandler.HandlePostRoom)
 
 	//admin only hotel handler
-	admin.Delete("/hotels/:id", hotelHandler.HandleDeleteHotel, roomHandler.HandleDeleteRoomsByHotel)
-	admin.Post("/hotels", hotelHandler.HandlePostHotel)
-	admin.Patch("/hotels/:id", hotelHandler.HandlePatchHotel)
-	admin.Delete("/bookings/:id", bookingHandler.HandleDeleteBooking)
+	admin.Delete("/hotels/:id", isAdmin, hotelHandler.HandleDeleteHotel, roomHandler.HandleDeleteRoomsByHotel)
+	admin.Post("/hotels", isAdmin, hotelHandler.HandlePostHotel)
+
+	//hotel staff hotel and booking handlers
+	admin.Patch("/hotels/:id", can(types.PermManageHotel, middleware.HotelParam("id")), hotelHandler.HandlePatchHotel)
+	admin.Delete("/bookings/:id", can(types.PermManageBookings, bookingHotel), bookingHandler.HandleDeleteBooking)
+	admin.Post("/bookings/:id/check-in", can(types.PermManageBookings, bookingHotel), bookingHandler.HandleCheckIn)
+	admin.Post("/bookings/:id/check-out", can(types.PermManageBookings, bookingHotel), bookingHandler.HandleCheckOut)
+	admin.Post("/bookings/:id/no-show", can(types.PermManageBookings, bookingHotel), bookingHandler.HandleNoShow)
+
+	//hotel staff payment handler
+	admin.Post("/payments/:id/capture", can(types.PermManagePayments, paymentHotel), paymentHandler.HandleCapturePayment)
+	admin.Post("/payments/:id/refund", can(types.PermManagePayments, paymentHotel), paymentHandler.HandleRefundPayment)
+	admin.Post("/payments/:id/void", can(types.PermManagePayments, paymentHotel), paymentHandler.HandleVoidPayment)
+
+	//hotel staff folio handler
+	admin.Post("/bookings/:id/folio", can(types.PermManageFolios, bookingHotel), folioHandler.HandlePostFolioItem)
+	admin.Post("/folio/:id/void", can(types.PermManageFolios, middleware.FolioItemHotel(store.Folio, bStore)), folioHandler.HandleVoidFolioItem)
+
+	//hotel staff rate plan handler
+	admin.Get("/hotels/:hid/rates", can(types.PermManageRates, middleware.HotelParam("hid")), rateHandler.HandleGetRatePlans)
+	admin.Post("/hotels/:hid/rates", can(types.PermManageRates, middleware.HotelParam("hid")), rateHandler.HandlePostRatePlan)
+	admin.Delete("/hotels/:hid/rates/:id", can(types.PermManageRates, middleware.HotelParam("hid")), rateHandler.HandleDeleteRatePlan)
+
+	//admin only promo handler
+	admin.Get("/promos", isAdmin, promoHandler.HandleGetPromos)
+	admin.Post("/promos", isAdmin, promoHandler.HandlePostPromo)
+	admin.Get("/promos/:id", isAdmin, promoHandler.HandleGetPromo)
+	admin.Delete("/promos/:id", isAdmin, promoHandler.HandleDeletePromo)
+	admin.Get("/promos/:id/redemptions", isAdmin, promoHandler.HandleGetPromoRedemptions)
 
 	log.Println("app listening on port ", listenAddr)
 	log.Fatal(app.Listen(listenAddr))
 }
 
+// initStore builds the stores for the configured driver: "memory" keeps
+// everything in process, anything else connects to MongoDB.
+func initStore(driver string) *db.Store {
+	if driver == "memory" {
+		log.Println("using in-memory stores, data is lost on restart")
+		return memory.NewStore()
+	}
+	db.DBURI = os.Getenv("MONGO_DB_URI")
+	db.DBNAME = os.Getenv("MONGO_DB_NAME")
+	if db.DBNAME == "" {
+		log.Fatal("error: MONGO_DB_NAME not found in .env")
+	}
+	if db.DBURI == "" {
+		log.Fatal("error: MONGO_DB_URI not found in .env")
+	}
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(db.DBURI))
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := db.NewMongoStore(client, db.DBNAME)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return store
+}
+
+// initPaymentProvider builds the configured payment provider. Only "fake"
+// exists for now, it keeps its charges in FAKE_PAYMENTS_FILE when set.
+func initPaymentProvider(name string) payments.PaymentProvider {
+	switch name {
+	case "", payments.FakeProviderName:
+		provider, err := payments.NewFakeProvider(os.Getenv("FAKE_PAYMENTS_FILE"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("using the fake payment provider, no money is moved")
+		return provider
+	}
+	log.Fatalf("error: unknown PAYMENT_PROVIDER %q in .env", name)
+	return nil
+}
+
+// initMailSender builds the configured mail sender. Only "outbox" exists
+// for now, it delivers nothing and appends the messages to MAIL_OUTBOX_FILE
+// when set.
+func initMailSender(name string) mail.Sender {
+	switch name {
+	case "", mail.OutboxSenderName:
+		log.Println("using the mail outbox, no email is delivered")
+		return mail.NewOutbox(os.Getenv("MAIL_OUTBOX_FILE"))
+	}
+	log.Fatalf("error: unknown MAIL_SENDER %q in .env", name)
+	return nil
+}
+
+// parseTTL reads the env var key as a Go duration, such as "15m",
+// defaulting to def when it is empty.
+func parseTTL(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Fatalf("error: invalid %s %q in .env", key, value)
+	}
+	return ttl
+}
+
+// expireHolds frees the nights of abandoned holds every interval, and the
+// promo code redemptions of holds abandoned while being booked. Expired
+// holds already stop blocking rooms, this only cleans them up.
+func expireHolds(store db.HoldStore, promoService *promos.Service, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		expired, err := store.ExpireHolds(context.Background(), now)
+		if err != nil {
+			log.Println("error expiring holds:", err)
+			continue
+		}
+		for _, id := range expired {
+			if err := promoService.Release(context.Background(), id); err != nil {
+				log.Printf("error releasing the promo code of hold %s: %v", id, err)
+			}
+		}
+		if len(expired) > 0 {
+			log.Printf("expired %d holds", len(expired))
+		}
+	}
+}
+
 //docker run -d --name YOUR_CONTAINER_NAME_HERE -p YOUR_LOCALHOST_PORT_HERE:27017 -e MONGO_INITDB_ROOT_USERNAME=YOUR_USERNAME_HERE -e MONGO_INITDB_ROOT_PASSWORD=YOUR_PASSWORD_HERE mongo
 //docker run --name mongodb -p 27017:27017 -d mongo:latest
 //go get go.mongodb.org/mongo-driver/mongo
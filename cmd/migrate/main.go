@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/jucaza1/hotel-reserv/db"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrate upgrades the documents of MONGO_DB_NAME written by older versions
+// of the API.
+func main() {
+	if err := godotenv.Load(".env"); err != nil {
+		if err := godotenv.Load("default.env"); err != nil {
+			log.Fatal(err)
+		}
+	}
+	db.DBURI = os.Getenv("MONGO_DB_URI")
+	db.DBNAME = os.Getenv("MONGO_DB_NAME")
+	if db.DBNAME == "" {
+		log.Fatal("error: MONGO_DB_NAME not found in .env")
+	}
+	if db.DBURI == "" {
+		log.Fatal("error: MONGO_DB_URI not found in .env")
+	}
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(db.DBURI))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := db.MigrateLegacyPrices(context.TODO(), client, db.DBNAME); err != nil {
+		log.Fatal(err)
+	}
+	if err := db.MigrateRoomNightLedger(context.TODO(), client, db.DBNAME); err != nil {
+		log.Fatal(err)
+	}
+	if err := db.MigrateEmailVerification(context.TODO(), client, db.DBNAME); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -9,17 +9,25 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/pricing"
 	"github.com/jucaza1/hotel-reserv/types"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func main() {
-	us, hs, rs, bs := initialization()
+	store := initialization()
+	var (
+		us     = store.User
+		hs     = store.Hotel
+		rs     = store.Room
+		bs     = store.Booking
+		engine = pricing.NewEngine(store.RatePlan)
+	)
 	var hotels [3]string
-	hotels[0] = seedHotel("Maria", "Spain", 5, hs)
-	hotels[1] = seedHotel("Rose", "France", 4, hs)
-	hotels[2] = seedHotel("Sheena", "Portugal", 3, hs)
+	hotels[0] = seedHotel("Maria", "Spain", "Europe/Madrid", 10, 5, hs)
+	hotels[1] = seedHotel("Rose", "France", "Europe/Paris", 10, 4, hs)
+	hotels[2] = seedHotel("Sheena", "Portugal", "Europe/Lisbon", 6, 3, hs)
 
 	var rooms [12]string
 	rooms[0] = seedRoom(hotels[0], types.Small, 100, rs)
@@ -37,6 +45,18 @@ func main() {
 	rooms[10] = seedRoom(hotels[2], types.Large, 129, rs)
 	rooms[11] = seedRoom(hotels[2], types.Extra, 135, rs)
 
+	seedRatePlan(hotels[0], types.CreateRatePlanParams{
+		Name: "Weekend",
+		WeekdayMultipliers: map[string]float64{
+			"friday":   1.2,
+			"saturday": 1.2,
+		},
+	}, store.RatePlan)
+	seedRatePlan(hotels[1], types.CreateRatePlanParams{
+		Name:          "Long stay",
+		StayDiscounts: []types.StayDiscount{{MinNights: 4, Percent: 10}},
+	}, store.RatePlan)
+
 	var users [4]string
 	users[0] = seedUser(true, "admin", "instrator", "admin@mail.com", "mysecretpassword", us)
 	users[1] = seedUser(false, "Levi", "Ackerman", "levi@mail.com", "topsecret", us)
@@ -44,20 +64,20 @@ func main() {
 	users[3] = seedUser(false, "Karl", "Fritz", "karl@mail.com", "topsecret", us)
 
 	var bookings [9]string
-	bookings[0] = seedBooking(users[1], hotels[0], rooms[0], 20, 25, bs)
-	bookings[1] = seedBooking(users[1], hotels[1], rooms[4], 26, 30, bs)
-	bookings[2] = seedBooking(users[1], hotels[2], rooms[8], 31, 35, bs)
-	bookings[3] = seedBooking(users[2], hotels[0], rooms[1], 20, 25, bs)
-	bookings[4] = seedBooking(users[2], hotels[1], rooms[5], 26, 30, bs)
-	bookings[5] = seedBooking(users[2], hotels[2], rooms[9], 31, 35, bs)
-	bookings[6] = seedBooking(users[3], hotels[0], rooms[0], 15, 19, bs)
-	bookings[7] = seedBooking(users[3], hotels[1], rooms[4], 20, 25, bs)
-	bookings[8] = seedBooking(users[3], hotels[2], rooms[8], 26, 30, bs)
+	bookings[0] = seedBooking(users[1], hotels[0], rooms[0], 20, 25, hs, rs, bs, engine)
+	bookings[1] = seedBooking(users[1], hotels[1], rooms[4], 26, 30, hs, rs, bs, engine)
+	bookings[2] = seedBooking(users[1], hotels[2], rooms[8], 31, 35, hs, rs, bs, engine)
+	bookings[3] = seedBooking(users[2], hotels[0], rooms[1], 20, 25, hs, rs, bs, engine)
+	bookings[4] = seedBooking(users[2], hotels[1], rooms[5], 26, 30, hs, rs, bs, engine)
+	bookings[5] = seedBooking(users[2], hotels[2], rooms[9], 31, 35, hs, rs, bs, engine)
+	bookings[6] = seedBooking(users[3], hotels[0], rooms[0], 15, 19, hs, rs, bs, engine)
+	bookings[7] = seedBooking(users[3], hotels[1], rooms[4], 20, 25, hs, rs, bs, engine)
+	bookings[8] = seedBooking(users[3], hotels[2], rooms[8], 26, 30, hs, rs, bs, engine)
 
 	fmt.Println("seeding the Database")
 }
 
-func initialization() (us db.UserStore, hs db.HotelStore, rs db.RoomStore, bs db.BookingStore) {
+func initialization() *db.Store {
 	if err := godotenv.Load(".env"); err != nil {
 		if err := godotenv.Load("default.env"); err != nil {
 			log.Fatal(err)
@@ -78,19 +98,31 @@ func initialization() (us db.UserStore, hs db.HotelStore, rs db.RoomStore, bs db
 	if err := client.Database(db.DBNAME).Drop(context.TODO()); err != nil {
 		log.Fatal(err)
 	}
-	userStore := db.NewMongoUserStore(client, db.DBNAME)
-	hotelStore := db.NewMongoHotelStore(client, db.DBNAME)
-	roomStore := db.NewMongoRoomStore(client, db.DBNAME, hotelStore)
-	bookingStore := db.NewMongoBookingStore(client, db.DBNAME)
-	return userStore, hotelStore, roomStore, bookingStore
+	store, err := db.NewMongoStore(client, db.DBNAME)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return store
 }
 
-func seedBooking(userID, hotelID, roomID string, fromDate, toDate int, bs db.BookingStore) (bookingID string) {
+func seedBooking(userID, hotelID, roomID string, fromDate, toDate int, hs db.HotelStore, rs db.RoomStore, bs db.BookingStore, engine *pricing.Engine) (bookingID string) {
 	params := types.CreateBookingParams{
-		FromDate: time.Now().Add(time.Hour * 24 * time.Duration(toDate)),
-		ToDate:   time.Now().Add(time.Hour * 24 * time.Duration(fromDate)),
+		FromDate: time.Now().AddDate(0, 0, fromDate),
+		ToDate:   time.Now().AddDate(0, 0, toDate),
 	}
-	booking, err := types.NewBookingFromParams(params, userID, hotelID, roomID)
+	hotel, err := hs.GetHotelByID(context.Background(), hotelID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	room, err := rs.GetRoom(context.Background(), roomID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	booking, err := types.NewBookingFromParams(params, userID, hotel, roomID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	booking.Price, err = engine.Price(context.Background(), hotel, room, booking.Nights, booking.Guests)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -101,10 +133,21 @@ func seedBooking(userID, hotelID, roomID string, fromDate, toDate int, bs db.Boo
 	return booking.ID
 }
 
+func seedRatePlan(hotelID string, params types.CreateRatePlanParams, rps db.RatePlanStore) (ratePlanID string) {
+	if errors := params.Validate(); len(errors) > 0 {
+		log.Fatal(errors)
+	}
+	plan, err := rps.InsertRatePlan(context.Background(), types.NewRatePlanFromParams(params, hotelID))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return plan.ID
+}
+
 func seedRoom(hotelID string, size types.RoomSize, price float64, rs db.RoomStore) (roomID string) {
 	params := types.CreateRoomParams{
 		Size:  size,
-		Price: price,
+		Price: types.MoneyFromFloat(price, "EUR"),
 	}
 	room := types.NewRoomFromParams(params)
 	room.HotelID = hotelID
@@ -115,15 +158,19 @@ func seedRoom(hotelID string, size types.RoomSize, price float64, rs db.RoomStor
 	return room.ID
 }
 
-func seedHotel(name, location string, rating int, hs db.HotelStore) (hotelID string) {
+func seedHotel(name, location, timeZone string, taxRate float64, rating int, hs db.HotelStore) (hotelID string) {
 	ctx := context.Background()
-	hotel := types.Hotel{
+	hotel, err := types.NewHotelFromParams(types.CreateHotelParams{
 		Name:     name,
 		Location: location,
-		Rooms:    []string{},
 		Rating:   rating,
+		TimeZone: timeZone,
+		TaxRate:  taxRate,
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
-	insertedHotel, err := hs.InsertHotel(ctx, &hotel)
+	insertedHotel, err := hs.InsertHotel(ctx, hotel)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -142,6 +189,7 @@ func seedUser(isAdmin bool, fname, lname, email, password string, us db.UserStor
 		log.Fatal(err)
 	}
 	user.IsAdmin = isAdmin
+	user.EmailVerified = true
 	user, err = us.InsertUser(ctx, user)
 	if err != nil {
 		log.Fatal(err)
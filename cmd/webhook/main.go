@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/jucaza1/hotel-reserv/payments"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+// webhook stands in for the payment processor, sending a payment event for
+// a booking to a running API signed with PAYMENT_WEBHOOK_SECRET.
+func main() {
+	var (
+		url       = flag.String("url", "http://localhost:4000/api/webhooks/payments", "webhook URL of the API")
+		bookingID = flag.String("booking", "", "ID of the booking paid")
+		reference = flag.String("reference", "", "provider reference of the payment of the booking")
+		eventType = flag.String("type", string(types.PaymentEventSucceeded), "payment.succeeded or payment.failed")
+		eventID   = flag.String("id", "", "event ID, reuse one to redeliver an event (default a new one)")
+		reason    = flag.String("reason", "", "failure reason of payment.failed events")
+	)
+	flag.Parse()
+	if err := godotenv.Load(".env"); err != nil {
+		if err := godotenv.Load("default.env"); err != nil {
+			log.Fatal(err)
+		}
+	}
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		log.Fatal("error: PAYMENT_WEBHOOK_SECRET not found in .env")
+	}
+	if *bookingID == "" || *reference == "" {
+		log.Fatal("error: -booking and -reference are required")
+	}
+	event := &types.PaymentEvent{
+		ID:            *eventID,
+		Type:          types.PaymentEventType(*eventType),
+		BookingID:     *bookingID,
+		Reference:     *reference,
+		FailureReason: *reason,
+	}
+	sender := payments.NewWebhookSender(*url, []byte(secret))
+	if err := sender.Send(context.Background(), event); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("delivered event %s", event.ID)
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type AvailabilityStore interface {
+	// GetAvailableRooms returns the rooms free for every day of the stay,
+	// grouped by hotel.
+	GetAvailableRooms(ctx context.Context, params types.AvailabilityParams) ([]*types.HotelAvailability, error)
+}
+
+type MongoAvailabilityStore struct {
+	client *mongo.Client
+	rooms  *mongo.Collection
+}
+
+func NewMongoAvailabilityStore(client *mongo.Client, dbname string) *MongoAvailabilityStore {
+	return &MongoAvailabilityStore{
+		client: client,
+		rooms:  client.Database(dbname).Collection(roomColl),
+	}
+}
+
+func (s *MongoAvailabilityStore) GetAvailableRooms(ctx context.Context, params types.AvailabilityParams) ([]*types.HotelAvailability, error) {
+	roomFilter := bson.M{}
+	if params.Size != 0 {
+		roomFilter["size"] = params.Size
+	}
+	if params.MaxPrice.Amount > 0 {
+		roomFilter["price.amount"] = bson.M{"$lte": params.MaxPrice.Amount}
+		roomFilter["price.currency"] = params.MaxPrice.Currency
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: roomFilter}},
+		//drop rooms with any of the requested nights booked or held
+		{{Key: "$lookup", Value: bson.M{
+			"from": roomNightColl,
+			"let":  bson.M{"roomID": bson.M{"$toString": "$_id"}},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"$expr": bson.M{"$and": bson.A{
+					bson.M{"$eq": bson.A{"$roomID", "$$roomID"}},
+					bson.M{"$in": bson.A{"$night", params.Nights()}},
+				}}}},
+				bson.M{"$match": activeNight(time.Now())},
+				bson.M{"$limit": 1},
+			},
+			"as": "taken",
+		}}},
+		{{Key: "$match", Value: bson.M{"taken": bson.M{"$size": 0}}}},
+		{{Key: "$project", Value: bson.M{"taken": 0}}},
+		{{Key: "$group", Value: bson.M{"_id": "$hotelID", "rooms": bson.M{"$push": "$$ROOT"}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from": hotelColl,
+			"let": bson.M{"hotelID": bson.M{"$convert": bson.M{
+				"input": "$_id", "to": "objectId", "onError": nil, "onNull": nil,
+			}}},
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$_id", "$$hotelID"}}}},
+			},
+			"as": "hotel",
+		}}},
+		{{Key: "$unwind", Value: "$hotel"}},
+	}
+	if params.Location != "" {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{
+			"hotel.location": bson.M{"$regex": regexp.QuoteMeta(params.Location), "$options": "i"},
+		}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.M{"_id": 1}}})
+
+	cur, err := s.rooms.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	availability := []*types.HotelAvailability{}
+	if err := cur.All(ctx, &availability); err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return availability, nil
+}
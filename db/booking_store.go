@@ -10,12 +10,20 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const bookingColl = "bookings"
 
 type BookingStore interface {
 	InsertBooking(ctx context.Context, booking *types.Booking) (*types.Booking, error)
+	// InsertBookingGroup stores bookings as one group, giving them a new
+	// common GroupID. Either every booking is stored, with all its nights,
+	// or none is.
+	InsertBookingGroup(ctx context.Context, bookings []*types.Booking) ([]*types.Booking, error)
+	// IsRoomAvailable reports whether every night of roomID is free, without
+	// reserving them.
+	IsRoomAvailable(ctx context.Context, roomID string, nights []string) (bool, error)
 	GetBookings(ctx context.Context) ([]*types.Booking, error)
 	GetBookingsByRoom(ctx context.Context, roomID string) ([]*types.Booking, error)
 	GetBookingsByHotel(ctx context.Context, hotelID string) ([]*types.Booking, error)
@@ -23,7 +31,33 @@ type BookingStore interface {
 	GetBookingsByUserAndHotel(ctx context.Context, userID, hotelID string) ([]*types.Booking, error)
 	GetBookingsByUserAndRoom(ctx context.Context, userID, roomID string) ([]*types.Booking, error)
 	GetBookingByID(ctx context.Context, bookingID string) (*types.Booking, error)
-	CancelBooking(ctx context.Context, bookingID string) error
+	GetBookingsByGroup(ctx context.Context, groupID string) ([]*types.Booking, error)
+	// CancelBooking stores cancelled, previous as read from the store after
+	// types.Booking.Cancel, and frees its nights. It fails if previous
+	// changed since it was read.
+	CancelBooking(ctx context.Context, previous, cancelled *types.Booking) (*types.Booking, error)
+	// CancelBookingGroup is CancelBooking for several bookings at once, each
+	// of previous replaced with the one of cancelled at the same index.
+	// Either every booking is cancelled or none is.
+	CancelBookingGroup(ctx context.Context, previous, cancelled []*types.Booking) ([]*types.Booking, error)
+	// TransitionBooking moves a booking to status, see types.Booking.Transition,
+	// and returns the updated booking.
+	TransitionBooking(ctx context.Context, bookingID string, status types.BookingStatus) (*types.Booking, error)
+	// RevertTransition undoes the last transition of a booking, which
+	// moved it to status, see types.Booking.Revert, and returns the updated
+	// booking.
+	RevertTransition(ctx context.Context, bookingID string, status types.BookingStatus) (*types.Booking, error)
+	// ModifyBooking atomically replaces the stay of previous, as read from
+	// the store, with the one of modified. It fails if the new nights are
+	// taken by another booking or previous changed since it was read.
+	ModifyBooking(ctx context.Context, previous, modified *types.Booking) (*types.Booking, error)
+	// ModifyBookingGroup is ModifyBooking for several bookings at once, each
+	// of previous replaced with the one of modified at the same index.
+	// Either every booking is modified or none is.
+	ModifyBookingGroup(ctx context.Context, previous, modified []*types.Booking) ([]*types.Booking, error)
+	// SetPaymentStatus records status, reported at at, on a booking and
+	// returns it. Reports older than the recorded one leave it unchanged.
+	SetPaymentStatus(ctx context.Context, bookingID string, status types.BookingPaymentStatus, at time.Time) (*types.Booking, error)
 	DeleteBooking(ctx context.Context, bookingID string) error
 
 	Dropper
@@ -32,51 +66,100 @@ type BookingStore interface {
 type MongoBookingStore struct {
 	client *mongo.Client
 	coll   *mongo.Collection
+	ledger roomNightLedger
 }
 
 func NewMongoBookingStore(client *mongo.Client, dbname string) *MongoBookingStore {
 	return &MongoBookingStore{
 		client: client,
 		coll:   client.Database(dbname).Collection(bookingColl),
+		ledger: newRoomNightLedger(client, dbname),
 	}
 }
 
 func (s *MongoBookingStore) Drop(ctx context.Context) error {
 	fmt.Println("--- dropping booking collection")
+	if err := s.ledger.drop(ctx); err != nil {
+		return err
+	}
 	return s.coll.Drop(ctx)
 }
 
 func (s *MongoBookingStore) InsertBooking(ctx context.Context, booking *types.Booking) (*types.Booking, error) {
-	//check if date is available
-	filter := bson.D{
-		{"roomID", booking.RoomID},
-		{"cancelled", false},
-		{"$or", bson.A{
-			bson.D{{"fromDate", bson.D{{"$gte", booking.FromDate}}}, {"fromDate", bson.D{{"$lte", booking.ToDate}}}},
-			bson.D{{"fromDate", bson.D{{"$lte", booking.FromDate}}}, {"toDate", bson.D{{"$gte", booking.ToDate}}}},
-			bson.D{{"toDate", bson.D{{"$gte", booking.FromDate}}}, {"toDate", bson.D{{"$lte", booking.ToDate}}}},
-		}},
+	//claim the room nights first so concurrent inserts can not both succeed
+	oid := primitive.NewObjectID()
+	if err := s.ledger.reserve(ctx, booking.RoomID, oid.Hex(), booking.Nights, time.Time{}); err != nil {
+		return nil, err
 	}
-	cur, err := s.coll.Find(ctx, filter)
-	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+	doc, err := documentWithID(oid, booking)
+	if err != nil {
+		s.ledger.release(ctx, oid.Hex())
 		return nil, types.ErrInternal(err)
 	}
-	var bookings []*types.Booking
-	if !errors.Is(err, mongo.ErrNoDocuments) {
-		if err = cur.All(ctx, &bookings); err != nil {
+	if _, err := s.coll.InsertOne(ctx, doc); err != nil {
+		s.ledger.release(ctx, oid.Hex())
+		return nil, types.ErrInternal(err)
+	}
+	booking.ID = oid.Hex()
+	return booking, nil
+}
+
+// InsertBookingGroup claims the nights of every booking before writing any,
+// releasing the ones claimed when a booking can not get its room.
+func (s *MongoBookingStore) InsertBookingGroup(ctx context.Context, bookings []*types.Booking) ([]*types.Booking, error) {
+	groupID := primitive.NewObjectID().Hex()
+	oids := make([]primitive.ObjectID, len(bookings))
+	docs := make([]any, len(bookings))
+	for i, booking := range bookings {
+		oids[i] = primitive.NewObjectID()
+		if err := s.ledger.reserve(ctx, booking.RoomID, oids[i].Hex(), booking.Nights, time.Time{}); err != nil {
+			if relErr := s.releaseGroup(ctx, oids[:i]); relErr != nil {
+				return nil, relErr
+			}
+			return nil, err
+		}
+		stored := *booking
+		stored.GroupID = groupID
+		doc, err := documentWithID(oids[i], &stored)
+		if err != nil {
+			if relErr := s.releaseGroup(ctx, oids[:i+1]); relErr != nil {
+				return nil, relErr
+			}
 			return nil, types.ErrInternal(err)
 		}
+		docs[i] = doc
 	}
-	if len(bookings) > 0 {
-		return nil, types.ErrUnavailableDate(fmt.Errorf("unavailable date"))
-	}
-	resp, err := s.coll.InsertOne(ctx, booking)
-	if err != nil {
+	if _, err := s.coll.InsertMany(ctx, docs); err != nil {
+		//an ordered insert may have written some bookings before failing
+		if _, delErr := s.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": oids}}); delErr != nil {
+			return nil, types.ErrInternal(delErr)
+		}
+		if relErr := s.releaseGroup(ctx, oids); relErr != nil {
+			return nil, relErr
+		}
 		return nil, types.ErrInternal(err)
 	}
-	booking.ID = resp.InsertedID.(primitive.ObjectID).Hex()
-	return booking, nil
+	for i, booking := range bookings {
+		booking.ID = oids[i].Hex()
+		booking.GroupID = groupID
+	}
+	return bookings, nil
 }
+
+// releaseGroup frees the nights of the bookings of oids.
+func (s *MongoBookingStore) releaseGroup(ctx context.Context, oids []primitive.ObjectID) error {
+	for _, oid := range oids {
+		if err := s.ledger.release(ctx, oid.Hex()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MongoBookingStore) IsRoomAvailable(ctx context.Context, roomID string, nights []string) (bool, error) {
+	return s.ledger.isFree(ctx, roomID, nights)
+}
+
 func (s *MongoBookingStore) GetBookings(ctx context.Context) ([]*types.Booking, error) {
 	cur, err := s.coll.Find(ctx, bson.M{})
 	if err != nil {
@@ -134,7 +217,7 @@ func (s *MongoBookingStore) GetBookingsByUser(ctx context.Context, userID string
 	return bookings, nil
 }
 func (s *MongoBookingStore) GetBookingsByUserAndHotel(ctx context.Context, userID, hotelID string) ([]*types.Booking, error) {
-	cur, err := s.coll.Find(ctx, bson.D{{"userID", userID}, {"hotelID", hotelID}})
+	cur, err := s.coll.Find(ctx, bson.D{{Key: "userID", Value: userID}, {Key: "hotelID", Value: hotelID}})
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return []*types.Booking{}, nil
@@ -148,7 +231,7 @@ func (s *MongoBookingStore) GetBookingsByUserAndHotel(ctx context.Context, userI
 	return bookings, nil
 }
 func (s *MongoBookingStore) GetBookingsByUserAndRoom(ctx context.Context, userID, roomID string) ([]*types.Booking, error) {
-	cur, err := s.coll.Find(ctx, bson.D{{"userID", userID}, {"roomID", roomID}})
+	cur, err := s.coll.Find(ctx, bson.D{{Key: "userID", Value: userID}, {Key: "roomID", Value: roomID}})
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return []*types.Booking{}, nil
@@ -176,32 +259,290 @@ func (s *MongoBookingStore) GetBookingByID(ctx context.Context, bookingID string
 	}
 	return &booking, nil
 }
-func (s *MongoBookingStore) CancelBooking(ctx context.Context, bookingID string) error {
+func (s *MongoBookingStore) GetBookingsByGroup(ctx context.Context, groupID string) ([]*types.Booking, error) {
+	cur, err := s.coll.Find(ctx, bson.M{"groupID": groupID}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	bookings := []*types.Booking{}
+	if err := cur.All(ctx, &bookings); err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return bookings, nil
+}
+func (s *MongoBookingStore) CancelBooking(ctx context.Context, previous, cancelled *types.Booking) (*types.Booking, error) {
+	oid, err := primitive.ObjectIDFromHex(previous.ID)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	//the fee was priced for this stay, so it must not have changed
+	res, err := s.coll.UpdateOne(ctx, unchangedStay(oid, previous), cancelUpdate(cancelled))
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	if res.MatchedCount == 0 {
+		return nil, types.ErrInvalidTransition(fmt.Errorf("booking %s changed concurrently", previous.ID))
+	}
+	if err := s.ledger.release(ctx, previous.ID); err != nil {
+		return nil, err
+	}
+	return cancelled, nil
+}
+
+func (s *MongoBookingStore) SetPaymentStatus(ctx context.Context, bookingID string, status types.BookingPaymentStatus, at time.Time) (*types.Booking, error) {
+	oid, err := primitive.ObjectIDFromHex(bookingID)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	//processors may deliver events out of order, keep the latest report
+	filter := bson.D{
+		{Key: "_id", Value: oid},
+		{Key: "$or", Value: bson.A{
+			bson.M{"paymentUpdatedAt": bson.M{"$exists": false}},
+			bson.M{"paymentUpdatedAt": bson.M{"$lt": at}},
+		}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "paymentStatus", Value: status},
+		{Key: "paymentUpdatedAt", Value: at},
+	}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var booking types.Booking
+	err = s.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&booking)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return s.GetBookingByID(ctx, bookingID)
+	}
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return &booking, nil
+}
+
+func (s *MongoBookingStore) TransitionBooking(ctx context.Context, bookingID string, status types.BookingStatus) (*types.Booking, error) {
 	oid, err := primitive.ObjectIDFromHex(bookingID)
 	if err != nil {
-		return types.ErrInvalidID(err)
+		return nil, types.ErrInvalidID(err)
 	}
 	var booking types.Booking
 	err = s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&booking)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return types.ErrNotFound(err)
+			return nil, types.ErrNotFound(err)
+		}
+		return nil, types.ErrInternal(err)
+	}
+	//only write if nobody moved the booking since it was read
+	filter := bson.M{"_id": oid, "status": statusMatch(booking.Status)}
+	if err := booking.Transition(status, time.Now()); err != nil {
+		return nil, err
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: booking.Status},
+		{Key: "statusHistory", Value: booking.StatusHistory},
+		{Key: "cancelled", Value: booking.Cancelled},
+		{Key: "cancelledAt", Value: booking.CancelledAt},
+	}}}
+	res, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	if res.MatchedCount == 0 {
+		return nil, types.ErrInvalidTransition(fmt.Errorf("booking %s changed status concurrently", bookingID))
+	}
+	if status.ReleasesNights() {
+		if err := s.ledger.release(ctx, bookingID); err != nil {
+			return nil, err
 		}
-		return types.ErrInternal(err)
 	}
-	if booking.FromDate.Unix() <= time.Now().Unix() {
-		return types.ErrCancelPastBooking(fmt.Errorf("can not cancel booking in the past"))
+	return &booking, nil
+}
+
+func (s *MongoBookingStore) RevertTransition(ctx context.Context, bookingID string, status types.BookingStatus) (*types.Booking, error) {
+	oid, err := primitive.ObjectIDFromHex(bookingID)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
 	}
-	filter := bson.M{"_id": oid}
-	update := bson.D{{"$set", bson.D{{"cancelled", true}, {"cancelledAt", time.Now()}}}}
-	if _, err = s.coll.UpdateOne(ctx, filter, update); err != nil {
+	var booking types.Booking
+	err = s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&booking)
+	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return types.ErrNotFound(err)
+			return nil, types.ErrNotFound(err)
 		}
-		return types.ErrInternal(err)
+		return nil, types.ErrInternal(err)
 	}
-	return nil
+	if err := booking.Revert(status); err != nil {
+		return nil, err
+	}
+	filter := bson.M{"_id": oid, "status": status}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: booking.Status},
+		{Key: "statusHistory", Value: booking.StatusHistory},
+	}}}
+	res, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	if res.MatchedCount == 0 {
+		return nil, types.ErrInvalidTransition(fmt.Errorf("booking %s changed status concurrently", bookingID))
+	}
+	return &booking, nil
+}
+
+// ModifyBooking replaces the stay of previous with the one of modified.
+// The nights modified adds are claimed before the booking is written and the
+// ones it drops released after, so the booking never loses its room.
+func (s *MongoBookingStore) ModifyBooking(ctx context.Context, previous, modified *types.Booking) (*types.Booking, error) {
+	oid, err := primitive.ObjectIDFromHex(previous.ID)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	added := modified.NightsNotIn(previous)
+	if err := s.ledger.reserve(ctx, modified.RoomID, previous.ID, added, time.Time{}); err != nil {
+		return nil, err
+	}
+	res, err := s.coll.UpdateOne(ctx, unchangedStay(oid, previous), stayUpdate(modified))
+	if err != nil || res.MatchedCount == 0 {
+		if relErr := s.ledger.releaseNights(ctx, modified.RoomID, previous.ID, added); relErr != nil {
+			return nil, relErr
+		}
+		if err != nil {
+			return nil, types.ErrInternal(err)
+		}
+		return nil, types.ErrBookingNotModifiable(fmt.Errorf("booking %s changed concurrently", previous.ID))
+	}
+	if err := s.ledger.releaseNights(ctx, previous.RoomID, previous.ID, previous.NightsNotIn(modified)); err != nil {
+		return nil, err
+	}
+	return modified, nil
+}
+
+// ModifyBookingGroup claims the nights every booking adds before writing
+// any. When a booking changed since it was read, the bookings already
+// written get their previous stay back.
+func (s *MongoBookingStore) ModifyBookingGroup(ctx context.Context, previous, modified []*types.Booking) ([]*types.Booking, error) {
+	oids := make([]primitive.ObjectID, len(previous))
+	for i, booking := range previous {
+		oid, err := primitive.ObjectIDFromHex(booking.ID)
+		if err != nil {
+			return nil, types.ErrInvalidID(err)
+		}
+		oids[i] = oid
+	}
+	added := make([][]string, len(modified))
+	releaseAdded := func(n int) error {
+		for i := 0; i < n; i++ {
+			if err := s.ledger.releaseNights(ctx, modified[i].RoomID, previous[i].ID, added[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := range modified {
+		added[i] = modified[i].NightsNotIn(previous[i])
+		if err := s.ledger.reserve(ctx, modified[i].RoomID, previous[i].ID, added[i], time.Time{}); err != nil {
+			if relErr := releaseAdded(i); relErr != nil {
+				return nil, relErr
+			}
+			return nil, err
+		}
+	}
+	for i := range modified {
+		res, err := s.coll.UpdateOne(ctx, unchangedStay(oids[i], previous[i]), stayUpdate(modified[i]))
+		if err == nil && res.MatchedCount > 0 {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if _, revErr := s.coll.UpdateOne(ctx, bson.M{"_id": oids[j]}, stayUpdate(previous[j])); revErr != nil {
+				return nil, types.ErrInternal(revErr)
+			}
+		}
+		if relErr := releaseAdded(len(modified)); relErr != nil {
+			return nil, relErr
+		}
+		if err != nil {
+			return nil, types.ErrInternal(err)
+		}
+		return nil, types.ErrBookingNotModifiable(fmt.Errorf("booking %s changed concurrently", previous[i].ID))
+	}
+	for i := range previous {
+		if err := s.ledger.releaseNights(ctx, previous[i].RoomID, previous[i].ID, previous[i].NightsNotIn(modified[i])); err != nil {
+			return nil, err
+		}
+	}
+	return modified, nil
 }
+
+// CancelBookingGroup writes every booking before freeing any night. When a
+// booking changed since it was read, the bookings already written get their
+// previous status back.
+func (s *MongoBookingStore) CancelBookingGroup(ctx context.Context, previous, cancelled []*types.Booking) ([]*types.Booking, error) {
+	oids := make([]primitive.ObjectID, len(previous))
+	for i, booking := range previous {
+		oid, err := primitive.ObjectIDFromHex(booking.ID)
+		if err != nil {
+			return nil, types.ErrInvalidID(err)
+		}
+		oids[i] = oid
+	}
+	for i := range cancelled {
+		res, err := s.coll.UpdateOne(ctx, unchangedStay(oids[i], previous[i]), cancelUpdate(cancelled[i]))
+		if err == nil && res.MatchedCount > 0 {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if _, revErr := s.coll.UpdateOne(ctx, bson.M{"_id": oids[j]}, cancelUpdate(previous[j])); revErr != nil {
+				return nil, types.ErrInternal(revErr)
+			}
+		}
+		if err != nil {
+			return nil, types.ErrInternal(err)
+		}
+		return nil, types.ErrInvalidTransition(fmt.Errorf("booking %s changed concurrently", previous[i].ID))
+	}
+	for _, booking := range previous {
+		if err := s.ledger.release(ctx, booking.ID); err != nil {
+			return nil, err
+		}
+	}
+	return cancelled, nil
+}
+
+// cancelUpdate writes the status and cancellation of booking, see
+// types.Booking.Cancel.
+func cancelUpdate(booking *types.Booking) bson.D {
+	return bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: booking.Status},
+		{Key: "statusHistory", Value: booking.StatusHistory},
+		{Key: "cancelled", Value: booking.Cancelled},
+		{Key: "cancelledAt", Value: booking.CancelledAt},
+		{Key: "cancellation", Value: booking.Cancellation},
+	}}}
+}
+
+// unchangedStay matches the booking of oid only while it still has the
+// status and stay of previous, as read from the store.
+func unchangedStay(oid primitive.ObjectID, previous *types.Booking) bson.M {
+	return bson.M{
+		"_id":    oid,
+		"status": statusMatch(previous.Status),
+		"roomID": previous.RoomID,
+		"nights": previous.Nights,
+	}
+}
+
+// stayUpdate writes the stay of booking, see types.Booking.Modify.
+func stayUpdate(booking *types.Booking) bson.D {
+	return bson.D{{Key: "$set", Value: bson.D{
+		{Key: "hotelID", Value: booking.HotelID},
+		{Key: "roomID", Value: booking.RoomID},
+		{Key: "fromDate", Value: booking.FromDate},
+		{Key: "toDate", Value: booking.ToDate},
+		{Key: "nights", Value: booking.Nights},
+		{Key: "price", Value: booking.Price},
+		{Key: "modifications", Value: booking.Modifications},
+	}}}
+}
+
 func (s *MongoBookingStore) DeleteBooking(ctx context.Context, bookingID string) error {
 	oid, err := primitive.ObjectIDFromHex(bookingID)
 	if err != nil {
@@ -214,5 +555,14 @@ func (s *MongoBookingStore) DeleteBooking(ctx context.Context, bookingID string)
 		}
 		return types.ErrInternal(err)
 	}
-	return nil
+	return s.ledger.release(ctx, bookingID)
+}
+
+// statusMatch matches a stored status, including the missing status of
+// bookings stored before statuses existed.
+func statusMatch(status types.BookingStatus) any {
+	if status == "" {
+		return bson.M{"$in": bson.A{nil, ""}}
+	}
+	return status
 }
@@ -2,6 +2,10 @@ package db
 
 import (
 	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var (
@@ -19,3 +23,80 @@ var (
 type Dropper interface {
 	Drop(ctx context.Context) error
 }
+
+// Store groups every store the API depends on, so a whole backend can be
+// selected at once.
+type Store struct {
+	User         UserStore
+	Hotel        HotelStore
+	Room         RoomStore
+	Booking      BookingStore
+	Availability AvailabilityStore
+	RatePlan     RatePlanStore
+	Hold         HoldStore
+	Payment      PaymentStore
+	PaymentEvent PaymentEventStore
+	Folio        FolioStore
+	Invoice      InvoiceStore
+	Promo        PromoStore
+	Token        TokenStore
+	Lockout      LockoutStore
+}
+
+// NewMongoStore returns the stores of dbname. It fails if the indexes the
+// stores rely on can not be created.
+func NewMongoStore(client *mongo.Client, dbname string) (*Store, error) {
+	hotelStore := NewMongoHotelStore(client, dbname)
+	invoiceStore, err := NewMongoInvoiceStore(client, dbname)
+	if err != nil {
+		return nil, err
+	}
+	promoStore, err := NewMongoPromoStore(client, dbname)
+	if err != nil {
+		return nil, err
+	}
+	tokenStore, err := NewMongoTokenStore(client, dbname)
+	if err != nil {
+		return nil, err
+	}
+	lockoutStore, err := NewMongoLockoutStore(client, dbname)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		User:         NewMongoUserStore(client, dbname),
+		Hotel:        hotelStore,
+		Room:         NewMongoRoomStore(client, dbname, hotelStore),
+		Booking:      NewMongoBookingStore(client, dbname),
+		Availability: NewMongoAvailabilityStore(client, dbname),
+		RatePlan:     NewMongoRatePlanStore(client, dbname),
+		Hold:         NewMongoHoldStore(client, dbname),
+		Payment:      NewMongoPaymentStore(client, dbname),
+		PaymentEvent: NewMongoPaymentEventStore(client, dbname),
+		Folio:        NewMongoFolioStore(client, dbname),
+		Invoice:      invoiceStore,
+		Promo:        promoStore,
+		Token:        tokenStore,
+		Lockout:      lockoutStore,
+	}, nil
+}
+
+// documentWithID marshals v into a document whose _id is oid, for inserts
+// that need to know the ID before the document is written.
+func documentWithID(oid primitive.ObjectID, v any) (bson.D, error) {
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields bson.D
+	if err := bson.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	doc := bson.D{{Key: "_id", Value: oid}}
+	for _, field := range fields {
+		if field.Key != "_id" {
+			doc = append(doc, field)
+		}
+	}
+	return doc, nil
+}
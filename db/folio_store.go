@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const folioColl = "folioItems"
+
+type FolioStore interface {
+	InsertFolioItems(ctx context.Context, items ...*types.FolioItem) ([]*types.FolioItem, error)
+	GetFolioItem(ctx context.Context, id string) (*types.FolioItem, error)
+	// GetFolioItems returns the items of the folio of a booking, in the
+	// order they were posted.
+	GetFolioItems(ctx context.Context, bookingID string) ([]*types.FolioItem, error)
+	// VoidFolioItem records void on an item and returns it. It fails if the
+	// item was voided before.
+	VoidFolioItem(ctx context.Context, id string, void types.FolioVoid) (*types.FolioItem, error)
+
+	Dropper
+}
+
+type MongoFolioStore struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+}
+
+func NewMongoFolioStore(client *mongo.Client, dbname string) *MongoFolioStore {
+	return &MongoFolioStore{
+		client: client,
+		coll:   client.Database(dbname).Collection(folioColl),
+	}
+}
+
+func (s *MongoFolioStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping folio collection")
+	return s.coll.Drop(ctx)
+}
+
+func (s *MongoFolioStore) InsertFolioItems(ctx context.Context, items ...*types.FolioItem) ([]*types.FolioItem, error) {
+	if len(items) == 0 {
+		return items, nil
+	}
+	docs := make([]any, len(items))
+	for i, item := range items {
+		docs[i] = item
+	}
+	res, err := s.coll.InsertMany(ctx, docs)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	for i, id := range res.InsertedIDs {
+		items[i].ID = id.(primitive.ObjectID).Hex()
+	}
+	return items, nil
+}
+
+func (s *MongoFolioStore) GetFolioItem(ctx context.Context, id string) (*types.FolioItem, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	var item types.FolioItem
+	if err = s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&item); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, types.ErrNotFound(err)
+		}
+		return nil, types.ErrInternal(err)
+	}
+	return &item, nil
+}
+
+func (s *MongoFolioStore) GetFolioItems(ctx context.Context, bookingID string) ([]*types.FolioItem, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cur, err := s.coll.Find(ctx, bson.M{"bookingID": bookingID}, opts)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	items := []*types.FolioItem{}
+	if err = cur.All(ctx, &items); err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return items, nil
+}
+
+func (s *MongoFolioStore) VoidFolioItem(ctx context.Context, id string, void types.FolioVoid) (*types.FolioItem, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	filter := bson.M{"_id": oid, "void": bson.M{"$exists": false}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "void", Value: void}}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var item types.FolioItem
+	err = s.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&item)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		//tell a missing item from a voided one
+		if _, err := s.GetFolioItem(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, types.ErrFolioItemVoided(fmt.Errorf("folio item %s", id))
+	}
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return &item, nil
+}
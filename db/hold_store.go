@@ -0,0 +1,178 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const holdColl = "holds"
+
+type HoldStore interface {
+	// InsertHold claims the nights of the hold until it expires.
+	InsertHold(ctx context.Context, hold *types.Hold) (*types.Hold, error)
+	GetHold(ctx context.Context, id string) (*types.Hold, error)
+	// ConvertHold turns a hold that has not expired into a booking with the
+	// same ID, which keeps the held nights.
+	ConvertHold(ctx context.Context, id string) (*types.Booking, error)
+	ReleaseHold(ctx context.Context, id string) error
+	// ExpireHolds removes the holds expired at now and frees their nights,
+	// returning the IDs of the holds removed.
+	ExpireHolds(ctx context.Context, now time.Time) ([]string, error)
+
+	Dropper
+}
+
+type MongoHoldStore struct {
+	client   *mongo.Client
+	coll     *mongo.Collection
+	bookings *mongo.Collection
+	ledger   roomNightLedger
+}
+
+func NewMongoHoldStore(client *mongo.Client, dbname string) *MongoHoldStore {
+	return &MongoHoldStore{
+		client:   client,
+		coll:     client.Database(dbname).Collection(holdColl),
+		bookings: client.Database(dbname).Collection(bookingColl),
+		ledger:   newRoomNightLedger(client, dbname),
+	}
+}
+
+func (s *MongoHoldStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping hold collection")
+	return s.coll.Drop(ctx)
+}
+
+func (s *MongoHoldStore) InsertHold(ctx context.Context, hold *types.Hold) (*types.Hold, error) {
+	oid := primitive.NewObjectID()
+	if err := s.ledger.reserve(ctx, hold.RoomID, oid.Hex(), hold.Nights, hold.ExpiresAt); err != nil {
+		return nil, err
+	}
+	doc, err := documentWithID(oid, hold)
+	if err != nil {
+		s.ledger.release(ctx, oid.Hex())
+		return nil, types.ErrInternal(err)
+	}
+	if _, err := s.coll.InsertOne(ctx, doc); err != nil {
+		s.ledger.release(ctx, oid.Hex())
+		return nil, types.ErrInternal(err)
+	}
+	hold.ID = oid.Hex()
+	return hold, nil
+}
+
+func (s *MongoHoldStore) GetHold(ctx context.Context, id string) (*types.Hold, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	var hold types.Hold
+	if err := s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&hold); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, types.ErrNotFound(err)
+		}
+		return nil, types.ErrInternal(err)
+	}
+	return &hold, nil
+}
+
+func (s *MongoHoldStore) ConvertHold(ctx context.Context, id string) (*types.Booking, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	now := time.Now()
+	//taking the hold out first makes a concurrent conversion fail
+	var hold types.Hold
+	err = s.coll.FindOneAndDelete(ctx, bson.M{"_id": oid, "expiresAt": bson.M{"$gt": now}}).Decode(&hold)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, types.ErrHoldExpired(fmt.Errorf("hold %s expired or not found", id))
+		}
+		return nil, types.ErrInternal(err)
+	}
+	filter := activeNight(now)
+	filter["bookingID"] = id
+	res, err := s.ledger.coll.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"expiresAt": ""}})
+	if err != nil {
+		s.ledger.release(ctx, id)
+		return nil, types.ErrInternal(err)
+	}
+	if res.ModifiedCount != int64(len(hold.Nights)) {
+		s.ledger.release(ctx, id)
+		return nil, types.ErrHoldExpired(fmt.Errorf("hold %s expired", id))
+	}
+	booking := hold.Booking()
+	doc, err := documentWithID(oid, booking)
+	if err != nil {
+		s.ledger.release(ctx, id)
+		return nil, types.ErrInternal(err)
+	}
+	if _, err := s.bookings.InsertOne(ctx, doc); err != nil {
+		s.ledger.release(ctx, id)
+		return nil, types.ErrInternal(err)
+	}
+	return booking, nil
+}
+
+func (s *MongoHoldStore) ReleaseHold(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.ErrInvalidID(err)
+	}
+	res, err := s.coll.DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	if res.DeletedCount == 0 {
+		return nil
+	}
+	return s.releaseHeld(ctx, id)
+}
+
+func (s *MongoHoldStore) ExpireHolds(ctx context.Context, now time.Time) ([]string, error) {
+	cur, err := s.coll.Find(ctx, bson.M{"expiresAt": bson.M{"$lte": now}})
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	var holds []*types.Hold
+	if err := cur.All(ctx, &holds); err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	expired := []string{}
+	for _, hold := range holds {
+		oid, err := primitive.ObjectIDFromHex(hold.ID)
+		if err != nil {
+			return expired, types.ErrInvalidID(err)
+		}
+		res, err := s.coll.DeleteOne(ctx, bson.M{"_id": oid, "expiresAt": bson.M{"$lte": now}})
+		if err != nil {
+			return expired, types.ErrInternal(err)
+		}
+		if res.DeletedCount == 0 {
+			continue
+		}
+		if err := s.releaseHeld(ctx, hold.ID); err != nil {
+			return expired, err
+		}
+		expired = append(expired, hold.ID)
+	}
+	return expired, nil
+}
+
+// releaseHeld frees the nights still held by a hold, leaving the ones it
+// passed on to its booking.
+func (s *MongoHoldStore) releaseHeld(ctx context.Context, id string) error {
+	filter := bson.M{"bookingID": id, "expiresAt": bson.M{"$exists": true}}
+	if _, err := s.ledger.coll.DeleteMany(ctx, filter); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
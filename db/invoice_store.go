@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const invoiceColl = "invoices"
+
+type InvoiceStore interface {
+	// InsertInvoice numbers invoice after the last invoice of its hotel in
+	// its year and stores it. When the booking already has an invoice that
+	// one is returned instead, so a booking is invoiced once.
+	InsertInvoice(ctx context.Context, invoice *types.Invoice) (*types.Invoice, error)
+	GetInvoiceByBooking(ctx context.Context, bookingID string) (*types.Invoice, error)
+
+	Dropper
+}
+
+type MongoInvoiceStore struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+}
+
+// NewMongoInvoiceStore returns the invoice store of dbname, creating its
+// indexes.
+func NewMongoInvoiceStore(client *mongo.Client, dbname string) (*MongoInvoiceStore, error) {
+	s := &MongoInvoiceStore{
+		client: client,
+		coll:   client.Database(dbname).Collection(invoiceColl),
+	}
+	if err := s.ensureIndexes(context.Background()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MongoInvoiceStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping invoice collection")
+	return s.coll.Drop(ctx)
+}
+
+// ensureIndexes creates, unless they exist, the unique indexes that reject
+// a second invoice of a booking and a second invoice with the same number.
+func (s *MongoInvoiceStore) ensureIndexes(ctx context.Context) error {
+	_, err := s.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "bookingID", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "hotelID", Value: 1}, {Key: "year", Value: 1}, {Key: "sequence", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+func (s *MongoInvoiceStore) InsertInvoice(ctx context.Context, invoice *types.Invoice) (*types.Invoice, error) {
+	//a number is only used once its invoice is stored, so a failed insert
+	//leaves no gap: it is retried with the number after the one taken
+	for {
+		last, err := s.lastSequence(ctx, invoice.HotelID, invoice.Year)
+		if err != nil {
+			return nil, err
+		}
+		invoice.SetSequence(last + 1)
+		res, err := s.coll.InsertOne(ctx, invoice)
+		if err == nil {
+			invoice.ID = res.InsertedID.(primitive.ObjectID).Hex()
+			return invoice, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, types.ErrInternal(err)
+		}
+		existing, err := s.GetInvoiceByBooking(ctx, invoice.BookingID)
+		if err == nil {
+			return existing, nil
+		}
+		var notFound types.ErrorSt
+		if !errors.As(err, &notFound) || notFound.Status != http.StatusNotFound {
+			return nil, err
+		}
+	}
+}
+
+// lastSequence returns the sequence of the last invoice of a hotel in year,
+// 0 when there is none.
+func (s *MongoInvoiceStore) lastSequence(ctx context.Context, hotelID string, year int) (int, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})
+	var last types.Invoice
+	err := s.coll.FindOne(ctx, bson.M{"hotelID": hotelID, "year": year}, opts).Decode(&last)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, types.ErrInternal(err)
+	}
+	return last.Sequence, nil
+}
+
+func (s *MongoInvoiceStore) GetInvoiceByBooking(ctx context.Context, bookingID string) (*types.Invoice, error) {
+	var invoice types.Invoice
+	if err := s.coll.FindOne(ctx, bson.M{"bookingID": bookingID}).Decode(&invoice); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, types.ErrNotFound(err)
+		}
+		return nil, types.ErrInternal(err)
+	}
+	return &invoice, nil
+}
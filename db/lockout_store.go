@@ -0,0 +1,193 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	loginAttemptColl = "loginAttempts"
+	lockoutEventColl = "lockoutEvents"
+)
+
+type LockoutStore interface {
+	// GetLoginAttempts returns the attempts of the key of types.LoginKey.
+	GetLoginAttempts(ctx context.Context, key string) (*types.LoginAttempts, error)
+	// RecordLoginFailure counts a failed login of subject at at and returns
+	// the attempts counted. Failures before at minus window are forgotten.
+	RecordLoginFailure(ctx context.Context, kind types.LoginKind, subject string, at time.Time, window time.Duration) (*types.LoginAttempts, error)
+	// ForgetLoginFailure gives back the failure counted last in attempts,
+	// as returned by RecordLoginFailure. The previous failure time comes
+	// back too unless another failure was counted since.
+	ForgetLoginFailure(ctx context.Context, attempts *types.LoginAttempts) error
+	// TouchLoginFailure moves the last failure of key to at, when later.
+	TouchLoginFailure(ctx context.Context, key string, at time.Time) error
+	// LockLogin locks out the logins of key until until, counting the
+	// failures anew from then.
+	LockLogin(ctx context.Context, key string, until time.Time) error
+	// ClearLoginAttempts forgets the failures and the lockout of key.
+	ClearLoginAttempts(ctx context.Context, key string) error
+
+	InsertLockoutEvent(ctx context.Context, event *types.LockoutEvent) (*types.LockoutEvent, error)
+	// GetLockoutEvents returns the events of subject, every event when
+	// empty, newest first.
+	GetLockoutEvents(ctx context.Context, kind types.LoginKind, subject string) ([]*types.LockoutEvent, error)
+
+	Dropper
+}
+
+type MongoLockoutStore struct {
+	client   *mongo.Client
+	attempts *mongo.Collection
+	events   *mongo.Collection
+}
+
+// NewMongoLockoutStore returns the lockout store of dbname, creating its
+// indexes.
+func NewMongoLockoutStore(client *mongo.Client, dbname string) (*MongoLockoutStore, error) {
+	s := &MongoLockoutStore{
+		client:   client,
+		attempts: client.Database(dbname).Collection(loginAttemptColl),
+		events:   client.Database(dbname).Collection(lockoutEventColl),
+	}
+	if err := s.ensureIndexes(context.Background()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MongoLockoutStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping lockout collection")
+	if err := s.events.Drop(ctx); err != nil {
+		return err
+	}
+	return s.attempts.Drop(ctx)
+}
+
+// ensureIndexes lets Mongo forget login attempts once they expire and
+// looks lockout events up by subject.
+func (s *MongoLockoutStore) ensureIndexes(ctx context.Context) error {
+	if _, err := s.attempts.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		return err
+	}
+	_, err := s.events.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "kind", Value: 1}, {Key: "subject", Value: 1}, {Key: "at", Value: -1}},
+	})
+	return err
+}
+
+func (s *MongoLockoutStore) GetLoginAttempts(ctx context.Context, key string) (*types.LoginAttempts, error) {
+	var attempts types.LoginAttempts
+	if err := s.attempts.FindOne(ctx, bson.M{"_id": key}).Decode(&attempts); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, types.ErrNotFound(err)
+		}
+		return nil, types.ErrInternal(err)
+	}
+	return &attempts, nil
+}
+
+func (s *MongoLockoutStore) RecordLoginFailure(ctx context.Context, kind types.LoginKind, subject string, at time.Time, window time.Duration) (*types.LoginAttempts, error) {
+	//a pipeline update counts concurrent failures atomically, restarting
+	//the count when the last failure is older than window
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"kind":    kind,
+		"subject": types.LoginSubject(kind, subject),
+		"failures": bson.M{"$cond": bson.A{
+			bson.M{"$gte": bson.A{"$lastFailure", at.Add(-window)}},
+			bson.M{"$add": bson.A{"$failures", 1}},
+			1,
+		}},
+		"lastFailure":     at,
+		"previousFailure": "$lastFailure",
+		"expiresAt":       bson.M{"$max": bson.A{at.Add(window), "$lockedUntil"}},
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var attempts types.LoginAttempts
+	if err := s.attempts.FindOneAndUpdate(ctx, bson.M{"_id": types.LoginKey(kind, subject)}, update, opts).Decode(&attempts); err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return &attempts, nil
+}
+
+func (s *MongoLockoutStore) ForgetLoginFailure(ctx context.Context, attempts *types.LoginAttempts) error {
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"failures": bson.M{"$add": bson.A{"$failures", -1}},
+		"lastFailure": bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$lastFailure", attempts.LastFailure}},
+			attempts.PreviousFailure,
+			"$lastFailure",
+		}},
+	}}}}
+	//a lockout counts the failures anew, there may be none to give back
+	filter := bson.M{"_id": attempts.ID, "failures": bson.M{"$gt": 0}}
+	if _, err := s.attempts.UpdateOne(ctx, filter, update); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+func (s *MongoLockoutStore) TouchLoginFailure(ctx context.Context, key string, at time.Time) error {
+	if _, err := s.attempts.UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$max": bson.M{"lastFailure": at}}); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+func (s *MongoLockoutStore) LockLogin(ctx context.Context, key string, until time.Time) error {
+	update := bson.M{"$set": bson.M{"failures": 0, "lockedUntil": until, "expiresAt": until}}
+	res, err := s.attempts.UpdateOne(ctx, bson.M{"_id": key}, update)
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	if res.MatchedCount == 0 {
+		return types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return nil
+}
+
+func (s *MongoLockoutStore) ClearLoginAttempts(ctx context.Context, key string) error {
+	if _, err := s.attempts.DeleteOne(ctx, bson.M{"_id": key}); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+func (s *MongoLockoutStore) InsertLockoutEvent(ctx context.Context, event *types.LockoutEvent) (*types.LockoutEvent, error) {
+	res, err := s.events.InsertOne(ctx, event)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	event.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return event, nil
+}
+
+func (s *MongoLockoutStore) GetLockoutEvents(ctx context.Context, kind types.LoginKind, subject string) ([]*types.LockoutEvent, error) {
+	filter := bson.M{}
+	if kind != "" {
+		filter["kind"] = kind
+	}
+	if subject != "" {
+		filter["subject"] = subject
+	}
+	cur, err := s.events.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "at", Value: -1}}))
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	events := []*types.LockoutEvent{}
+	if err := cur.All(ctx, &events); err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return events, nil
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+var _ db.AvailabilityStore = (*AvailabilityStore)(nil)
+
+type AvailabilityStore struct {
+	hotels   *HotelStore
+	rooms    *RoomStore
+	bookings *BookingStore
+}
+
+func NewAvailabilityStore(hotels *HotelStore, rooms *RoomStore, bookings *BookingStore) *AvailabilityStore {
+	return &AvailabilityStore{
+		hotels:   hotels,
+		rooms:    rooms,
+		bookings: bookings,
+	}
+}
+
+func (s *AvailabilityStore) GetAvailableRooms(ctx context.Context, params types.AvailabilityParams) ([]*types.HotelAvailability, error) {
+	maxPrice := params.MaxPrice
+	candidates, err := s.rooms.filter(func(r *types.Room) bool {
+		return (params.Size == 0 || r.Size == params.Size) &&
+			(maxPrice.Amount <= 0 || (r.Price.Amount <= maxPrice.Amount && r.Price.Currency == maxPrice.Currency))
+	})
+	if err != nil {
+		return nil, err
+	}
+	nights := params.Nights()
+	byHotel := map[string][]*types.Room{}
+	s.bookings.mu.RLock()
+	for _, room := range candidates {
+		if !s.bookings.isFree(room.ID, nights) {
+			continue
+		}
+		byHotel[room.HotelID] = append(byHotel[room.HotelID], room)
+	}
+	s.bookings.mu.RUnlock()
+
+	availability := []*types.HotelAvailability{}
+	for hotelID, rooms := range byHotel {
+		hotel, err := s.hotels.GetHotelByID(ctx, hotelID)
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(hotel.Location), strings.ToLower(params.Location)) {
+			continue
+		}
+		availability = append(availability, &types.HotelAvailability{Hotel: hotel, Rooms: rooms})
+	}
+	sort.Slice(availability, func(i, j int) bool {
+		return availability[i].Hotel.ID < availability[j].Hotel.ID
+	})
+	return availability, nil
+}
@@ -0,0 +1,412 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.BookingStore = (*BookingStore)(nil)
+
+type BookingStore struct {
+	mu       sync.RWMutex
+	bookings []*types.Booking
+	// nights maps a room night, as "roomID:YYYY-MM-DD", to the booking or
+	// hold holding it, mirroring the Mongo room night ledger.
+	nights map[string]nightHolder
+}
+
+// nightHolder is the booking or hold holding a room night. Nights of holds
+// expire, nights of bookings do not.
+type nightHolder struct {
+	id        string
+	expiresAt time.Time
+}
+
+func (h nightHolder) active(now time.Time) bool {
+	return h.expiresAt.IsZero() || now.Before(h.expiresAt)
+}
+
+func NewBookingStore() *BookingStore {
+	return &BookingStore{
+		nights: map[string]nightHolder{},
+	}
+}
+
+func (s *BookingStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping booking store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bookings = nil
+	s.nights = map[string]nightHolder{}
+	return nil
+}
+
+func (s *BookingStore) find(id string) (int, *types.Booking) {
+	for i, booking := range s.bookings {
+		if booking.ID == id {
+			return i, booking
+		}
+	}
+	return -1, nil
+}
+
+func (s *BookingStore) release(bookingID string) {
+	for night, holder := range s.nights {
+		if holder.id == bookingID {
+			delete(s.nights, night)
+		}
+	}
+}
+
+// hold gives the nights of roomID to id until expiresAt, forever when zero.
+// The caller must hold s.mu.
+func (s *BookingStore) hold(roomID, id string, nights []string, expiresAt time.Time) {
+	for _, night := range nights {
+		s.nights[roomID+":"+night] = nightHolder{id: id, expiresAt: expiresAt}
+	}
+}
+
+// isFree reports whether none of the nights of roomID are held. The caller
+// must hold s.mu.
+func (s *BookingStore) isFree(roomID string, nights []string) bool {
+	now := time.Now()
+	for _, night := range nights {
+		if holder, ok := s.nights[roomID+":"+night]; ok && holder.active(now) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *BookingStore) filter(match func(*types.Booking) bool) ([]*types.Booking, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	bookings := []*types.Booking{}
+	for _, booking := range s.bookings {
+		if !match(booking) {
+			continue
+		}
+		b, err := clone(booking)
+		if err != nil {
+			return nil, err
+		}
+		bookings = append(bookings, b)
+	}
+	return bookings, nil
+}
+
+func (s *BookingStore) InsertBooking(ctx context.Context, booking *types.Booking) (*types.Booking, error) {
+	stored, err := clone(booking)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isFree(booking.RoomID, booking.Nights) {
+		return nil, types.ErrUnavailableDate(fmt.Errorf("unavailable date"))
+	}
+	s.hold(booking.RoomID, stored.ID, booking.Nights, time.Time{})
+	s.bookings = append(s.bookings, stored)
+	booking.ID = stored.ID
+	return booking, nil
+}
+
+func (s *BookingStore) InsertBookingGroup(ctx context.Context, bookings []*types.Booking) ([]*types.Booking, error) {
+	groupID := newID()
+	stored := make([]*types.Booking, len(bookings))
+	for i, booking := range bookings {
+		b, err := clone(booking)
+		if err != nil {
+			return nil, err
+		}
+		b.ID = newID()
+		b.GroupID = groupID
+		stored[i] = b
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, booking := range stored {
+		if !s.isFree(booking.RoomID, booking.Nights) {
+			for _, held := range stored[:i] {
+				s.release(held.ID)
+			}
+			return nil, types.ErrUnavailableDate(fmt.Errorf("unavailable date"))
+		}
+		s.hold(booking.RoomID, booking.ID, booking.Nights, time.Time{})
+	}
+	s.bookings = append(s.bookings, stored...)
+	for i, booking := range bookings {
+		booking.ID = stored[i].ID
+		booking.GroupID = groupID
+	}
+	return bookings, nil
+}
+
+func (s *BookingStore) IsRoomAvailable(ctx context.Context, roomID string, nights []string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.isFree(roomID, nights), nil
+}
+
+func (s *BookingStore) GetBookings(ctx context.Context) ([]*types.Booking, error) {
+	return s.filter(func(b *types.Booking) bool {
+		return true
+	})
+}
+
+func (s *BookingStore) GetBookingsByRoom(ctx context.Context, roomID string) ([]*types.Booking, error) {
+	return s.filter(func(b *types.Booking) bool {
+		return b.RoomID == roomID
+	})
+}
+
+func (s *BookingStore) GetBookingsByHotel(ctx context.Context, hotelID string) ([]*types.Booking, error) {
+	return s.filter(func(b *types.Booking) bool {
+		return b.HotelID == hotelID
+	})
+}
+
+func (s *BookingStore) GetBookingsByUser(ctx context.Context, userID string) ([]*types.Booking, error) {
+	return s.filter(func(b *types.Booking) bool {
+		return b.UserID == userID
+	})
+}
+
+func (s *BookingStore) GetBookingsByUserAndHotel(ctx context.Context, userID, hotelID string) ([]*types.Booking, error) {
+	return s.filter(func(b *types.Booking) bool {
+		return b.UserID == userID && b.HotelID == hotelID
+	})
+}
+
+func (s *BookingStore) GetBookingsByUserAndRoom(ctx context.Context, userID, roomID string) ([]*types.Booking, error) {
+	return s.filter(func(b *types.Booking) bool {
+		return b.UserID == userID && b.RoomID == roomID
+	})
+}
+
+func (s *BookingStore) GetBookingByID(ctx context.Context, bookingID string) (*types.Booking, error) {
+	if err := validateID(bookingID); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, booking := s.find(bookingID)
+	if booking == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return clone(booking)
+}
+
+func (s *BookingStore) GetBookingsByGroup(ctx context.Context, groupID string) ([]*types.Booking, error) {
+	return s.filter(func(b *types.Booking) bool {
+		return b.GroupID == groupID
+	})
+}
+
+func (s *BookingStore) CancelBooking(ctx context.Context, previous, cancelled *types.Booking) (*types.Booking, error) {
+	if err := validateID(previous.ID); err != nil {
+		return nil, err
+	}
+	stored, err := clone(cancelled)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, booking := s.find(previous.ID)
+	if booking == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	if booking.Status != previous.Status || booking.RoomID != previous.RoomID ||
+		!slices.Equal(booking.Nights, previous.Nights) {
+		return nil, types.ErrInvalidTransition(fmt.Errorf("booking %s changed concurrently", previous.ID))
+	}
+	s.bookings[i] = stored
+	s.release(previous.ID)
+	return cancelled, nil
+}
+
+func (s *BookingStore) CancelBookingGroup(ctx context.Context, previous, cancelled []*types.Booking) ([]*types.Booking, error) {
+	stored := make([]*types.Booking, len(cancelled))
+	for i, booking := range cancelled {
+		if err := validateID(previous[i].ID); err != nil {
+			return nil, err
+		}
+		b, err := clone(booking)
+		if err != nil {
+			return nil, err
+		}
+		stored[i] = b
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	indexes := make([]int, len(previous))
+	for i, prev := range previous {
+		j, booking := s.find(prev.ID)
+		if booking == nil {
+			return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+		}
+		if booking.Status != prev.Status || booking.RoomID != prev.RoomID ||
+			!slices.Equal(booking.Nights, prev.Nights) {
+			return nil, types.ErrInvalidTransition(fmt.Errorf("booking %s changed concurrently", prev.ID))
+		}
+		indexes[i] = j
+	}
+	for i, prev := range previous {
+		s.bookings[indexes[i]] = stored[i]
+		s.release(prev.ID)
+	}
+	return cancelled, nil
+}
+
+func (s *BookingStore) SetPaymentStatus(ctx context.Context, bookingID string, status types.BookingPaymentStatus, at time.Time) (*types.Booking, error) {
+	if err := validateID(bookingID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, booking := s.find(bookingID)
+	if booking == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	if booking.PaymentUpdatedAt.Before(at) {
+		booking.PaymentStatus = status
+		booking.PaymentUpdatedAt = at
+	}
+	return clone(booking)
+}
+
+func (s *BookingStore) TransitionBooking(ctx context.Context, bookingID string, status types.BookingStatus) (*types.Booking, error) {
+	if err := validateID(bookingID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, booking := s.find(bookingID)
+	if booking == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	updated, err := clone(booking)
+	if err != nil {
+		return nil, err
+	}
+	if err := updated.Transition(status, time.Now()); err != nil {
+		return nil, err
+	}
+	*booking = *updated
+	if status.ReleasesNights() {
+		s.release(bookingID)
+	}
+	return clone(booking)
+}
+
+func (s *BookingStore) RevertTransition(ctx context.Context, bookingID string, status types.BookingStatus) (*types.Booking, error) {
+	if err := validateID(bookingID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, booking := s.find(bookingID)
+	if booking == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	updated, err := clone(booking)
+	if err != nil {
+		return nil, err
+	}
+	if err := updated.Revert(status); err != nil {
+		return nil, err
+	}
+	*booking = *updated
+	return clone(booking)
+}
+
+func (s *BookingStore) ModifyBooking(ctx context.Context, previous, modified *types.Booking) (*types.Booking, error) {
+	if err := validateID(previous.ID); err != nil {
+		return nil, err
+	}
+	stored, err := clone(modified)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, booking := s.find(previous.ID)
+	if booking == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	if booking.Status != previous.Status || booking.RoomID != previous.RoomID ||
+		!slices.Equal(booking.Nights, previous.Nights) {
+		return nil, types.ErrBookingNotModifiable(fmt.Errorf("booking %s changed concurrently", previous.ID))
+	}
+	added := modified.NightsNotIn(previous)
+	if !s.isFree(modified.RoomID, added) {
+		return nil, types.ErrUnavailableDate(fmt.Errorf("unavailable date"))
+	}
+	for _, night := range previous.NightsNotIn(modified) {
+		delete(s.nights, previous.RoomID+":"+night)
+	}
+	s.hold(modified.RoomID, previous.ID, added, time.Time{})
+	s.bookings[i] = stored
+	return modified, nil
+}
+
+func (s *BookingStore) ModifyBookingGroup(ctx context.Context, previous, modified []*types.Booking) ([]*types.Booking, error) {
+	stored := make([]*types.Booking, len(modified))
+	for i, booking := range modified {
+		if err := validateID(previous[i].ID); err != nil {
+			return nil, err
+		}
+		b, err := clone(booking)
+		if err != nil {
+			return nil, err
+		}
+		stored[i] = b
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	indexes := make([]int, len(previous))
+	for i, prev := range previous {
+		j, booking := s.find(prev.ID)
+		if booking == nil {
+			return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+		}
+		if booking.Status != prev.Status || booking.RoomID != prev.RoomID ||
+			!slices.Equal(booking.Nights, prev.Nights) {
+			return nil, types.ErrBookingNotModifiable(fmt.Errorf("booking %s changed concurrently", prev.ID))
+		}
+		if !s.isFree(modified[i].RoomID, modified[i].NightsNotIn(prev)) {
+			return nil, types.ErrUnavailableDate(fmt.Errorf("unavailable date"))
+		}
+		indexes[i] = j
+	}
+	for i, prev := range previous {
+		for _, night := range prev.NightsNotIn(modified[i]) {
+			delete(s.nights, prev.RoomID+":"+night)
+		}
+		s.hold(modified[i].RoomID, prev.ID, modified[i].NightsNotIn(prev), time.Time{})
+		s.bookings[indexes[i]] = stored[i]
+	}
+	return modified, nil
+}
+
+func (s *BookingStore) DeleteBooking(ctx context.Context, bookingID string) error {
+	if err := validateID(bookingID); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, _ := s.find(bookingID); i >= 0 {
+		s.bookings = append(s.bookings[:i], s.bookings[i+1:]...)
+	}
+	s.release(bookingID)
+	return nil
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.FolioStore = (*FolioStore)(nil)
+
+type FolioStore struct {
+	mu    sync.RWMutex
+	items []*types.FolioItem
+}
+
+func NewFolioStore() *FolioStore {
+	return &FolioStore{}
+}
+
+func (s *FolioStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping folio store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = nil
+	return nil
+}
+
+func (s *FolioStore) find(id string) *types.FolioItem {
+	for _, item := range s.items {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+func (s *FolioStore) InsertFolioItems(ctx context.Context, items ...*types.FolioItem) ([]*types.FolioItem, error) {
+	stored := make([]*types.FolioItem, len(items))
+	for i, item := range items {
+		var err error
+		if stored[i], err = clone(item); err != nil {
+			return nil, err
+		}
+		stored[i].ID = newID()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = append(s.items, stored...)
+	for i, item := range items {
+		item.ID = stored[i].ID
+	}
+	return items, nil
+}
+
+func (s *FolioStore) GetFolioItem(ctx context.Context, id string) (*types.FolioItem, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item := s.find(id)
+	if item == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return clone(item)
+}
+
+func (s *FolioStore) GetFolioItems(ctx context.Context, bookingID string) ([]*types.FolioItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := []*types.FolioItem{}
+	for _, item := range s.items {
+		if item.BookingID != bookingID {
+			continue
+		}
+		i, err := clone(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	return items, nil
+}
+
+func (s *FolioStore) VoidFolioItem(ctx context.Context, id string, void types.FolioVoid) (*types.FolioItem, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item := s.find(id)
+	if item == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	if item.Void != nil {
+		return nil, types.ErrFolioItemVoided(fmt.Errorf("folio item %s", id))
+	}
+	item.Void = &void
+	return clone(item)
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.HoldStore = (*HoldStore)(nil)
+
+// HoldStore keeps its holds next to the bookings of a BookingStore, sharing
+// its lock and room nights so holds and bookings exclude each other.
+type HoldStore struct {
+	bookings *BookingStore
+	holds    map[string]*types.Hold
+}
+
+func NewHoldStore(bookingStore *BookingStore) *HoldStore {
+	return &HoldStore{
+		bookings: bookingStore,
+		holds:    map[string]*types.Hold{},
+	}
+}
+
+func (s *HoldStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping hold store")
+	s.bookings.mu.Lock()
+	defer s.bookings.mu.Unlock()
+	for id := range s.holds {
+		s.releaseHeld(id)
+	}
+	s.holds = map[string]*types.Hold{}
+	return nil
+}
+
+// releaseHeld frees the nights still held by the hold id. The caller must
+// hold s.bookings.mu.
+func (s *HoldStore) releaseHeld(id string) {
+	for night, holder := range s.bookings.nights {
+		if holder.id == id && !holder.expiresAt.IsZero() {
+			delete(s.bookings.nights, night)
+		}
+	}
+}
+
+func (s *HoldStore) InsertHold(ctx context.Context, hold *types.Hold) (*types.Hold, error) {
+	stored, err := clone(hold)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.bookings.mu.Lock()
+	defer s.bookings.mu.Unlock()
+	if !s.bookings.isFree(hold.RoomID, hold.Nights) {
+		return nil, types.ErrUnavailableDate(fmt.Errorf("unavailable date"))
+	}
+	s.bookings.hold(hold.RoomID, stored.ID, hold.Nights, hold.ExpiresAt)
+	s.holds[stored.ID] = stored
+	hold.ID = stored.ID
+	return hold, nil
+}
+
+func (s *HoldStore) GetHold(ctx context.Context, id string) (*types.Hold, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	s.bookings.mu.RLock()
+	defer s.bookings.mu.RUnlock()
+	hold, ok := s.holds[id]
+	if !ok {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return clone(hold)
+}
+
+func (s *HoldStore) ConvertHold(ctx context.Context, id string) (*types.Booking, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	s.bookings.mu.Lock()
+	defer s.bookings.mu.Unlock()
+	hold, ok := s.holds[id]
+	if !ok || hold.Expired(time.Now()) {
+		return nil, types.ErrHoldExpired(fmt.Errorf("hold %s expired or not found", id))
+	}
+	delete(s.holds, id)
+	booking := hold.Booking()
+	stored, err := clone(booking)
+	if err != nil {
+		s.releaseHeld(id)
+		return nil, err
+	}
+	s.bookings.hold(hold.RoomID, id, hold.Nights, time.Time{})
+	s.bookings.bookings = append(s.bookings.bookings, stored)
+	return booking, nil
+}
+
+func (s *HoldStore) ReleaseHold(ctx context.Context, id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.bookings.mu.Lock()
+	defer s.bookings.mu.Unlock()
+	if _, ok := s.holds[id]; !ok {
+		return nil
+	}
+	delete(s.holds, id)
+	s.releaseHeld(id)
+	return nil
+}
+
+func (s *HoldStore) ExpireHolds(ctx context.Context, now time.Time) ([]string, error) {
+	s.bookings.mu.Lock()
+	defer s.bookings.mu.Unlock()
+	expired := []string{}
+	for id, hold := range s.holds {
+		if !hold.Expired(now) {
+			continue
+		}
+		delete(s.holds, id)
+		s.releaseHeld(id)
+		expired = append(expired, id)
+	}
+	return expired, nil
+}
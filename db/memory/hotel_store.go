@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.HotelStore = (*HotelStore)(nil)
+
+type HotelStore struct {
+	mu     sync.RWMutex
+	hotels []*types.Hotel
+}
+
+func NewHotelStore() *HotelStore {
+	return &HotelStore{}
+}
+
+func (s *HotelStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping hotel store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hotels = nil
+	return nil
+}
+
+func (s *HotelStore) find(id string) (int, *types.Hotel) {
+	for i, hotel := range s.hotels {
+		if hotel.ID == id {
+			return i, hotel
+		}
+	}
+	return -1, nil
+}
+
+func (s *HotelStore) UpdateHotelRooms(ctx context.Context, id, updateRoom string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, hotel := s.find(id); hotel != nil {
+		hotel.Rooms = append(hotel.Rooms, updateRoom)
+	}
+	return nil
+}
+
+func (s *HotelStore) UpdateHotel(ctx context.Context, id string, validUpdate map[string]any) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, hotel := s.find(id)
+	if hotel == nil {
+		return nil
+	}
+	return applySet(hotel, validUpdate)
+}
+
+func (s *HotelStore) InsertHotel(ctx context.Context, hotel *types.Hotel) (*types.Hotel, error) {
+	stored, err := clone(hotel)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.mu.Lock()
+	s.hotels = append(s.hotels, stored)
+	s.mu.Unlock()
+	hotel.ID = stored.ID
+	return hotel, nil
+}
+
+func (s *HotelStore) GetHotels(ctx context.Context) ([]*types.Hotel, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hotels := []*types.Hotel{}
+	for _, hotel := range s.hotels {
+		h, err := clone(hotel)
+		if err != nil {
+			return nil, err
+		}
+		hotels = append(hotels, h)
+	}
+	return hotels, nil
+}
+
+func (s *HotelStore) GetHotelByID(ctx context.Context, id string) (*types.Hotel, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, hotel := s.find(id)
+	if hotel == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return clone(hotel)
+}
+
+func (s *HotelStore) DeleteHotel(ctx context.Context, id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, _ := s.find(id); i >= 0 {
+		s.hotels = append(s.hotels[:i], s.hotels[i+1:]...)
+	}
+	return nil
+}
+
+func (s *HotelStore) DeleteHotelRoom(ctx context.Context, hotelID, roomID string) error {
+	if err := validateID(hotelID); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, hotel := s.find(hotelID)
+	if hotel == nil {
+		return nil
+	}
+	rooms := []string{}
+	for _, id := range hotel.Rooms {
+		if id != roomID {
+			rooms = append(rooms, id)
+		}
+	}
+	hotel.Rooms = rooms
+	return nil
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.InvoiceStore = (*InvoiceStore)(nil)
+
+type InvoiceStore struct {
+	mu       sync.RWMutex
+	invoices []*types.Invoice
+}
+
+func NewInvoiceStore() *InvoiceStore {
+	return &InvoiceStore{}
+}
+
+func (s *InvoiceStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping invoice store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.invoices = nil
+	return nil
+}
+
+func (s *InvoiceStore) InsertInvoice(ctx context.Context, invoice *types.Invoice) (*types.Invoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := 0
+	for _, stored := range s.invoices {
+		if stored.BookingID == invoice.BookingID {
+			return clone(stored)
+		}
+		if stored.HotelID == invoice.HotelID && stored.Year == invoice.Year && stored.Sequence > last {
+			last = stored.Sequence
+		}
+	}
+	invoice.SetSequence(last + 1)
+	stored, err := clone(invoice)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.invoices = append(s.invoices, stored)
+	invoice.ID = stored.ID
+	return invoice, nil
+}
+
+func (s *InvoiceStore) GetInvoiceByBooking(ctx context.Context, bookingID string) (*types.Invoice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, invoice := range s.invoices {
+		if invoice.BookingID == bookingID {
+			return clone(invoice)
+		}
+	}
+	return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+}
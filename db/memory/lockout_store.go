@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.LockoutStore = (*LockoutStore)(nil)
+
+type LockoutStore struct {
+	mu       sync.RWMutex
+	attempts map[string]*types.LoginAttempts
+	events   []*types.LockoutEvent
+}
+
+func NewLockoutStore() *LockoutStore {
+	return &LockoutStore{
+		attempts: map[string]*types.LoginAttempts{},
+	}
+}
+
+func (s *LockoutStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping lockout store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = map[string]*types.LoginAttempts{}
+	s.events = nil
+	return nil
+}
+
+func (s *LockoutStore) GetLoginAttempts(ctx context.Context, key string) (*types.LoginAttempts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	attempts, ok := s.attempts[key]
+	if !ok || !time.Now().Before(attempts.ExpiresAt) {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return clone(attempts)
+}
+
+func (s *LockoutStore) RecordLoginFailure(ctx context.Context, kind types.LoginKind, subject string, at time.Time, window time.Duration) (*types.LoginAttempts, error) {
+	key := types.LoginKey(kind, subject)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	//forget attempts that expired, as the Mongo TTL index does
+	now := time.Now()
+	for id, attempts := range s.attempts {
+		if !now.Before(attempts.ExpiresAt) {
+			delete(s.attempts, id)
+		}
+	}
+	attempts, ok := s.attempts[key]
+	if !ok {
+		attempts = &types.LoginAttempts{ID: key, Kind: kind, Subject: types.LoginSubject(kind, subject)}
+		s.attempts[key] = attempts
+	}
+	if attempts.LastFailure.Before(at.Add(-window)) {
+		attempts.Failures = 0
+	}
+	attempts.Failures++
+	attempts.PreviousFailure = attempts.LastFailure
+	attempts.LastFailure = at
+	attempts.ExpiresAt = at.Add(window)
+	if attempts.LockedUntil.After(attempts.ExpiresAt) {
+		attempts.ExpiresAt = attempts.LockedUntil
+	}
+	//keep the times as Mongo does, so ForgetLoginFailure can compare them
+	stored, err := clone(attempts)
+	if err != nil {
+		return nil, err
+	}
+	s.attempts[key] = stored
+	return clone(stored)
+}
+
+func (s *LockoutStore) ForgetLoginFailure(ctx context.Context, attempts *types.LoginAttempts) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.attempts[attempts.ID]
+	if !ok || stored.Failures == 0 {
+		return nil
+	}
+	stored.Failures--
+	if stored.LastFailure.Equal(attempts.LastFailure) {
+		stored.LastFailure = attempts.PreviousFailure
+	}
+	return nil
+}
+
+func (s *LockoutStore) TouchLoginFailure(ctx context.Context, key string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if attempts, ok := s.attempts[key]; ok && at.After(attempts.LastFailure) {
+		attempts.LastFailure = at
+	}
+	return nil
+}
+
+func (s *LockoutStore) LockLogin(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts, ok := s.attempts[key]
+	if !ok {
+		return types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	attempts.Failures = 0
+	attempts.LockedUntil = until
+	attempts.ExpiresAt = until
+	return nil
+}
+
+func (s *LockoutStore) ClearLoginAttempts(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.attempts, key)
+	return nil
+}
+
+func (s *LockoutStore) InsertLockoutEvent(ctx context.Context, event *types.LockoutEvent) (*types.LockoutEvent, error) {
+	stored, err := clone(event)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, stored)
+	event.ID = stored.ID
+	return event, nil
+}
+
+func (s *LockoutStore) GetLockoutEvents(ctx context.Context, kind types.LoginKind, subject string) ([]*types.LockoutEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := []*types.LockoutEvent{}
+	for _, event := range s.events {
+		if (kind != "" && event.Kind != kind) || (subject != "" && event.Subject != subject) {
+			continue
+		}
+		cloned, err := clone(event)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, cloned)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At.After(events[j].At)
+	})
+	return events, nil
+}
@@ -0,0 +1,87 @@
+// Package memory provides thread-safe in-memory implementations of the db
+// store interfaces, for running the API and its tests without MongoDB.
+package memory
+
+import (
+	"fmt"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NewStore returns a db.Store backed entirely by memory.
+func NewStore() *db.Store {
+	var (
+		hotelStore   = NewHotelStore()
+		roomStore    = NewRoomStore(hotelStore)
+		bookingStore = NewBookingStore()
+	)
+	return &db.Store{
+		User:         NewUserStore(),
+		Hotel:        hotelStore,
+		Room:         roomStore,
+		Booking:      bookingStore,
+		Availability: NewAvailabilityStore(hotelStore, roomStore, bookingStore),
+		RatePlan:     NewRatePlanStore(),
+		Hold:         NewHoldStore(bookingStore),
+		Payment:      NewPaymentStore(),
+		PaymentEvent: NewPaymentEventStore(),
+		Folio:        NewFolioStore(),
+		Invoice:      NewInvoiceStore(),
+		Promo:        NewPromoStore(),
+		Token:        NewTokenStore(),
+		Lockout:      NewLockoutStore(),
+	}
+}
+
+func newID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+func validateID(id string) error {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return types.ErrInvalidID(err)
+	}
+	return nil
+}
+
+// clone deep copies v through its bson encoding, so callers get back the
+// same shape of value the Mongo stores would return and can not mutate the
+// stored one.
+func clone[T any](v *T) (*T, error) {
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	var out T
+	if err := bson.Unmarshal(raw, &out); err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return &out, nil
+}
+
+// applySet mimics a Mongo $set of the given bson fields on v.
+func applySet[T any, V any](v *T, update map[string]V) error {
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return types.ErrInternal(err)
+	}
+	for key, value := range update {
+		doc[key] = value
+	}
+	if raw, err = bson.Marshal(doc); err != nil {
+		return types.ErrInternal(err)
+	}
+	var out T
+	if err := bson.Unmarshal(raw, &out); err != nil {
+		return types.ErrInternal(fmt.Errorf("invalid update: %w", err))
+	}
+	*v = out
+	return nil
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+var _ db.PaymentEventStore = (*PaymentEventStore)(nil)
+
+type PaymentEventStore struct {
+	mu     sync.Mutex
+	events map[string]*types.PaymentEvent
+}
+
+func NewPaymentEventStore() *PaymentEventStore {
+	return &PaymentEventStore{
+		events: map[string]*types.PaymentEvent{},
+	}
+}
+
+func (s *PaymentEventStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping payment event store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = map[string]*types.PaymentEvent{}
+	return nil
+}
+
+func (s *PaymentEventStore) InsertPaymentEvent(ctx context.Context, event *types.PaymentEvent) (bool, error) {
+	stored, err := clone(event)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.events[event.ID]; ok {
+		return false, nil
+	}
+	s.events[event.ID] = stored
+	return true, nil
+}
+
+func (s *PaymentEventStore) DeletePaymentEvent(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.events, id)
+	return nil
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.PaymentStore = (*PaymentStore)(nil)
+
+type PaymentStore struct {
+	mu       sync.RWMutex
+	payments []*types.Payment
+}
+
+func NewPaymentStore() *PaymentStore {
+	return &PaymentStore{}
+}
+
+func (s *PaymentStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping payment store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payments = nil
+	return nil
+}
+
+func (s *PaymentStore) find(id string) (int, *types.Payment) {
+	for i, payment := range s.payments {
+		if payment.ID == id {
+			return i, payment
+		}
+	}
+	return -1, nil
+}
+
+func (s *PaymentStore) InsertPayment(ctx context.Context, payment *types.Payment) (*types.Payment, error) {
+	stored, err := clone(payment)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.payments = append(s.payments, stored)
+	payment.ID = stored.ID
+	return payment, nil
+}
+
+func (s *PaymentStore) GetPayment(ctx context.Context, id string) (*types.Payment, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, payment := s.find(id)
+	if payment == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return clone(payment)
+}
+
+func (s *PaymentStore) GetPaymentsByBooking(ctx context.Context, bookingID string) ([]*types.Payment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	payments := []*types.Payment{}
+	for _, payment := range s.payments {
+		if payment.BookingID != bookingID {
+			continue
+		}
+		p, err := clone(payment)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, nil
+}
+
+func (s *PaymentStore) UpdatePayment(ctx context.Context, previous, updated *types.Payment) (*types.Payment, error) {
+	if err := validateID(previous.ID); err != nil {
+		return nil, err
+	}
+	stored, err := clone(updated)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, payment := s.find(previous.ID)
+	if payment == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	if payment.Status != previous.Status || payment.Refunded.Amount != previous.Refunded.Amount ||
+		payment.PendingKey() != previous.PendingKey() {
+		return nil, types.ErrInvalidPaymentOperation(fmt.Errorf("payment %s changed concurrently", previous.ID))
+	}
+	s.payments[i] = stored
+	return updated, nil
+}
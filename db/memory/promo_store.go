@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.PromoStore = (*PromoStore)(nil)
+
+type PromoStore struct {
+	mu          sync.RWMutex
+	promos      []*types.Promo
+	redemptions []*types.PromoRedemption
+}
+
+func NewPromoStore() *PromoStore {
+	return &PromoStore{}
+}
+
+func (s *PromoStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping promo store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.promos = nil
+	s.redemptions = nil
+	return nil
+}
+
+func (s *PromoStore) find(id string) (int, *types.Promo) {
+	for i, promo := range s.promos {
+		if promo.ID == id {
+			return i, promo
+		}
+	}
+	return -1, nil
+}
+
+func (s *PromoStore) InsertPromo(ctx context.Context, promo *types.Promo) (*types.Promo, error) {
+	stored, err := clone(promo)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	if stored.UserRedemptions == nil {
+		stored.UserRedemptions = map[string]int{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.promos {
+		if other.Code == promo.Code {
+			return nil, types.ErrInvalidParams(fmt.Errorf("promo code %s already exists", promo.Code))
+		}
+	}
+	s.promos = append(s.promos, stored)
+	promo.ID = stored.ID
+	return promo, nil
+}
+
+func (s *PromoStore) GetPromos(ctx context.Context) ([]*types.Promo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	promos := []*types.Promo{}
+	for _, promo := range s.promos {
+		p, err := clone(promo)
+		if err != nil {
+			return nil, err
+		}
+		promos = append(promos, p)
+	}
+	return promos, nil
+}
+
+func (s *PromoStore) GetPromo(ctx context.Context, id string) (*types.Promo, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, promo := s.find(id)
+	if promo == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return clone(promo)
+}
+
+func (s *PromoStore) GetPromoByCode(ctx context.Context, code string) (*types.Promo, error) {
+	code = types.NormalizePromoCode(code)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, promo := range s.promos {
+		if promo.Code == code {
+			return clone(promo)
+		}
+	}
+	return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+}
+
+func (s *PromoStore) DeletePromo(ctx context.Context, id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, promo := s.find(id); promo != nil {
+		s.promos = append(s.promos[:i], s.promos[i+1:]...)
+	}
+	return nil
+}
+
+func (s *PromoStore) RedeemPromo(ctx context.Context, redemption *types.PromoRedemption) (*types.PromoRedemption, error) {
+	if err := validateID(redemption.PromoID); err != nil {
+		return nil, err
+	}
+	stored, err := clone(redemption)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, promo := s.find(redemption.PromoID)
+	if promo == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	if !promo.Available(redemption.UserID) {
+		return nil, types.ErrPromoNotApplicable(fmt.Errorf("promo code %s reached its redemption limit", promo.Code))
+	}
+	for _, other := range s.redemptions {
+		if other.BookingID == redemption.BookingID {
+			return nil, types.ErrPromoNotApplicable(fmt.Errorf("booking %s already redeemed a promo code", redemption.BookingID))
+		}
+	}
+	promo.Redemptions++
+	promo.UserRedemptions[redemption.UserID]++
+	s.redemptions = append(s.redemptions, stored)
+	redemption.ID = stored.ID
+	return redemption, nil
+}
+
+func (s *PromoStore) ReleasePromoRedemption(ctx context.Context, bookingID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, redemption := range s.redemptions {
+		if redemption.BookingID != bookingID {
+			continue
+		}
+		s.redemptions = append(s.redemptions[:i], s.redemptions[i+1:]...)
+		if _, promo := s.find(redemption.PromoID); promo != nil {
+			promo.Redemptions--
+			promo.UserRedemptions[redemption.UserID]--
+		}
+		return nil
+	}
+	return nil
+}
+
+func (s *PromoStore) GetPromoRedemptions(ctx context.Context, promoID string) ([]*types.PromoRedemption, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	redemptions := []*types.PromoRedemption{}
+	for _, redemption := range s.redemptions {
+		if redemption.PromoID != promoID {
+			continue
+		}
+		r, err := clone(redemption)
+		if err != nil {
+			return nil, err
+		}
+		redemptions = append(redemptions, r)
+	}
+	sort.SliceStable(redemptions, func(i, j int) bool {
+		return redemptions[i].RedeemedAt.Before(redemptions[j].RedeemedAt)
+	})
+	return redemptions, nil
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.RatePlanStore = (*RatePlanStore)(nil)
+
+type RatePlanStore struct {
+	mu    sync.RWMutex
+	plans []*types.RatePlan
+}
+
+func NewRatePlanStore() *RatePlanStore {
+	return &RatePlanStore{}
+}
+
+func (s *RatePlanStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping rate plan store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.plans = nil
+	return nil
+}
+
+func (s *RatePlanStore) find(id string) (int, *types.RatePlan) {
+	for i, plan := range s.plans {
+		if plan.ID == id {
+			return i, plan
+		}
+	}
+	return -1, nil
+}
+
+func (s *RatePlanStore) InsertRatePlan(ctx context.Context, plan *types.RatePlan) (*types.RatePlan, error) {
+	stored, err := clone(plan)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.mu.Lock()
+	s.plans = append(s.plans, stored)
+	s.mu.Unlock()
+	plan.ID = stored.ID
+	return plan, nil
+}
+
+// GetRatePlansByHotel returns the plans of a hotel, highest priority first.
+func (s *RatePlanStore) GetRatePlansByHotel(ctx context.Context, hotelID string) ([]*types.RatePlan, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	plans := []*types.RatePlan{}
+	for _, plan := range s.plans {
+		if plan.HotelID != hotelID {
+			continue
+		}
+		p, err := clone(plan)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, p)
+	}
+	sort.SliceStable(plans, func(i, j int) bool {
+		return plans[i].Priority > plans[j].Priority
+	})
+	return plans, nil
+}
+
+func (s *RatePlanStore) GetRatePlan(ctx context.Context, id string) (*types.RatePlan, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, plan := s.find(id)
+	if plan == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return clone(plan)
+}
+
+func (s *RatePlanStore) DeleteRatePlan(ctx context.Context, id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, plan := s.find(id); plan != nil {
+		s.plans = append(s.plans[:i], s.plans[i+1:]...)
+	}
+	return nil
+}
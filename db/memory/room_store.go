@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.RoomStore = (*RoomStore)(nil)
+
+type RoomStore struct {
+	mu    sync.RWMutex
+	rooms []*types.Room
+
+	db.HotelStore
+}
+
+func NewRoomStore(hotelStore db.HotelStore) *RoomStore {
+	return &RoomStore{
+		HotelStore: hotelStore,
+	}
+}
+
+func (s *RoomStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping room store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rooms = nil
+	return nil
+}
+
+func (s *RoomStore) find(id string) (int, *types.Room) {
+	for i, room := range s.rooms {
+		if room.ID == id {
+			return i, room
+		}
+	}
+	return -1, nil
+}
+
+func (s *RoomStore) InsertRoom(ctx context.Context, room *types.Room) (*types.Room, error) {
+	stored, err := clone(room)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.mu.Lock()
+	s.rooms = append(s.rooms, stored)
+	s.mu.Unlock()
+	room.ID = stored.ID
+
+	//update hotel with room IDs slice
+	if err := s.HotelStore.UpdateHotelRooms(ctx, room.HotelID, room.ID); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+func (s *RoomStore) filter(match func(*types.Room) bool) ([]*types.Room, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rooms := []*types.Room{}
+	for _, room := range s.rooms {
+		if !match(room) {
+			continue
+		}
+		r, err := clone(room)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, r)
+	}
+	return rooms, nil
+}
+
+func (s *RoomStore) GetRooms(ctx context.Context, hotelID string) ([]*types.Room, error) {
+	return s.filter(func(r *types.Room) bool {
+		return r.HotelID == hotelID
+	})
+}
+
+func (s *RoomStore) GetRoom(ctx context.Context, roomID string) (*types.Room, error) {
+	if err := validateID(roomID); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, room := s.find(roomID)
+	if room == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return clone(room)
+}
+
+func (s *RoomStore) DeleteRoom(ctx context.Context, id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	i, room := s.find(id)
+	if room == nil {
+		s.mu.Unlock()
+		return nil
+	}
+	s.rooms = append(s.rooms[:i], s.rooms[i+1:]...)
+	s.mu.Unlock()
+	return s.HotelStore.DeleteHotelRoom(ctx, room.HotelID, id)
+}
+
+func (s *RoomStore) DeleteRoomsByHotel(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rooms := []*types.Room{}
+	for _, room := range s.rooms {
+		if room.HotelID != id {
+			rooms = append(rooms, room)
+		}
+	}
+	s.rooms = rooms
+	return nil
+}
@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.TokenStore = (*TokenStore)(nil)
+
+type TokenStore struct {
+	mu      sync.RWMutex
+	refresh []*types.RefreshToken
+	// revoked maps the ID of each revoked access token to its expiry.
+	revoked map[string]time.Time
+	resets  []*types.PasswordReset
+}
+
+func NewTokenStore() *TokenStore {
+	return &TokenStore{
+		revoked: map[string]time.Time{},
+	}
+}
+
+func (s *TokenStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping token store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh = nil
+	s.revoked = map[string]time.Time{}
+	s.resets = nil
+	return nil
+}
+
+func (s *TokenStore) InsertRefreshToken(ctx context.Context, token *types.RefreshToken) (*types.RefreshToken, error) {
+	stored, err := clone(token)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.refresh {
+		if other.Hash == token.Hash {
+			return nil, types.ErrInternal(fmt.Errorf("duplicate refresh token"))
+		}
+	}
+	s.refresh = append(s.refresh, stored)
+	token.ID = stored.ID
+	return token, nil
+}
+
+func (s *TokenStore) GetRefreshToken(ctx context.Context, hash string) (*types.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, token := range s.refresh {
+		if token.Hash == hash {
+			return clone(token)
+		}
+	}
+	return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+}
+
+func (s *TokenStore) UseRefreshToken(ctx context.Context, id string, at time.Time) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.refresh {
+		if token.ID != id {
+			continue
+		}
+		if !token.UsedAt.IsZero() || !token.RevokedAt.IsZero() {
+			return types.ErrUnauthorized(fmt.Errorf("refresh token already used"))
+		}
+		token.UsedAt = at
+		return nil
+	}
+	return types.ErrNotFound(mongo.ErrNoDocuments)
+}
+
+func (s *TokenStore) RevokeRefreshTokens(ctx context.Context, family string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.refresh {
+		if token.Family == family && token.RevokedAt.IsZero() {
+			token.RevokedAt = at
+		}
+	}
+	return nil
+}
+
+func (s *TokenStore) RevokeUserRefreshTokens(ctx context.Context, userID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.refresh {
+		if token.UserID == userID && token.RevokedAt.IsZero() {
+			token.RevokedAt = at
+		}
+	}
+	return nil
+}
+
+func (s *TokenStore) RevokeAccessToken(ctx context.Context, token *types.RevokedToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	//forget tokens that expired anyway, as the Mongo TTL index does
+	now := time.Now()
+	for id, expiresAt := range s.revoked {
+		if !now.Before(expiresAt) {
+			delete(s.revoked, id)
+		}
+	}
+	s.revoked[token.ID] = token.ExpiresAt
+	return nil
+}
+
+func (s *TokenStore) IsAccessTokenRevoked(ctx context.Context, id string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.revoked[id]
+	return ok, nil
+}
+
+func (s *TokenStore) InsertPasswordReset(ctx context.Context, reset *types.PasswordReset) (*types.PasswordReset, error) {
+	stored, err := clone(reset)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resets = append(s.resets, stored)
+	reset.ID = stored.ID
+	return reset, nil
+}
+
+func (s *TokenStore) GetPasswordReset(ctx context.Context, hash string) (*types.PasswordReset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, reset := range s.resets {
+		if reset.Hash == hash {
+			return clone(reset)
+		}
+	}
+	return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+}
+
+func (s *TokenStore) UsePasswordReset(ctx context.Context, id string, at time.Time) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, reset := range s.resets {
+		if reset.ID != id {
+			continue
+		}
+		if !reset.UsedAt.IsZero() {
+			return types.ErrUnauthorized(fmt.Errorf("reset token already used"))
+		}
+		reset.UsedAt = at
+		return nil
+	}
+	return types.ErrNotFound(mongo.ErrNoDocuments)
+}
@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var _ db.UserStore = (*UserStore)(nil)
+
+type UserStore struct {
+	mu    sync.RWMutex
+	users []*types.User
+}
+
+func NewUserStore() *UserStore {
+	return &UserStore{}
+}
+
+func (s *UserStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping user store")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = nil
+	return nil
+}
+
+func (s *UserStore) find(id string) (int, *types.User) {
+	for i, user := range s.users {
+		if user.ID == id {
+			return i, user
+		}
+	}
+	return -1, nil
+}
+
+func (s *UserStore) UpdateUser(ctx context.Context, id string, updateValid map[string]string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, user := s.find(id)
+	if user == nil {
+		return nil
+	}
+	return applySet(user, updateValid)
+}
+
+func (s *UserStore) DeleteUser(ctx context.Context, id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, _ := s.find(id); i >= 0 {
+		s.users = append(s.users[:i], s.users[i+1:]...)
+	}
+	return nil
+}
+
+func (s *UserStore) InsertUser(ctx context.Context, user *types.User) (*types.User, error) {
+	stored, err := clone(user)
+	if err != nil {
+		return nil, err
+	}
+	stored.ID = newID()
+	s.mu.Lock()
+	s.users = append(s.users, stored)
+	s.mu.Unlock()
+	user.ID = stored.ID
+	return user, nil
+}
+
+func (s *UserStore) GetUserByID(ctx context.Context, id string) (*types.User, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, user := s.find(id)
+	if user == nil {
+		return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return clone(user)
+}
+
+func (s *UserStore) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, user := range s.users {
+		if user.Email == email {
+			return clone(user)
+		}
+	}
+	return nil, types.ErrNotFound(mongo.ErrNoDocuments)
+}
+
+func (s *UserStore) GetUsers(ctx context.Context) ([]*types.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := []*types.User{}
+	for _, user := range s.users {
+		u, err := clone(user)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (s *UserStore) SetUserRoles(ctx context.Context, id string, isAdmin bool, roles []types.RoleGrant) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, user := s.find(id)
+	if user == nil {
+		return types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return applySet(user, map[string]any{"isAdmin": isAdmin, "roles": roles})
+}
+
+func (s *UserStore) SetPassword(ctx context.Context, id, encpw string, changedAt time.Time) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, user := s.find(id)
+	if user == nil {
+		return types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	user.EncyptedPassword = encpw
+	user.PasswordChangedAt = changedAt
+	return nil
+}
+
+func (s *UserStore) SetEmailVerified(ctx context.Context, id, email string, verified bool) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, user := s.find(id)
+	if user == nil || (verified && user.Email != email) {
+		return types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	user.EmailVerified = verified
+	return nil
+}
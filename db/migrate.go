@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var legacyNumber = bson.M{"$type": "number"}
+
+// MigrateLegacyPrices rewrites the prices stored as floats before
+// types.Money existed as Money in the currency of their hotel. Documents
+// already migrated are left untouched, so it can be run more than once.
+func MigrateLegacyPrices(ctx context.Context, client *mongo.Client, dbname string) error {
+	var (
+		database   = client.Database(dbname)
+		hotelStore = NewMongoHotelStore(client, dbname)
+		currencies = map[string]string{}
+	)
+	currencyOf := func(hotelID string) (string, error) {
+		if currency, ok := currencies[hotelID]; ok {
+			return currency, nil
+		}
+		hotel, err := hotelStore.GetHotelByID(ctx, hotelID)
+		if err != nil {
+			return "", fmt.Errorf("hotel %s: %w", hotelID, err)
+		}
+		currencies[hotelID] = hotel.PriceCurrency()
+		return currencies[hotelID], nil
+	}
+
+	var rooms []*types.Room
+	if err := findAll(ctx, database.Collection(roomColl), bson.M{"price": legacyNumber}, &rooms); err != nil {
+		return err
+	}
+	for _, room := range rooms {
+		currency, err := currencyOf(room.HotelID)
+		if err != nil {
+			return err
+		}
+		if err := setByID(ctx, database.Collection(roomColl), room.ID, bson.M{"price": room.Price.OrCurrency(currency)}); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("--- migrated %d room prices\n", len(rooms))
+
+	var plans []*types.RatePlan
+	if err := findAll(ctx, database.Collection(ratePlanColl), bson.M{"baseRate": legacyNumber}, &plans); err != nil {
+		return err
+	}
+	for _, plan := range plans {
+		currency, err := currencyOf(plan.HotelID)
+		if err != nil {
+			return err
+		}
+		if err := setByID(ctx, database.Collection(ratePlanColl), plan.ID, bson.M{"baseRate": plan.BaseRate.OrCurrency(currency)}); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("--- migrated %d rate plan prices\n", len(plans))
+
+	var bookings []*types.Booking
+	if err := findAll(ctx, database.Collection(bookingColl), bson.M{"price.total": legacyNumber}, &bookings); err != nil {
+		return err
+	}
+	for _, booking := range bookings {
+		currency, err := currencyOf(booking.HotelID)
+		if err != nil {
+			return err
+		}
+		if err := setByID(ctx, database.Collection(bookingColl), booking.ID, bson.M{"price": booking.Price.OrCurrency(currency)}); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("--- migrated %d booking prices\n", len(bookings))
+	return nil
+}
+
+func findAll(ctx context.Context, coll *mongo.Collection, filter bson.M, results any) error {
+	cur, err := coll.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	return cur.All(ctx, results)
+}
+
+func setByID(ctx context.Context, coll *mongo.Collection, id string, set bson.M) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": set})
+	return err
+}
+
+// MigrateEmailVerification flags the users registered before email
+// verification existed verified, so they can keep booking.
+func MigrateEmailVerification(ctx context.Context, client *mongo.Client, dbname string) error {
+	filter := bson.M{"emailVerified": bson.M{"$exists": false}}
+	res, err := client.Database(dbname).Collection(userColl).UpdateMany(ctx, filter, bson.M{"$set": bson.M{"emailVerified": true}})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("--- migrated %d users to verified emails\n", res.ModifiedCount)
+	return nil
+}
+
+// MigrateRoomNightLedger reserves in the room night ledger the nights of
+// the bookings written before it existed, which would otherwise show their
+// rooms free. Nights already reserved for the booking are kept, so it can
+// be run more than once; nights another booking reserved are reported as
+// overbooked and left to it.
+func MigrateRoomNightLedger(ctx context.Context, client *mongo.Client, dbname string) error {
+	var (
+		ledger   = newRoomNightLedger(client, dbname)
+		bookings []*types.Booking
+	)
+	filter := bson.M{
+		"cancelled": bson.M{"$ne": true},
+		"status":    bson.M{"$nin": bson.A{types.StatusCancelled, types.StatusNoShow}},
+		"toDate":    bson.M{"$gt": time.Now()},
+	}
+	if err := findAll(ctx, client.Database(dbname).Collection(bookingColl), filter, &bookings); err != nil {
+		return err
+	}
+	reserved := 0
+	for _, booking := range bookings {
+		if len(booking.Nights) == 0 {
+			booking.Nights = types.StayNights(booking.FromDate, booking.ToDate)
+			if err := setByID(ctx, client.Database(dbname).Collection(bookingColl), booking.ID, bson.M{"nights": booking.Nights}); err != nil {
+				return err
+			}
+		}
+		for _, night := range booking.Nights {
+			owner, err := ledger.backfill(ctx, booking.RoomID, booking.ID, night)
+			if err != nil {
+				return err
+			}
+			if owner != booking.ID {
+				fmt.Printf("--- room %s overbooked on %s by bookings %s and %s\n", booking.RoomID, night, owner, booking.ID)
+				continue
+			}
+			reserved++
+		}
+	}
+	fmt.Printf("--- reserved %d nights of %d bookings\n", reserved, len(bookings))
+	return nil
+}
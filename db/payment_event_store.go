@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const paymentEventColl = "paymentEvents"
+
+// PaymentEventStore keeps the payment processor events already processed,
+// by their ID, so a redelivered event is only processed once.
+type PaymentEventStore interface {
+	// InsertPaymentEvent records event and reports false, without error,
+	// when an event with its ID was recorded before.
+	InsertPaymentEvent(ctx context.Context, event *types.PaymentEvent) (bool, error)
+	// DeletePaymentEvent forgets an event whose processing failed, so it is
+	// processed again when redelivered.
+	DeletePaymentEvent(ctx context.Context, id string) error
+
+	Dropper
+}
+
+type MongoPaymentEventStore struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+}
+
+func NewMongoPaymentEventStore(client *mongo.Client, dbname string) *MongoPaymentEventStore {
+	return &MongoPaymentEventStore{
+		client: client,
+		coll:   client.Database(dbname).Collection(paymentEventColl),
+	}
+}
+
+func (s *MongoPaymentEventStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping payment event collection")
+	return s.coll.Drop(ctx)
+}
+
+func (s *MongoPaymentEventStore) InsertPaymentEvent(ctx context.Context, event *types.PaymentEvent) (bool, error) {
+	//the event ID is the _id, so only the first delivery is inserted
+	if _, err := s.coll.InsertOne(ctx, event); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, types.ErrInternal(err)
+	}
+	return true, nil
+}
+
+func (s *MongoPaymentEventStore) DeletePaymentEvent(ctx context.Context, id string) error {
+	if _, err := s.coll.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
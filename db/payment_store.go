@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const paymentColl = "payments"
+
+type PaymentStore interface {
+	InsertPayment(ctx context.Context, payment *types.Payment) (*types.Payment, error)
+	GetPayment(ctx context.Context, id string) (*types.Payment, error)
+	// GetPaymentsByBooking returns the payments of a booking, oldest first.
+	GetPaymentsByBooking(ctx context.Context, bookingID string) ([]*types.Payment, error)
+	// UpdatePayment stores updated, previous as read from the store, when
+	// an operation is recorded as pending or carried out. It fails if
+	// previous changed since it was read.
+	UpdatePayment(ctx context.Context, previous, updated *types.Payment) (*types.Payment, error)
+
+	Dropper
+}
+
+type MongoPaymentStore struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+}
+
+func NewMongoPaymentStore(client *mongo.Client, dbname string) *MongoPaymentStore {
+	return &MongoPaymentStore{
+		client: client,
+		coll:   client.Database(dbname).Collection(paymentColl),
+	}
+}
+
+func (s *MongoPaymentStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping payment collection")
+	return s.coll.Drop(ctx)
+}
+
+func (s *MongoPaymentStore) InsertPayment(ctx context.Context, payment *types.Payment) (*types.Payment, error) {
+	res, err := s.coll.InsertOne(ctx, payment)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	payment.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return payment, nil
+}
+
+func (s *MongoPaymentStore) GetPayment(ctx context.Context, id string) (*types.Payment, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	var payment types.Payment
+	if err = s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&payment); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, types.ErrNotFound(err)
+		}
+		return nil, types.ErrInternal(err)
+	}
+	return &payment, nil
+}
+
+func (s *MongoPaymentStore) GetPaymentsByBooking(ctx context.Context, bookingID string) ([]*types.Payment, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cur, err := s.coll.Find(ctx, bson.M{"bookingID": bookingID}, opts)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	payments := []*types.Payment{}
+	if err = cur.All(ctx, &payments); err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return payments, nil
+}
+
+func (s *MongoPaymentStore) UpdatePayment(ctx context.Context, previous, updated *types.Payment) (*types.Payment, error) {
+	oid, err := primitive.ObjectIDFromHex(previous.ID)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	//only write if nobody changed the payment since it was read
+	filter := bson.M{
+		"_id":             oid,
+		"status":          previous.Status,
+		"refunded.amount": previous.Refunded.Amount,
+	}
+	if previous.Pending == nil {
+		filter["pending"] = nil
+	} else {
+		filter["pending.key"] = previous.Pending.Key
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: updated.Status},
+		{Key: "captured", Value: updated.Captured},
+		{Key: "refunded", Value: updated.Refunded},
+		{Key: "pending", Value: updated.Pending},
+		{Key: "updatedAt", Value: updated.UpdatedAt},
+	}}}
+	res, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	if res.MatchedCount == 0 {
+		return nil, types.ErrInvalidPaymentOperation(fmt.Errorf("payment %s changed concurrently", previous.ID))
+	}
+	return updated, nil
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	promoColl           = "promos"
+	promoRedemptionColl = "promoRedemptions"
+)
+
+type PromoStore interface {
+	// InsertPromo fails when another promo has the same code.
+	InsertPromo(ctx context.Context, promo *types.Promo) (*types.Promo, error)
+	GetPromos(ctx context.Context) ([]*types.Promo, error)
+	GetPromo(ctx context.Context, id string) (*types.Promo, error)
+	GetPromoByCode(ctx context.Context, code string) (*types.Promo, error)
+	DeletePromo(ctx context.Context, id string) error
+	// RedeemPromo counts the redemption against the limits of its promo,
+	// failing when any is reached, and records it.
+	RedeemPromo(ctx context.Context, redemption *types.PromoRedemption) (*types.PromoRedemption, error)
+	// ReleasePromoRedemption gives back the redemption of a booking, if it
+	// has one.
+	ReleasePromoRedemption(ctx context.Context, bookingID string) error
+	GetPromoRedemptions(ctx context.Context, promoID string) ([]*types.PromoRedemption, error)
+
+	Dropper
+}
+
+type MongoPromoStore struct {
+	client      *mongo.Client
+	coll        *mongo.Collection
+	redemptions *mongo.Collection
+}
+
+// NewMongoPromoStore returns the promo store of dbname, creating its
+// indexes.
+func NewMongoPromoStore(client *mongo.Client, dbname string) (*MongoPromoStore, error) {
+	s := &MongoPromoStore{
+		client:      client,
+		coll:        client.Database(dbname).Collection(promoColl),
+		redemptions: client.Database(dbname).Collection(promoRedemptionColl),
+	}
+	if err := s.ensureIndexes(context.Background()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MongoPromoStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping promo collection")
+	if err := s.redemptions.Drop(ctx); err != nil {
+		return err
+	}
+	return s.coll.Drop(ctx)
+}
+
+// ensureIndexes creates, unless they exist, the unique indexes that reject
+// a second promo with the same code and a second redemption of a booking.
+func (s *MongoPromoStore) ensureIndexes(ctx context.Context) error {
+	if _, err := s.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return err
+	}
+	_, err := s.redemptions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "bookingID", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (s *MongoPromoStore) InsertPromo(ctx context.Context, promo *types.Promo) (*types.Promo, error) {
+	res, err := s.coll.InsertOne(ctx, promo)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, types.ErrInvalidParams(fmt.Errorf("promo code %s already exists", promo.Code))
+	}
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	promo.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return promo, nil
+}
+
+func (s *MongoPromoStore) GetPromos(ctx context.Context) ([]*types.Promo, error) {
+	cur, err := s.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	promos := []*types.Promo{}
+	if err := cur.All(ctx, &promos); err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return promos, nil
+}
+
+func (s *MongoPromoStore) GetPromo(ctx context.Context, id string) (*types.Promo, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	return s.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (s *MongoPromoStore) GetPromoByCode(ctx context.Context, code string) (*types.Promo, error) {
+	return s.findOne(ctx, bson.M{"code": types.NormalizePromoCode(code)})
+}
+
+func (s *MongoPromoStore) findOne(ctx context.Context, filter bson.M) (*types.Promo, error) {
+	var promo types.Promo
+	if err := s.coll.FindOne(ctx, filter).Decode(&promo); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, types.ErrNotFound(err)
+		}
+		return nil, types.ErrInternal(err)
+	}
+	return &promo, nil
+}
+
+func (s *MongoPromoStore) DeletePromo(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.ErrInvalidID(err)
+	}
+	if _, err = s.coll.DeleteOne(ctx, bson.M{"_id": oid}); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+// RedeemPromo increments the counters of the promo in a single update whose
+// filter only matches while both limits are below their maximum, so
+// concurrent redemptions can not exceed them.
+func (s *MongoPromoStore) RedeemPromo(ctx context.Context, redemption *types.PromoRedemption) (*types.PromoRedemption, error) {
+	oid, err := primitive.ObjectIDFromHex(redemption.PromoID)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	userCount := "userRedemptions." + redemption.UserID
+	belowLimit := func(count any, limit string) bson.M {
+		return bson.M{"$or": bson.A{
+			bson.M{"$eq": bson.A{limit, 0}},
+			bson.M{"$lt": bson.A{count, limit}},
+		}}
+	}
+	filter := bson.M{
+		"_id": oid,
+		"$expr": bson.M{"$and": bson.A{
+			belowLimit("$redemptions", "$maxRedemptions"),
+			belowLimit(bson.M{"$ifNull": bson.A{"$" + userCount, 0}}, "$maxPerUser"),
+		}},
+	}
+	update := bson.M{"$inc": bson.M{"redemptions": 1, userCount: 1}}
+	res, err := s.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	if res.MatchedCount == 0 {
+		if _, err := s.GetPromo(ctx, redemption.PromoID); err != nil {
+			return nil, err
+		}
+		return nil, types.ErrPromoNotApplicable(fmt.Errorf("promo code %s reached its redemption limit", redemption.Code))
+	}
+	ins, err := s.redemptions.InsertOne(ctx, redemption)
+	if err != nil {
+		//give the counted redemption back
+		if _, undoErr := s.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$inc": bson.M{"redemptions": -1, userCount: -1}}); undoErr != nil {
+			return nil, types.ErrInternal(errors.Join(err, undoErr))
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, types.ErrPromoNotApplicable(fmt.Errorf("booking %s already redeemed a promo code", redemption.BookingID))
+		}
+		return nil, types.ErrInternal(err)
+	}
+	redemption.ID = ins.InsertedID.(primitive.ObjectID).Hex()
+	return redemption, nil
+}
+
+func (s *MongoPromoStore) ReleasePromoRedemption(ctx context.Context, bookingID string) error {
+	var redemption types.PromoRedemption
+	if err := s.redemptions.FindOneAndDelete(ctx, bson.M{"bookingID": bookingID}).Decode(&redemption); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil
+		}
+		return types.ErrInternal(err)
+	}
+	oid, err := primitive.ObjectIDFromHex(redemption.PromoID)
+	if err != nil {
+		return types.ErrInvalidID(err)
+	}
+	update := bson.M{"$inc": bson.M{"redemptions": -1, "userRedemptions." + redemption.UserID: -1}}
+	if _, err := s.coll.UpdateOne(ctx, bson.M{"_id": oid}, update); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+func (s *MongoPromoStore) GetPromoRedemptions(ctx context.Context, promoID string) ([]*types.PromoRedemption, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "redeemedAt", Value: 1}})
+	cur, err := s.redemptions.Find(ctx, bson.M{"promoID": promoID}, opts)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	redemptions := []*types.PromoRedemption{}
+	if err := cur.All(ctx, &redemptions); err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return redemptions, nil
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const ratePlanColl = "ratePlans"
+
+type RatePlanStore interface {
+	InsertRatePlan(ctx context.Context, plan *types.RatePlan) (*types.RatePlan, error)
+	GetRatePlansByHotel(ctx context.Context, hotelID string) ([]*types.RatePlan, error)
+	GetRatePlan(ctx context.Context, id string) (*types.RatePlan, error)
+	DeleteRatePlan(ctx context.Context, id string) error
+
+	Dropper
+}
+
+type MongoRatePlanStore struct {
+	client *mongo.Client
+	coll   *mongo.Collection
+}
+
+func NewMongoRatePlanStore(client *mongo.Client, dbname string) *MongoRatePlanStore {
+	return &MongoRatePlanStore{
+		client: client,
+		coll:   client.Database(dbname).Collection(ratePlanColl),
+	}
+}
+
+func (s *MongoRatePlanStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping rate plan collection")
+	return s.coll.Drop(ctx)
+}
+
+func (s *MongoRatePlanStore) InsertRatePlan(ctx context.Context, plan *types.RatePlan) (*types.RatePlan, error) {
+	res, err := s.coll.InsertOne(ctx, plan)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	plan.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return plan, nil
+}
+
+// GetRatePlansByHotel returns the plans of a hotel, highest priority first.
+func (s *MongoRatePlanStore) GetRatePlansByHotel(ctx context.Context, hotelID string) ([]*types.RatePlan, error) {
+	opts := options.Find().SetSort(bson.D{
+		{Key: "priority", Value: -1},
+		{Key: "_id", Value: 1},
+	})
+	cur, err := s.coll.Find(ctx, bson.M{"hotelID": hotelID}, opts)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	plans := []*types.RatePlan{}
+	if err = cur.All(ctx, &plans); err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return plans, nil
+}
+
+func (s *MongoRatePlanStore) GetRatePlan(ctx context.Context, id string) (*types.RatePlan, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, types.ErrInvalidID(err)
+	}
+	var plan types.RatePlan
+	if err = s.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&plan); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, types.ErrNotFound(err)
+		}
+		return nil, types.ErrInternal(err)
+	}
+	return &plan, nil
+}
+
+func (s *MongoRatePlanStore) DeleteRatePlan(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.ErrInvalidID(err)
+	}
+	if _, err = s.coll.DeleteOne(ctx, bson.M{"_id": oid}); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
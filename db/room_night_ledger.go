@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const roomNightColl = "roomNights"
+
+// roomNight reserves a single night of a room for a booking or a hold. Its
+// _id is built from the room and the night, so the unique _id index rejects
+// a second reservation of the same room for the same night. Nights of holds
+// have an ExpiresAt, after which they count as free.
+type roomNight struct {
+	ID        string     `bson:"_id"`
+	RoomID    string     `bson:"roomID"`
+	Night     string     `bson:"night"`
+	BookingID string     `bson:"bookingID"`
+	ExpiresAt *time.Time `bson:"expiresAt,omitempty"`
+}
+
+type roomNightLedger struct {
+	coll *mongo.Collection
+}
+
+func newRoomNightLedger(client *mongo.Client, dbname string) roomNightLedger {
+	return roomNightLedger{
+		coll: client.Database(dbname).Collection(roomNightColl),
+	}
+}
+
+func roomNightID(roomID, night string) string {
+	return fmt.Sprintf("%s:%s", roomID, night)
+}
+
+func roomNightIDs(roomID string, nights []string) []string {
+	ids := make([]string, len(nights))
+	for i, night := range nights {
+		ids[i] = roomNightID(roomID, night)
+	}
+	return ids
+}
+
+// activeNight matches the room nights that are not expired at now.
+func activeNight(now time.Time) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"expiresAt": nil},
+		bson.M{"expiresAt": bson.M{"$gt": now}},
+	}}
+}
+
+// reserve claims every night of roomID for bookingID. Either all nights are
+// claimed or none: on conflict the nights already written are released and
+// an unavailable date error is returned. Nights bookingID held before are
+// kept. A non zero expiresAt makes the nights a hold that expires then.
+func (l roomNightLedger) reserve(ctx context.Context, roomID, bookingID string, nights []string, expiresAt time.Time) error {
+	if len(nights) == 0 {
+		return nil
+	}
+	//expired holds still occupy their _id until purged
+	ids := roomNightIDs(roomID, nights)
+	if _, err := l.coll.DeleteMany(ctx, bson.M{
+		"_id":       bson.M{"$in": ids},
+		"expiresAt": bson.M{"$lte": time.Now()},
+	}); err != nil {
+		return types.ErrInternal(err)
+	}
+	docs := make([]any, len(nights))
+	for i, night := range nights {
+		doc := roomNight{
+			ID:        ids[i],
+			RoomID:    roomID,
+			Night:     night,
+			BookingID: bookingID,
+		}
+		if !expiresAt.IsZero() {
+			doc.ExpiresAt = &expiresAt
+		}
+		docs[i] = doc
+	}
+	if _, err := l.coll.InsertMany(ctx, docs); err != nil {
+		if relErr := l.releaseNights(ctx, roomID, bookingID, nights); relErr != nil {
+			return relErr
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return types.ErrUnavailableDate(fmt.Errorf("unavailable date"))
+		}
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+// isFree reports whether none of the nights of roomID are reserved.
+func (l roomNightLedger) isFree(ctx context.Context, roomID string, nights []string) (bool, error) {
+	if len(nights) == 0 {
+		return true, nil
+	}
+	filter := activeNight(time.Now())
+	filter["_id"] = bson.M{"$in": roomNightIDs(roomID, nights)}
+	n, err := l.coll.CountDocuments(ctx, filter)
+	if err != nil {
+		return false, types.ErrInternal(err)
+	}
+	return n == 0, nil
+}
+
+// release frees every night held by bookingID.
+func (l roomNightLedger) release(ctx context.Context, bookingID string) error {
+	if _, err := l.coll.DeleteMany(ctx, bson.M{"bookingID": bookingID}); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+// releaseNights frees the given nights of roomID if bookingID holds them.
+func (l roomNightLedger) releaseNights(ctx context.Context, roomID, bookingID string, nights []string) error {
+	if len(nights) == 0 {
+		return nil
+	}
+	ids := roomNightIDs(roomID, nights)
+	if _, err := l.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}, "bookingID": bookingID}); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+// backfill reserves night of roomID for bookingID unless it is reserved
+// already, and returns the booking or hold holding it.
+func (l roomNightLedger) backfill(ctx context.Context, roomID, bookingID, night string) (string, error) {
+	doc := roomNight{
+		ID:        roomNightID(roomID, night),
+		RoomID:    roomID,
+		Night:     night,
+		BookingID: bookingID,
+	}
+	//an expired hold does not keep the night
+	if _, err := l.coll.DeleteOne(ctx, bson.M{"_id": doc.ID, "expiresAt": bson.M{"$lte": time.Now()}}); err != nil {
+		return "", types.ErrInternal(err)
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var held roomNight
+	if err := l.coll.FindOneAndUpdate(ctx, bson.M{"_id": doc.ID}, bson.M{"$setOnInsert": doc}, opts).Decode(&held); err != nil {
+		return "", types.ErrInternal(err)
+	}
+	return held.BookingID, nil
+}
+
+func (l roomNightLedger) drop(ctx context.Context) error {
+	return l.coll.Drop(ctx)
+}
@@ -11,6 +11,8 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+const roomColl = "rooms"
+
 type RoomStore interface {
 	InsertRoom(ctx context.Context, room *types.Room) (*types.Room, error)
 	GetRooms(ctx context.Context, hotelID string) ([]*types.Room, error)
@@ -30,7 +32,7 @@ type MongoRoomStore struct {
 func NewMongoRoomStore(client *mongo.Client, dbname string, hotelStore HotelStore) *MongoRoomStore {
 	return &MongoRoomStore{
 		client:     client,
-		coll:       client.Database(dbname).Collection("rooms"),
+		coll:       client.Database(dbname).Collection(roomColl),
 		HotelStore: hotelStore,
 	}
 }
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	refreshTokenColl  = "refreshTokens"
+	revokedTokenColl  = "revokedTokens"
+	passwordResetColl = "passwordResets"
+)
+
+type TokenStore interface {
+	InsertRefreshToken(ctx context.Context, token *types.RefreshToken) (*types.RefreshToken, error)
+	GetRefreshToken(ctx context.Context, hash string) (*types.RefreshToken, error)
+	// UseRefreshToken marks the refresh token of id used at at. It fails
+	// when the token was used or revoked meanwhile, so a token is only
+	// exchanged once.
+	UseRefreshToken(ctx context.Context, id string, at time.Time) error
+	// RevokeRefreshTokens revokes at at every refresh token of family.
+	RevokeRefreshTokens(ctx context.Context, family string, at time.Time) error
+	// RevokeUserRefreshTokens revokes at at every refresh token of a user.
+	RevokeUserRefreshTokens(ctx context.Context, userID string, at time.Time) error
+	// RevokeAccessToken denies an access token until it expires.
+	RevokeAccessToken(ctx context.Context, token *types.RevokedToken) error
+	IsAccessTokenRevoked(ctx context.Context, id string) (bool, error)
+
+	InsertPasswordReset(ctx context.Context, reset *types.PasswordReset) (*types.PasswordReset, error)
+	GetPasswordReset(ctx context.Context, hash string) (*types.PasswordReset, error)
+	// UsePasswordReset marks the password reset of id used at at. It fails
+	// when the reset was used meanwhile, so a reset token works only once.
+	UsePasswordReset(ctx context.Context, id string, at time.Time) error
+
+	Dropper
+}
+
+type MongoTokenStore struct {
+	client  *mongo.Client
+	refresh *mongo.Collection
+	revoked *mongo.Collection
+	resets  *mongo.Collection
+}
+
+// NewMongoTokenStore returns the token store of dbname, creating its
+// indexes.
+func NewMongoTokenStore(client *mongo.Client, dbname string) (*MongoTokenStore, error) {
+	s := &MongoTokenStore{
+		client:  client,
+		refresh: client.Database(dbname).Collection(refreshTokenColl),
+		revoked: client.Database(dbname).Collection(revokedTokenColl),
+		resets:  client.Database(dbname).Collection(passwordResetColl),
+	}
+	if err := s.ensureIndexes(context.Background()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *MongoTokenStore) Drop(ctx context.Context) error {
+	fmt.Println("--- dropping token collection")
+	if err := s.revoked.Drop(ctx); err != nil {
+		return err
+	}
+	if err := s.resets.Drop(ctx); err != nil {
+		return err
+	}
+	return s.refresh.Drop(ctx)
+}
+
+// ensureIndexes looks refresh tokens and password resets up by hash and
+// lets Mongo delete them once they expire.
+func (s *MongoTokenStore) ensureIndexes(ctx context.Context) error {
+	expire := func() mongo.IndexModel {
+		return mongo.IndexModel{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		}
+	}
+	for _, coll := range []*mongo.Collection{s.refresh, s.resets} {
+		if _, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			expire(),
+		}); err != nil {
+			return err
+		}
+	}
+	_, err := s.revoked.Indexes().CreateOne(ctx, expire())
+	return err
+}
+
+func (s *MongoTokenStore) InsertRefreshToken(ctx context.Context, token *types.RefreshToken) (*types.RefreshToken, error) {
+	res, err := s.refresh.InsertOne(ctx, token)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	token.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return token, nil
+}
+
+func (s *MongoTokenStore) GetRefreshToken(ctx context.Context, hash string) (*types.RefreshToken, error) {
+	var token types.RefreshToken
+	if err := s.refresh.FindOne(ctx, bson.M{"hash": hash}).Decode(&token); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, types.ErrNotFound(err)
+		}
+		return nil, types.ErrInternal(err)
+	}
+	return &token, nil
+}
+
+func (s *MongoTokenStore) UseRefreshToken(ctx context.Context, id string, at time.Time) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.ErrInvalidID(err)
+	}
+	//only the first of concurrent refreshes finds the token unused
+	filter := bson.M{
+		"_id":       oid,
+		"usedAt":    bson.M{"$exists": false},
+		"revokedAt": bson.M{"$exists": false},
+	}
+	res, err := s.refresh.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"usedAt": at}})
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	if res.MatchedCount == 0 {
+		return types.ErrUnauthorized(fmt.Errorf("refresh token already used"))
+	}
+	return nil
+}
+
+func (s *MongoTokenStore) RevokeRefreshTokens(ctx context.Context, family string, at time.Time) error {
+	filter := bson.M{"family": family, "revokedAt": bson.M{"$exists": false}}
+	if _, err := s.refresh.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": at}}); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+func (s *MongoTokenStore) RevokeUserRefreshTokens(ctx context.Context, userID string, at time.Time) error {
+	filter := bson.M{"userID": userID, "revokedAt": bson.M{"$exists": false}}
+	if _, err := s.refresh.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revokedAt": at}}); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+func (s *MongoTokenStore) RevokeAccessToken(ctx context.Context, token *types.RevokedToken) error {
+	opts := options.Replace().SetUpsert(true)
+	if _, err := s.revoked.ReplaceOne(ctx, bson.M{"_id": token.ID}, token, opts); err != nil {
+		return types.ErrInternal(err)
+	}
+	return nil
+}
+
+func (s *MongoTokenStore) IsAccessTokenRevoked(ctx context.Context, id string) (bool, error) {
+	n, err := s.revoked.CountDocuments(ctx, bson.M{"_id": id}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, types.ErrInternal(err)
+	}
+	return n > 0, nil
+}
+
+func (s *MongoTokenStore) InsertPasswordReset(ctx context.Context, reset *types.PasswordReset) (*types.PasswordReset, error) {
+	res, err := s.resets.InsertOne(ctx, reset)
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	reset.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return reset, nil
+}
+
+func (s *MongoTokenStore) GetPasswordReset(ctx context.Context, hash string) (*types.PasswordReset, error) {
+	var reset types.PasswordReset
+	if err := s.resets.FindOne(ctx, bson.M{"hash": hash}).Decode(&reset); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, types.ErrNotFound(err)
+		}
+		return nil, types.ErrInternal(err)
+	}
+	return &reset, nil
+}
+
+func (s *MongoTokenStore) UsePasswordReset(ctx context.Context, id string, at time.Time) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.ErrInvalidID(err)
+	}
+	filter := bson.M{"_id": oid, "usedAt": bson.M{"$exists": false}}
+	res, err := s.resets.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"usedAt": at}})
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	if res.MatchedCount == 0 {
+		return types.ErrUnauthorized(fmt.Errorf("reset token already used"))
+	}
+	return nil
+}
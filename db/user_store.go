@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jucaza1/hotel-reserv/types"
 	"go.mongodb.org/mongo-driver/bson"
@@ -20,6 +21,14 @@ type UserStore interface {
 	GetUserByID(ctx context.Context, id string) (*types.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*types.User, error)
 	GetUsers(ctx context.Context) ([]*types.User, error)
+	// SetUserRoles replaces the admin flag and staff roles of a user.
+	SetUserRoles(ctx context.Context, id string, isAdmin bool, roles []types.RoleGrant) error
+	// SetPassword replaces the encrypted password of a user, changed at
+	// changedAt.
+	SetPassword(ctx context.Context, id, encpw string, changedAt time.Time) error
+	// SetEmailVerified flags the email of a user verified, or not when it
+	// changed. Verifying fails when the email of the user is not email.
+	SetEmailVerified(ctx context.Context, id, email string, verified bool) error
 
 	Dropper
 }
@@ -119,3 +128,54 @@ func (s *MongoUserStore) GetUsers(ctx context.Context) ([]*types.User, error) {
 	}
 	return users, nil
 }
+
+func (s *MongoUserStore) SetUserRoles(ctx context.Context, id string, isAdmin bool, roles []types.RoleGrant) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.ErrInvalidID(err)
+	}
+	update := bson.M{"$set": bson.M{"isAdmin": isAdmin, "roles": roles}}
+	res, err := s.coll.UpdateOne(ctx, bson.M{"_id": oid}, update)
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	if res.MatchedCount == 0 {
+		return types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return nil
+}
+
+func (s *MongoUserStore) SetPassword(ctx context.Context, id, encpw string, changedAt time.Time) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.ErrInvalidID(err)
+	}
+	update := bson.M{"$set": bson.M{"password": encpw, "passwordChangedAt": changedAt}}
+	res, err := s.coll.UpdateOne(ctx, bson.M{"_id": oid}, update)
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	if res.MatchedCount == 0 {
+		return types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return nil
+}
+
+func (s *MongoUserStore) SetEmailVerified(ctx context.Context, id, email string, verified bool) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return types.ErrInvalidID(err)
+	}
+	filter := bson.M{"_id": oid}
+	if verified {
+		filter["email"] = email
+	}
+	res, err := s.coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"emailVerified": verified}})
+	if err != nil {
+		return types.ErrInternal(err)
+	}
+	if res.MatchedCount == 0 {
+		return types.ErrNotFound(mongo.ErrNoDocuments)
+	}
+	return nil
+}
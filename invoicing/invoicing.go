@@ -0,0 +1,82 @@
+// Package invoicing issues the invoices of stays, numbered per hotel and
+// year, and renders them as HTML and PDF.
+package invoicing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+// Service issues invoices from the bookings, hotels, rooms, users and
+// folios of the stores.
+type Service struct {
+	invoiceStore db.InvoiceStore
+	hotelStore   db.HotelStore
+	roomStore    db.RoomStore
+	userStore    db.UserStore
+	folioStore   db.FolioStore
+}
+
+func NewService(is db.InvoiceStore, hs db.HotelStore, rs db.RoomStore, us db.UserStore, fs db.FolioStore) *Service {
+	return &Service{
+		invoiceStore: is,
+		hotelStore:   hs,
+		roomStore:    rs,
+		userStore:    us,
+		folioStore:   fs,
+	}
+}
+
+// Issue returns the invoice of booking, issuing it the first time once the
+// booking is checked out. Before then the booking has no invoice. Once
+// issued an invoice never changes, charges posted later are not on it.
+func (s *Service) Issue(ctx context.Context, booking *types.Booking) (*types.Invoice, error) {
+	invoice, err := s.invoiceStore.GetInvoiceByBooking(ctx, booking.ID)
+	if err == nil || !isNotFound(err) {
+		return invoice, err
+	}
+	if !booking.Invoiceable() {
+		return nil, types.ErrNotFound(fmt.Errorf("%s booking %s is not invoiced until checked out", booking.Status, booking.ID))
+	}
+	hotel, err := s.hotelStore.GetHotelByID(ctx, booking.HotelID)
+	if err != nil {
+		return nil, err
+	}
+	room, err := s.roomStore.GetRoom(ctx, booking.RoomID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userStore.GetUserByID(ctx, booking.UserID)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	items, err := s.folioStore.GetFolioItems(ctx, booking.ID)
+	if err != nil {
+		return nil, err
+	}
+	invoice, err = types.NewInvoice(booking, hotel, room, user, items, time.Now())
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	return s.invoiceStore.InsertInvoice(ctx, invoice)
+}
+
+// Issued reports whether the booking of bookingID has an invoice.
+func (s *Service) Issued(ctx context.Context, bookingID string) (bool, error) {
+	_, err := s.invoiceStore.GetInvoiceByBooking(ctx, bookingID)
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func isNotFound(err error) bool {
+	var e types.ErrorSt
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
@@ -0,0 +1,187 @@
+package invoicing
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+var htmlTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format(time.DateOnly) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; }
+.amount { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Invoice {{.Number}}</h1>
+<p>Issued on {{date .IssuedAt}}</p>
+<p><strong>{{.Seller.Name}}</strong><br>{{.Seller.Address}}</p>
+<p>Billed to: {{if .Customer.Name}}{{.Customer.Name}}<br>{{.Customer.Email}}{{else}}guest {{.Customer.ID}}{{end}}</p>
+<p>Stay from {{date .FromDate}} to {{date .ToDate}}, booking {{.BookingID}}</p>
+<table>
+<tr><th>Description</th><th class="amount">Quantity</th><th class="amount">Unit price</th><th class="amount">Amount</th></tr>
+{{range .Lines}}<tr><td>{{.Description}}</td><td class="amount">{{.Quantity}}</td><td class="amount">{{.UnitPrice}}</td><td class="amount">{{.Amount}}</td></tr>
+{{end}}<tr><td colspan="3">Subtotal</td><td class="amount">{{.Subtotal}}</td></tr>
+{{range .TaxLines}}<tr><td colspan="3">{{.Name}}{{if .PerNight}} ({{.PerNight}} x {{.Guests}} guests x {{.Nights}} nights){{end}}</td><td class="amount">{{.Amount}}</td></tr>
+{{else}}<tr><td colspan="3">Taxes ({{.TaxRate}}%)</td><td class="amount">{{.Taxes}}</td></tr>
+{{end}}
+<tr><th colspan="3">Total</th><th class="amount">{{.Total}}</th></tr>
+</table>
+</body>
+</html>
+`))
+
+// RenderHTML writes invoice as an HTML page.
+func RenderHTML(w io.Writer, invoice *types.Invoice) error {
+	return htmlTemplate.Execute(w, invoice)
+}
+
+const (
+	pdfPageWidth    = 595 // A4 in points
+	pdfPageHeight   = 842
+	pdfMargin       = 50
+	pdfLineHeight   = 14
+	pdfFontSize     = 9
+	pdfTitleSize    = 16
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin - 2*pdfLineHeight) / pdfLineHeight
+	// pdfColumns is how many Courier characters fit a line.
+	pdfColumns = 82
+)
+
+// RenderPDF writes invoice as a PDF document. The text is laid out in a
+// monospaced font, so no font metrics are needed.
+func RenderPDF(w io.Writer, invoice *types.Invoice) error {
+	lines := pdfText(invoice)
+	var pages [][]string
+	for len(lines) > pdfLinesPerPage {
+		pages = append(pages, lines[:pdfLinesPerPage])
+		lines = lines[pdfLinesPerPage:]
+	}
+	pages = append(pages, lines)
+	return writePDF(w, "Invoice "+invoice.Number, pages)
+}
+
+// pdfText lays the invoice out as lines of at most pdfColumns characters.
+func pdfText(invoice *types.Invoice) []string {
+	row := func(description, quantity, unit, amount string) string {
+		if runes := []rune(description); len(runes) > 44 {
+			description = string(runes[:43]) + "~"
+		}
+		return fmt.Sprintf("%-44s %5s %15s %15s", description, quantity, unit, amount)
+	}
+	customer := "guest " + invoice.Customer.ID
+	if invoice.Customer.Name != "" {
+		customer = fmt.Sprintf("%s <%s>", invoice.Customer.Name, invoice.Customer.Email)
+	}
+	lines := []string{
+		"Issued on " + invoice.IssuedAt.Format(time.DateOnly),
+		"",
+		invoice.Seller.Name,
+		invoice.Seller.Address,
+		"",
+		"Billed to: " + customer,
+		fmt.Sprintf("Stay from %s to %s, booking %s",
+			invoice.FromDate.Format(time.DateOnly), invoice.ToDate.Format(time.DateOnly), invoice.BookingID),
+		"",
+		row("Description", "Qty", "Unit price", "Amount"),
+		strings.Repeat("-", pdfColumns),
+	}
+	for _, line := range invoice.Lines {
+		lines = append(lines, row(line.Description, fmt.Sprint(line.Quantity), line.UnitPrice.String(), line.Amount.String()))
+	}
+	lines = append(lines,
+		strings.Repeat("-", pdfColumns),
+		row("Subtotal", "", "", invoice.Subtotal.String()),
+	)
+	for _, tax := range invoice.TaxLines {
+		if tax.PerNight != nil {
+			lines = append(lines, row(tax.Name, fmt.Sprint(tax.Guests*tax.Nights), tax.PerNight.String(), tax.Amount.String()))
+			continue
+		}
+		lines = append(lines, row(tax.Name, "", "", tax.Amount.String()))
+	}
+	if len(invoice.TaxLines) == 0 {
+		lines = append(lines, row(fmt.Sprintf("Taxes (%g%%)", invoice.TaxRate), "", "", invoice.Taxes.String()))
+	}
+	return append(lines, row("Total", "", "", invoice.Total.String()))
+}
+
+// writePDF writes a minimal PDF with a page for each group of lines, under
+// title on every page.
+func writePDF(w io.Writer, title string, pages [][]string) error {
+	var (
+		buf     bytes.Buffer
+		offsets []int
+	)
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	// objects 1 and 2 are the catalog and the page tree, 3 and 4 the fonts,
+	// then every page is followed by its content stream
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	buf.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	for i, lines := range pages {
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT /F1 %d Tf %d %d Td (%s) Tj ET\n",
+			pdfTitleSize, pdfMargin, pdfPageHeight-pdfMargin, pdfString(title))
+		fmt.Fprintf(&content, "BT /F2 %d Tf %d TL %d %d Td\n",
+			pdfFontSize, pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin-2*pdfLineHeight)
+		for _, line := range lines {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfString(line))
+		}
+		content.WriteString("ET\n")
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Contents %d 0 R /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// pdfString escapes s for a PDF literal string in WinAnsiEncoding, which
+// shares its printable characters with Latin-1. Other characters are
+// replaced with '?'.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
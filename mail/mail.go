@@ -0,0 +1,16 @@
+// Package mail sends emails to users through a pluggable Sender.
+package mail
+
+import "context"
+
+// Message is an email to a single recipient.
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Sender delivers messages, a real mail server or a local outbox.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
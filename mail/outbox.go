@@ -0,0 +1,69 @@
+package mail
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+const OutboxSenderName = "outbox"
+
+var _ Sender = (*Outbox)(nil)
+
+// OutboxMessage is a message kept by the outbox and when it was sent.
+type OutboxMessage struct {
+	Message
+	SentAt time.Time `json:"sentAt"`
+}
+
+// Outbox is a Sender for development and tests that delivers nothing. It
+// keeps the messages in memory and, when it has a path, appends them to a
+// file as JSON lines, so they can be read there.
+type Outbox struct {
+	mu       sync.Mutex
+	path     string
+	messages []OutboxMessage
+}
+
+// NewOutbox returns an outbox appending to the file at path. An empty path
+// keeps the messages only in memory.
+func NewOutbox(path string) *Outbox {
+	return &Outbox{path: path}
+}
+
+func (o *Outbox) Send(ctx context.Context, msg Message) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	sent := OutboxMessage{Message: msg, SentAt: time.Now()}
+	if o.path != "" {
+		data, err := json.Marshal(sent)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(o.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := f.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	o.messages = append(o.messages, sent)
+	return nil
+}
+
+// Messages returns the messages sent to the address to, oldest first.
+func (o *Outbox) Messages(to string) []OutboxMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	messages := []OutboxMessage{}
+	for _, msg := range o.messages {
+		if msg.To == to {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,157 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	FakeProviderName = "fake"
+	// FakeDeclinedMethod is a payment method the fake provider always
+	// declines, every other method is accepted.
+	FakeDeclinedMethod = "fake-declined"
+)
+
+var _ PaymentProvider = (*FakeProvider)(nil)
+
+// fakeCharge is an authorization held by the fake provider.
+type fakeCharge struct {
+	BookingID string      `json:"bookingID"`
+	Amount    types.Money `json:"amount"`
+	Captured  int64       `json:"captured"`
+	Refunded  int64       `json:"refunded"`
+	Voided    bool        `json:"voided"`
+	// Keys are the keys of the operations carried out on the charge.
+	Keys []string `json:"keys,omitempty"`
+}
+
+// done reports whether the operation of key was carried out on the charge.
+func (c *fakeCharge) done(key string) bool {
+	return slices.Contains(c.Keys, key)
+}
+
+// FakeProvider is a PaymentProvider for development and tests that moves no
+// money. It keeps its charges in memory and, when it has a path, in a JSON
+// file so they survive restarts.
+type FakeProvider struct {
+	mu      sync.Mutex
+	path    string
+	charges map[string]*fakeCharge
+}
+
+// NewFakeProvider loads the charges kept in the file at path, if any. An
+// empty path keeps them only in memory.
+func NewFakeProvider(path string) (*FakeProvider, error) {
+	p := &FakeProvider{
+		path:    path,
+		charges: map[string]*fakeCharge{},
+	}
+	if path == "" {
+		return p, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &p.charges); err != nil {
+		return nil, fmt.Errorf("invalid fake payments file %s: %w", path, err)
+	}
+	return p, nil
+}
+
+func (p *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (p *FakeProvider) Authorize(ctx context.Context, bookingID, method string, amount types.Money) (string, error) {
+	if method == FakeDeclinedMethod {
+		return "", ErrDeclined
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reference := "fake_" + primitive.NewObjectID().Hex()
+	p.charges[reference] = &fakeCharge{BookingID: bookingID, Amount: amount}
+	return reference, p.save()
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, reference string, amount types.Money, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	charge, err := p.charge(reference, amount.Currency)
+	if err != nil || charge.done(key) {
+		return err
+	}
+	if charge.Voided || charge.Captured > 0 || amount.Amount > charge.Amount.Amount {
+		return fmt.Errorf("%w: can not capture %s of charge %s", ErrRejected, amount, reference)
+	}
+	charge.Captured = amount.Amount
+	charge.Keys = append(charge.Keys, key)
+	return p.save()
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, reference string, amount types.Money, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	charge, err := p.charge(reference, amount.Currency)
+	if err != nil || charge.done(key) {
+		return err
+	}
+	if charge.Refunded+amount.Amount > charge.Captured {
+		return fmt.Errorf("%w: can not refund %s of charge %s", ErrRejected, amount, reference)
+	}
+	charge.Refunded += amount.Amount
+	charge.Keys = append(charge.Keys, key)
+	return p.save()
+}
+
+func (p *FakeProvider) Void(ctx context.Context, reference, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	charge, err := p.charge(reference, "")
+	if err != nil || charge.done(key) {
+		return err
+	}
+	if charge.Captured > 0 {
+		return fmt.Errorf("%w: can not void captured charge %s", ErrRejected, reference)
+	}
+	charge.Voided = true
+	charge.Keys = append(charge.Keys, key)
+	return p.save()
+}
+
+// charge returns the charge of reference, checking currency unless empty.
+// The caller must hold p.mu.
+func (p *FakeProvider) charge(reference, currency string) (*fakeCharge, error) {
+	charge, ok := p.charges[reference]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown charge %s", ErrRejected, reference)
+	}
+	if currency != "" && currency != charge.Amount.Currency {
+		return nil, fmt.Errorf("%w: charge %s is in %s", ErrRejected, reference, charge.Amount.Currency)
+	}
+	return charge, nil
+}
+
+// save writes the charges to the file of the provider, if any. The caller
+// must hold p.mu.
+func (p *FakeProvider) save() error {
+	if p.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(p.charges, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.path, data, 0o600)
+}
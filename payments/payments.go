@@ -0,0 +1,231 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Service charges bookings through a provider, recording each payment and
+// every change to it in the payment store, and posting the money taken and
+// refunded to the folio of the booking.
+type Service struct {
+	provider     PaymentProvider
+	paymentStore db.PaymentStore
+	folioStore   db.FolioStore
+}
+
+func NewService(provider PaymentProvider, ps db.PaymentStore, fs db.FolioStore) *Service {
+	return &Service{
+		provider:     provider,
+		paymentStore: ps,
+		folioStore:   fs,
+	}
+}
+
+// Authorize holds the total of booking on method. A declined authorization
+// is recorded as a failed payment and returned with a payment declined
+// error, and an authorization that can not be recorded is voided.
+func (s *Service) Authorize(ctx context.Context, booking *types.Booking, method string) (*types.Payment, error) {
+	amount := booking.Price.Total
+	reference, err := s.provider.Authorize(ctx, booking.ID, method, amount)
+	if errors.Is(err, ErrDeclined) {
+		payment := types.NewFailedPayment(booking, s.provider.Name(), amount, err.Error())
+		if _, insErr := s.paymentStore.InsertPayment(ctx, payment); insErr != nil {
+			return nil, insErr
+		}
+		return payment, types.ErrPaymentDeclined(err)
+	}
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	payment, err := s.paymentStore.InsertPayment(ctx, types.NewPayment(booking, s.provider.Name(), reference, amount))
+	if err != nil {
+		if voidErr := s.provider.Void(ctx, reference, primitive.NewObjectID().Hex()); voidErr != nil {
+			return nil, types.ErrInternal(errors.Join(err, voidErr))
+		}
+		return nil, err
+	}
+	return payment, nil
+}
+
+// Capture takes amount of an authorized payment.
+func (s *Service) Capture(ctx context.Context, payment *types.Payment, amount types.Money) (*types.Payment, error) {
+	return s.apply(ctx, payment, types.PaymentOperation{Type: types.PaymentCapture, Amount: amount})
+}
+
+// Refund gives back amount of a captured payment.
+func (s *Service) Refund(ctx context.Context, payment *types.Payment, amount types.Money) (*types.Payment, error) {
+	return s.apply(ctx, payment, types.PaymentOperation{Type: types.PaymentRefund, Amount: amount})
+}
+
+// Void releases an authorized payment.
+func (s *Service) Void(ctx context.Context, payment *types.Payment) (*types.Payment, error) {
+	return s.apply(ctx, payment, types.PaymentOperation{Type: types.PaymentVoid})
+}
+
+// apply carries out op on payment. The operation is recorded as pending on
+// the payment first, so only one of concurrent operations reaches the
+// provider, then it is sent to the provider and its outcome recorded. An
+// operation left pending on payment is completed before op, and op is not
+// repeated when it is that same operation retried.
+func (s *Service) apply(ctx context.Context, payment *types.Payment, op types.PaymentOperation) (*types.Payment, error) {
+	if pending := payment.Pending; pending != nil {
+		completed, err := s.complete(ctx, payment)
+		if err != nil {
+			return nil, err
+		}
+		if pending.Type == op.Type && pending.Amount == op.Amount {
+			return completed, nil
+		}
+		payment = completed
+	}
+	now := time.Now()
+	check := *payment
+	if err := check.Apply(op, now); err != nil {
+		return nil, err
+	}
+	op.Key = primitive.NewObjectID().Hex()
+	op.At = now
+	pending := *payment
+	pending.Pending = &op
+	pending.UpdatedAt = now
+	if _, err := s.paymentStore.UpdatePayment(ctx, payment, &pending); err != nil {
+		return nil, err
+	}
+	return s.complete(ctx, &pending)
+}
+
+// complete sends the pending operation of payment to the provider and
+// records its outcome, posting the money moved to the folio. An operation
+// the provider rejects is dropped, one failing otherwise stays pending so
+// the next operation on payment sends it again with the same key.
+func (s *Service) complete(ctx context.Context, payment *types.Payment) (*types.Payment, error) {
+	op := *payment.Pending
+	var err error
+	switch op.Type {
+	case types.PaymentCapture:
+		err = s.provider.Capture(ctx, payment.Reference, op.Amount, op.Key)
+	case types.PaymentRefund:
+		err = s.provider.Refund(ctx, payment.Reference, op.Amount, op.Key)
+	case types.PaymentVoid:
+		err = s.provider.Void(ctx, payment.Reference, op.Key)
+	}
+	if errors.Is(err, ErrRejected) {
+		dropped := *payment
+		dropped.Pending = nil
+		if _, dropErr := s.paymentStore.UpdatePayment(ctx, payment, &dropped); dropErr != nil {
+			return nil, types.ErrInternal(errors.Join(err, dropErr))
+		}
+		return nil, types.ErrInternal(err)
+	}
+	if err != nil {
+		return nil, types.ErrInternal(err)
+	}
+	updated := *payment
+	updated.Pending = nil
+	if err := updated.Apply(op, time.Now()); err != nil {
+		return nil, err
+	}
+	if _, err := s.paymentStore.UpdatePayment(ctx, payment, &updated); err != nil {
+		return nil, err
+	}
+	switch op.Type {
+	case types.PaymentCapture:
+		err = s.post(ctx, &updated, types.FolioPayment, "Payment", op.Amount)
+	case types.PaymentRefund:
+		err = s.post(ctx, &updated, types.FolioRefund, "Refund", op.Amount)
+	}
+	return &updated, err
+}
+
+// post adds amount, taken or refunded on payment, to the folio of its
+// booking.
+func (s *Service) post(ctx context.Context, payment *types.Payment, itemType types.FolioItemType, action string, amount types.Money) error {
+	description := fmt.Sprintf("%s via %s", action, payment.Provider)
+	item := types.NewFolioItem(payment.BookingID, itemType, description, amount, "")
+	item.PaymentID = payment.ID
+	_, err := s.folioStore.InsertFolioItems(ctx, item)
+	return err
+}
+
+// Reauthorize holds the total of modified, booking changed to another stay,
+// on method when the change moves its total. It returns the authorization
+// taken and the ones of booking it replaces, which are to be voided once
+// modified is stored, none when the authorizations need no change. The total
+// of a booking already charged can not change.
+func (s *Service) Reauthorize(ctx context.Context, booking, modified *types.Booking, method string) (*types.Payment, []*types.Payment, error) {
+	if modified.Price.Total == booking.Price.Total {
+		return nil, nil, nil
+	}
+	payments, err := s.paymentStore.GetPaymentsByBooking(ctx, booking.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	replaced := []*types.Payment{}
+	for _, payment := range payments {
+		switch payment.Status {
+		case types.PaymentAuthorized:
+			replaced = append(replaced, payment)
+		case types.PaymentCaptured:
+			return nil, nil, types.ErrBookingNotModifiable(fmt.Errorf("booking %s is charged, its total can not change", booking.ID))
+		}
+	}
+	if len(replaced) == 0 {
+		return nil, nil, nil
+	}
+	if len(method) == 0 {
+		return nil, nil, types.ErrInvalidParams(fmt.Errorf("paymentMethod is required to change the total of booking %s", booking.ID))
+	}
+	payment, err := s.Authorize(ctx, modified, method)
+	if err != nil {
+		return nil, nil, err
+	}
+	return payment, replaced, nil
+}
+
+// SettleCancellation charges the fee of a cancelled booking and releases
+// the rest: authorizations capture the fee, or are voided when there is
+// none, and captured payments refund what exceeds it.
+func (s *Service) SettleCancellation(ctx context.Context, bookingID string, cancellation types.Cancellation) error {
+	payments, err := s.paymentStore.GetPaymentsByBooking(ctx, bookingID)
+	if err != nil {
+		return err
+	}
+	fee := cancellation.Fee
+	for _, payment := range payments {
+		switch payment.Status {
+		case types.PaymentAuthorized:
+			capture := payment.Capturable()
+			if fee.Amount < capture.Amount {
+				capture.Amount = fee.Amount
+			}
+			if capture.Amount == 0 {
+				_, err = s.Void(ctx, payment)
+			} else {
+				_, err = s.Capture(ctx, payment, capture)
+			}
+			fee.Amount -= capture.Amount
+		case types.PaymentCaptured:
+			kept := payment.Refundable()
+			if fee.Amount < kept.Amount {
+				kept.Amount = fee.Amount
+			}
+			refund := payment.Refundable()
+			refund.Amount -= kept.Amount
+			if refund.Amount > 0 {
+				_, err = s.Refund(ctx, payment, refund)
+			}
+			fee.Amount -= kept.Amount
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
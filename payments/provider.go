@@ -0,0 +1,36 @@
+// Package payments charges bookings through a payment provider and keeps a
+// record of every payment in a db.PaymentStore.
+package payments
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+// ErrDeclined is returned by providers refusing to authorize a payment.
+var ErrDeclined = errors.New("payment declined")
+
+// ErrRejected is returned, wrapped, by providers refusing to carry out an
+// operation on an authorization. Nothing was done and sending it again
+// changes nothing, while after any other error the operation may or may not
+// have been carried out.
+var ErrRejected = errors.New("operation rejected")
+
+// PaymentProvider moves money at an external payment processor. Amounts
+// are authorized first and later captured, or voided if never captured,
+// and captured amounts may be refunded.
+type PaymentProvider interface {
+	// Name identifies the provider on payment records.
+	Name() string
+	// Authorize holds amount on the payment method of a booking and returns
+	// the provider reference of the authorization.
+	Authorize(ctx context.Context, bookingID, method string, amount types.Money) (string, error)
+	// Capture, Refund and Void carry an operation out on the authorization
+	// of reference once per key: sent again with the same key they succeed
+	// without repeating it. A refusal is reported with ErrRejected.
+	Capture(ctx context.Context, reference string, amount types.Money, key string) error
+	Refund(ctx context.Context, reference string, amount types.Money, key string) error
+	Void(ctx context.Context, reference, key string) error
+}
@@ -0,0 +1,110 @@
+// Package pricing prices the nights of a stay from the room price and the
+// rate plans of its hotel.
+package pricing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type Engine struct {
+	ratePlanStore db.RatePlanStore
+}
+
+func NewEngine(rps db.RatePlanStore) *Engine {
+	return &Engine{
+		ratePlanStore: rps,
+	}
+}
+
+// Price prices every night of a stay of guests in room. Each night uses the
+// rate plan that applies to it with the highest priority, preferring plans
+// of the room over plans of the whole hotel, and falls back to the room
+// price when no plan applies. Guests beyond the ones the room includes add
+// their extra price to every night, see types.Room.ExtraGuests. The taxes
+// of the hotel are added on top.
+func (e *Engine) Price(ctx context.Context, hotel *types.Hotel, room *types.Room, nights []string, guests types.Guests) (types.PriceBreakdown, error) {
+	plans, err := e.ratePlanStore.GetRatePlansByHotel(ctx, hotel.ID)
+	if err != nil {
+		return types.PriceBreakdown{}, err
+	}
+	var (
+		currency  = hotel.PriceCurrency()
+		roomRate  = room.Price.OrCurrency(currency)
+		extra     = room.ExtraGuests(guests, currency)
+		prices    = make([]types.NightPrice, len(nights))
+		discounts = []types.AppliedDiscount{}
+		applied   = map[string]int{}
+	)
+	if roomRate.Currency != currency {
+		return types.PriceBreakdown{}, types.ErrInternal(fmt.Errorf("room %s is priced in %s but hotel %s in %s",
+			room.ID, roomRate.Currency, hotel.ID, currency))
+	}
+	if extra.Amount > 0 && (room.ExtraAdult.OrCurrency(currency).Currency != currency ||
+		room.ExtraChild.OrCurrency(currency).Currency != currency) {
+		return types.PriceBreakdown{}, types.ErrInternal(fmt.Errorf("extra guests of room %s are not priced in %s of hotel %s",
+			room.ID, currency, hotel.ID))
+	}
+	for i, night := range nights {
+		plan := selectPlan(plans, room.ID, night)
+		if plan == nil {
+			rate, err := roomRate.Add(extra)
+			if err != nil {
+				return types.PriceBreakdown{}, types.ErrInternal(err)
+			}
+			prices[i] = types.NewNightPrice(night, rate, 0, "")
+			continue
+		}
+		rate := roomRate
+		if plan.BaseRate.Amount > 0 {
+			rate = plan.BaseRate.OrCurrency(currency)
+		}
+		if rate.Currency != currency {
+			return types.PriceBreakdown{}, types.ErrInternal(fmt.Errorf("rate plan %s is priced in %s but hotel %s in %s",
+				plan.ID, rate.Currency, hotel.ID, currency))
+		}
+		if rate, err = rate.Mul(plan.Multiplier(night)).Add(extra); err != nil {
+			return types.PriceBreakdown{}, types.ErrInternal(err)
+		}
+		discount := plan.StayDiscount(len(nights))
+		discountPercent := 0.0
+		if discount != nil {
+			discountPercent = discount.Percent
+		}
+		prices[i] = types.NewNightPrice(night, rate, discountPercent, plan.ID)
+		prices[i].NonRefundable = plan.NonRefundable
+		if discount == nil {
+			continue
+		}
+		name := fmt.Sprintf("%s: %g%% off stays of %d+ nights", plan.Name, discount.Percent, discount.MinNights)
+		j, ok := applied[name]
+		if !ok {
+			j = len(discounts)
+			applied[name] = j
+			discounts = append(discounts, types.AppliedDiscount{Name: name, Amount: types.NewMoney(0, currency)})
+		}
+		discounts[j].Amount.Amount += prices[i].Discount.Amount
+	}
+	price, err := types.NewPriceBreakdown(prices, discounts, hotel, guests)
+	if err != nil {
+		return types.PriceBreakdown{}, types.ErrInternal(err)
+	}
+	return price, nil
+}
+
+func selectPlan(plans []*types.RatePlan, roomID, night string) *types.RatePlan {
+	var best *types.RatePlan
+	for _, plan := range plans {
+		if !plan.AppliesTo(roomID, night) {
+			continue
+		}
+		if best == nil || plan.Priority > best.Priority ||
+			(plan.Priority == best.Priority && best.RoomID == "" && plan.RoomID != "") {
+			best = plan
+		}
+	}
+	return best
+}
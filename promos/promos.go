@@ -0,0 +1,102 @@
+// Package promos discounts stays with promo codes and counts the bookings
+// that redeem them against the limits of each code.
+package promos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jucaza1/hotel-reserv/db"
+	"github.com/jucaza1/hotel-reserv/types"
+)
+
+type Service struct {
+	promoStore db.PromoStore
+}
+
+func NewService(ps db.PromoStore) *Service {
+	return &Service{
+		promoStore: ps,
+	}
+}
+
+// Apply discounts price, of a stay of guests at hotel, with code when
+// userID can still redeem it. The code is only redeemed by Redeem, once
+// the stay is booked.
+func (s *Service) Apply(ctx context.Context, code, userID string, hotel *types.Hotel, guests types.Guests, price types.PriceBreakdown) (types.PriceBreakdown, error) {
+	promo, err := s.promo(ctx, code)
+	if err != nil {
+		return types.PriceBreakdown{}, err
+	}
+	if err := promo.AppliesTo(hotel.ID, time.Now()); err != nil {
+		return types.PriceBreakdown{}, types.ErrPromoNotApplicable(err)
+	}
+	if !promo.Available(userID) {
+		return types.PriceBreakdown{}, types.ErrPromoNotApplicable(fmt.Errorf("promo code %s reached its redemption limit", promo.Code))
+	}
+	return s.apply(promo, hotel, guests, price)
+}
+
+// Reapply discounts price with the code a booking already redeemed, when
+// the booking moves to another stay. Only the hotel restriction of the
+// code is checked again.
+func (s *Service) Reapply(ctx context.Context, code string, hotel *types.Hotel, guests types.Guests, price types.PriceBreakdown) (types.PriceBreakdown, error) {
+	promo, err := s.promo(ctx, code)
+	if err != nil {
+		return types.PriceBreakdown{}, err
+	}
+	if err := promo.AppliesToHotel(hotel.ID); err != nil {
+		return types.PriceBreakdown{}, types.ErrPromoNotApplicable(err)
+	}
+	return s.apply(promo, hotel, guests, price)
+}
+
+func (s *Service) apply(promo *types.Promo, hotel *types.Hotel, guests types.Guests, price types.PriceBreakdown) (types.PriceBreakdown, error) {
+	discounted, err := promo.Apply(price, hotel, guests)
+	if err != nil {
+		return types.PriceBreakdown{}, types.ErrPromoNotApplicable(err)
+	}
+	return discounted, nil
+}
+
+// Redeem counts the promo code booking was priced with, if any, failing
+// when the code is no longer valid or its limits were reached meanwhile.
+func (s *Service) Redeem(ctx context.Context, booking *types.Booking) error {
+	if booking.Price.PromoCode == "" {
+		return nil
+	}
+	promo, err := s.promo(ctx, booking.Price.PromoCode)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := promo.AppliesTo(booking.HotelID, now); err != nil {
+		return types.ErrPromoNotApplicable(err)
+	}
+	_, err = s.promoStore.RedeemPromo(ctx, &types.PromoRedemption{
+		PromoID:    promo.ID,
+		Code:       promo.Code,
+		UserID:     booking.UserID,
+		BookingID:  booking.ID,
+		Discount:   booking.Price.PromoDiscount(),
+		RedeemedAt: now,
+	})
+	return err
+}
+
+// Release gives back the redemption of a booking that was not made.
+func (s *Service) Release(ctx context.Context, bookingID string) error {
+	return s.promoStore.ReleasePromoRedemption(ctx, bookingID)
+}
+
+func (s *Service) promo(ctx context.Context, code string) (*types.Promo, error) {
+	promo, err := s.promoStore.GetPromoByCode(ctx, code)
+	var e types.ErrorSt
+	if errors.As(err, &e) && e.Status == http.StatusNotFound {
+		return nil, types.ErrPromoNotApplicable(fmt.Errorf("unknown promo code %s", types.NormalizePromoCode(code)))
+	}
+	return promo, err
+}
@@ -0,0 +1,91 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// AvailabilityQuery holds the raw query string of an availability search.
+type AvailabilityQuery struct {
+	From     string  `query:"from"`
+	To       string  `query:"to"`
+	Size     string  `query:"size"`
+	MaxPrice float64 `query:"maxPrice"`
+	Currency string  `query:"currency"`
+	Location string  `query:"location"`
+}
+
+// AvailabilityParams describes a search for rooms free for a whole stay,
+// from the check-in day FromDate to the check-out day ToDate.
+// Zero values of Size, MaxPrice and Location disable that filter. MaxPrice
+// always has a currency and only rooms priced in it match, as amounts in
+// other currencies can not be compared.
+type AvailabilityParams struct {
+	FromDate time.Time
+	ToDate   time.Time
+	Size     RoomSize
+	MaxPrice Money
+	Location string
+}
+
+type HotelAvailability struct {
+	Hotel *Hotel  `bson:"hotel" json:"hotel"`
+	Rooms []*Room `bson:"rooms" json:"rooms"`
+}
+
+func NewAvailabilityParams(q AvailabilityQuery) (AvailabilityParams, error) {
+	var (
+		params AvailabilityParams
+		err    error
+	)
+	if params.FromDate, err = parseQueryDate(q.From); err != nil {
+		return params, fmt.Errorf("invalid from date: %w", err)
+	}
+	if params.ToDate, err = parseQueryDate(q.To); err != nil {
+		return params, fmt.Errorf("invalid to date: %w", err)
+	}
+	if q.Size != "" {
+		if params.Size, err = ParseRoomSize(q.Size); err != nil {
+			return params, err
+		}
+	}
+	if q.MaxPrice < 0 {
+		return params, fmt.Errorf("maxPrice should not be negative")
+	}
+	if q.MaxPrice > 0 && q.Currency == "" {
+		return params, fmt.Errorf("currency is required with maxPrice")
+	}
+	if q.Currency != "" && !isCurrencyValid(q.Currency) {
+		return params, fmt.Errorf("currency %s should be an ISO 4217 code", q.Currency)
+	}
+	params.MaxPrice = MoneyFromFloat(q.MaxPrice, q.Currency)
+	params.Location = q.Location
+	return params, params.Validate()
+}
+
+func (p AvailabilityParams) Validate() error {
+	if err := validateStay(p.FromDate, p.ToDate); err != nil {
+		return err
+	}
+	// hotels may be a day behind UTC, so yesterday can still be today there
+	if calendarDate(p.FromDate).Before(calendarDate(time.Now().UTC()).AddDate(0, 0, -1)) {
+		return fmt.Errorf("invalid date")
+	}
+	return nil
+}
+
+// Nights returns the nights of the searched stay.
+func (p AvailabilityParams) Nights() []string {
+	return StayNights(p.FromDate, p.ToDate)
+}
+
+// parseQueryDate accepts either a plain date or a RFC 3339 timestamp.
+func parseQueryDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("missing date")
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
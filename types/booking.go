@@ -5,39 +5,187 @@ import (
 	"time"
 )
 
+// Booking is a stay of one or more nights. FromDate is the check-in and
+// ToDate the check-out instant, both at the hotel's own times and time zone,
+// and Nights lists the booked nights [check-in, check-out) as YYYY-MM-DD.
 type Booking struct {
-	ID          string    `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID      string    `bson:"userID,omitempty" json:"userID,omitempty"`
-	HotelID     string    `bson:"hotelID,omitempty" json:"hotelID,omitempty"`
-	RoomID      string    `bson:"roomID,omitempty" json:"roomID,omitempty"`
-	FromDate    time.Time `bson:"fromDate,omitempty" json:"fromDate,omitempty"`
-	ToDate      time.Time `bson:"toDate,omitempty" json:"toDate,omitempty"`
-	CreatedDate time.Time `bson:"createDate,omitempty" json:"CreatedDate,omitempty"`
-	CancelledAt time.Time `bson:"cancelledAt" json:"cancelledAt"`
-	Cancelled   bool      `bson:"cancelled" json:"cancelled"`
+	ID      string `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID  string `bson:"userID,omitempty" json:"userID,omitempty"`
+	HotelID string `bson:"hotelID,omitempty" json:"hotelID,omitempty"`
+	RoomID  string `bson:"roomID,omitempty" json:"roomID,omitempty"`
+	// GroupID is shared by the bookings of a group booking, see
+	// BookingGroup.
+	GroupID     string         `bson:"groupID,omitempty" json:"groupID,omitempty"`
+	FromDate    time.Time      `bson:"fromDate,omitempty" json:"fromDate,omitempty"`
+	ToDate      time.Time      `bson:"toDate,omitempty" json:"toDate,omitempty"`
+	Nights      []string       `bson:"nights" json:"nights"`
+	Guests      Guests         `bson:"guests" json:"guests"`
+	Price       PriceBreakdown `bson:"price" json:"price"`
+	CreatedDate time.Time      `bson:"createDate,omitempty" json:"CreatedDate,omitempty"`
+	// Status only changes through Transition, StatusHistory records when
+	// each status was entered.
+	Status        BookingStatus  `bson:"status" json:"status"`
+	StatusHistory []StatusChange `bson:"statusHistory" json:"statusHistory"`
+	// Modifications keeps the stays the booking had before each change.
+	Modifications []BookingModification `bson:"modifications,omitempty" json:"modifications,omitempty"`
+	CancelledAt   time.Time             `bson:"cancelledAt" json:"cancelledAt"`
+	Cancelled     bool                  `bson:"cancelled" json:"cancelled"`
+	Cancellation  *Cancellation         `bson:"cancellation,omitempty" json:"cancellation,omitempty"`
+	// PaymentStatus is the settlement last reported by the payment
+	// processor, at PaymentUpdatedAt, see PaymentEvent.
+	PaymentStatus    BookingPaymentStatus `bson:"paymentStatus,omitempty" json:"paymentStatus,omitempty"`
+	PaymentUpdatedAt time.Time            `bson:"paymentUpdatedAt,omitempty" json:"paymentUpdatedAt,omitempty"`
 }
+
+// CreateBookingParams carries the check-in and check-out days. Only the
+// calendar date written by the client is used, the time of day and offset
+// are replaced by the hotel's check-in and check-out times. Without Guests
+// a single adult stays.
+// QuoteToken is optional and, when it comes from a quote of the same stay,
+// books the stay at the quoted price. PromoCode is optional and discounts
+// the stay, see Promo. PaymentMethod is only required to book, see
+// PaymentParams.
 type CreateBookingParams struct {
-	FromDate time.Time `json:"fromDate,omitempty"`
-	ToDate   time.Time `json:"toDate,omitempty"`
+	FromDate      time.Time `json:"fromDate,omitempty"`
+	ToDate        time.Time `json:"toDate,omitempty"`
+	Guests        Guests    `json:"guests,omitempty"`
+	QuoteToken    string    `json:"quoteToken,omitempty"`
+	PromoCode     string    `json:"promoCode,omitempty"`
+	PaymentMethod string    `json:"paymentMethod,omitempty"`
 }
 
 func (p CreateBookingParams) Validate() error {
-	if p.FromDate.After(p.ToDate) && time.Now().After(p.FromDate) {
-		return fmt.Errorf("invalid date")
+	if err := validateStay(p.FromDate, p.ToDate); err != nil {
+		return err
 	}
-	return nil
+	return p.Guests.Validate()
+}
+
+// ModifyBookingParams moves a booking to new check-in and check-out days
+// and, when RoomID is set, to another room. PaymentMethod is only required
+// when the new stay changes the total of an authorized booking, which is
+// authorized again on it.
+type ModifyBookingParams struct {
+	RoomID        string    `json:"roomID,omitempty"`
+	FromDate      time.Time `json:"fromDate,omitempty"`
+	ToDate        time.Time `json:"toDate,omitempty"`
+	PaymentMethod string    `json:"paymentMethod,omitempty"`
+}
+
+func (p ModifyBookingParams) Validate() error {
+	return CreateBookingParams{FromDate: p.FromDate, ToDate: p.ToDate}.Validate()
+}
+
+// BookingModification records the stay a booking had before a change, who
+// changed it and when.
+type BookingModification struct {
+	At       time.Time      `bson:"at" json:"at"`
+	UserID   string         `bson:"userID" json:"userID"`
+	HotelID  string         `bson:"hotelID" json:"hotelID"`
+	RoomID   string         `bson:"roomID" json:"roomID"`
+	FromDate time.Time      `bson:"fromDate" json:"fromDate"`
+	ToDate   time.Time      `bson:"toDate" json:"toDate"`
+	Nights   []string       `bson:"nights" json:"nights"`
+	Price    PriceBreakdown `bson:"price" json:"price"`
 }
-func NewBookingFromParams(params CreateBookingParams, userID string, hotelID string, roomID string) (*Booking, error) {
 
+func NewBookingFromParams(params CreateBookingParams, userID string, hotel *Hotel, roomID string) (*Booking, error) {
+	checkIn, checkOut, err := hotel.StayTimes(params.FromDate, params.ToDate)
+	if err != nil {
+		return nil, err
+	}
+	if checkIn.Format(time.DateOnly) < hotel.Today() {
+		return nil, fmt.Errorf("invalid date")
+	}
+	now := time.Now()
 	return &Booking{
-		UserID:      userID,
-		RoomID:      roomID,
-		HotelID:     hotelID,
-		FromDate:    truncateToDay(params.FromDate),
-		ToDate:      truncateToDay(params.ToDate),
-		CreatedDate: time.Now(),
+		UserID:        userID,
+		RoomID:        roomID,
+		HotelID:       hotel.ID,
+		FromDate:      checkIn,
+		ToDate:        checkOut,
+		Nights:        StayNights(params.FromDate, params.ToDate),
+		Guests:        params.Guests.OrDefault(),
+		CreatedDate:   now,
+		Status:        StatusConfirmed,
+		StatusHistory: []StatusChange{{Status: StatusConfirmed, At: now}},
 	}, nil
 }
-func truncateToDay(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+
+// Modify moves the booking to the room, dates and price of stay, keeping
+// the previous stay in Modifications. Only bookings not yet started can be
+// modified.
+func (b *Booking) Modify(stay *Booking, userID string, now time.Time) error {
+	if status := b.CurrentStatus(); status != StatusPending && status != StatusConfirmed {
+		return ErrBookingNotModifiable(fmt.Errorf("can not modify a %s booking", status))
+	}
+	if !now.Before(b.FromDate) {
+		return ErrBookingNotModifiable(fmt.Errorf("can not modify a booking after its check-in time"))
+	}
+	b.Modifications = append(b.Modifications, BookingModification{
+		At:       now,
+		UserID:   userID,
+		HotelID:  b.HotelID,
+		RoomID:   b.RoomID,
+		FromDate: b.FromDate,
+		ToDate:   b.ToDate,
+		Nights:   b.Nights,
+		Price:    b.Price,
+	})
+	b.HotelID = stay.HotelID
+	b.RoomID = stay.RoomID
+	b.FromDate = stay.FromDate
+	b.ToDate = stay.ToDate
+	b.Nights = stay.Nights
+	b.Price = stay.Price
+	return nil
+}
+
+// NightsNotIn returns the nights of b that other does not hold.
+func (b *Booking) NightsNotIn(other *Booking) []string {
+	if other.RoomID != b.RoomID {
+		return b.Nights
+	}
+	held := map[string]bool{}
+	for _, night := range other.Nights {
+		held[night] = true
+	}
+	nights := []string{}
+	for _, night := range b.Nights {
+		if !held[night] {
+			nights = append(nights, night)
+		}
+	}
+	return nights
+}
+
+// StayNights returns the nights of a stay from the check-in day to the day
+// before check-out, formatted as YYYY-MM-DD.
+func StayNights(checkIn, checkOut time.Time) []string {
+	nights := []string{}
+	last := calendarDate(checkOut)
+	for d := calendarDate(checkIn); d.Before(last); d = d.AddDate(0, 0, 1) {
+		nights = append(nights, d.Format(time.DateOnly))
+	}
+	return nights
+}
+
+// validateStay checks a stay from the check-in day from to the check-out
+// day to has between 1 and maxStayNights nights, before any night of it is
+// listed.
+func validateStay(from, to time.Time) error {
+	checkIn, checkOut := calendarDate(from), calendarDate(to)
+	if !checkOut.After(checkIn) {
+		return fmt.Errorf("invalid date")
+	}
+	if checkOut.After(checkIn.AddDate(0, 0, maxStayNights)) {
+		return fmt.Errorf("a stay should be at most %d nights", maxStayNights)
+	}
+	return nil
+}
+
+// calendarDate keeps the date of t as written in its own location, as a UTC
+// midnight that can be compared and iterated regardless of offsets.
+func calendarDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
 }
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+const maxGroupRooms = 20
+
+// CreateGroupBookingParams books every room of RoomIDs for the same stay
+// and guests, see CreateBookingParams. Groups are priced at the rates of
+// each room, quote tokens and promo codes only apply to single bookings.
+type CreateGroupBookingParams struct {
+	RoomIDs       []string  `json:"roomIDs"`
+	FromDate      time.Time `json:"fromDate,omitempty"`
+	ToDate        time.Time `json:"toDate,omitempty"`
+	Guests        Guests    `json:"guests,omitempty"`
+	PaymentMethod string    `json:"paymentMethod,omitempty"`
+}
+
+func (p CreateGroupBookingParams) Validate() error {
+	if len(p.RoomIDs) == 0 || len(p.RoomIDs) > maxGroupRooms {
+		return fmt.Errorf("a group should book 1 to %d rooms", maxGroupRooms)
+	}
+	seen := map[string]bool{}
+	for _, roomID := range p.RoomIDs {
+		if len(roomID) == 0 {
+			return fmt.Errorf("missing room id")
+		}
+		if seen[roomID] {
+			return fmt.Errorf("room %s is booked twice", roomID)
+		}
+		seen[roomID] = true
+	}
+	return p.BookingParams().Validate()
+}
+
+// BookingParams returns the params of the booking of each room.
+func (p CreateGroupBookingParams) BookingParams() CreateBookingParams {
+	return CreateBookingParams{
+		FromDate:      p.FromDate,
+		ToDate:        p.ToDate,
+		Guests:        p.Guests,
+		PaymentMethod: p.PaymentMethod,
+	}
+}
+
+// ModifyGroupBookingParams moves every booking of a group to new check-in
+// and check-out days, each in its own room. PaymentMethod is required as in
+// ModifyBookingParams.
+type ModifyGroupBookingParams struct {
+	FromDate      time.Time `json:"fromDate,omitempty"`
+	ToDate        time.Time `json:"toDate,omitempty"`
+	PaymentMethod string    `json:"paymentMethod,omitempty"`
+}
+
+func (p ModifyGroupBookingParams) Validate() error {
+	return ModifyBookingParams{FromDate: p.FromDate, ToDate: p.ToDate}.Validate()
+}
+
+// BookingGroup is the bookings made together by a group booking, which
+// share GroupID.
+type BookingGroup struct {
+	ID       string     `json:"id"`
+	UserID   string     `json:"userID"`
+	Bookings []*Booking `json:"bookings"`
+}
+
+// NewBookingGroup groups bookings, which must not be empty and share their
+// GroupID.
+func NewBookingGroup(bookings []*Booking) *BookingGroup {
+	return &BookingGroup{
+		ID:       bookings[0].GroupID,
+		UserID:   bookings[0].UserID,
+		Bookings: bookings,
+	}
+}
+
+// Active returns the bookings of the group that were not cancelled.
+func (g *BookingGroup) Active() []*Booking {
+	active := []*Booking{}
+	for _, booking := range g.Bookings {
+		if booking.CurrentStatus() != StatusCancelled {
+			active = append(active, booking)
+		}
+	}
+	return active
+}
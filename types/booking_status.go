@@ -0,0 +1,107 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+type BookingStatus string
+
+const (
+	StatusPending    BookingStatus = "pending"
+	StatusConfirmed  BookingStatus = "confirmed"
+	StatusCheckedIn  BookingStatus = "checked-in"
+	StatusCheckedOut BookingStatus = "checked-out"
+	StatusNoShow     BookingStatus = "no-show"
+	StatusCancelled  BookingStatus = "cancelled"
+)
+
+// earlyCheckIn is how long before the check-in time a guest may be checked in.
+const earlyCheckIn = time.Hour * 12
+
+// bookingTransitions lists the statuses a booking can move to from each
+// status. Statuses missing as a key are final.
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	StatusPending:   {StatusConfirmed, StatusCancelled},
+	StatusConfirmed: {StatusCheckedIn, StatusNoShow, StatusCancelled},
+	StatusCheckedIn: {StatusCheckedOut},
+}
+
+// StatusChange records when a booking entered a status.
+type StatusChange struct {
+	Status BookingStatus `bson:"status" json:"status"`
+	At     time.Time     `bson:"at" json:"at"`
+}
+
+// CurrentStatus returns the status of the booking, deriving it from the
+// cancelled flag for bookings stored before statuses existed.
+func (b *Booking) CurrentStatus() BookingStatus {
+	switch {
+	case b.Status != "":
+		return b.Status
+	case b.Cancelled:
+		return StatusCancelled
+	default:
+		return StatusConfirmed
+	}
+}
+
+// Transition moves the booking to status at now, enforcing the allowed
+// transitions and the times at which they may happen.
+func (b *Booking) Transition(status BookingStatus, now time.Time) error {
+	current := b.CurrentStatus()
+	allowed := false
+	for _, next := range bookingTransitions[current] {
+		if next == status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return ErrInvalidTransition(fmt.Errorf("can not move a %s booking to %s", current, status))
+	}
+	switch status {
+	case StatusCancelled:
+		//pending bookings were never confirmed, they can always be dropped
+		if current != StatusPending && !now.Before(b.FromDate) {
+			return ErrCancelPastBooking(fmt.Errorf("can not cancel booking in the past"))
+		}
+		b.Cancelled = true
+		b.CancelledAt = now
+	case StatusCheckedIn:
+		if now.Before(b.FromDate.Add(-earlyCheckIn)) {
+			return ErrInvalidTransition(fmt.Errorf("can not check in before the check-in day"))
+		}
+	case StatusNoShow:
+		if now.Before(b.FromDate) {
+			return ErrInvalidTransition(fmt.Errorf("can not mark a no-show before the check-in time"))
+		}
+	}
+	b.Status = status
+	b.StatusHistory = append(b.StatusHistory, StatusChange{Status: status, At: now})
+	return nil
+}
+
+// Revert undoes the last transition of the booking, which moved it to
+// status, returning it to the status it had before. Transitions releasing
+// the nights of the booking are not undone.
+func (b *Booking) Revert(status BookingStatus) error {
+	n := len(b.StatusHistory)
+	if b.CurrentStatus() != status || status.ReleasesNights() || n < 2 {
+		return ErrInvalidTransition(fmt.Errorf("can not revert a %s booking from %s", b.CurrentStatus(), status))
+	}
+	b.StatusHistory = b.StatusHistory[:n-1]
+	b.Status = b.StatusHistory[n-2].Status
+	return nil
+}
+
+// AwaitPayment makes a new booking pending until its payment is authorized.
+func (b *Booking) AwaitPayment() {
+	b.Status = StatusPending
+	b.StatusHistory = []StatusChange{{Status: StatusPending, At: b.CreatedDate}}
+}
+
+// ReleasesNights reports whether a booking in status no longer holds its
+// room nights.
+func (s BookingStatus) ReleasesNights() bool {
+	return s == StatusCancelled || s == StatusNoShow
+}
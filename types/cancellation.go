@@ -0,0 +1,144 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// CancellationRule charges FeePercent of the booking total when it is
+// cancelled less than DaysBefore days before its check-in time.
+type CancellationRule struct {
+	DaysBefore int     `bson:"daysBefore" json:"daysBefore"`
+	FeePercent float64 `bson:"feePercent" json:"feePercent"`
+}
+
+// CancellationPolicy decides what cancelling a booking of a hotel costs.
+// Without rules cancelling is free until check-in. When several rules apply
+// the highest fee wins.
+type CancellationPolicy struct {
+	Rules []CancellationRule `bson:"rules,omitempty" json:"rules,omitempty"`
+	// NonRefundable charges the whole booking whenever it is cancelled.
+	NonRefundable bool `bson:"nonRefundable" json:"nonRefundable"`
+}
+
+func validateCancellationPolicy(errors map[string]string, policy *CancellationPolicy) {
+	if policy == nil {
+		return
+	}
+	for _, rule := range policy.Rules {
+		if rule.DaysBefore < 1 {
+			errors["cancellationPolicy"] = "daysBefore should be at least 1"
+		}
+		if rule.FeePercent < 0 || rule.FeePercent > maxPercent {
+			errors["cancellationPolicy"] = fmt.Sprintf("feePercent should be between 0 and %d", maxPercent)
+		}
+	}
+}
+
+// FeePercent returns the percentage of the booking total charged for
+// cancelling at now a stay that checks in at checkIn.
+func (p CancellationPolicy) FeePercent(checkIn, now time.Time) float64 {
+	if p.NonRefundable {
+		return maxPercent
+	}
+	fee := 0.0
+	for _, rule := range p.Rules {
+		if now.After(checkIn.AddDate(0, 0, -rule.DaysBefore)) && rule.FeePercent > fee {
+			fee = rule.FeePercent
+		}
+	}
+	return fee
+}
+
+// Cancellation records what cancelling a booking cost: Fee is kept by the
+// hotel and Refund is the rest of the booking total.
+type Cancellation struct {
+	At     time.Time `bson:"at" json:"at"`
+	UserID string    `bson:"userID" json:"userID"`
+	Fee    Money     `bson:"fee" json:"fee"`
+	Refund Money     `bson:"refund" json:"refund"`
+	// PolicyFee is the fee the hotel policy set, which an admin may have
+	// replaced giving a Reason.
+	PolicyFee  Money  `bson:"policyFee" json:"policyFee"`
+	Overridden bool   `bson:"overridden" json:"overridden"`
+	Reason     string `bson:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// CancelBookingParams lets an admin replace the fee of a cancellation.
+type CancelBookingParams struct {
+	Fee    *Money `json:"fee,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func (p CancelBookingParams) Validate() error {
+	if p.Fee == nil {
+		return nil
+	}
+	if p.Fee.Amount < 0 {
+		return fmt.Errorf("fee should not be negative")
+	}
+	if len(p.Reason) == 0 {
+		return fmt.Errorf("a reason is required to override the cancellation fee")
+	}
+	return nil
+}
+
+// NewCancellation prices cancelling booking at now under the policy of its
+// hotel. Nights sold at non refundable rates are charged in full whatever
+// the policy, and taxes are charged in the same proportion as the nights.
+func NewCancellation(booking *Booking, hotel *Hotel, userID string, now time.Time) Cancellation {
+	var (
+		total   = booking.Price.Total.OrCurrency(hotel.PriceCurrency())
+		percent = hotel.CancellationPolicy.FeePercent(booking.FromDate, now)
+		fee     = total.Percent(percent)
+	)
+	var subtotal, charged float64
+	for _, night := range booking.Price.NightPrices {
+		subtotal += float64(night.Amount.Amount)
+		if night.NonRefundable {
+			charged += float64(night.Amount.Amount)
+		} else {
+			charged += float64(night.Amount.Amount) * percent / maxPercent
+		}
+	}
+	if subtotal > 0 {
+		fee = total.Mul(charged / subtotal)
+	}
+	return Cancellation{
+		At:        now,
+		UserID:    userID,
+		Fee:       fee,
+		Refund:    Money{Amount: total.Amount - fee.Amount, Currency: total.Currency},
+		PolicyFee: fee,
+	}
+}
+
+// Override replaces the policy fee with fee, which can not exceed the
+// booking total.
+func (c *Cancellation) Override(fee Money, reason string) error {
+	total, err := c.Fee.Add(c.Refund)
+	if err != nil {
+		return err
+	}
+	fee = fee.OrCurrency(total.Currency)
+	if fee.Currency != total.Currency {
+		return fmt.Errorf("fee should be in %s", total.Currency)
+	}
+	if fee.Amount > total.Amount {
+		return fmt.Errorf("fee should not exceed the booking total of %s", total)
+	}
+	c.Fee = fee
+	c.Refund = Money{Amount: total.Amount - fee.Amount, Currency: total.Currency}
+	c.Overridden = true
+	c.Reason = reason
+	return nil
+}
+
+// Cancel cancels the booking at now, keeping what it cost.
+func (b *Booking) Cancel(cancellation Cancellation, now time.Time) error {
+	if err := b.Transition(StatusCancelled, now); err != nil {
+		return err
+	}
+	b.Cancellation = &cancellation
+	return nil
+}
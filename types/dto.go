@@ -4,12 +4,37 @@ type MsgUpdated struct {
 	Updated string `json:"updated"`
 }
 
+type MsgVerified struct {
+	Verified string `json:"verified"`
+}
+
 type MsgDeleted struct {
 	Deleted string `json:"deleted"`
 }
 
 type MsgCancelled struct {
-	Cancelled string `json:"cancelled"`
+	Cancelled    string        `json:"cancelled"`
+	Cancellation *Cancellation `json:"cancellation,omitempty"`
+}
+
+// MsgGroupCancelled lists the cancellation of every booking a group
+// cancellation cancelled.
+type MsgGroupCancelled struct {
+	Cancelled     string          `json:"cancelled"`
+	Cancellations []*Cancellation `json:"cancellations"`
+}
+
+// MsgReceived acknowledges a webhook event, Duplicate when it had already
+// been processed.
+type MsgReceived struct {
+	Received  string `json:"received"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+}
+
+// MsgResetRequested answers a password reset request alike whether the
+// email has an account or not.
+type MsgResetRequested struct {
+	Message string `json:"message"`
 }
 
 type MsgError struct {
@@ -71,3 +71,80 @@ func ErrCancelPastBooking(e error) ErrorSt {
 		Err:    e,
 	}
 }
+func ErrInvalidTransition(e error) ErrorSt {
+	return ErrorSt{
+		Msg:    "invalid booking status transition",
+		Status: http.StatusConflict,
+		Err:    e,
+	}
+}
+func ErrBookingNotModifiable(e error) ErrorSt {
+	return ErrorSt{
+		Msg:    "booking can not be modified",
+		Status: http.StatusConflict,
+		Err:    e,
+	}
+}
+func ErrHoldExpired(e error) ErrorSt {
+	return ErrorSt{
+		Msg:    "hold expired",
+		Status: http.StatusGone,
+		Err:    e,
+	}
+}
+func ErrPaymentDeclined(e error) ErrorSt {
+	return ErrorSt{
+		Msg:    "payment declined",
+		Status: http.StatusPaymentRequired,
+		Err:    e,
+	}
+}
+func ErrInvalidPaymentOperation(e error) ErrorSt {
+	return ErrorSt{
+		Msg:    "invalid payment operation",
+		Status: http.StatusConflict,
+		Err:    e,
+	}
+}
+func ErrFolioNotSettled(e error) ErrorSt {
+	return ErrorSt{
+		Msg:    "folio not settled",
+		Status: http.StatusConflict,
+		Err:    e,
+	}
+}
+func ErrFolioItemVoided(e error) ErrorSt {
+	return ErrorSt{
+		Msg:    "folio item already voided",
+		Status: http.StatusConflict,
+		Err:    e,
+	}
+}
+func ErrOccupancyExceeded(e error) ErrorSt {
+	return ErrorSt{
+		Msg:    "guests exceed room occupancy",
+		Status: http.StatusUnprocessableEntity,
+		Err:    e,
+	}
+}
+func ErrPromoNotApplicable(e error) ErrorSt {
+	return ErrorSt{
+		Msg:    "promo code can not be applied",
+		Status: http.StatusUnprocessableEntity,
+		Err:    e,
+	}
+}
+func ErrEmailNotVerified(e error) ErrorSt {
+	return ErrorSt{
+		Msg:    "email not verified",
+		Status: http.StatusForbidden,
+		Err:    e,
+	}
+}
+func ErrTooManyLogins(e error) ErrorSt {
+	return ErrorSt{
+		Msg:    "too many failed logins",
+		Status: http.StatusTooManyRequests,
+		Err:    e,
+	}
+}
@@ -0,0 +1,177 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+type FolioItemType string
+
+const (
+	FolioRoom  FolioItemType = "room"
+	FolioTax   FolioItemType = "tax"
+	FolioExtra FolioItemType = "extra"
+	// FolioFee is the cancellation fee of a cancelled booking.
+	FolioFee     FolioItemType = "fee"
+	FolioPayment FolioItemType = "payment"
+	FolioRefund  FolioItemType = "refund"
+)
+
+// FolioItem is a line of the folio of a booking. Amount is always positive,
+// Type tells whether it is charged or paid. Voided items stay on the folio
+// but no longer count towards its balance.
+type FolioItem struct {
+	ID          string        `bson:"_id,omitempty" json:"id,omitempty"`
+	BookingID   string        `bson:"bookingID" json:"bookingID"`
+	Type        FolioItemType `bson:"type" json:"type"`
+	Description string        `bson:"description" json:"description"`
+	Amount      Money         `bson:"amount" json:"amount"`
+	// Night is set on room items, PaymentID on items of provider payments.
+	Night     string     `bson:"night,omitempty" json:"night,omitempty"`
+	PaymentID string     `bson:"paymentID,omitempty" json:"paymentID,omitempty"`
+	PostedBy  string     `bson:"postedBy,omitempty" json:"postedBy,omitempty"`
+	PostedAt  time.Time  `bson:"postedAt" json:"postedAt"`
+	Void      *FolioVoid `bson:"void,omitempty" json:"void,omitempty"`
+}
+
+// FolioVoid records who voided a folio item, when and why.
+type FolioVoid struct {
+	At     time.Time `bson:"at" json:"at"`
+	UserID string    `bson:"userID" json:"userID"`
+	Reason string    `bson:"reason" json:"reason"`
+}
+
+// PostFolioItemParams is a charge for an extra, such as the minibar or
+// parking, or a payment taken at the front desk.
+type PostFolioItemParams struct {
+	Type        FolioItemType `json:"type"`
+	Description string        `json:"description"`
+	Amount      Money         `json:"amount"`
+}
+
+func (p PostFolioItemParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if p.Type != FolioExtra && p.Type != FolioPayment {
+		errors["type"] = fmt.Sprintf("type should be %s or %s", FolioExtra, FolioPayment)
+	}
+	if p.Description == "" {
+		errors["description"] = "description is required"
+	}
+	if p.Amount.Amount <= 0 {
+		errors["amount"] = "amount should be greater than 0"
+	}
+	return errors
+}
+
+type VoidFolioItemParams struct {
+	Reason string `json:"reason"`
+}
+
+func (p VoidFolioItemParams) Validate() error {
+	if p.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	return nil
+}
+
+// NewFolioItem posts amount to the folio of bookingID on behalf of userID,
+// empty for items posted by the API itself.
+func NewFolioItem(bookingID string, itemType FolioItemType, description string, amount Money, userID string) *FolioItem {
+	return &FolioItem{
+		BookingID:   bookingID,
+		Type:        itemType,
+		Description: description,
+		Amount:      amount,
+		PostedBy:    userID,
+		PostedAt:    time.Now(),
+	}
+}
+
+// NewStayFolioItems charges the nights of a booking, one item per night as
+// priced at booking time, and each of its taxes.
+func NewStayFolioItems(booking *Booking, hotel *Hotel) []*FolioItem {
+	var (
+		currency = booking.PriceCurrency(hotel)
+		items    []*FolioItem
+	)
+	for _, night := range booking.Price.NightPrices {
+		item := NewFolioItem(booking.ID, FolioRoom, "Room night "+night.Night, night.Amount.OrCurrency(currency), "")
+		item.Night = night.Night
+		items = append(items, item)
+	}
+	//legacy bookings only have the subtotal of their nights
+	if subtotal := booking.Price.Subtotal.OrCurrency(currency); len(booking.Price.NightPrices) == 0 && !subtotal.IsZero() {
+		description := fmt.Sprintf("Room, %d nights", len(booking.Nights))
+		items = append(items, NewFolioItem(booking.ID, FolioRoom, description, subtotal, ""))
+	}
+	for _, line := range booking.Price.TaxLines {
+		items = append(items, NewFolioItem(booking.ID, FolioTax, line.Name, line.Amount.OrCurrency(currency), ""))
+	}
+	//bookings priced before tax lines only have their total
+	if taxes := booking.Price.Taxes.OrCurrency(currency); len(booking.Price.TaxLines) == 0 && !taxes.IsZero() {
+		items = append(items, NewFolioItem(booking.ID, FolioTax, "Taxes", taxes, ""))
+	}
+	return items
+}
+
+// PriceCurrency returns the currency the booking was priced in, the one of
+// its hotel for legacy bookings.
+func (b *Booking) PriceCurrency(hotel *Hotel) string {
+	if b.Price.Currency != "" {
+		return b.Price.Currency
+	}
+	return hotel.PriceCurrency()
+}
+
+// FolioOpen reports whether items can be posted to or voided on the folio
+// of the booking, from its confirmation until check-out.
+func (b *Booking) FolioOpen() bool {
+	return b.Status == StatusConfirmed || b.Status == StatusCheckedIn
+}
+
+// Folio is the account of a booking: what was charged to it and paid for
+// it. A positive Balance is owed by the guest, a negative one to the guest.
+type Folio struct {
+	BookingID string       `json:"bookingID"`
+	Items     []*FolioItem `json:"items"`
+	Charges   Money        `json:"charges"`
+	Payments  Money        `json:"payments"`
+	Balance   Money        `json:"balance"`
+}
+
+// NewFolio totals the items of a booking whose prices are in currency.
+func NewFolio(bookingID, currency string, items []*FolioItem) (*Folio, error) {
+	folio := &Folio{
+		BookingID: bookingID,
+		Items:     items,
+		Charges:   NewMoney(0, currency),
+		Payments:  NewMoney(0, currency),
+	}
+	var err error
+	for _, item := range items {
+		if item.Void != nil {
+			continue
+		}
+		switch item.Type {
+		case FolioPayment:
+			folio.Payments, err = folio.Payments.Add(item.Amount)
+		case FolioRefund:
+			folio.Payments, err = folio.Payments.Sub(item.Amount)
+		default:
+			folio.Charges, err = folio.Charges.Add(item.Amount)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	folio.Balance, err = folio.Charges.Sub(folio.Payments)
+	if err != nil {
+		return nil, err
+	}
+	return folio, nil
+}
+
+// Settled reports whether nothing is owed by or to the guest.
+func (f *Folio) Settled() bool {
+	return f.Balance.IsZero()
+}
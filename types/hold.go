@@ -0,0 +1,60 @@
+package types
+
+import "time"
+
+// Hold keeps the nights of a room for a user until ExpiresAt, while they
+// pay, at the price quoted when the hold was made. Converting it creates a
+// booking with the same ID.
+type Hold struct {
+	ID        string         `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    string         `bson:"userID" json:"userID"`
+	HotelID   string         `bson:"hotelID" json:"hotelID"`
+	RoomID    string         `bson:"roomID" json:"roomID"`
+	FromDate  time.Time      `bson:"fromDate" json:"fromDate"`
+	ToDate    time.Time      `bson:"toDate" json:"toDate"`
+	Nights    []string       `bson:"nights" json:"nights"`
+	Guests    Guests         `bson:"guests" json:"guests"`
+	Price     PriceBreakdown `bson:"price" json:"price"`
+	CreatedAt time.Time      `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time      `bson:"expiresAt" json:"expiresAt"`
+}
+
+// NewHold holds the stay of booking for ttl.
+func NewHold(booking *Booking, ttl time.Duration) *Hold {
+	now := time.Now()
+	return &Hold{
+		UserID:    booking.UserID,
+		HotelID:   booking.HotelID,
+		RoomID:    booking.RoomID,
+		FromDate:  booking.FromDate,
+		ToDate:    booking.ToDate,
+		Nights:    booking.Nights,
+		Guests:    booking.Guests,
+		Price:     booking.Price,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
+
+func (h *Hold) Expired(now time.Time) bool {
+	return !now.Before(h.ExpiresAt)
+}
+
+// Booking returns the confirmed booking the hold becomes.
+func (h *Hold) Booking() *Booking {
+	now := time.Now()
+	return &Booking{
+		ID:            h.ID,
+		UserID:        h.UserID,
+		HotelID:       h.HotelID,
+		RoomID:        h.RoomID,
+		FromDate:      h.FromDate,
+		ToDate:        h.ToDate,
+		Nights:        h.Nights,
+		Guests:        h.Guests,
+		Price:         h.Price,
+		CreatedDate:   now,
+		Status:        StatusConfirmed,
+		StatusHistory: []StatusChange{{Status: StatusConfirmed, At: now}},
+	}
+}
@@ -1,25 +1,55 @@
 package types
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
 
 const (
 	minHotelName     = 3
 	minHotelLocation = 4
 	minHotelRating   = 0
+	maxHotelTaxRate  = 100
+
+	defaultTimeZone     = "UTC"
+	defaultCheckInTime  = "15:00"
+	defaultCheckOutTime = "11:00"
+	clockLayout         = "15:04"
 )
 
+var currencyRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+
 type Hotel struct {
 	ID       string   `bson:"_id,omitempty" json:"id,omitempty"`
 	Name     string   `bson:"name" json:"name"`
 	Location string   `bson:"location" json:"location"`
 	Rooms    []string `bson:"rooms" json:"rooms"`
 	Rating   int      `bson:"rating" json:"rating"`
+	// TimeZone is an IANA zone name, bookings are dated in it.
+	TimeZone     string `bson:"timeZone" json:"timeZone"`
+	CheckInTime  string `bson:"checkInTime" json:"checkInTime"`
+	CheckOutTime string `bson:"checkOutTime" json:"checkOutTime"`
+	// Currency is the ISO 4217 code room prices are expressed in.
+	Currency string `bson:"currency" json:"currency"`
+	// TaxRate is the VAT percentage added to the room nights of a booking.
+	TaxRate    float64    `bson:"taxRate" json:"taxRate"`
+	TouristTax TouristTax `bson:"touristTax" json:"touristTax"`
+	// CancellationPolicy defaults to free cancellation until check-in.
+	CancellationPolicy CancellationPolicy `bson:"cancellationPolicy" json:"cancellationPolicy"`
 }
 
 type CreateHotelParams struct {
-	Name     string `json:"name"`
-	Location string `json:"location"`
-	Rating   int    `json:"rating"`
+	Name               string             `json:"name"`
+	Location           string             `json:"location"`
+	Rating             int                `json:"rating"`
+	TimeZone           string             `json:"timeZone"`
+	CheckInTime        string             `json:"checkInTime"`
+	CheckOutTime       string             `json:"checkOutTime"`
+	Currency           string             `json:"currency"`
+	TaxRate            float64            `json:"taxRate"`
+	TouristTax         TouristTax         `json:"touristTax"`
+	CancellationPolicy CancellationPolicy `json:"cancellationPolicy"`
 }
 
 func (p CreateHotelParams) Validate() map[string]string {
@@ -33,23 +63,143 @@ func (p CreateHotelParams) Validate() map[string]string {
 	if p.Rating < minHotelRating {
 		errors["rating"] = fmt.Sprintf("hotel rating should be greater than %d", minHotelRating)
 	}
+	validateSchedule(errors, p.TimeZone, p.CheckInTime, p.CheckOutTime)
+	validatePricing(errors, p.Currency, &p.TaxRate)
+	validateTouristTax(errors, &p.TouristTax)
+	validateTouristTaxCurrency(errors, &Hotel{Currency: p.Currency, TouristTax: p.TouristTax})
+	validateCancellationPolicy(errors, &p.CancellationPolicy)
 	return errors
 }
+
+func validatePricing(errors map[string]string, currency string, taxRate *float64) {
+	if currency != "" && !isCurrencyValid(currency) {
+		errors["currency"] = fmt.Sprintf("currency %s should be an ISO 4217 code", currency)
+	}
+	if taxRate != nil && (*taxRate < 0 || *taxRate > maxHotelTaxRate) {
+		errors["taxRate"] = fmt.Sprintf("tax rate should be between 0 and %d", maxHotelTaxRate)
+	}
+}
+
+func isCurrencyValid(c string) bool {
+	return currencyRegex.MatchString(c)
+}
+
+func validateSchedule(errors map[string]string, timeZone, checkIn, checkOut string) {
+	if timeZone != "" {
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			errors["timeZone"] = fmt.Sprintf("time zone %s is invalid", timeZone)
+		}
+	}
+	if checkIn != "" {
+		if _, err := time.Parse(clockLayout, checkIn); err != nil {
+			errors["checkInTime"] = "check-in time should be formatted as HH:MM"
+		}
+	}
+	if checkOut != "" {
+		if _, err := time.Parse(clockLayout, checkOut); err != nil {
+			errors["checkOutTime"] = "check-out time should be formatted as HH:MM"
+		}
+	}
+}
+
 func NewHotelFromParams(params CreateHotelParams) (*Hotel, error) {
-	return &Hotel{
-		Name:     params.Name,
-		Location: params.Location,
-		Rating:   params.Rating,
-		Rooms:    []string{},
-	}, nil
+	hotel := &Hotel{
+		Name:               params.Name,
+		Location:           params.Location,
+		Rating:             params.Rating,
+		Rooms:              []string{},
+		TimeZone:           params.TimeZone,
+		CheckInTime:        params.CheckInTime,
+		CheckOutTime:       params.CheckOutTime,
+		Currency:           params.Currency,
+		TaxRate:            params.TaxRate,
+		TouristTax:         params.TouristTax,
+		CancellationPolicy: params.CancellationPolicy,
+	}
+	if hotel.Currency == "" {
+		hotel.Currency = defaultCurrency
+	}
+	if hotel.TimeZone == "" {
+		hotel.TimeZone = defaultTimeZone
+	}
+	if hotel.CheckInTime == "" {
+		hotel.CheckInTime = defaultCheckInTime
+	}
+	if hotel.CheckOutTime == "" {
+		hotel.CheckOutTime = defaultCheckOutTime
+	}
+	return hotel, nil
+}
+
+// PriceCurrency returns the currency of the hotel's prices, EUR when unset.
+func (h *Hotel) PriceCurrency() string {
+	if h.Currency == "" {
+		return defaultCurrency
+	}
+	return h.Currency
+}
+
+// TimeLocation returns the hotel's time zone, UTC when unset.
+func (h *Hotel) TimeLocation() (*time.Location, error) {
+	if h.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(h.TimeZone)
+}
+
+// Today returns the current date at the hotel as YYYY-MM-DD.
+func (h *Hotel) Today() string {
+	loc, err := h.TimeLocation()
+	if err != nil {
+		loc = time.UTC
+	}
+	return time.Now().In(loc).Format(time.DateOnly)
+}
+
+// StayTimes returns the check-in and check-out instants, in the hotel's time
+// zone, for a stay between the calendar dates of checkIn and checkOut.
+func (h *Hotel) StayTimes(checkIn, checkOut time.Time) (time.Time, time.Time, error) {
+	loc, err := h.TimeLocation()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	in, err := parseClock(h.CheckInTime, defaultCheckInTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	out, err := parseClock(h.CheckOutTime, defaultCheckOutTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return atClock(checkIn, in, loc), atClock(checkOut, out, loc), nil
+}
+
+func parseClock(clock, fallback string) (time.Time, error) {
+	if clock == "" {
+		clock = fallback
+	}
+	return time.Parse(clockLayout, clock)
+}
+
+func atClock(day, clock time.Time, loc *time.Location) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
 }
 
 type UpdateHotel struct {
-	Name     string `json:"name"`
-	Location string `json:"location"`
+	Name               string              `json:"name"`
+	Location           string              `json:"location"`
+	TimeZone           string              `json:"timeZone"`
+	CheckInTime        string              `json:"checkInTime"`
+	CheckOutTime       string              `json:"checkOutTime"`
+	Currency           string              `json:"currency"`
+	TaxRate            *float64            `json:"taxRate"`
+	TouristTax         *TouristTax         `json:"touristTax"`
+	CancellationPolicy *CancellationPolicy `json:"cancellationPolicy"`
 }
 
-func ValidateHotelUpdate(updateMap UpdateHotel) (*map[string]any, error) {
+// ValidateHotelUpdate returns the fields of hotel, as stored, that updateMap
+// changes, checking the hotel they leave is valid.
+func ValidateHotelUpdate(hotel *Hotel, updateMap UpdateHotel) (*map[string]any, error) {
 	validUpdate := map[string]any{}
 	if updateMap.Name != "" {
 		validUpdate["name"] = updateMap.Name
@@ -57,7 +207,44 @@ func ValidateHotelUpdate(updateMap UpdateHotel) (*map[string]any, error) {
 	if updateMap.Location != "" {
 		validUpdate["location"] = updateMap.Location
 	}
-	if updateMap.Name == "" && updateMap.Location == "" {
+	scheduleErrors := map[string]string{}
+	validateSchedule(scheduleErrors, updateMap.TimeZone, updateMap.CheckInTime, updateMap.CheckOutTime)
+	validatePricing(scheduleErrors, updateMap.Currency, updateMap.TaxRate)
+	validateTouristTax(scheduleErrors, updateMap.TouristTax)
+	validateCancellationPolicy(scheduleErrors, updateMap.CancellationPolicy)
+	updated := *hotel
+	if updateMap.Currency != "" {
+		updated.Currency = updateMap.Currency
+	}
+	if updateMap.TouristTax != nil {
+		updated.TouristTax = *updateMap.TouristTax
+	}
+	validateTouristTaxCurrency(scheduleErrors, &updated)
+	for _, msg := range scheduleErrors {
+		return nil, fmt.Errorf("%s", msg)
+	}
+	if updateMap.TimeZone != "" {
+		validUpdate["timeZone"] = updateMap.TimeZone
+	}
+	if updateMap.CheckInTime != "" {
+		validUpdate["checkInTime"] = updateMap.CheckInTime
+	}
+	if updateMap.CheckOutTime != "" {
+		validUpdate["checkOutTime"] = updateMap.CheckOutTime
+	}
+	if updateMap.Currency != "" {
+		validUpdate["currency"] = updateMap.Currency
+	}
+	if updateMap.TaxRate != nil {
+		validUpdate["taxRate"] = *updateMap.TaxRate
+	}
+	if updateMap.TouristTax != nil {
+		validUpdate["touristTax"] = *updateMap.TouristTax
+	}
+	if updateMap.CancellationPolicy != nil {
+		validUpdate["cancellationPolicy"] = *updateMap.CancellationPolicy
+	}
+	if len(validUpdate) == 0 {
 		return nil, fmt.Errorf("no valid update parameters for hotel")
 	}
 	return &validUpdate, nil
@@ -0,0 +1,132 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// Invoice is the legal invoice of a stay. It is issued once per booking and
+// never changes afterwards. Sequence numbers the invoices of a hotel within
+// Year without gaps, Number is how it is printed.
+type Invoice struct {
+	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
+	Number    string    `bson:"number" json:"number"`
+	HotelID   string    `bson:"hotelID" json:"hotelID"`
+	Year      int       `bson:"year" json:"year"`
+	Sequence  int       `bson:"sequence" json:"sequence"`
+	BookingID string    `bson:"bookingID" json:"bookingID"`
+	IssuedAt  time.Time `bson:"issuedAt" json:"issuedAt"`
+	// Seller and Customer are copied at issue time, later changes to the
+	// hotel or the user do not alter the invoice.
+	Seller   InvoiceParty  `bson:"seller" json:"seller"`
+	Customer InvoiceParty  `bson:"customer" json:"customer"`
+	FromDate time.Time     `bson:"fromDate" json:"fromDate"`
+	ToDate   time.Time     `bson:"toDate" json:"toDate"`
+	Lines    []InvoiceLine `bson:"lines" json:"lines"`
+	Subtotal Money         `bson:"subtotal" json:"subtotal"`
+	TaxRate  float64       `bson:"taxRate" json:"taxRate"`
+	TaxLines []TaxLine     `bson:"taxLines,omitempty" json:"taxLines,omitempty"`
+	Taxes    Money         `bson:"taxes" json:"taxes"`
+	Total    Money         `bson:"total" json:"total"`
+	Currency string        `bson:"currency" json:"currency"`
+}
+
+type InvoiceParty struct {
+	ID      string `bson:"id" json:"id"`
+	Name    string `bson:"name" json:"name"`
+	Address string `bson:"address,omitempty" json:"address,omitempty"`
+	Email   string `bson:"email,omitempty" json:"email,omitempty"`
+}
+
+type InvoiceLine struct {
+	Description string `bson:"description" json:"description"`
+	Quantity    int    `bson:"quantity" json:"quantity"`
+	UnitPrice   Money  `bson:"unitPrice" json:"unitPrice"`
+	Amount      Money  `bson:"amount" json:"amount"`
+}
+
+// Invoiceable reports whether an invoice can be issued for the booking,
+// which is once it is checked out and its folio closed.
+func (b *Booking) Invoiceable() bool {
+	return b.Status == StatusCheckedOut
+}
+
+// NewInvoice prepares the invoice of a booking, numbered later by the
+// store. Each night is charged at its booking price or, for legacy bookings
+// without one, at the room price, followed by the extras charged to the
+// folio. user is nil when the guest no longer exists.
+func NewInvoice(booking *Booking, hotel *Hotel, room *Room, user *User, extras []*FolioItem, now time.Time) (*Invoice, error) {
+	currency := booking.PriceCurrency(hotel)
+	//the year of the numbering is the one at the hotel
+	loc, err := hotel.TimeLocation()
+	if err != nil {
+		loc = time.UTC
+	}
+	invoice := &Invoice{
+		HotelID:   hotel.ID,
+		Year:      now.In(loc).Year(),
+		BookingID: booking.ID,
+		IssuedAt:  now,
+		Seller:    InvoiceParty{ID: hotel.ID, Name: hotel.Name, Address: hotel.Location},
+		Customer:  InvoiceParty{ID: booking.UserID},
+		FromDate:  booking.FromDate,
+		ToDate:    booking.ToDate,
+		Subtotal:  NewMoney(0, currency),
+		TaxRate:   hotel.TaxRate,
+		Currency:  currency,
+	}
+	if user != nil {
+		invoice.Customer.Name = fmt.Sprintf("%s %s", user.Firstname, user.Lastname)
+		invoice.Customer.Email = user.Email
+	}
+	for _, night := range booking.Price.NightPrices {
+		if err = invoice.add(fmt.Sprintf("%s room, night %s", room.Size, night.Night), night.Amount.OrCurrency(currency)); err != nil {
+			return nil, err
+		}
+	}
+	invoice.TaxLines = booking.Price.TaxLines
+	invoice.Taxes = booking.Price.Taxes.OrCurrency(currency)
+	if len(booking.Price.NightPrices) == 0 {
+		for _, night := range booking.Nights {
+			if err = invoice.add(fmt.Sprintf("%s room, night %s", room.Size, night), room.Price.OrCurrency(currency)); err != nil {
+				return nil, err
+			}
+		}
+		invoice.Taxes = invoice.Subtotal.Percent(hotel.TaxRate)
+	}
+	for _, item := range extras {
+		if item.Void != nil || item.Type != FolioExtra {
+			continue
+		}
+		if err = invoice.add(item.Description, item.Amount); err != nil {
+			return nil, err
+		}
+	}
+	if invoice.Total, err = invoice.Subtotal.Add(invoice.Taxes); err != nil {
+		return nil, err
+	}
+	return invoice, nil
+}
+
+// add appends a line of a single unit of amount.
+func (i *Invoice) add(description string, amount Money) error {
+	subtotal, err := i.Subtotal.Add(amount)
+	if err != nil {
+		return err
+	}
+	i.Subtotal = subtotal
+	i.Lines = append(i.Lines, InvoiceLine{
+		Description: description,
+		Quantity:    1,
+		UnitPrice:   amount,
+		Amount:      amount,
+	})
+	return nil
+}
+
+// SetSequence numbers the invoice as the sequence-th of its hotel in its
+// year.
+func (i *Invoice) SetSequence(sequence int) {
+	i.Sequence = sequence
+	i.Number = fmt.Sprintf("%d-%06d", i.Year, sequence)
+}
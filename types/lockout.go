@@ -0,0 +1,130 @@
+package types
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// LoginKind is what failed logins are counted by.
+type LoginKind string
+
+const (
+	// LoginByEmail counts the failed logins to an account.
+	LoginByEmail LoginKind = "email"
+	// LoginByIP counts the failed logins from a client IP, to any account.
+	LoginByIP LoginKind = "ip"
+)
+
+const (
+	LockoutLocked   = "locked"
+	LockoutUnlocked = "unlocked"
+)
+
+// LoginSubject returns subject, an email or a client IP by kind, as login
+// attempts are counted by. Emails are compared case insensitively.
+func LoginSubject(kind LoginKind, subject string) string {
+	if kind == LoginByEmail {
+		return strings.ToLower(strings.TrimSpace(subject))
+	}
+	return subject
+}
+
+// LoginKey returns the ID of the login attempts of subject.
+func LoginKey(kind LoginKind, subject string) string {
+	return string(kind) + ":" + LoginSubject(kind, subject)
+}
+
+// LoginAttempts counts the recent failed logins of an email or a client IP.
+// Failures are forgotten once the record expires.
+type LoginAttempts struct {
+	ID          string    `bson:"_id" json:"id"`
+	Kind        LoginKind `bson:"kind" json:"kind"`
+	Subject     string    `bson:"subject" json:"subject"`
+	Failures    int       `bson:"failures" json:"failures"`
+	LastFailure time.Time `bson:"lastFailure" json:"lastFailure"`
+	// PreviousFailure is the LastFailure before the last failure counted.
+	PreviousFailure time.Time `bson:"previousFailure,omitempty" json:"previousFailure,omitempty"`
+	LockedUntil     time.Time `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	ExpiresAt       time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+// Before returns the attempts as they were before the last failure was
+// counted.
+func (a *LoginAttempts) Before() *LoginAttempts {
+	before := *a
+	before.Failures--
+	before.LastFailure = a.PreviousFailure
+	return &before
+}
+
+// LockoutPolicy throttles the logins of an email or a client IP. After
+// FreeFailures failures each attempt waits BaseDelay, doubled on every
+// further failure up to MaxDelay, and LockAfter failures lock out logins
+// for LockDuration. Failures more than Window apart are not added up.
+type LockoutPolicy struct {
+	FreeFailures int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockAfter    int
+	LockDuration time.Duration
+	Window       time.Duration
+}
+
+// RetryAt returns when the next login after the attempts may be tried, the
+// zero time when it may be tried now.
+func (p LockoutPolicy) RetryAt(a *LoginAttempts) time.Time {
+	retry := a.LockedUntil
+	if n := a.Failures - p.FreeFailures; n >= 0 {
+		delay := p.MaxDelay
+		//shifting by 30 or more would overflow long before MaxDelay
+		if n < 30 && p.BaseDelay<<n < p.MaxDelay {
+			delay = p.BaseDelay << n
+		}
+		if backoff := a.LastFailure.Add(delay); backoff.After(retry) {
+			retry = backoff
+		}
+	}
+	return retry
+}
+
+// Locks reports whether the attempts lock out logins.
+func (p LockoutPolicy) Locks(a *LoginAttempts) bool {
+	return a.Failures >= p.LockAfter
+}
+
+// LockoutEvent is the audit record of a lockout of an email or a client
+// IP, or of an admin lifting it.
+type LockoutEvent struct {
+	ID      string    `bson:"_id,omitempty" json:"id,omitempty"`
+	Kind    LoginKind `bson:"kind" json:"kind"`
+	Subject string    `bson:"subject" json:"subject"`
+	// Action is LockoutLocked or LockoutUnlocked.
+	Action string `bson:"action" json:"action"`
+	// Failures are the failed logins counted when the event happened.
+	Failures int `bson:"failures" json:"failures"`
+	// IP is the client IP of the failed login that locked.
+	IP          string    `bson:"ip,omitempty" json:"ip,omitempty"`
+	LockedUntil time.Time `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	// UnlockedBy is the admin who lifted the lockout.
+	UnlockedBy string    `bson:"unlockedBy,omitempty" json:"unlockedBy,omitempty"`
+	At         time.Time `bson:"at" json:"at"`
+}
+
+// UnlockParams lifts the lockout of an email, of a client IP or both.
+type UnlockParams struct {
+	Email string `json:"email"`
+	IP    string `json:"ip"`
+}
+
+func (p UnlockParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(p.Email) == 0 && len(p.IP) == 0 {
+		errors["email"] = "email or ip is required"
+	}
+	if len(p.IP) > 0 && net.ParseIP(p.IP) == nil {
+		errors["ip"] = fmt.Sprintf("ip %s is invalid", p.IP)
+	}
+	return errors
+}
@@ -0,0 +1,150 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// legacyMinorUnits is the number of decimals assumed for prices stored as
+// plain floats, before Money existed.
+const legacyMinorUnits = 2
+
+// minorUnits lists the ISO 4217 currencies whose minor unit is not a
+// hundredth.
+var minorUnits = map[string]int{
+	"BHD": 3, "BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0,
+	"JOD": 3, "JPY": 0, "KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3,
+	"PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// Money is an amount in minor units, such as cents, of an ISO 4217
+// currency. A Money without currency comes from a legacy float price and
+// holds hundredths of the currency it is later given with OrCurrency.
+type Money struct {
+	Amount   int64  `bson:"amount" json:"amount"`
+	Currency string `bson:"currency" json:"currency"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// MoneyFromFloat converts an amount in major units, rounding half away from
+// zero to the currency's minor unit.
+func MoneyFromFloat(amount float64, currency string) Money {
+	return Money{
+		Amount:   int64(math.Round(amount * math.Pow10(MinorUnits(currency)))),
+		Currency: currency,
+	}
+}
+
+// MinorUnits returns the number of decimals of a currency.
+func MinorUnits(currency string) int {
+	if units, ok := minorUnits[currency]; ok {
+		return units
+	}
+	return legacyMinorUnits
+}
+
+// OrCurrency gives currency to a legacy Money and returns any other Money
+// unchanged.
+func (m Money) OrCurrency(currency string) Money {
+	if m.Currency != "" {
+		return m
+	}
+	return MoneyFromFloat(float64(m.Amount)/math.Pow10(legacyMinorUnits), currency)
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("can not add %s to %s", o.Currency, m.Currency)
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("can not subtract %s from %s", o.Currency, m.Currency)
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}, nil
+}
+
+// Mul scales m by factor, rounding half away from zero.
+func (m Money) Mul(factor float64) Money {
+	return Money{Amount: int64(math.Round(float64(m.Amount) * factor)), Currency: m.Currency}
+}
+
+// Percent returns percent hundredths of m, rounding half away from zero.
+func (m Money) Percent(percent float64) Money {
+	return m.Mul(percent / 100)
+}
+
+// Div splits m in n parts, rounding half away from zero.
+func (m Money) Div(n int) Money {
+	if n == 0 {
+		return Money{Currency: m.Currency}
+	}
+	return m.Mul(1 / float64(n))
+}
+
+// Float returns the amount in major units.
+func (m Money) Float() float64 {
+	return float64(m.Amount) / math.Pow10(MinorUnits(m.Currency))
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%.*f %s", MinorUnits(m.Currency), m.Float(), m.Currency)
+}
+
+type money Money
+
+// UnmarshalJSON also accepts a plain number, the legacy float price, read
+// as major units with two decimals and no currency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var amount float64
+	if err := json.Unmarshal(data, &amount); err == nil {
+		*m = MoneyFromFloat(amount, "")
+		return nil
+	}
+	var v money
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid money: %w", err)
+	}
+	*m = Money(v)
+	return nil
+}
+
+// UnmarshalBSONValue also decodes the legacy float prices stored before
+// Money existed, see OrCurrency.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bsontype.Double:
+		*m = MoneyFromFloat(raw.Double(), "")
+		return nil
+	case bsontype.Int32:
+		*m = MoneyFromFloat(float64(raw.Int32()), "")
+		return nil
+	case bsontype.Int64:
+		*m = MoneyFromFloat(float64(raw.Int64()), "")
+		return nil
+	case bsontype.Null, bsontype.Undefined:
+		*m = Money{}
+		return nil
+	}
+	var v money
+	if err := raw.Unmarshal(&v); err != nil {
+		return fmt.Errorf("invalid money: %w", err)
+	}
+	*m = Money(v)
+	return nil
+}
@@ -0,0 +1,98 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type ChangePasswordParams struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+func (p ChangePasswordParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(p.CurrentPassword) == 0 {
+		errors["currentPassword"] = "currentPassword is required"
+	}
+	validateNewPassword(p.NewPassword, errors)
+	return errors
+}
+
+type ForgotPasswordParams struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordParams sets NewPassword with the Token of a password reset.
+type ResetPasswordParams struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+func (p ResetPasswordParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(p.Token) == 0 {
+		errors["token"] = "token is required"
+	}
+	validateNewPassword(p.NewPassword, errors)
+	return errors
+}
+
+func validateNewPassword(password string, errors map[string]string) {
+	if len(password) < minPasswordLen {
+		errors["newPassword"] = fmt.Sprintf("newPassword length should be at least %d characters", minPasswordLen)
+	}
+}
+
+// EncryptPassword returns the bcrypt hash of password stored on users.
+func EncryptPassword(password string) (string, error) {
+	encpw, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(encpw), nil
+}
+
+// PasswordReset is the server side record of a token, mailed to a user who
+// forgot their password, that sets a new password once before ExpiresAt.
+// Only the SHA-256 Hash of the token is kept.
+type PasswordReset struct {
+	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    string    `bson:"userID" json:"userID"`
+	Hash      string    `bson:"hash" json:"-"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+	UsedAt    time.Time `bson:"usedAt,omitempty" json:"usedAt,omitempty"`
+}
+
+// NewPasswordReset returns the record of a new password reset of userID,
+// valid for ttl, and the token to mail to the user.
+func NewPasswordReset(userID string, ttl time.Duration) (*PasswordReset, string, error) {
+	token, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	return &PasswordReset{
+		UserID:    userID,
+		Hash:      HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, token, nil
+}
+
+// Usable fails unless the reset can still set the password of user at now.
+// Resets issued before the password last changed are void.
+func (r *PasswordReset) Usable(user *User, now time.Time) error {
+	switch {
+	case !r.UsedAt.IsZero():
+		return fmt.Errorf("reset token already used")
+	case !now.Before(r.ExpiresAt):
+		return fmt.Errorf("reset token expired")
+	case r.CreatedAt.Before(user.PasswordChangedAt):
+		return fmt.Errorf("reset token issued before the last password change")
+	}
+	return nil
+}
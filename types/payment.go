@@ -0,0 +1,189 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+type PaymentStatus string
+
+const (
+	PaymentAuthorized PaymentStatus = "authorized"
+	PaymentCaptured   PaymentStatus = "captured"
+	PaymentRefunded   PaymentStatus = "refunded"
+	PaymentVoided     PaymentStatus = "voided"
+	PaymentFailed     PaymentStatus = "failed"
+)
+
+type PaymentOperationType string
+
+const (
+	PaymentCapture PaymentOperationType = "capture"
+	PaymentRefund  PaymentOperationType = "refund"
+	PaymentVoid    PaymentOperationType = "void"
+)
+
+// PaymentOperation is a capture, refund or void of a payment sent to its
+// provider. It is recorded on the payment as pending before the provider is
+// called, so an operation interrupted midway is sent again with the same Key
+// and carried out once.
+type PaymentOperation struct {
+	Type PaymentOperationType `bson:"type" json:"type"`
+	// Amount is the amount captured or refunded, empty on voids.
+	Amount Money `bson:"amount" json:"amount"`
+	// Key identifies the operation at the provider.
+	Key string    `bson:"key" json:"key"`
+	At  time.Time `bson:"at" json:"at"`
+}
+
+// Payment is the money a payment provider holds or took for a booking.
+// Amount is authorized first, then all or part of it is Captured, and what
+// was captured may be Refunded. An authorization not captured is Voided.
+type Payment struct {
+	ID        string `bson:"_id,omitempty" json:"id,omitempty"`
+	BookingID string `bson:"bookingID" json:"bookingID"`
+	UserID    string `bson:"userID" json:"userID"`
+	Provider  string `bson:"provider" json:"provider"`
+	// Reference identifies the authorization at the provider.
+	Reference     string        `bson:"reference,omitempty" json:"reference,omitempty"`
+	Status        PaymentStatus `bson:"status" json:"status"`
+	Amount        Money         `bson:"amount" json:"amount"`
+	Captured      Money         `bson:"captured" json:"captured"`
+	Refunded      Money         `bson:"refunded" json:"refunded"`
+	FailureReason string        `bson:"failureReason,omitempty" json:"failureReason,omitempty"`
+	// Pending is the operation sent to the provider and not recorded yet.
+	Pending   *PaymentOperation `bson:"pending,omitempty" json:"pending,omitempty"`
+	CreatedAt time.Time         `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time         `bson:"updatedAt" json:"updatedAt"`
+}
+
+// PaymentParams carries the payment method to charge, an opaque token
+// understood by the configured payment provider.
+type PaymentParams struct {
+	PaymentMethod string `json:"paymentMethod,omitempty"`
+}
+
+func (p PaymentParams) Validate() error {
+	if len(p.PaymentMethod) == 0 {
+		return fmt.Errorf("paymentMethod is required")
+	}
+	return nil
+}
+
+// PaymentAmountParams sets the amount of a capture or refund, the whole
+// amount left when empty.
+type PaymentAmountParams struct {
+	Amount *Money `json:"amount,omitempty"`
+}
+
+// NewPayment returns the authorization of amount for booking at provider.
+func NewPayment(booking *Booking, provider, reference string, amount Money) *Payment {
+	now := time.Now()
+	return &Payment{
+		BookingID: booking.ID,
+		UserID:    booking.UserID,
+		Provider:  provider,
+		Reference: reference,
+		Status:    PaymentAuthorized,
+		Amount:    amount,
+		Captured:  NewMoney(0, amount.Currency),
+		Refunded:  NewMoney(0, amount.Currency),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// NewFailedPayment records an authorization of amount for booking that
+// provider refused.
+func NewFailedPayment(booking *Booking, provider string, amount Money, reason string) *Payment {
+	payment := NewPayment(booking, provider, "", amount)
+	payment.Status = PaymentFailed
+	payment.FailureReason = reason
+	return payment
+}
+
+// Capturable returns what is left to capture of the authorization.
+func (p *Payment) Capturable() Money {
+	if p.Status != PaymentAuthorized {
+		return NewMoney(0, p.Amount.Currency)
+	}
+	return Money{Amount: p.Amount.Amount - p.Captured.Amount, Currency: p.Amount.Currency}
+}
+
+// Refundable returns what is left to refund of the captured amount.
+func (p *Payment) Refundable() Money {
+	return Money{Amount: p.Captured.Amount - p.Refunded.Amount, Currency: p.Amount.Currency}
+}
+
+// Capture takes amount of an authorized payment. A payment is captured at
+// most once, what is left of the authorization is released.
+func (p *Payment) Capture(amount Money, now time.Time) error {
+	if p.Status != PaymentAuthorized {
+		return ErrInvalidPaymentOperation(fmt.Errorf("can not capture a %s payment", p.Status))
+	}
+	if err := checkPaymentAmount(amount, p.Capturable()); err != nil {
+		return err
+	}
+	p.Captured = amount
+	p.Status = PaymentCaptured
+	p.UpdatedAt = now
+	return nil
+}
+
+// Refund gives back amount of what a payment captured.
+func (p *Payment) Refund(amount Money, now time.Time) error {
+	if p.Status != PaymentCaptured {
+		return ErrInvalidPaymentOperation(fmt.Errorf("can not refund a %s payment", p.Status))
+	}
+	if err := checkPaymentAmount(amount, p.Refundable()); err != nil {
+		return err
+	}
+	p.Refunded.Amount += amount.Amount
+	if p.Refunded.Amount == p.Captured.Amount {
+		p.Status = PaymentRefunded
+	}
+	p.UpdatedAt = now
+	return nil
+}
+
+// Void releases an authorization nothing was captured of.
+func (p *Payment) Void(now time.Time) error {
+	if p.Status != PaymentAuthorized {
+		return ErrInvalidPaymentOperation(fmt.Errorf("can not void a %s payment", p.Status))
+	}
+	p.Status = PaymentVoided
+	p.UpdatedAt = now
+	return nil
+}
+
+// Apply carries out op on the payment.
+func (p *Payment) Apply(op PaymentOperation, now time.Time) error {
+	switch op.Type {
+	case PaymentCapture:
+		return p.Capture(op.Amount, now)
+	case PaymentRefund:
+		return p.Refund(op.Amount, now)
+	case PaymentVoid:
+		return p.Void(now)
+	}
+	return ErrInvalidPaymentOperation(fmt.Errorf("unknown payment operation %s", op.Type))
+}
+
+// PendingKey returns the key of the pending operation, empty when there is
+// none.
+func (p *Payment) PendingKey() string {
+	if p.Pending == nil {
+		return ""
+	}
+	return p.Pending.Key
+}
+
+func checkPaymentAmount(amount, available Money) error {
+	if amount.Currency != available.Currency {
+		return ErrInvalidParams(fmt.Errorf("amount should be in %s", available.Currency))
+	}
+	if amount.Amount <= 0 || amount.Amount > available.Amount {
+		return ErrInvalidParams(fmt.Errorf("amount should be greater than 0 and at most %s", available))
+	}
+	return nil
+}
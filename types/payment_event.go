@@ -0,0 +1,66 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// BookingPaymentStatus is how the payment processor settled the payment of
+// a booking.
+type BookingPaymentStatus string
+
+const (
+	BookingPaid          BookingPaymentStatus = "paid"
+	BookingPaymentFailed BookingPaymentStatus = "failed"
+)
+
+type PaymentEventType string
+
+const (
+	PaymentEventSucceeded PaymentEventType = "payment.succeeded"
+	PaymentEventFailed    PaymentEventType = "payment.failed"
+)
+
+// PaymentEvent is a callback of the payment processor settling the payment
+// of a booking. The processor may send an event more than once, ID tells
+// the copies apart from new events.
+type PaymentEvent struct {
+	ID        string           `bson:"_id" json:"id"`
+	Type      PaymentEventType `bson:"type" json:"type"`
+	BookingID string           `bson:"bookingID" json:"bookingID"`
+	// Reference identifies the payment at the processor, see Payment.
+	Reference     string    `bson:"reference,omitempty" json:"reference,omitempty"`
+	FailureReason string    `bson:"failureReason,omitempty" json:"failureReason,omitempty"`
+	CreatedAt     time.Time `bson:"createdAt" json:"createdAt"`
+	ReceivedAt    time.Time `bson:"receivedAt" json:"receivedAt,omitempty"`
+}
+
+func (e PaymentEvent) Validate() error {
+	if e.ID == "" {
+		return fmt.Errorf("id is required")
+	}
+	if e.BookingID == "" {
+		return fmt.Errorf("bookingID is required")
+	}
+	if e.Reference == "" {
+		return fmt.Errorf("reference is required")
+	}
+	if e.CreatedAt.IsZero() {
+		return fmt.Errorf("createdAt is required")
+	}
+	if _, err := e.BookingPaymentStatus(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// BookingPaymentStatus is the status the event records on its booking.
+func (e PaymentEvent) BookingPaymentStatus() (BookingPaymentStatus, error) {
+	switch e.Type {
+	case PaymentEventSucceeded:
+		return BookingPaid, nil
+	case PaymentEventFailed:
+		return BookingPaymentFailed, nil
+	}
+	return "", fmt.Errorf("unknown event type %q", e.Type)
+}
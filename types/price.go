@@ -0,0 +1,113 @@
+package types
+
+const defaultCurrency = "EUR"
+
+// PriceBreakdown is the price a guest agreed to when booking, kept on the
+// booking so later room price or rate plan changes do not alter it.
+type PriceBreakdown struct {
+	// NightlyRate is the average price of a night of the stay.
+	NightlyRate Money             `bson:"nightlyRate" json:"nightlyRate"`
+	Nights      int               `bson:"nights" json:"nights"`
+	NightPrices []NightPrice      `bson:"nightPrices,omitempty" json:"nightPrices,omitempty"`
+	Discounts   []AppliedDiscount `bson:"discounts,omitempty" json:"discounts,omitempty"`
+	// PromoCode is the promo code the stay was discounted with, if any.
+	PromoCode string    `bson:"promoCode,omitempty" json:"promoCode,omitempty"`
+	Subtotal  Money     `bson:"subtotal" json:"subtotal"`
+	TaxLines  []TaxLine `bson:"taxLines,omitempty" json:"taxLines,omitempty"`
+	Taxes     Money     `bson:"taxes" json:"taxes"`
+	Total     Money     `bson:"total" json:"total"`
+	Currency  string    `bson:"currency" json:"currency"`
+}
+
+// NightPrice is the price of a single night: its Rate, less any stay
+// discount, gives the Amount charged.
+type NightPrice struct {
+	Night      string `bson:"night" json:"night"`
+	Rate       Money  `bson:"rate" json:"rate"`
+	Discount   Money  `bson:"discount" json:"discount"`
+	Amount     Money  `bson:"amount" json:"amount"`
+	RatePlanID string `bson:"ratePlanID,omitempty" json:"ratePlanID,omitempty"`
+	// NonRefundable is set for nights of non refundable rate plans.
+	NonRefundable bool `bson:"nonRefundable,omitempty" json:"nonRefundable,omitempty"`
+}
+
+// AppliedDiscount sums what a named discount took off the stay.
+type AppliedDiscount struct {
+	Name   string `bson:"name" json:"name"`
+	Amount Money  `bson:"amount" json:"amount"`
+}
+
+func NewNightPrice(night string, rate Money, discountPercent float64, ratePlanID string) NightPrice {
+	discount := rate.Percent(discountPercent)
+	return NightPrice{
+		Night:      night,
+		Rate:       rate,
+		Discount:   discount,
+		Amount:     Money{Amount: rate.Amount - discount.Amount, Currency: rate.Currency},
+		RatePlanID: ratePlanID,
+	}
+}
+
+// NewPriceBreakdown totals the nights of a stay of guests and adds the
+// hotel taxes. Every amount must be in the hotel's currency.
+func NewPriceBreakdown(nights []NightPrice, discounts []AppliedDiscount, hotel *Hotel, guests Guests) (PriceBreakdown, error) {
+	var (
+		currency = hotel.PriceCurrency()
+		subtotal = NewMoney(0, currency)
+		err      error
+	)
+	for _, night := range nights {
+		if subtotal, err = subtotal.Add(night.Amount); err != nil {
+			return PriceBreakdown{}, err
+		}
+	}
+	taxLines, err := StayTaxes(hotel, subtotal, len(nights), guests)
+	if err != nil {
+		return PriceBreakdown{}, err
+	}
+	taxes := NewMoney(0, currency)
+	for _, line := range taxLines {
+		if taxes, err = taxes.Add(line.Amount); err != nil {
+			return PriceBreakdown{}, err
+		}
+	}
+	total, err := subtotal.Add(taxes)
+	if err != nil {
+		return PriceBreakdown{}, err
+	}
+	return PriceBreakdown{
+		NightlyRate: subtotal.Div(len(nights)),
+		Nights:      len(nights),
+		NightPrices: nights,
+		Discounts:   discounts,
+		Subtotal:    subtotal,
+		TaxLines:    taxLines,
+		Taxes:       taxes,
+		Total:       total,
+		Currency:    currency,
+	}, nil
+}
+
+// OrCurrency gives currency to the legacy float amounts of p.
+func (p PriceBreakdown) OrCurrency(currency string) PriceBreakdown {
+	if p.Currency != "" {
+		currency = p.Currency
+	}
+	p.NightlyRate = p.NightlyRate.OrCurrency(currency)
+	p.Subtotal = p.Subtotal.OrCurrency(currency)
+	p.Taxes = p.Taxes.OrCurrency(currency)
+	p.Total = p.Total.OrCurrency(currency)
+	p.Currency = currency
+	for i, night := range p.NightPrices {
+		p.NightPrices[i].Rate = night.Rate.OrCurrency(currency)
+		p.NightPrices[i].Discount = night.Discount.OrCurrency(currency)
+		p.NightPrices[i].Amount = night.Amount.OrCurrency(currency)
+	}
+	for i, line := range p.TaxLines {
+		p.TaxLines[i].Amount = line.Amount.OrCurrency(currency)
+	}
+	for i, discount := range p.Discounts {
+		p.Discounts[i].Amount = discount.Amount.OrCurrency(currency)
+	}
+	return p
+}
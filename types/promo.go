@@ -0,0 +1,220 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var promoCodeRegex = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+type PromoKind string
+
+const (
+	PromoPercent PromoKind = "percent"
+	PromoFixed   PromoKind = "fixed"
+)
+
+// Promo is a code guests give when quoting or booking to take Percent off
+// the nights of the stay, or a fixed Amount off the whole stay. It is valid
+// between ValidFrom and ValidTo, when set, and only at HotelIDs when any is
+// listed. MaxRedemptions and MaxPerUser limit how many bookings, in total
+// and per user, can use it; zero means no limit.
+type Promo struct {
+	ID             string    `bson:"_id,omitempty" json:"id,omitempty"`
+	Code           string    `bson:"code" json:"code"`
+	Kind           PromoKind `bson:"kind" json:"kind"`
+	Percent        float64   `bson:"percent,omitempty" json:"percent,omitempty"`
+	Amount         Money     `bson:"amount" json:"amount"`
+	ValidFrom      time.Time `bson:"validFrom,omitempty" json:"validFrom,omitempty"`
+	ValidTo        time.Time `bson:"validTo,omitempty" json:"validTo,omitempty"`
+	MaxRedemptions int       `bson:"maxRedemptions" json:"maxRedemptions"`
+	MaxPerUser     int       `bson:"maxPerUser" json:"maxPerUser"`
+	HotelIDs       []string  `bson:"hotelIDs,omitempty" json:"hotelIDs,omitempty"`
+	// Redemptions counts the bookings that used the code, UserRedemptions
+	// the ones of each user. They only change through the promo store.
+	Redemptions     int            `bson:"redemptions" json:"redemptions"`
+	UserRedemptions map[string]int `bson:"userRedemptions" json:"userRedemptions"`
+	CreatedAt       time.Time      `bson:"createdAt" json:"createdAt"`
+}
+
+// PromoRedemption records the use of a promo code by a booking.
+type PromoRedemption struct {
+	ID         string    `bson:"_id,omitempty" json:"id,omitempty"`
+	PromoID    string    `bson:"promoID" json:"promoID"`
+	Code       string    `bson:"code" json:"code"`
+	UserID     string    `bson:"userID" json:"userID"`
+	BookingID  string    `bson:"bookingID" json:"bookingID"`
+	Discount   Money     `bson:"discount" json:"discount"`
+	RedeemedAt time.Time `bson:"redeemedAt" json:"redeemedAt"`
+}
+
+type CreatePromoParams struct {
+	Code           string    `json:"code"`
+	Kind           PromoKind `json:"kind"`
+	Percent        float64   `json:"percent"`
+	Amount         Money     `json:"amount"`
+	ValidFrom      time.Time `json:"validFrom"`
+	ValidTo        time.Time `json:"validTo"`
+	MaxRedemptions int       `json:"maxRedemptions"`
+	MaxPerUser     int       `json:"maxPerUser"`
+	HotelIDs       []string  `json:"hotelIDs"`
+}
+
+func (p CreatePromoParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if !promoCodeRegex.MatchString(NormalizePromoCode(p.Code)) {
+		errors["code"] = "code should be 3 to 32 letters, digits, '-' or '_'"
+	}
+	switch p.Kind {
+	case PromoPercent:
+		if p.Percent <= 0 || p.Percent > maxPercent {
+			errors["percent"] = fmt.Sprintf("percent should be greater than 0 and at most %d", maxPercent)
+		}
+	case PromoFixed:
+		if p.Amount.Amount <= 0 {
+			errors["amount"] = "amount should be greater than 0"
+		}
+		if !isCurrencyValid(p.Amount.Currency) {
+			errors["amount"] = "amount should have an ISO 4217 currency"
+		}
+	default:
+		errors["kind"] = fmt.Sprintf("kind should be %s or %s", PromoPercent, PromoFixed)
+	}
+	if !p.ValidFrom.IsZero() && !p.ValidTo.IsZero() && !p.ValidTo.After(p.ValidFrom) {
+		errors["validTo"] = "validTo should be after validFrom"
+	}
+	if p.MaxRedemptions < 0 {
+		errors["maxRedemptions"] = "maxRedemptions should not be negative"
+	}
+	if p.MaxPerUser < 0 {
+		errors["maxPerUser"] = "maxPerUser should not be negative"
+	}
+	return errors
+}
+
+func NewPromoFromParams(params CreatePromoParams) *Promo {
+	promo := &Promo{
+		Code:            NormalizePromoCode(params.Code),
+		Kind:            params.Kind,
+		ValidFrom:       params.ValidFrom,
+		ValidTo:         params.ValidTo,
+		MaxRedemptions:  params.MaxRedemptions,
+		MaxPerUser:      params.MaxPerUser,
+		HotelIDs:        params.HotelIDs,
+		UserRedemptions: map[string]int{},
+		CreatedAt:       time.Now(),
+	}
+	if promo.Kind == PromoPercent {
+		promo.Percent = params.Percent
+	} else {
+		promo.Amount = params.Amount
+	}
+	return promo
+}
+
+// NormalizePromoCode returns code as stored, codes are case insensitive.
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// AppliesTo reports why the promo can not be used at hotelID at now, or nil
+// when it can.
+func (p *Promo) AppliesTo(hotelID string, now time.Time) error {
+	if !p.ValidFrom.IsZero() && now.Before(p.ValidFrom) {
+		return fmt.Errorf("promo code %s is not valid yet", p.Code)
+	}
+	if !p.ValidTo.IsZero() && !now.Before(p.ValidTo) {
+		return fmt.Errorf("promo code %s has expired", p.Code)
+	}
+	return p.AppliesToHotel(hotelID)
+}
+
+// AppliesToHotel reports why the promo can not be used at hotelID, or nil
+// when it can.
+func (p *Promo) AppliesToHotel(hotelID string) error {
+	if len(p.HotelIDs) == 0 {
+		return nil
+	}
+	for _, id := range p.HotelIDs {
+		if id == hotelID {
+			return nil
+		}
+	}
+	return fmt.Errorf("promo code %s is not valid at this hotel", p.Code)
+}
+
+// Available reports whether userID can still redeem the promo. Only the
+// promo store decides it for certain, redemptions may be racing.
+func (p *Promo) Available(userID string) bool {
+	if p.MaxRedemptions > 0 && p.Redemptions >= p.MaxRedemptions {
+		return false
+	}
+	return p.MaxPerUser == 0 || p.UserRedemptions[userID] < p.MaxPerUser
+}
+
+// Apply takes the promo off the nights of price, a stay of guests at hotel,
+// and prices the stay again so taxes follow the discounted nights. A fixed
+// amount is spread over the nights in proportion to their price and never
+// takes more than the nights cost.
+func (p *Promo) Apply(price PriceBreakdown, hotel *Hotel, guests Guests) (PriceBreakdown, error) {
+	currency := hotel.PriceCurrency()
+	price = price.OrCurrency(currency)
+	if p.Kind == PromoFixed && p.Amount.Currency != currency {
+		return PriceBreakdown{}, fmt.Errorf("promo code %s is in %s but the stay in %s", p.Code, p.Amount.Currency, currency)
+	}
+	nights := make([]NightPrice, len(price.NightPrices))
+	copy(nights, price.NightPrices)
+	var (
+		discount = NewMoney(0, currency)
+		left     = p.Amount.Amount
+		subtotal = price.Subtotal.Amount
+	)
+	if left > subtotal {
+		left = subtotal
+	}
+	for i, night := range nights {
+		var off Money
+		switch {
+		case p.Kind == PromoPercent:
+			off = night.Amount.Percent(p.Percent)
+		case i == len(nights)-1:
+			off = NewMoney(left, currency)
+		case subtotal > 0:
+			off = NewMoney(p.Amount.Amount, currency).Mul(float64(night.Amount.Amount) / float64(subtotal))
+			if off.Amount > left {
+				off.Amount = left
+			}
+		}
+		if off.Amount > night.Amount.Amount {
+			off.Amount = night.Amount.Amount
+		}
+		left -= off.Amount
+		nights[i].Discount.Amount += off.Amount
+		nights[i].Amount.Amount -= off.Amount
+		discount.Amount += off.Amount
+	}
+	discounts := append([]AppliedDiscount{}, price.Discounts...)
+	discounts = append(discounts, AppliedDiscount{Name: promoDiscountName(p.Code), Amount: discount})
+	discounted, err := NewPriceBreakdown(nights, discounts, hotel, guests)
+	if err != nil {
+		return PriceBreakdown{}, err
+	}
+	discounted.PromoCode = p.Code
+	return discounted, nil
+}
+
+// PromoDiscount returns what the promo code of price took off it.
+func (p PriceBreakdown) PromoDiscount() Money {
+	for _, discount := range p.Discounts {
+		if discount.Name == promoDiscountName(p.PromoCode) {
+			return discount.Amount
+		}
+	}
+	return NewMoney(0, p.Currency)
+}
+
+func promoDiscountName(code string) string {
+	return "Promo code " + code
+}
@@ -0,0 +1,18 @@
+package types
+
+import "time"
+
+// Quote is the price of a stay before booking it. Passing Token as the
+// quoteToken of the booking before ExpiresAt books the stay at Price, even
+// if rates change in between.
+type Quote struct {
+	RoomID    string         `json:"roomID"`
+	HotelID   string         `json:"hotelID"`
+	FromDate  time.Time      `json:"fromDate"`
+	ToDate    time.Time      `json:"toDate"`
+	Nights    []string       `json:"nights"`
+	Guests    Guests         `json:"guests"`
+	Price     PriceBreakdown `json:"price"`
+	Token     string         `json:"token"`
+	ExpiresAt time.Time      `json:"expiresAt"`
+}
@@ -0,0 +1,159 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	minRatePlanName = 3
+	maxPercent      = 100
+)
+
+var weekdays = map[string]bool{
+	"sunday":    true,
+	"monday":    true,
+	"tuesday":   true,
+	"wednesday": true,
+	"thursday":  true,
+	"friday":    true,
+	"saturday":  true,
+}
+
+// StayDiscount takes Percent off every night of stays of at least MinNights.
+type StayDiscount struct {
+	MinNights int     `bson:"minNights" json:"minNights"`
+	Percent   float64 `bson:"percent" json:"percent"`
+}
+
+// RatePlan prices the nights of a hotel, or of a single room when RoomID is
+// set, between FromDate and ToDate inclusive (YYYY-MM-DD, empty means
+// unbounded). When several plans apply to a night the highest Priority wins.
+type RatePlan struct {
+	ID       string `bson:"_id,omitempty" json:"id,omitempty"`
+	HotelID  string `bson:"hotelID" json:"hotelID"`
+	RoomID   string `bson:"roomID,omitempty" json:"roomID,omitempty"`
+	Name     string `bson:"name" json:"name"`
+	FromDate string `bson:"fromDate,omitempty" json:"fromDate,omitempty"`
+	ToDate   string `bson:"toDate,omitempty" json:"toDate,omitempty"`
+	// BaseRate replaces the room price when greater than zero.
+	BaseRate Money `bson:"baseRate" json:"baseRate"`
+	// WeekdayMultipliers scales the rate of nights starting on the given
+	// weekday, keyed by lowercase english day name.
+	WeekdayMultipliers map[string]float64 `bson:"weekdayMultipliers,omitempty" json:"weekdayMultipliers,omitempty"`
+	StayDiscounts      []StayDiscount     `bson:"stayDiscounts,omitempty" json:"stayDiscounts,omitempty"`
+	Priority           int                `bson:"priority" json:"priority"`
+	// NonRefundable nights are charged in full when the booking is cancelled.
+	NonRefundable bool      `bson:"nonRefundable" json:"nonRefundable"`
+	CreatedAt     time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+type CreateRatePlanParams struct {
+	RoomID             string             `json:"roomID"`
+	Name               string             `json:"name"`
+	FromDate           string             `json:"fromDate"`
+	ToDate             string             `json:"toDate"`
+	BaseRate           Money              `json:"baseRate"`
+	WeekdayMultipliers map[string]float64 `json:"weekdayMultipliers"`
+	StayDiscounts      []StayDiscount     `json:"stayDiscounts"`
+	Priority           int                `json:"priority"`
+	NonRefundable      bool               `json:"nonRefundable"`
+}
+
+func (p CreateRatePlanParams) Validate() map[string]string {
+	errors := map[string]string{}
+	if len(p.Name) < minRatePlanName {
+		errors["name"] = fmt.Sprintf("rate plan name should be at least %d characters", minRatePlanName)
+	}
+	if p.FromDate != "" {
+		if _, err := time.Parse(time.DateOnly, p.FromDate); err != nil {
+			errors["fromDate"] = "fromDate should be formatted as YYYY-MM-DD"
+		}
+	}
+	if p.ToDate != "" {
+		if _, err := time.Parse(time.DateOnly, p.ToDate); err != nil {
+			errors["toDate"] = "toDate should be formatted as YYYY-MM-DD"
+		}
+	}
+	if p.FromDate != "" && p.ToDate != "" && p.ToDate < p.FromDate {
+		errors["toDate"] = "toDate should not be before fromDate"
+	}
+	if p.BaseRate.Amount < 0 {
+		errors["baseRate"] = "baseRate should not be negative"
+	}
+	for day, multiplier := range p.WeekdayMultipliers {
+		if !weekdays[strings.ToLower(day)] {
+			errors["weekdayMultipliers"] = fmt.Sprintf("%s is not a weekday", day)
+		} else if multiplier <= 0 {
+			errors["weekdayMultipliers"] = fmt.Sprintf("%s multiplier should be greater than 0", day)
+		}
+	}
+	for _, discount := range p.StayDiscounts {
+		if discount.MinNights < 1 {
+			errors["stayDiscounts"] = "minNights should be at least 1"
+		}
+		if discount.Percent <= 0 || discount.Percent > maxPercent {
+			errors["stayDiscounts"] = fmt.Sprintf("percent should be greater than 0 and at most %d", maxPercent)
+		}
+	}
+	return errors
+}
+
+func NewRatePlanFromParams(params CreateRatePlanParams, hotelID string) *RatePlan {
+	multipliers := map[string]float64{}
+	for day, multiplier := range params.WeekdayMultipliers {
+		multipliers[strings.ToLower(day)] = multiplier
+	}
+	return &RatePlan{
+		HotelID:            hotelID,
+		RoomID:             params.RoomID,
+		Name:               params.Name,
+		FromDate:           params.FromDate,
+		ToDate:             params.ToDate,
+		BaseRate:           params.BaseRate,
+		WeekdayMultipliers: multipliers,
+		StayDiscounts:      params.StayDiscounts,
+		Priority:           params.Priority,
+		NonRefundable:      params.NonRefundable,
+		CreatedAt:          time.Now(),
+	}
+}
+
+// AppliesTo reports whether the plan prices the given night of roomID.
+func (p *RatePlan) AppliesTo(roomID, night string) bool {
+	if p.RoomID != "" && p.RoomID != roomID {
+		return false
+	}
+	if p.FromDate != "" && night < p.FromDate {
+		return false
+	}
+	if p.ToDate != "" && night > p.ToDate {
+		return false
+	}
+	return true
+}
+
+// Multiplier returns the weekday multiplier of the night, 1 when unset.
+func (p *RatePlan) Multiplier(night string) float64 {
+	day, err := time.Parse(time.DateOnly, night)
+	if err != nil {
+		return 1
+	}
+	if multiplier, ok := p.WeekdayMultipliers[strings.ToLower(day.Weekday().String())]; ok {
+		return multiplier
+	}
+	return 1
+}
+
+// StayDiscount returns the largest discount the plan grants to a stay of
+// the given number of nights, nil when there is none.
+func (p *RatePlan) StayDiscount(nights int) *StayDiscount {
+	var best *StayDiscount
+	for i, discount := range p.StayDiscounts {
+		if nights >= discount.MinNights && (best == nil || discount.Percent > best.Percent) {
+			best = &p.StayDiscounts[i]
+		}
+	}
+	return best
+}
@@ -0,0 +1,161 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Role is a set of permissions a user is granted, on specific hotels for
+// the staff roles.
+type Role string
+
+const (
+	// RoleAdmin administers the whole platform, with every permission on
+	// every hotel. It is the IsAdmin flag of the user.
+	RoleAdmin Role = "admin"
+	// RoleManager runs the hotels of the grant: rooms, rates, bookings,
+	// payments and folios.
+	RoleManager Role = "manager"
+	// RoleFrontDesk sees the bookings of the hotels of the grant, checks
+	// guests in and out and charges their folios.
+	RoleFrontDesk Role = "frontDesk"
+	// RoleGuest is the role of every user without other roles, who may only
+	// access their own bookings.
+	RoleGuest Role = "guest"
+)
+
+// Permission is an action on the resources of a hotel.
+type Permission string
+
+const (
+	PermManageHotel    Permission = "hotel:manage"
+	PermManageRooms    Permission = "rooms:manage"
+	PermManageRates    Permission = "rates:manage"
+	PermViewBookings   Permission = "bookings:view"
+	PermManageBookings Permission = "bookings:manage"
+	PermManagePayments Permission = "payments:manage"
+	PermManageFolios   Permission = "folios:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleManager: {
+		PermManageHotel,
+		PermManageRooms,
+		PermManageRates,
+		PermViewBookings,
+		PermManageBookings,
+		PermManagePayments,
+		PermManageFolios,
+	},
+	RoleFrontDesk: {
+		PermViewBookings,
+		PermManageBookings,
+		PermManageFolios,
+	},
+}
+
+// Permits reports whether the role has perm on the hotels it is granted on.
+func (r Role) Permits(perm Permission) bool {
+	return slices.Contains(rolePermissions[r], perm)
+}
+
+// RoleGrant gives Role on the hotels of HotelIDs.
+type RoleGrant struct {
+	Role     Role     `bson:"role" json:"role"`
+	HotelIDs []string `bson:"hotelIDs" json:"hotelIDs"`
+}
+
+// RoleParams grants or revokes Role, on the hotels of HotelIDs for the
+// staff roles. Revoking a staff role without HotelIDs revokes it on every
+// hotel.
+type RoleParams struct {
+	Role     Role     `json:"role"`
+	HotelIDs []string `json:"hotelIDs,omitempty"`
+}
+
+func (p RoleParams) Validate() map[string]string {
+	errors := map[string]string{}
+	switch p.Role {
+	case RoleAdmin:
+		if len(p.HotelIDs) > 0 {
+			errors["hotelIDs"] = "admin is granted on every hotel"
+		}
+	case RoleManager, RoleFrontDesk:
+		for _, hotelID := range p.HotelIDs {
+			if len(hotelID) == 0 {
+				errors["hotelIDs"] = "missing hotel id"
+			}
+		}
+	case RoleGuest:
+		errors["role"] = "every user is a guest"
+	default:
+		errors["role"] = fmt.Sprintf("role should be one of %s, %s or %s", RoleAdmin, RoleManager, RoleFrontDesk)
+	}
+	return errors
+}
+
+// Can reports whether the user has perm on the hotel of hotelID.
+func (u User) Can(perm Permission, hotelID string) bool {
+	if u.IsAdmin {
+		return true
+	}
+	for _, grant := range u.Roles {
+		if grant.Role.Permits(perm) && slices.Contains(grant.HotelIDs, hotelID) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccess reports whether the user owns booking or has perm on its hotel.
+func (u User) CanAccess(booking *Booking, perm Permission) bool {
+	return booking.UserID == u.ID || u.Can(perm, booking.HotelID)
+}
+
+// Grant adds the role of params to the user.
+func (u *User) Grant(params RoleParams) error {
+	if params.Role == RoleAdmin {
+		u.IsAdmin = true
+		return nil
+	}
+	if len(params.HotelIDs) == 0 {
+		return fmt.Errorf("%s is granted on hotels, hotelIDs is required", params.Role)
+	}
+	for i, grant := range u.Roles {
+		if grant.Role != params.Role {
+			continue
+		}
+		for _, hotelID := range params.HotelIDs {
+			if !slices.Contains(grant.HotelIDs, hotelID) {
+				u.Roles[i].HotelIDs = append(u.Roles[i].HotelIDs, hotelID)
+			}
+		}
+		return nil
+	}
+	u.Roles = append(u.Roles, RoleGrant{Role: params.Role, HotelIDs: slices.Clone(params.HotelIDs)})
+	return nil
+}
+
+// Revoke removes the role of params from the user.
+func (u *User) Revoke(params RoleParams) {
+	if params.Role == RoleAdmin {
+		u.IsAdmin = false
+		return
+	}
+	roles := []RoleGrant{}
+	for _, grant := range u.Roles {
+		if grant.Role == params.Role {
+			if len(params.HotelIDs) == 0 {
+				continue
+			}
+			grant.HotelIDs = slices.DeleteFunc(grant.HotelIDs, func(hotelID string) bool {
+				return slices.Contains(params.HotelIDs, hotelID)
+			})
+			if len(grant.HotelIDs) == 0 {
+				continue
+			}
+		}
+		roles = append(roles, grant)
+	}
+	u.Roles = roles
+}
@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 )
 
 type RoomSize int
@@ -14,23 +15,168 @@ const (
 	Extra
 )
 
+const maxBeds = 10
+
+type BedType string
+
+const (
+	BedSingle BedType = "single"
+	BedDouble BedType = "double"
+	BedQueen  BedType = "queen"
+	BedKing   BedType = "king"
+	BedSofa   BedType = "sofa"
+	BedCrib   BedType = "crib"
+)
+
+var bedTypes = []BedType{BedSingle, BedDouble, BedQueen, BedKing, BedSofa, BedCrib}
+
+// Bed is Count beds of the same Type.
+type Bed struct {
+	Type  BedType `bson:"type" json:"type"`
+	Count int     `bson:"count" json:"count"`
+}
+
+// Occupancy limits who can stay in a room: at most MaxAdults adults,
+// MaxChildren children and MaxTotal guests altogether.
+type Occupancy struct {
+	MaxAdults   int `bson:"maxAdults,omitempty" json:"maxAdults,omitempty"`
+	MaxChildren int `bson:"maxChildren,omitempty" json:"maxChildren,omitempty"`
+	MaxTotal    int `bson:"maxTotal,omitempty" json:"maxTotal,omitempty"`
+}
+
+// Room is priced at Price per night for up to IncludedGuests guests, adults
+// counted first. Every further adult adds ExtraAdult and every further
+// child ExtraChild to the night. Without IncludedGuests the price covers
+// every guest.
 type Room struct {
-	ID      string   `bson:"_id,omitempty" json:"id,omitempty"`
-	Size    RoomSize `bson:"size" json:"size"`
-	Price   float64  `bson:"price" json:"price"`
-	HotelID string   `bson:"hotelID" json:"hotelID"`
+	ID             string    `bson:"_id,omitempty" json:"id,omitempty"`
+	Size           RoomSize  `bson:"size" json:"size"`
+	Price          Money     `bson:"price" json:"price"`
+	HotelID        string    `bson:"hotelID" json:"hotelID"`
+	Occupancy      Occupancy `bson:"occupancy" json:"occupancy"`
+	Beds           []Bed     `bson:"beds,omitempty" json:"beds,omitempty"`
+	IncludedGuests int       `bson:"includedGuests,omitempty" json:"includedGuests,omitempty"`
+	ExtraAdult     Money     `bson:"extraAdult" json:"extraAdult"`
+	ExtraChild     Money     `bson:"extraChild" json:"extraChild"`
 }
+
+// CreateRoomParams takes the prices in the hotel's currency, a plain number
+// is read as major units. Occupancy limits left at 0 are taken from the
+// size of the room, see RoomSize.Occupancy.
 type CreateRoomParams struct {
-	Size  RoomSize `json:"size"`
-	Price float64  `json:"price"`
+	Size           RoomSize  `json:"size"`
+	Price          Money     `json:"price"`
+	Occupancy      Occupancy `json:"occupancy"`
+	Beds           []Bed     `json:"beds"`
+	IncludedGuests int       `json:"includedGuests"`
+	ExtraAdult     Money     `json:"extraAdult"`
+	ExtraChild     Money     `json:"extraChild"`
+}
+
+func (p CreateRoomParams) Validate() map[string]string {
+	errors := map[string]string{}
+	o := p.Occupancy
+	if o.MaxAdults < 0 || o.MaxChildren < 0 || o.MaxTotal < 0 ||
+		o.MaxAdults > maxGuests || o.MaxChildren > maxGuests || o.MaxTotal > maxGuests {
+		errors["occupancy"] = fmt.Sprintf("occupancy limits should be between 0 and %d", maxGuests)
+	} else if o.MaxTotal > 0 && o.MaxAdults > o.MaxTotal {
+		errors["occupancy"] = "maxAdults should not be above maxTotal"
+	}
+	if len(p.Beds) > maxBeds {
+		errors["beds"] = fmt.Sprintf("a room should have at most %d kinds of beds", maxBeds)
+	}
+	for _, bed := range p.Beds {
+		if !slices.Contains(bedTypes, bed.Type) {
+			errors["beds"] = fmt.Sprintf("bed type should be one of %v", bedTypes)
+		}
+		if bed.Count < 1 || bed.Count > maxGuests {
+			errors["beds"] = fmt.Sprintf("bed count should be between 1 and %d", maxGuests)
+		}
+	}
+	if p.IncludedGuests < 0 || p.IncludedGuests > maxGuests {
+		errors["includedGuests"] = fmt.Sprintf("includedGuests should be between 0 and %d", maxGuests)
+	}
+	if p.ExtraAdult.Amount < 0 || p.ExtraChild.Amount < 0 {
+		errors["extraGuests"] = "extra guest prices should not be negative"
+	}
+	if p.IncludedGuests == 0 && (p.ExtraAdult.Amount > 0 || p.ExtraChild.Amount > 0) {
+		errors["includedGuests"] = "includedGuests should be set to charge extra guests"
+	}
+	return errors
 }
 
 func NewRoomFromParams(params CreateRoomParams) *Room {
 	return &Room{
-		Size:  params.Size,
-		Price: params.Price,
+		Size:           params.Size,
+		Price:          params.Price,
+		Occupancy:      params.Occupancy,
+		Beds:           params.Beds,
+		IncludedGuests: params.IncludedGuests,
+		ExtraAdult:     params.ExtraAdult,
+		ExtraChild:     params.ExtraChild,
+	}
+}
+
+// Limits returns the occupancy of the room, taking the limits it does not
+// set from its size.
+func (r *Room) Limits() Occupancy {
+	limits, defaults := r.Occupancy, r.Size.Occupancy()
+	if limits.MaxAdults == 0 {
+		limits.MaxAdults = defaults.MaxAdults
+	}
+	if limits.MaxChildren == 0 {
+		limits.MaxChildren = defaults.MaxChildren
+	}
+	if limits.MaxTotal == 0 {
+		limits.MaxTotal = defaults.MaxTotal
 	}
+	return limits
 }
+
+// Admits fails when guests do not fit in the room.
+func (r *Room) Admits(guests Guests) error {
+	limits := r.Limits()
+	switch {
+	case guests.Adults > limits.MaxAdults:
+		return ErrOccupancyExceeded(fmt.Errorf("room %s admits at most %d adults", r.ID, limits.MaxAdults))
+	case len(guests.ChildAges) > limits.MaxChildren:
+		return ErrOccupancyExceeded(fmt.Errorf("room %s admits at most %d children", r.ID, limits.MaxChildren))
+	case guests.Adults+len(guests.ChildAges) > limits.MaxTotal:
+		return ErrOccupancyExceeded(fmt.Errorf("room %s admits at most %d guests", r.ID, limits.MaxTotal))
+	}
+	return nil
+}
+
+// ExtraGuests returns what guests beyond IncludedGuests add to the price of
+// a night, in currency.
+func (r *Room) ExtraGuests(guests Guests, currency string) Money {
+	extra := NewMoney(0, currency)
+	if r.IncludedGuests == 0 {
+		return extra
+	}
+	adults := max(guests.Adults-r.IncludedGuests, 0)
+	children := max(len(guests.ChildAges)-max(r.IncludedGuests-guests.Adults, 0), 0)
+	extra.Amount = int64(adults)*r.ExtraAdult.Amount + int64(children)*r.ExtraChild.Amount
+	return extra
+}
+
+// Occupancy returns the limits of rooms of size rs that do not set their
+// own. Rooms of unknown size are only limited by the guests of a booking.
+func (rs RoomSize) Occupancy() Occupancy {
+	switch rs {
+	case Small:
+		return Occupancy{MaxAdults: 2, MaxChildren: 1, MaxTotal: 2}
+	case Normal:
+		return Occupancy{MaxAdults: 2, MaxChildren: 2, MaxTotal: 3}
+	case Large:
+		return Occupancy{MaxAdults: 3, MaxChildren: 3, MaxTotal: 4}
+	case Extra:
+		return Occupancy{MaxAdults: 4, MaxChildren: 4, MaxTotal: 6}
+	default:
+		return Occupancy{MaxAdults: maxGuests, MaxChildren: maxGuests, MaxTotal: maxGuests}
+	}
+}
+
 func (rs RoomSize) String() string {
 	switch rs {
 	case Small:
@@ -57,17 +203,25 @@ func (rs *RoomSize) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &s); err != nil {
 		return err
 	}
+	size, err := ParseRoomSize(s)
+	if err != nil {
+		return err
+	}
+	*rs = size
+	return nil
+}
+
+func ParseRoomSize(s string) (RoomSize, error) {
 	switch s {
 	case "Small":
-		*rs = Small
+		return Small, nil
 	case "Normal":
-		*rs = Normal
+		return Normal, nil
 	case "Large":
-		*rs = Large
+		return Large, nil
 	case "Extra":
-		*rs = Extra
+		return Extra, nil
 	default:
-		return fmt.Errorf("invalid RoomSize: %s", s)
+		return 0, fmt.Errorf("invalid RoomSize: %s", s)
 	}
-	return nil
 }
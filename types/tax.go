@@ -0,0 +1,155 @@
+package types
+
+import "fmt"
+
+const (
+	maxGuests     = 20
+	maxChildAge   = 17
+	maxStayNights = 366
+)
+
+type TaxKind string
+
+const (
+	TaxVAT     TaxKind = "vat"
+	TaxTourist TaxKind = "tourist"
+)
+
+// TouristTax is the city tax a hotel charges of Amount per guest and
+// night. When MaxNights is set only the first MaxNights nights of a stay
+// are charged, and children younger than ExemptUnderAge are exempt.
+type TouristTax struct {
+	Amount         Money `bson:"amount" json:"amount"`
+	MaxNights      int   `bson:"maxNights,omitempty" json:"maxNights,omitempty"`
+	ExemptUnderAge int   `bson:"exemptUnderAge,omitempty" json:"exemptUnderAge,omitempty"`
+}
+
+func validateTouristTax(errors map[string]string, tax *TouristTax) {
+	if tax == nil {
+		return
+	}
+	if tax.Amount.Amount < 0 {
+		errors["touristTax"] = "tourist tax amount should not be negative"
+	}
+	if tax.Amount.Currency != "" && !isCurrencyValid(tax.Amount.Currency) {
+		errors["touristTax"] = fmt.Sprintf("currency %s should be an ISO 4217 code", tax.Amount.Currency)
+	}
+	if tax.MaxNights < 0 || tax.MaxNights > maxStayNights {
+		errors["touristTax"] = fmt.Sprintf("maxNights should be between 0 and %d", maxStayNights)
+	}
+	if tax.ExemptUnderAge < 0 || tax.ExemptUnderAge > maxChildAge+1 {
+		errors["touristTax"] = fmt.Sprintf("exemptUnderAge should be between 0 and %d", maxChildAge+1)
+	}
+}
+
+// validateTouristTaxCurrency checks the tourist tax of hotel, if it has a
+// currency, is in the currency the hotel prices stays in.
+func validateTouristTaxCurrency(errors map[string]string, hotel *Hotel) {
+	tax := hotel.TouristTax.Amount.Currency
+	if currency := hotel.PriceCurrency(); tax != "" && tax != currency {
+		errors["touristTax"] = fmt.Sprintf("tourist tax should be in the hotel currency %s", currency)
+	}
+}
+
+// Guests are who stay in a booking: Adults and the age of every child.
+// Bookings made before guests were recorded have none and count as a
+// single adult.
+type Guests struct {
+	Adults    int   `bson:"adults" json:"adults"`
+	ChildAges []int `bson:"childAges,omitempty" json:"childAges,omitempty"`
+}
+
+func (g Guests) Validate() error {
+	if g.Adults < 0 {
+		return fmt.Errorf("adults should not be negative")
+	}
+	if g.Adults == 0 && len(g.ChildAges) > 0 {
+		return fmt.Errorf("children should stay with at least one adult")
+	}
+	if g.Adults+len(g.ChildAges) > maxGuests {
+		return fmt.Errorf("a booking should have at most %d guests", maxGuests)
+	}
+	for _, age := range g.ChildAges {
+		if age < 0 || age > maxChildAge {
+			return fmt.Errorf("child ages should be between 0 and %d", maxChildAge)
+		}
+	}
+	return nil
+}
+
+// OrDefault returns a single adult for guests without adults.
+func (g Guests) OrDefault() Guests {
+	if g.Adults == 0 {
+		g.Adults = 1
+	}
+	return g
+}
+
+// Equal reports whether g and o are the same guests.
+func (g Guests) Equal(o Guests) bool {
+	g, o = g.OrDefault(), o.OrDefault()
+	if g.Adults != o.Adults || len(g.ChildAges) != len(o.ChildAges) {
+		return false
+	}
+	for i := range g.ChildAges {
+		if g.ChildAges[i] != o.ChildAges[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TaxLine is one of the taxes of a stay. VAT lines are Rate percent of
+// the nights, tourist tax lines PerNight for each of Guests during Nights.
+type TaxLine struct {
+	Kind     TaxKind `bson:"kind" json:"kind"`
+	Name     string  `bson:"name" json:"name"`
+	Rate     float64 `bson:"rate,omitempty" json:"rate,omitempty"`
+	PerNight *Money  `bson:"perNight,omitempty" json:"perNight,omitempty"`
+	Guests   int     `bson:"guests,omitempty" json:"guests,omitempty"`
+	Nights   int     `bson:"nights,omitempty" json:"nights,omitempty"`
+	Amount   Money   `bson:"amount" json:"amount"`
+}
+
+// StayTaxes applies the tax rules of hotel to a stay of nights whose rooms
+// cost subtotal. Taxes that come to nothing are left out.
+func StayTaxes(hotel *Hotel, subtotal Money, nights int, guests Guests) ([]TaxLine, error) {
+	lines := []TaxLine{}
+	if vat := subtotal.Percent(hotel.TaxRate); !vat.IsZero() {
+		lines = append(lines, TaxLine{
+			Kind:   TaxVAT,
+			Name:   fmt.Sprintf("VAT %g%%", hotel.TaxRate),
+			Rate:   hotel.TaxRate,
+			Amount: vat,
+		})
+	}
+	tax := hotel.TouristTax
+	if tax.Amount.IsZero() {
+		return lines, nil
+	}
+	perNight := tax.Amount.OrCurrency(subtotal.Currency)
+	if perNight.Currency != subtotal.Currency {
+		return nil, fmt.Errorf("tourist tax is in %s but the stay in %s", perNight.Currency, subtotal.Currency)
+	}
+	if tax.MaxNights > 0 && nights > tax.MaxNights {
+		nights = tax.MaxNights
+	}
+	guests = guests.OrDefault()
+	taxed := guests.Adults
+	for _, age := range guests.ChildAges {
+		if age >= tax.ExemptUnderAge {
+			taxed++
+		}
+	}
+	if taxed == 0 || nights == 0 {
+		return lines, nil
+	}
+	return append(lines, TaxLine{
+		Kind:     TaxTourist,
+		Name:     "Tourist tax",
+		PerNight: &perNight,
+		Guests:   taxed,
+		Nights:   nights,
+		Amount:   perNight.Mul(float64(taxed * nights)),
+	}), nil
+}
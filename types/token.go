@@ -0,0 +1,116 @@
+package types
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// AccessToken identifies a verified access token: its ID, the jti claim,
+// its user and when it expires.
+type AccessToken struct {
+	ID        string
+	UserID    string
+	ExpiresAt time.Time
+}
+
+// RevokedToken denies the access token of ID until it expires anyway.
+type RevokedToken struct {
+	ID        string    `bson:"_id" json:"id"`
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+// RefreshToken is the server side record of a refresh token, only the
+// SHA-256 Hash of the token given to the client is kept. Each refresh uses
+// the token and issues a new one of the same Family, so a token works only
+// once; a used token presented again revokes its whole family, as either
+// the client or an attacker holds a stolen copy.
+type RefreshToken struct {
+	ID        string    `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    string    `bson:"userID" json:"userID"`
+	Family    string    `bson:"family" json:"family"`
+	Hash      string    `bson:"hash" json:"-"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+	UsedAt    time.Time `bson:"usedAt,omitempty" json:"usedAt,omitempty"`
+	RevokedAt time.Time `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// RefreshParams carries the refresh token to rotate or, on logout, to
+// revoke.
+type RefreshParams struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func (p RefreshParams) Validate() error {
+	if len(p.RefreshToken) == 0 {
+		return fmt.Errorf("refreshToken is required")
+	}
+	return nil
+}
+
+// NewRefreshToken returns the record of a new refresh token of userID,
+// valid for ttl, and the token to give to the client. An empty family
+// starts a new one, as a login does.
+func NewRefreshToken(userID, family string, ttl time.Duration) (*RefreshToken, string, error) {
+	token, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	if family == "" {
+		if family, err = NewTokenID(); err != nil {
+			return nil, "", err
+		}
+	}
+	now := time.Now()
+	return &RefreshToken{
+		UserID:    userID,
+		Family:    family,
+		Hash:      HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, token, nil
+}
+
+// newSecret returns a random token to give to a client, of which only the
+// hash is stored.
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashToken returns the hash a refresh or password reset token is stored
+// and looked up by.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewTokenID returns a random ID for an access token or a refresh token
+// family.
+func NewTokenID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Usable fails unless the token can still be exchanged at now.
+func (t *RefreshToken) Usable(now time.Time) error {
+	switch {
+	case !t.RevokedAt.IsZero():
+		return fmt.Errorf("refresh token revoked")
+	case !t.UsedAt.IsZero():
+		return fmt.Errorf("refresh token already used")
+	case !now.Before(t.ExpiresAt):
+		return fmt.Errorf("refresh token expired")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package types
 import (
 	"fmt"
 	"regexp"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -77,10 +78,17 @@ type User struct {
 	Email            string `bson:"email" json:"email"`
 	EncyptedPassword string `bson:"password" json:"-"`
 	IsAdmin          bool   `bson:"isAdmin" json:"isAdmin"`
+	// EmailVerified is set once the user follows the verification link
+	// mailed to Email, users can not book until then.
+	EmailVerified bool `bson:"emailVerified" json:"emailVerified"`
+	// Roles are the staff roles of the user, on top of guest.
+	Roles []RoleGrant `bson:"roles,omitempty" json:"roles,omitempty"`
+	// PasswordChangedAt voids the sessions started before it.
+	PasswordChangedAt time.Time `bson:"passwordChangedAt,omitempty" json:"-"`
 }
 
 func NewUserFromParams(params CreateUserParams) (*User, error) {
-	encpw, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcryptCost)
+	encpw, err := EncryptPassword(params.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -88,7 +96,7 @@ func NewUserFromParams(params CreateUserParams) (*User, error) {
 		Firstname:        params.Firstname,
 		Lastname:         params.Lastname,
 		Email:            params.Email,
-		EncyptedPassword: string(encpw),
+		EncyptedPassword: encpw,
 	}, nil
 }
 